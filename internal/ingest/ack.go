@@ -0,0 +1,41 @@
+package ingest
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// AckMode is how events were acknowledged by a collector.
+type AckMode int32
+
+const (
+	// AckDelivered means Receive returned once events were delivered downstream:
+	// a successful Receive confirms the delivery.
+	AckDelivered AckMode = iota
+
+	// AckEnqueued means Receive returned once events were enqueued (eg. in a memory buffer or a producer queue):
+	// events may still be lost, downstream failures are not reported to the sender.
+	AckEnqueued
+)
+
+type ackContextKey struct{}
+
+// WithAck returns a context tracking how collectors acknowledge the events received with it,
+// and a function returning the acknowledgement mode in effect (AckDelivered unless a collector called MarkEnqueued).
+//
+// A single context may be used for several events (eg. a batch): events are AckEnqueued if any of them was.
+func WithAck(ctx context.Context) (context.Context, func() AckMode) {
+	var mode atomic.Int32
+
+	return context.WithValue(ctx, ackContextKey{}, &mode), func() AckMode {
+		return AckMode(mode.Load())
+	}
+}
+
+// MarkEnqueued records that the event received with the context was enqueued, but not delivered yet.
+// Collectors acknowledging events before delivering them call it before returning successfully.
+func MarkEnqueued(ctx context.Context) {
+	if mode, ok := ctx.Value(ackContextKey{}).(*atomic.Int32); ok {
+		mode.Store(int32(AckEnqueued))
+	}
+}
@@ -0,0 +1,30 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAck(t *testing.T) {
+	// Collectors may be used without tracking acknowledgements
+	MarkEnqueued(context.Background())
+
+	ctx, ack := WithAck(context.Background())
+	assert.Equal(t, AckDelivered, ack())
+
+	buffered, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: collectorFunc(func(context.Context, event.Event) error { return nil }),
+	})
+	require.NoError(t, err)
+
+	defer func() {
+		_ = buffered.Close(context.Background())
+	}()
+
+	require.NoError(t, buffered.Receive(ctx, newEvent("1")))
+	assert.Equal(t, AckEnqueued, ack())
+}
@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+)
+
+// Outcomes of forwarding audited events (see AuditCollectorConfig.AfterForward).
+const (
+	AuditOutcomeForwarded = "forwarded"
+	AuditOutcomeFailed    = "failed"
+)
+
+// AuditRecord is the minimal audit trail of an ingested event.
+type AuditRecord struct {
+	ID         string    `json:"id"`
+	Source     string    `json:"source"`
+	Type       string    `json:"type"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	Tenant     string    `json:"tenant,omitempty"`
+
+	// Outcome is the outcome of forwarding the event, when recorded after forwarding it.
+	Outcome string `json:"outcome,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditSink durably stores audit records.
+type AuditSink interface {
+	WriteAuditRecord(ctx context.Context, record AuditRecord) error
+}
+
+// AuditCollectorConfig configures an AuditCollector.
+type AuditCollectorConfig struct {
+	// Collector receives events after they are audited.
+	Collector Collector
+
+	// Sink stores the audit records.
+	Sink AuditSink
+
+	// Required rejects events that cannot be audited.
+	// Otherwise auditing is best-effort: failures are logged and the event is forwarded anyway.
+	Required bool
+
+	// AfterForward writes records once events are forwarded, with the outcome (see AuditRecord.Outcome).
+	// Otherwise records are written before forwarding events, so required audits reject events before they are forwarded.
+	//
+	// With AfterForward, events whose record cannot be written fail if the audit is required although they were forwarded:
+	// producers retrying them send duplicates.
+	AfterForward bool
+
+	// Flag restricts auditing to the events with the flag (eg. FlagAudit, see FlagsFromContext).
+	// Every event is audited when empty.
+	Flag string
+
+	// Tenant resolves the tenant of the request an event is received in (optional).
+	Tenant func(ctx context.Context) string
+
+	Logger *slog.Logger
+}
+
+// AuditCollector writes an audit record of every event (or of flagged events) to an {AuditSink}
+// before or after forwarding it to a downstream {Collector}.
+type AuditCollector struct {
+	collector    Collector
+	sink         AuditSink
+	required     bool
+	afterForward bool
+	flag         string
+	tenant       func(ctx context.Context) string
+	logger       *slog.Logger
+	now          func() time.Time
+}
+
+// NewAuditCollector returns a new AuditCollector.
+func NewAuditCollector(config AuditCollectorConfig) (*AuditCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Sink == nil {
+		return nil, errors.New("audit sink is required")
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &AuditCollector{
+		collector:    config.Collector,
+		sink:         config.Sink,
+		required:     config.Required,
+		afterForward: config.AfterForward,
+		flag:         config.Flag,
+		tenant:       config.Tenant,
+		logger:       logger,
+		now:          time.Now,
+	}, nil
+}
+
+func (c *AuditCollector) Receive(ctx context.Context, ev event.Event) error {
+	if c.flag != "" && !FlagsFromContext(ctx).Has(c.flag) {
+		return c.collector.Receive(ctx, ev)
+	}
+
+	record := AuditRecord{
+		ID:         ev.ID(),
+		Source:     ev.Source(),
+		Type:       ev.Type(),
+		ReceivedAt: c.now().UTC(),
+	}
+
+	if c.tenant != nil {
+		record.Tenant = c.tenant(ctx)
+	}
+
+	if !c.afterForward {
+		if err := c.write(ctx, record); err != nil {
+			return err
+		}
+
+		return c.collector.Receive(ctx, ev)
+	}
+
+	err := c.collector.Receive(ctx, ev)
+
+	record.Outcome = AuditOutcomeForwarded
+	if err != nil {
+		record.Outcome = AuditOutcomeFailed
+		record.Error = err.Error()
+	}
+
+	if werr := c.write(ctx, record); werr != nil && err == nil {
+		return werr
+	}
+
+	return err
+}
+
+// write writes the record, returning an error only when the audit is required.
+func (c *AuditCollector) write(ctx context.Context, record AuditRecord) error {
+	if err := c.sink.WriteAuditRecord(ctx, record); err != nil {
+		if c.required {
+			return fmt.Errorf("audit event: %w", err)
+		}
+
+		c.logger.ErrorCtx(ctx, "unable to write audit record", slog.String("event_id", record.ID), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// WriterAuditSink writes audit records to an {io.Writer} as newline delimited JSON.
+type WriterAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterAuditSink returns a new WriterAuditSink.
+func NewWriterAuditSink(w io.Writer) *WriterAuditSink {
+	return &WriterAuditSink{
+		w: w,
+	}
+}
+
+func (s *WriterAuditSink) WriteAuditRecord(_ context.Context, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+
+	return err
+}
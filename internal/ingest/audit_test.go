@@ -0,0 +1,191 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditSinkFunc func(ctx context.Context, record AuditRecord) error
+
+func (f auditSinkFunc) WriteAuditRecord(ctx context.Context, record AuditRecord) error {
+	return f(ctx, record)
+}
+
+func TestAuditCollector(t *testing.T) {
+	var records []AuditRecord
+	var forwarded []string
+
+	collector, err := NewAuditCollector(AuditCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Sink: auditSinkFunc(func(_ context.Context, record AuditRecord) error {
+			records = append(records, record)
+
+			return nil
+		}),
+		Tenant: func(ctx context.Context) string {
+			return "tenant-1"
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	ev := newEvent("1")
+	ev.SetType("api-calls")
+
+	require.NoError(t, collector.Receive(context.Background(), ev))
+
+	assert.Equal(t, []string{"1"}, forwarded)
+	assert.Equal(t, []AuditRecord{
+		{ID: "1", Source: "test", Type: "api-calls", ReceivedAt: now, Tenant: "tenant-1"},
+	}, records)
+}
+
+func TestAuditCollector_Flag(t *testing.T) {
+	var audited, forwarded []string
+
+	collector, err := NewAuditCollector(AuditCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Sink: auditSinkFunc(func(_ context.Context, record AuditRecord) error {
+			audited = append(audited, record.ID)
+
+			return nil
+		}),
+		Flag: FlagAudit,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Receive(ContextWithFlags(ctx, ParseFlags("beta,audit")), newEvent("2")))
+
+	assert.Equal(t, []string{"1", "2"}, forwarded)
+	assert.Equal(t, []string{"2"}, audited)
+}
+
+func TestAuditCollector_SinkFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		required  bool
+		forwarded int
+	}{
+		{name: "BestEffort", forwarded: 1},
+		{name: "Required", required: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded int
+
+			collector, err := NewAuditCollector(AuditCollectorConfig{
+				Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+					forwarded++
+
+					return nil
+				}),
+				Sink: auditSinkFunc(func(_ context.Context, record AuditRecord) error {
+					return errors.New("audit log unavailable")
+				}),
+				Required: tt.required,
+			})
+			require.NoError(t, err)
+
+			err = collector.Receive(context.Background(), newEvent("1"))
+			if tt.required {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.forwarded, forwarded)
+		})
+	}
+}
+
+func TestAuditCollector_AfterForward(t *testing.T) {
+	var records []AuditRecord
+
+	collector, err := NewAuditCollector(AuditCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			if ev.ID() == "2" {
+				return errors.New("downstream failure")
+			}
+
+			return nil
+		}),
+		Sink: auditSinkFunc(func(_ context.Context, record AuditRecord) error {
+			records = append(records, record)
+
+			return nil
+		}),
+		AfterForward: true,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+	require.Error(t, collector.Receive(context.Background(), newEvent("2")))
+
+	assert.Equal(t, []AuditRecord{
+		{ID: "1", Source: "test", ReceivedAt: now, Outcome: AuditOutcomeForwarded},
+		{ID: "2", Source: "test", ReceivedAt: now, Outcome: AuditOutcomeFailed, Error: "downstream failure"},
+	}, records)
+
+	t.Run("Required", func(t *testing.T) {
+		var forwarded int
+
+		collector, err := NewAuditCollector(AuditCollectorConfig{
+			Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+				forwarded++
+
+				return nil
+			}),
+			Sink: auditSinkFunc(func(_ context.Context, record AuditRecord) error {
+				return errors.New("audit log unavailable")
+			}),
+			Required:     true,
+			AfterForward: true,
+		})
+		require.NoError(t, err)
+
+		assert.Error(t, collector.Receive(context.Background(), newEvent("1")))
+		assert.Equal(t, 1, forwarded)
+	})
+}
+
+func TestWriterAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+
+	sink := NewWriterAuditSink(&buf)
+
+	err := sink.WriteAuditRecord(context.Background(), AuditRecord{
+		ID:         "1",
+		Source:     "test",
+		Type:       "api-calls",
+		ReceivedAt: time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"id":"1","source":"test","type":"api-calls","receivedAt":"2023-06-15T14:33:00Z"}`+"\n", buf.String())
+}
@@ -0,0 +1,537 @@
+// Package badgeringest queues events durably in an embedded BadgerDB database, for store and forward on edge deployments.
+package badgeringest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/dgraph-io/badger/v4"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const (
+	defaultDrainBatchSize = 100
+	defaultRetryInterval  = time.Second
+	defaultGCInterval     = 5 * time.Minute
+
+	// sequenceBandwidth is the number of sequence numbers leased at once (see badger.Sequence)
+	sequenceBandwidth = 1000
+
+	gcDiscardRatio = 0.5
+)
+
+var (
+	eventPrefix = []byte("e/")
+	sequenceKey = []byte("s")
+)
+
+// errUnconfirmedDelivery is returned when the collector acknowledges a queued event before delivering it.
+var errUnconfirmedDelivery = errors.New("collector does not confirm delivery (ingest.AckEnqueued), the event is kept queued")
+
+// CollectorConfig configures a Collector.
+type CollectorConfig struct {
+	// Collector is the collector queued events are forwarded to.
+	// It must confirm the delivery of events (ingest.AckDelivered, eg. kafkaingest.Collector with ConfirmDelivery).
+	Collector ingest.Collector
+
+	// Directory is the directory of the database. It is created if it does not exist.
+	Directory string
+
+	// MaxSize is the maximum total size (in bytes) of queued events (optional).
+	// Events are rejected with ingest.ErrBufferFull when the limit would be exceeded.
+	// Files on disk are larger, until space of forwarded events is reclaimed (see GCInterval).
+	MaxSize int64
+
+	// SyncWrites syncs the database to disk before acknowledging every event, so they survive machine crashes.
+	// Events survive process crashes otherwise, and are written faster.
+	SyncWrites bool
+
+	// DrainBatchSize is the number of events read from the queue at once. Defaults to 100.
+	DrainBatchSize int
+
+	// RetryInterval is the delay before forwarding events again after the collector fails. Defaults to 1s.
+	RetryInterval time.Duration
+
+	// GCInterval is how often disk space of forwarded events is reclaimed. Defaults to 5m.
+	GCInterval time.Duration
+
+	Logger *slog.Logger
+
+	// Name identifies the collector in queue metrics (required with Metrics).
+	Name string
+
+	// Metrics records the number of queued events and the events rejected because the queue is full (optional).
+	Metrics *ingest.QueueMetrics
+}
+
+// Collector queues events in a BadgerDB database and forwards them to a {Collector} in the background,
+// in the order they were received.
+//
+// Events are acknowledged once they are written to the database (AckEnqueued) and deleted once the collector confirms their delivery:
+// delivery is at least once, events are forwarded again if the process stops after forwarding them but before deleting them.
+// Events the collector only enqueued (AckEnqueued) are kept queued and forwarded again, as they may still be lost.
+// Events left in the database (eg. while the collector is down) are forwarded after a restart.
+//
+// The collector is retried until it accepts events, except events rejected because of their content (ingest.ErrInvalidEvent),
+// which are dropped as forwarding them again cannot succeed. A single process may open a database at a time.
+type Collector struct {
+	collector      ingest.Collector
+	db             *badger.DB
+	sequence       *badger.Sequence
+	maxSize        int64
+	drainBatchSize int
+	retryInterval  time.Duration
+	gcInterval     time.Duration
+	logger         *slog.Logger
+	name           string
+	metrics        *ingest.QueueMetrics
+
+	mu     sync.Mutex
+	depth  int
+	size   int64
+	closed bool
+
+	// writes tracks events being written, so the database is closed after them
+	writes sync.WaitGroup
+
+	// queued is signaled when events are queued
+	queued chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var _ ingest.Collector = (*Collector)(nil)
+
+// NewCollector opens (or creates) the database and starts forwarding queued events in the background.
+func NewCollector(config CollectorConfig) (*Collector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Directory == "" {
+		return nil, errors.New("directory is required")
+	}
+
+	if config.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid max size: %d", config.MaxSize)
+	}
+
+	if config.DrainBatchSize < 0 {
+		return nil, fmt.Errorf("invalid drain batch size: %d", config.DrainBatchSize)
+	}
+
+	if config.RetryInterval < 0 {
+		return nil, fmt.Errorf("invalid retry interval: %s", config.RetryInterval)
+	}
+
+	if config.GCInterval < 0 {
+		return nil, fmt.Errorf("invalid gc interval: %s", config.GCInterval)
+	}
+
+	drainBatchSize := config.DrainBatchSize
+	if drainBatchSize == 0 {
+		drainBatchSize = defaultDrainBatchSize
+	}
+
+	retryInterval := config.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultRetryInterval
+	}
+
+	gcInterval := config.GCInterval
+	if gcInterval == 0 {
+		gcInterval = defaultGCInterval
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	options := badger.DefaultOptions(config.Directory).
+		WithSyncWrites(config.SyncWrites).
+		WithLogger(badgerLogger{logger})
+
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, fmt.Errorf("open badger database: %w", err)
+	}
+
+	sequence, err := db.GetSequence(sequenceKey, sequenceBandwidth)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("init badger sequence: %w", err)
+	}
+
+	c := &Collector{
+		collector:      config.Collector,
+		db:             db,
+		sequence:       sequence,
+		maxSize:        config.MaxSize,
+		drainBatchSize: drainBatchSize,
+		retryInterval:  retryInterval,
+		gcInterval:     gcInterval,
+		logger:         logger,
+		name:           config.Name,
+		metrics:        config.Metrics,
+		queued:         make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	if err := c.count(); err != nil {
+		_ = sequence.Release()
+		_ = db.Close()
+
+		return nil, err
+	}
+
+	if err := c.metrics.Register(c.name, c.Depth); err != nil {
+		_ = sequence.Release()
+		_ = db.Close()
+
+		return nil, err
+	}
+
+	if c.depth > 0 {
+		logger.Info("replaying queued events", slog.Int("events", c.depth))
+	}
+
+	go c.run()
+
+	return c, nil
+}
+
+// count counts the events left in the database.
+func (c *Collector) count() error {
+	return c.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: eventPrefix})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			c.depth++
+			c.size += it.Item().ValueSize()
+		}
+
+		return nil
+	})
+}
+
+// Receive writes the event to the database.
+func (c *Collector) Receive(ctx context.Context, ev event.Event) error {
+	value, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ingest.ErrInvalidEvent, err)
+	}
+
+	size := int64(len(value))
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+
+		return ingest.ErrCollectorClosed
+	}
+
+	if c.maxSize > 0 && c.size+size > c.maxSize {
+		c.mu.Unlock()
+
+		c.metrics.RecordDrop(c.name)
+
+		return ingest.ErrBufferFull
+	}
+
+	// Space is reserved before writing, so concurrent events cannot exceed the limit
+	c.depth++
+	c.size += size
+	c.writes.Add(1)
+	c.mu.Unlock()
+
+	defer c.writes.Done()
+
+	if err := c.write(value); err != nil {
+		c.mu.Lock()
+		c.depth--
+		c.size -= size
+		c.mu.Unlock()
+
+		return err
+	}
+
+	select {
+	case c.queued <- struct{}{}:
+	default:
+	}
+
+	ingest.MarkEnqueued(ctx)
+
+	return nil
+}
+
+func (c *Collector) write(value []byte) error {
+	seq, err := c.sequence.Next()
+	if err != nil {
+		return fmt.Errorf("queue event: %w", err)
+	}
+
+	key := make([]byte, len(eventPrefix)+8)
+	copy(key, eventPrefix)
+	binary.BigEndian.PutUint64(key[len(eventPrefix):], seq)
+
+	if err := c.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	}); err != nil {
+		return fmt.Errorf("queue event: %w", err)
+	}
+
+	return nil
+}
+
+// Depth returns the number of queued events.
+func (c *Collector) Depth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.depth
+}
+
+func (c *Collector) run() {
+	defer close(c.done)
+
+	gc := time.NewTicker(c.gcInterval)
+	defer gc.Stop()
+
+	for {
+		drained, err := c.drain()
+		if errors.Is(err, ingest.ErrCollectorClosed) {
+			return
+		}
+
+		if err != nil {
+			c.logger.Error("unable to forward queued events", slog.Any("error", err))
+
+			select {
+			case <-time.After(c.retryInterval):
+			case <-c.stop:
+				return
+			}
+
+			continue
+		}
+
+		// More events may be queued
+		if drained == c.drainBatchSize {
+			continue
+		}
+
+		select {
+		case <-c.queued:
+		case <-gc.C:
+			c.collectGarbage()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+type queuedEvent struct {
+	key   []byte
+	value []byte
+}
+
+// drain forwards the oldest queued events, up to DrainBatchSize, and deletes the forwarded ones.
+// It returns the number of events read from the queue, and the error of the collector if it failed to forward one.
+func (c *Collector) drain() (int, error) {
+	var events []queuedEvent
+
+	err := c.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			PrefetchValues: true,
+			PrefetchSize:   c.drainBatchSize,
+			Prefix:         eventPrefix,
+		})
+		defer it.Close()
+
+		for it.Rewind(); it.Valid() && len(events) < c.drainBatchSize; it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, queuedEvent{key: it.Item().KeyCopy(nil), value: value})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read queued events: %w", err)
+	}
+
+	var (
+		forwarded []queuedEvent
+		ferr      error
+	)
+
+	for _, queued := range events {
+		select {
+		case <-c.stop:
+			ferr = ingest.ErrCollectorClosed
+		default:
+			ferr = c.forward(queued)
+		}
+
+		if ferr != nil {
+			break
+		}
+
+		forwarded = append(forwarded, queued)
+	}
+
+	if err := c.delete(forwarded); err != nil {
+		return 0, err
+	}
+
+	if ferr != nil {
+		return 0, ferr
+	}
+
+	return len(events), nil
+}
+
+// forward forwards a queued event to the collector. Events that can never be forwarded are dropped.
+func (c *Collector) forward(queued queuedEvent) error {
+	var ev event.Event
+
+	if err := json.Unmarshal(queued.value, &ev); err != nil {
+		c.logger.Error("dropping queued event that cannot be decoded", slog.Any("error", err))
+
+		return nil
+	}
+
+	ctx, ack := ingest.WithAck(context.Background())
+
+	err := c.collector.Receive(ctx, ev)
+	if errors.Is(err, ingest.ErrInvalidEvent) {
+		c.logger.Error("dropping queued event rejected by the collector", slog.String("event_id", ev.ID()), slog.Any("error", err))
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if ack() == ingest.AckEnqueued {
+		return errUnconfirmedDelivery
+	}
+
+	return nil
+}
+
+// delete deletes forwarded events from the database.
+func (c *Collector) delete(events []queuedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var size int64
+
+	err := c.db.Update(func(txn *badger.Txn) error {
+		for _, queued := range events {
+			if err := txn.Delete(queued.key); err != nil {
+				return err
+			}
+
+			size += int64(len(queued.value))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete forwarded events: %w", err)
+	}
+
+	c.mu.Lock()
+	c.depth -= len(events)
+	c.size -= size
+	c.mu.Unlock()
+
+	return nil
+}
+
+// collectGarbage reclaims disk space of forwarded events.
+func (c *Collector) collectGarbage() {
+	for {
+		err := c.db.RunValueLogGC(gcDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return
+		}
+
+		if err != nil {
+			c.logger.Error("unable to reclaim badger disk space", slog.Any("error", err))
+
+			return
+		}
+	}
+}
+
+// Close stops accepting and forwarding events, and closes the database.
+// Queued events are left in the database: they are forwarded the next time it is opened.
+func (c *Collector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+
+		return nil
+	}
+
+	c.closed = true
+	close(c.stop)
+	c.mu.Unlock()
+
+	stopped := make(chan struct{})
+
+	go func() {
+		<-c.done
+		c.writes.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return errors.Join(c.sequence.Release(), c.db.Close())
+}
+
+// badgerLogger logs badger messages with slog.
+type badgerLogger struct {
+	logger *slog.Logger
+}
+
+func (l badgerLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Error(fmt.Sprintf(format, args...))
+}
+
+func (l badgerLogger) Warningf(format string, args ...interface{}) {
+	l.logger.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l badgerLogger) Infof(format string, args ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l badgerLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
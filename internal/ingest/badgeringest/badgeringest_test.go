@@ -0,0 +1,200 @@
+package badgeringest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func newEvent(id string) event.Event {
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetSource("test")
+	ev.SetType("api-calls")
+	ev.SetSubject("sub")
+
+	return ev
+}
+
+func newTestCollector(t *testing.T, config CollectorConfig) *Collector {
+	t.Helper()
+
+	if config.Directory == "" {
+		config.Directory = t.TempDir()
+	}
+
+	if config.RetryInterval == 0 {
+		config.RetryInterval = 10 * time.Millisecond
+	}
+
+	collector, err := NewCollector(config)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, collector.Close(context.Background()))
+	})
+
+	return collector
+}
+
+func TestCollector(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	collector := newTestCollector(t, CollectorConfig{Collector: downstream})
+
+	ctx, ack := ingest.WithAck(context.Background())
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, collector.Receive(ctx, newEvent(id)))
+	}
+
+	assert.Equal(t, ingest.AckEnqueued, ack())
+
+	assert.Eventually(t, func() bool { return downstream.Len() == 3 }, 5*time.Second, 10*time.Millisecond)
+	testcollector.AssertReceived(t, downstream, "1", "2", "3")
+
+	assert.Eventually(t, func() bool { return collector.Depth() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCollector_Replay(t *testing.T) {
+	directory := t.TempDir()
+
+	collector, err := NewCollector(CollectorConfig{
+		Collector:     &testcollector.Collector{Err: errors.New("downstream failure")},
+		Directory:     directory,
+		RetryInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+	require.NoError(t, collector.Receive(context.Background(), newEvent("2")))
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	downstream := &testcollector.Collector{}
+	collector = newTestCollector(t, CollectorConfig{Collector: downstream, Directory: directory})
+
+	assert.Eventually(t, func() bool { return downstream.Len() == 2 }, 5*time.Second, 10*time.Millisecond)
+	testcollector.AssertReceived(t, downstream, "1", "2")
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("3")))
+
+	assert.Eventually(t, func() bool { return downstream.Len() == 3 }, 5*time.Second, 10*time.Millisecond)
+	testcollector.AssertReceived(t, downstream, "1", "2", "3")
+}
+
+func TestCollector_Retry(t *testing.T) {
+	failures := 2
+
+	downstream := &testcollector.Collector{}
+	downstream.ErrFunc = func(ev event.Event) error {
+		if failures > 0 {
+			failures--
+
+			return errors.New("downstream failure")
+		}
+
+		return nil
+	}
+
+	collector := newTestCollector(t, CollectorConfig{Collector: downstream})
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+
+	assert.Eventually(t, func() bool { return downstream.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+	testcollector.AssertReceived(t, downstream, "1")
+}
+
+// enqueuingCollector acknowledges events without confirming their delivery.
+type enqueuingCollector struct {
+	*testcollector.Collector
+}
+
+func (c enqueuingCollector) Receive(ctx context.Context, ev event.Event) error {
+	ingest.MarkEnqueued(ctx)
+
+	return c.Collector.Receive(ctx, ev)
+}
+
+func TestCollector_UnconfirmedDelivery(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	collector := newTestCollector(t, CollectorConfig{Collector: enqueuingCollector{downstream}})
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+
+	// Events are forwarded again, as they may still be lost
+	assert.Eventually(t, func() bool { return downstream.Len() >= 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, collector.Depth())
+}
+
+func TestCollector_DropInvalid(t *testing.T) {
+	downstream := &testcollector.Collector{
+		ErrFunc: func(ev event.Event) error {
+			if ev.ID() == "1" {
+				return ingest.ErrInvalidEvent
+			}
+
+			return nil
+		},
+	}
+
+	collector := newTestCollector(t, CollectorConfig{Collector: downstream})
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+	require.NoError(t, collector.Receive(context.Background(), newEvent("2")))
+
+	assert.Eventually(t, func() bool { return collector.Depth() == 0 }, 5*time.Second, 10*time.Millisecond)
+	testcollector.AssertReceived(t, downstream, "2")
+}
+
+func TestCollector_MaxSize(t *testing.T) {
+	value, err := json.Marshal(newEvent("1"))
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+
+	metrics, err := ingest.NewQueueMetrics(registry)
+	require.NoError(t, err)
+
+	collector := newTestCollector(t, CollectorConfig{
+		Collector:     &testcollector.Collector{Err: errors.New("downstream failure")},
+		MaxSize:       int64(len(value)) + 1,
+		RetryInterval: time.Hour,
+		Name:          "badger",
+		Metrics:       metrics,
+	})
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+
+	err = collector.Receive(context.Background(), newEvent("2"))
+	assert.ErrorIs(t, err, ingest.ErrBufferFull)
+
+	assert.Equal(t, 1, collector.Depth())
+
+	count, err := testutil.GatherAndCount(registry, "openmeter_ingest_queue_depth", "openmeter_ingest_queue_dropped_events_total")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestCollector_Closed(t *testing.T) {
+	collector, err := NewCollector(CollectorConfig{
+		Collector: &testcollector.Collector{},
+		Directory: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	err = collector.Receive(context.Background(), newEvent("1"))
+	assert.ErrorIs(t, err, ingest.ErrCollectorClosed)
+}
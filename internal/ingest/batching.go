@@ -0,0 +1,276 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+)
+
+// BatchReceiver is a receiver of event batches (eg. a broker producer).
+type BatchReceiver interface {
+	ReceiveBatch(ctx context.Context, events []event.Event) error
+}
+
+const (
+	defaultFlushInterval       = time.Second
+	defaultMaxForwardChunkSize = 500
+)
+
+// BatchingCollectorConfig configures a BatchingCollector.
+type BatchingCollectorConfig struct {
+	// Receiver receives the accumulated events.
+	Receiver BatchReceiver
+
+	// FlushInterval is how often accumulated events are forwarded. Defaults to 1s.
+	FlushInterval time.Duration
+
+	// FlushSize forwards accumulated events as soon as there are at least this many, without waiting for the interval (optional).
+	FlushSize int
+
+	// MaxForwardChunkSize is the maximum number of events forwarded in a single batch. Defaults to 500.
+	// Accumulated events are split into chunks of this size, keeping produce requests within downstream size limits.
+	MaxForwardChunkSize int
+
+	// MaxPending is the maximum number of accumulated events, including the ones of chunks waiting to be retried (optional).
+	// Events are rejected with ErrBufferFull once it is reached, eg. while the receiver keeps failing.
+	MaxPending int
+
+	Logger *slog.Logger
+
+	// Name identifies the collector in queue metrics (required with Metrics).
+	Name string
+
+	// Metrics records the number of accumulated events (optional).
+	Metrics *QueueMetrics
+}
+
+// ChunkError is returned when a chunk of accumulated events cannot be forwarded.
+type ChunkError struct {
+	// Offset is the position of the first event of the chunk among the flushed events.
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("forwarding events %d-%d: %s", e.Offset, e.Offset+e.Size-1, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BatchingCollector accumulates events and forwards them to a {BatchReceiver} periodically, in chunks.
+//
+// Events are acknowledged once they are accumulated (AckEnqueued): downstream failures are logged, but not reported to the sender.
+// Chunks are forwarded independently: the failure of a chunk does not prevent forwarding the others.
+// Chunks that cannot be forwarded are accumulated again and retried at the next flush,
+// except chunks rejected because of their content (ErrInvalidEvent), which are dropped as forwarding them again cannot succeed.
+type BatchingCollector struct {
+	receiver      BatchReceiver
+	flushInterval time.Duration
+	chunkSize     int
+	flushSize     int
+	maxPending    int
+	logger        *slog.Logger
+	name          string
+	metrics       *QueueMetrics
+
+	mu      sync.Mutex
+	pending []event.Event
+	closed  bool
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBatchingCollector returns a new BatchingCollector and starts forwarding events in the background.
+func NewBatchingCollector(config BatchingCollectorConfig) (*BatchingCollector, error) {
+	if config.Receiver == nil {
+		return nil, errors.New("receiver is required")
+	}
+
+	if config.FlushInterval < 0 {
+		return nil, fmt.Errorf("invalid flush interval: %s", config.FlushInterval)
+	}
+
+	if config.MaxForwardChunkSize < 0 {
+		return nil, fmt.Errorf("invalid max forward chunk size: %d", config.MaxForwardChunkSize)
+	}
+
+	if config.FlushSize < 0 {
+		return nil, fmt.Errorf("invalid flush size: %d", config.FlushSize)
+	}
+
+	if config.MaxPending < 0 {
+		return nil, fmt.Errorf("invalid max pending: %d", config.MaxPending)
+	}
+
+	flushInterval := config.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	chunkSize := config.MaxForwardChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultMaxForwardChunkSize
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &BatchingCollector{
+		receiver:      config.Receiver,
+		flushInterval: flushInterval,
+		chunkSize:     chunkSize,
+		flushSize:     config.FlushSize,
+		maxPending:    config.MaxPending,
+		logger:        logger,
+		name:          config.Name,
+		metrics:       config.Metrics,
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	if err := config.Metrics.Register(config.Name, c.depth); err != nil {
+		return nil, err
+	}
+
+	go c.run()
+
+	return c, nil
+}
+
+func (c *BatchingCollector) Receive(ctx context.Context, ev event.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCollectorClosed
+	}
+
+	if c.maxPending > 0 && len(c.pending) >= c.maxPending {
+		c.metrics.RecordDrop(c.name)
+
+		return ErrBufferFull
+	}
+
+	c.pending = append(c.pending, ev)
+
+	if c.flushSize > 0 && len(c.pending) >= c.flushSize {
+		// A flush is already requested when the channel is full
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+
+	MarkEnqueued(ctx)
+
+	return nil
+}
+
+// depth returns the number of accumulated events.
+func (c *BatchingCollector) depth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.pending)
+}
+
+func (c *BatchingCollector) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+
+		case <-c.full:
+			c.flush()
+
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *BatchingCollector) flush() {
+	if _, err := c.Flush(context.Background()); err != nil {
+		c.logger.Error("unable to forward accumulated events", slog.Any("error", err))
+	}
+}
+
+// Flush forwards accumulated events in chunks of at most MaxForwardChunkSize events and returns the number of forwarded events.
+// The returned error joins a ChunkError for every chunk that could not be forwarded.
+// Events of chunks that could not be forwarded are accumulated again, before the events received since.
+func (c *BatchingCollector) Flush(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	events := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	var (
+		errs      []error
+		forwarded int
+		failed    []event.Event
+	)
+
+	for offset := 0; offset < len(events); offset += c.chunkSize {
+		end := offset + c.chunkSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		if err := c.receiver.ReceiveBatch(ctx, events[offset:end]); err != nil {
+			errs = append(errs, &ChunkError{Offset: offset, Size: end - offset, Err: err})
+
+			if !errors.Is(err, ErrInvalidEvent) {
+				failed = append(failed, events[offset:end]...)
+			}
+
+			continue
+		}
+
+		forwarded += end - offset
+	}
+
+	if len(failed) > 0 {
+		c.mu.Lock()
+		c.pending = append(failed, c.pending...)
+		c.mu.Unlock()
+	}
+
+	return forwarded, errors.Join(errs...)
+}
+
+// Close stops accepting events and forwards the accumulated ones. Events that cannot be forwarded then are lost.
+func (c *BatchingCollector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	_, err := c.Flush(ctx)
+
+	return err
+}
@@ -0,0 +1,208 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchReceiverFunc func(ctx context.Context, events []event.Event) error
+
+func (f batchReceiverFunc) ReceiveBatch(ctx context.Context, events []event.Event) error {
+	return f(ctx, events)
+}
+
+func TestBatchingCollector_Chunks(t *testing.T) {
+	var sizes []int
+
+	failures := 1
+
+	collector, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			sizes = append(sizes, len(events))
+
+			// The second chunk fails once
+			if events[0].ID() == "3" && failures > 0 {
+				failures--
+
+				return errors.New("downstream failure")
+			}
+
+			return nil
+		}),
+		FlushInterval:       time.Hour,
+		MaxForwardChunkSize: 3,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		require.NoError(t, collector.Receive(ctx, newEvent(strconv.Itoa(i))))
+	}
+
+	forwarded, err := collector.Flush(ctx)
+	require.Error(t, err)
+
+	assert.Equal(t, 5, forwarded)
+	assert.Equal(t, []int{3, 3, 2}, sizes)
+
+	var chunkErr *ChunkError
+	require.ErrorAs(t, err, &chunkErr)
+
+	assert.Equal(t, 3, chunkErr.Offset)
+	assert.Equal(t, 3, chunkErr.Size)
+	assert.EqualError(t, err, "forwarding events 3-5: downstream failure")
+
+	// The failed chunk is retried
+	assert.Equal(t, 3, collector.depth())
+
+	forwarded, err = collector.Flush(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, forwarded)
+	assert.Equal(t, []int{3, 3, 2, 3}, sizes)
+
+	require.NoError(t, collector.Close(ctx))
+}
+
+func TestBatchingCollector_MaxPending(t *testing.T) {
+	collector, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			return errors.New("downstream failure")
+		}),
+		FlushInterval: time.Hour,
+		MaxPending:    2,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("3")), ErrBufferFull)
+
+	_, err = collector.Flush(ctx)
+	require.Error(t, err)
+
+	assert.Equal(t, 2, collector.depth())
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("3")), ErrBufferFull)
+
+	assert.Error(t, collector.Close(ctx))
+
+	// Chunks rejected because of their content are dropped
+	invalid, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			return fmt.Errorf("%w: message too large", ErrInvalidEvent)
+		}),
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, invalid.Receive(ctx, newEvent("1")))
+
+	_, err = invalid.Flush(ctx)
+	require.ErrorIs(t, err, ErrInvalidEvent)
+
+	assert.Equal(t, 0, invalid.depth())
+	require.NoError(t, invalid.Close(ctx))
+}
+
+func TestBatchingCollector_Interval(t *testing.T) {
+	var mu sync.Mutex
+	var received []event.Event
+
+	collector, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			received = append(received, events...)
+
+			return nil
+		}),
+		FlushInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(received) == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, collector.Close(context.Background()))
+}
+
+func TestBatchingCollector_FlushSize(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+
+	collector, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			sizes = append(sizes, len(events))
+
+			return nil
+		}),
+		FlushInterval: time.Hour,
+		FlushSize:     3,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, collector.Receive(ctx, newEvent(strconv.Itoa(i))))
+	}
+
+	assert.Equal(t, 2, collector.depth())
+
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(sizes) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, []int{3}, sizes)
+
+	require.NoError(t, collector.Close(ctx))
+}
+
+func TestBatchingCollector_Close(t *testing.T) {
+	var received []event.Event
+
+	collector, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(_ context.Context, events []event.Event) error {
+			received = append(received, events...)
+
+			return nil
+		}),
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Close(ctx))
+
+	assert.Len(t, received, 1)
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("2")), ErrCollectorClosed)
+}
@@ -0,0 +1,285 @@
+// Package blobingest archives events to object stores (eg. S3 or GCS buckets).
+package blobingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/google/uuid"
+	"gocloud.dev/blob"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const (
+	// DefaultNamespace is the key segment of events without a namespace.
+	DefaultNamespace = "default"
+
+	defaultNamespaceExtension = "namespace"
+	defaultRetries            = 3
+	defaultBackoff            = time.Second
+)
+
+// ReceiverConfig configures a Receiver.
+type ReceiverConfig struct {
+	// Bucket is the bucket objects are written to (see gocloud.dev/blob for the supported stores).
+	Bucket *blob.Bucket
+
+	// Prefix is prepended to the keys of objects (optional), eg. events/.
+	Prefix string
+
+	// NamespaceExtension is the extension events declare their namespace in. Defaults to namespace.
+	NamespaceExtension string
+
+	// Retries is the number of times a failed upload is retried. Defaults to 3, a negative value disables retries.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubled after every attempt. Defaults to 1s.
+	Backoff time.Duration
+
+	Logger *slog.Logger
+}
+
+// Receiver writes batches of events to a bucket as gzip compressed NDJSON objects (one object per namespace).
+//
+// Objects are keyed by namespace and the time they are written:
+// {prefix}{namespace}/{yyyy}/{mm}/{dd}/{hh}/{yyyymmddThhmmssZ}-{uuid}.ndjson.gz
+//
+// Namespaces are declared by clients: every byte but letters, digits, - and _ is percent-encoded
+// (eg. a/b becomes a%2Fb), so a namespace is always a single key segment.
+//
+// Receiver is an ingest.BatchReceiver: use it with an ingest.BatchingCollector (see NewCollector)
+// to buffer events and write them on size and time thresholds, rather than one object per event.
+type Receiver struct {
+	bucket             *blob.Bucket
+	prefix             string
+	namespaceExtension string
+	retries            int
+	backoff            time.Duration
+	logger             *slog.Logger
+	now                func() time.Time
+}
+
+var _ ingest.BatchReceiver = (*Receiver)(nil)
+
+// NewReceiver returns a new Receiver.
+func NewReceiver(config ReceiverConfig) (*Receiver, error) {
+	if config.Bucket == nil {
+		return nil, errors.New("bucket is required")
+	}
+
+	if config.NamespaceExtension != "" && !event.IsExtensionNameValid(config.NamespaceExtension) {
+		return nil, errors.New("invalid namespace extension name")
+	}
+
+	if config.Backoff < 0 {
+		return nil, fmt.Errorf("invalid backoff: %s", config.Backoff)
+	}
+
+	namespaceExtension := config.NamespaceExtension
+	if namespaceExtension == "" {
+		namespaceExtension = defaultNamespaceExtension
+	}
+
+	retries := config.Retries
+	switch {
+	case retries == 0:
+		retries = defaultRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	backoff := config.Backoff
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Receiver{
+		bucket:             config.Bucket,
+		prefix:             config.Prefix,
+		namespaceExtension: namespaceExtension,
+		retries:            retries,
+		backoff:            backoff,
+		logger:             logger,
+		now:                time.Now,
+	}, nil
+}
+
+// ReceiveBatch writes the events to an object per namespace.
+// The returned error joins the errors of the namespaces whose object could not be written after retries.
+func (r *Receiver) ReceiveBatch(ctx context.Context, events []event.Event) error {
+	// Namespaces are written in the order they appear in
+	var namespaces []string
+	byNamespace := make(map[string][]event.Event)
+
+	for _, ev := range events {
+		namespace := r.namespace(ev)
+
+		if _, ok := byNamespace[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+
+		byNamespace[namespace] = append(byNamespace[namespace], ev)
+	}
+
+	now := r.now().UTC()
+
+	var errs []error
+
+	for _, namespace := range namespaces {
+		body, err := encode(byNamespace[namespace])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("encode events of namespace %s: %w", namespace, err))
+
+			continue
+		}
+
+		key := r.key(namespace, now)
+
+		if err := r.write(ctx, key, body); err != nil {
+			errs = append(errs, fmt.Errorf("write events of namespace %s to %s: %w", namespace, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Receiver) namespace(ev event.Event) string {
+	value, ok := ev.Extensions()[r.namespaceExtension]
+	if !ok {
+		return DefaultNamespace
+	}
+
+	namespace, err := types.ToString(value)
+	if err != nil || namespace == "" {
+		return DefaultNamespace
+	}
+
+	return namespace
+}
+
+func (r *Receiver) key(namespace string, t time.Time) string {
+	return fmt.Sprintf("%s%s/%s/%s-%s.ndjson.gz", r.prefix, escapeNamespace(namespace), t.Format("2006/01/02/15"), t.Format("20060102T150405Z"), uuid.NewString())
+}
+
+// escapeNamespace percent-encodes every byte of the namespace but letters, digits, - and _.
+func escapeNamespace(namespace string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(namespace); i++ {
+		c := namespace[i]
+
+		if c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+			b.WriteByte(c)
+
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// write uploads the object, retrying failed uploads with exponential backoff.
+func (r *Receiver) write(ctx context.Context, key string, body []byte) error {
+	opts := &blob.WriterOptions{
+		ContentType:     "application/x-ndjson",
+		ContentEncoding: "gzip",
+	}
+
+	backoff := r.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := r.bucket.WriteAll(ctx, key, body, opts)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= r.retries {
+			return err
+		}
+
+		r.logger.WarnCtx(ctx, "unable to write events to bucket, retrying", "key", key, "attempt", attempt+1, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (%s)", err, ctx.Err())
+		}
+
+		backoff *= 2
+	}
+}
+
+// encode returns the events as gzip compressed NDJSON.
+func encode(events []event.Event) ([]byte, error) {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+
+	for _, ev := range events {
+		if err := encoder.Encode(ev); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CollectorConfig configures a collector archiving events to a bucket.
+type CollectorConfig struct {
+	ReceiverConfig
+
+	// FlushInterval is how often buffered events are written. Defaults to 1s.
+	FlushInterval time.Duration
+
+	// FlushSize writes buffered events as soon as there are this many (optional).
+	FlushSize int
+
+	// MaxObjectSize is the maximum number of events written to a single object. Defaults to 500.
+	MaxObjectSize int
+
+	// MaxPending is the maximum number of buffered events (optional), events are rejected with ingest.ErrBufferFull once it is reached.
+	MaxPending int
+}
+
+// NewCollector returns an ingest.BatchingCollector buffering events and writing them to the bucket
+// periodically, when FlushSize events are buffered, and when it is closed.
+//
+// Events are acknowledged once they are buffered: events that cannot be written after retries are kept buffered
+// and written at the next flush, unless the collector is closed.
+func NewCollector(config CollectorConfig) (*ingest.BatchingCollector, error) {
+	receiver, err := NewReceiver(config.ReceiverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return ingest.NewBatchingCollector(ingest.BatchingCollectorConfig{
+		Receiver:            receiver,
+		FlushInterval:       config.FlushInterval,
+		FlushSize:           config.FlushSize,
+		MaxForwardChunkSize: config.MaxObjectSize,
+		MaxPending:          config.MaxPending,
+		Logger:              config.Logger,
+	})
+}
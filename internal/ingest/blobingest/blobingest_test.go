@@ -0,0 +1,225 @@
+package blobingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/memblob"
+	"golang.org/x/exp/slog"
+)
+
+func newTestEvent(id string, namespace string) event.Event {
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetType("api-calls")
+	ev.SetSource("test")
+
+	if namespace != "" {
+		ev.SetExtension("namespace", namespace)
+	}
+
+	return ev
+}
+
+// readObjects returns the IDs of the events of every object of the bucket by key.
+func readObjects(t *testing.T, bucket *blob.Bucket) map[string][]string {
+	t.Helper()
+
+	ctx := context.Background()
+	objects := make(map[string][]string)
+
+	iter := bucket.List(nil)
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := bucket.ReadAll(ctx, obj.Key)
+		require.NoError(t, err)
+
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var ev event.Event
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+
+			objects[obj.Key] = append(objects[obj.Key], ev.ID())
+		}
+		require.NoError(t, scanner.Err())
+	}
+
+	return objects
+}
+
+func TestReceiver(t *testing.T) {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	receiver, err := NewReceiver(ReceiverConfig{
+		Bucket: bucket,
+		Prefix: "events/",
+	})
+	require.NoError(t, err)
+
+	receiver.now = func() time.Time {
+		return time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC)
+	}
+
+	err = receiver.ReceiveBatch(context.Background(), []event.Event{
+		newTestEvent("1", "acme"),
+		newTestEvent("2", ""),
+		newTestEvent("3", "acme"),
+	})
+	require.NoError(t, err)
+
+	objects := readObjects(t, bucket)
+	require.Len(t, objects, 2)
+
+	byNamespace := make(map[string][]string)
+	for key, ids := range objects {
+		assert.True(t, strings.HasSuffix(key, ".ndjson.gz"), key)
+
+		namespace, rest, _ := strings.Cut(strings.TrimPrefix(key, "events/"), "/")
+		assert.True(t, strings.HasPrefix(rest, "2023/07/01/12/20230701T123000Z-"), key)
+
+		byNamespace[namespace] = ids
+	}
+
+	assert.Equal(t, map[string][]string{
+		"acme":           {"1", "3"},
+		DefaultNamespace: {"2"},
+	}, byNamespace)
+
+	attrs, err := bucket.Attributes(context.Background(), func() string {
+		for key := range objects {
+			return key
+		}
+
+		return ""
+	}())
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/x-ndjson", attrs.ContentType)
+	assert.Equal(t, "gzip", attrs.ContentEncoding)
+}
+
+func TestReceiver_NamespaceEscaping(t *testing.T) {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	receiver, err := NewReceiver(ReceiverConfig{
+		Bucket: bucket,
+		Prefix: "events/",
+	})
+	require.NoError(t, err)
+
+	err = receiver.ReceiveBatch(context.Background(), []event.Event{
+		newTestEvent("1", "a/b"),
+		newTestEvent("2", "../x"),
+		newTestEvent("3", "a//b"),
+		newTestEvent("4", "a%2Fb"),
+	})
+	require.NoError(t, err)
+
+	byNamespace := make(map[string][]string)
+	for key, ids := range readObjects(t, bucket) {
+		namespace, _, _ := strings.Cut(strings.TrimPrefix(key, "events/"), "/")
+
+		byNamespace[namespace] = ids
+	}
+
+	assert.Equal(t, map[string][]string{
+		"a%2Fb":      {"1"},
+		"%2E%2E%2Fx": {"2"},
+		"a%2F%2Fb":   {"3"},
+		"a%252Fb":    {"4"},
+	}, byNamespace)
+}
+
+func TestReceiver_Retries(t *testing.T) {
+	bucket := memblob.OpenBucket(nil)
+
+	// Writes to a closed bucket fail
+	require.NoError(t, bucket.Close())
+
+	var logs bytes.Buffer
+
+	receiver, err := NewReceiver(ReceiverConfig{
+		Bucket:  bucket,
+		Retries: 2,
+		Backoff: time.Millisecond,
+		Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+
+	err = receiver.ReceiveBatch(context.Background(), []event.Event{newTestEvent("1", "acme")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "write events of namespace acme")
+
+	// Two retries after the first attempt
+	assert.Equal(t, 2, strings.Count(logs.String(), "retrying"))
+
+	t.Run("Disabled", func(t *testing.T) {
+		logs.Reset()
+
+		receiver, err := NewReceiver(ReceiverConfig{
+			Bucket:  bucket,
+			Retries: -1,
+			Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+		})
+		require.NoError(t, err)
+
+		err = receiver.ReceiveBatch(context.Background(), []event.Event{newTestEvent("1", "acme")})
+		require.Error(t, err)
+
+		assert.Zero(t, strings.Count(logs.String(), "retrying"))
+	})
+}
+
+func TestNewCollector(t *testing.T) {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+
+	collector, err := NewCollector(CollectorConfig{
+		ReceiverConfig: ReceiverConfig{
+			Bucket: bucket,
+		},
+		FlushInterval: time.Hour,
+		MaxObjectSize: 2,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, collector.Receive(ctx, newTestEvent(strconv.Itoa(i), "acme")))
+	}
+
+	assert.Empty(t, readObjects(t, bucket))
+
+	// Buffered events are written when the collector is closed
+	require.NoError(t, collector.Close(ctx))
+
+	var sizes []int
+	for _, ids := range readObjects(t, bucket) {
+		sizes = append(sizes, len(ids))
+	}
+
+	assert.ElementsMatch(t, []int{2, 2, 1}, sizes)
+}
@@ -0,0 +1,196 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+)
+
+// ErrBufferFull is returned by a buffering collector when it cannot accept more events.
+var ErrBufferFull = errors.New("collector buffer is full")
+
+// ErrCollectorClosed is returned when an event is sent to a collector that has been closed.
+var ErrCollectorClosed = errors.New("collector is closed")
+
+const defaultBufferSize = 1000
+
+// BufferedCollectorConfig configures a BufferedCollector.
+type BufferedCollectorConfig struct {
+	// Collector receives events from the buffer.
+	Collector Collector
+
+	// Size is the number of events the buffer can hold. Defaults to 1000.
+	Size int
+
+	// Block makes Receive wait for capacity when the buffer is full instead of failing immediately.
+	// Waiting stops when the context of the request is done or after MaxBlock.
+	Block bool
+
+	// MaxBlock is the maximum duration Receive waits for capacity when Block is enabled.
+	// Zero means waiting until the context of the request is done.
+	MaxBlock time.Duration
+
+	Logger *slog.Logger
+
+	// Name identifies the collector in queue metrics (required with Metrics).
+	Name string
+
+	// Metrics records the depth of the buffer and the events dropped or blocked (optional).
+	Metrics *QueueMetrics
+}
+
+// BufferedCollector accepts events into an in-memory buffer and forwards them to a downstream {Collector} in the background.
+//
+// Events are acknowledged once they are in the buffer (AckEnqueued): downstream failures are logged, but not reported to the sender.
+type BufferedCollector struct {
+	collector Collector
+	buffer    chan event.Event
+	block     bool
+	maxBlock  time.Duration
+	logger    *slog.Logger
+	name      string
+	metrics   *QueueMetrics
+
+	mu     sync.RWMutex
+	closed bool
+
+	// closing is closed by Close to release blocked senders, senders tracks them so the buffer is closed after they return
+	closing chan struct{}
+	senders sync.WaitGroup
+
+	done chan struct{}
+}
+
+// NewBufferedCollector returns a new BufferedCollector and starts forwarding events in the background.
+func NewBufferedCollector(config BufferedCollectorConfig) (*BufferedCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Size < 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", config.Size)
+	}
+
+	size := config.Size
+	if size == 0 {
+		size = defaultBufferSize
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &BufferedCollector{
+		collector: config.Collector,
+		buffer:    make(chan event.Event, size),
+		block:     config.Block,
+		maxBlock:  config.MaxBlock,
+		logger:    logger,
+		name:      config.Name,
+		metrics:   config.Metrics,
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if err := c.metrics.Register(c.name, func() int { return len(c.buffer) }); err != nil {
+		return nil, err
+	}
+
+	go c.forward()
+
+	return c, nil
+}
+
+func (c *BufferedCollector) Receive(ctx context.Context, ev event.Event) error {
+	// The lock is not held while waiting for capacity, so Close is not blocked by waiting senders
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+
+		return ErrCollectorClosed
+	}
+
+	c.senders.Add(1)
+	c.mu.RUnlock()
+
+	defer c.senders.Done()
+
+	select {
+	case c.buffer <- ev:
+		MarkEnqueued(ctx)
+
+		return nil
+	default:
+	}
+
+	if !c.block {
+		c.metrics.RecordDrop(c.name)
+
+		return ErrBufferFull
+	}
+
+	c.metrics.RecordBlock(c.name)
+
+	if c.maxBlock > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.maxBlock)
+		defer cancel()
+	}
+
+	select {
+	case c.buffer <- ev:
+		MarkEnqueued(ctx)
+
+		return nil
+	case <-ctx.Done():
+		c.metrics.RecordDrop(c.name)
+
+		return fmt.Errorf("%w: %s", ErrBufferFull, ctx.Err())
+	case <-c.closing:
+		c.metrics.RecordDrop(c.name)
+
+		return ErrCollectorClosed
+	}
+}
+
+func (c *BufferedCollector) forward() {
+	defer close(c.done)
+
+	for ev := range c.buffer {
+		err := c.collector.Receive(context.Background(), ev)
+		if err != nil {
+			c.logger.Error("unable to forward buffered event", slog.String("event_id", ev.ID()), slog.Any("error", err))
+		}
+	}
+}
+
+// Close stops accepting events and waits until buffered events are forwarded or the context is done.
+// Senders waiting for capacity are rejected with ErrCollectorClosed.
+func (c *BufferedCollector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	closing := !c.closed
+	c.closed = true
+	c.mu.Unlock()
+
+	if closing {
+		close(c.closing)
+
+		// Senders return promptly once closing is closed, the buffer must not be closed before they do
+		c.senders.Wait()
+		close(c.buffer)
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingCollector blocks every event until it is released.
+type blockingCollector struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (c *blockingCollector) Receive(ctx context.Context, ev event.Event) error {
+	<-c.release
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, ev)
+
+	return nil
+}
+
+func newEvent(id string) event.Event {
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetSource("test")
+
+	return ev
+}
+
+// fill fills the buffer of the collector, including the event held by the forwarder.
+func fill(t *testing.T, collector *BufferedCollector) {
+	t.Helper()
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("forwarding")))
+
+	require.Eventually(t, func() bool {
+		return len(collector.buffer) == 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("buffered")))
+}
+
+func TestBufferedCollector_Full(t *testing.T) {
+	downstream := &blockingCollector{release: make(chan struct{})}
+
+	collector, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: downstream,
+		Size:      1,
+	})
+	require.NoError(t, err)
+
+	fill(t, collector)
+
+	err = collector.Receive(context.Background(), newEvent("rejected"))
+	assert.ErrorIs(t, err, ErrBufferFull)
+
+	close(downstream.release)
+	require.NoError(t, collector.Close(context.Background()))
+
+	assert.Len(t, downstream.events, 2)
+}
+
+func TestBufferedCollector_Block(t *testing.T) {
+	downstream := &blockingCollector{release: make(chan struct{})}
+
+	collector, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: downstream,
+		Size:      1,
+		Block:     true,
+		MaxBlock:  time.Second,
+	})
+	require.NoError(t, err)
+
+	fill(t, collector)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(downstream.release)
+	}()
+
+	// Blocks until the downstream makes room in the buffer
+	err = collector.Receive(context.Background(), newEvent("blocked"))
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	assert.Len(t, downstream.events, 3)
+}
+
+func TestBufferedCollector_BlockTimeout(t *testing.T) {
+	downstream := &blockingCollector{release: make(chan struct{})}
+	defer close(downstream.release)
+
+	collector, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: downstream,
+		Size:      1,
+		Block:     true,
+		MaxBlock:  10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	fill(t, collector)
+
+	start := time.Now()
+
+	err = collector.Receive(context.Background(), newEvent("rejected"))
+	assert.ErrorIs(t, err, ErrBufferFull)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = collector.Receive(ctx, newEvent("rejected"))
+	assert.ErrorIs(t, err, ErrBufferFull, "request context must bound waiting")
+}
+
+func TestBufferedCollector_CloseBlocked(t *testing.T) {
+	downstream := &blockingCollector{release: make(chan struct{})}
+
+	collector, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: downstream,
+		Size:      1,
+		Block:     true,
+	})
+	require.NoError(t, err)
+
+	fill(t, collector)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- collector.Receive(context.Background(), newEvent("blocked"))
+	}()
+
+	// Wait for the sender to block
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// The blocked sender is released, even though the buffer is not drained in time
+	assert.ErrorIs(t, collector.Close(ctx), context.DeadlineExceeded)
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrCollectorClosed)
+	case <-time.After(time.Second):
+		t.Fatal("blocked sender not released by Close")
+	}
+
+	close(downstream.release)
+	require.NoError(t, collector.Close(context.Background()))
+
+	assert.Len(t, downstream.events, 2)
+}
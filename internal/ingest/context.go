@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"context"
+)
+
+// The values of the request an event is received in are carried by the context passed to Collector.Receive,
+// so collectors can route or tag events without setting them as extensions.
+
+type namespaceContextKey struct{}
+
+// ContextWithNamespace returns a context carrying the namespace of the event being ingested.
+func ContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace of the event being ingested.
+// The default namespace is empty: ok reports whether the namespace is known.
+func NamespaceFromContext(ctx context.Context) (namespace string, ok bool) {
+	namespace, ok = ctx.Value(namespaceContextKey{}).(string)
+
+	return namespace, ok
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a context carrying the authenticated identity of the request the event is received in.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated identity of the request the event is received in.
+func PrincipalFromContext(ctx context.Context) (principal string, ok bool) {
+	principal, ok = ctx.Value(principalContextKey{}).(string)
+
+	return principal, ok
+}
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a context carrying the ID of the request the event is received in.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request the event is received in.
+func RequestIDFromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(requestIDContextKey{}).(string)
+
+	return id, ok
+}
@@ -0,0 +1,38 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextValues(t *testing.T) {
+	ctx := context.Background()
+
+	_, ok := NamespaceFromContext(ctx)
+	assert.False(t, ok)
+
+	_, ok = PrincipalFromContext(ctx)
+	assert.False(t, ok)
+
+	_, ok = RequestIDFromContext(ctx)
+	assert.False(t, ok)
+
+	ctx = ContextWithNamespace(ctx, "")
+	ctx = ContextWithPrincipal(ctx, "user-1")
+	ctx = ContextWithRequestID(ctx, "request-1")
+
+	// The default namespace is empty
+	namespace, ok := NamespaceFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "", namespace)
+
+	principal, ok := PrincipalFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user-1", principal)
+
+	id, ok := RequestIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "request-1", id)
+}
@@ -0,0 +1,320 @@
+package ingest
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+)
+
+// Deduplicator remembers the keys of ingested events.
+//
+// Implementations must be safe for concurrent use.
+type Deduplicator interface {
+	// Seen records the key for the TTL and reports whether it was already recorded.
+	// A zero TTL uses the default TTL of the deduplicator.
+	// Checking and recording must be atomic, so concurrent duplicates are detected.
+	Seen(ctx context.Context, key string, ttl time.Duration) (bool, error)
+
+	// Forget removes the key, so the event can be ingested again (eg. after it could not be forwarded).
+	Forget(ctx context.Context, key string) error
+}
+
+// DedupKey returns the deduplication key of an event: events with the same source and ID are duplicates.
+//
+// When bucket is positive, the key also includes the event time truncated to the bucket,
+// so only duplicates within the same time bucket (eg. the same minute) are detected.
+func DedupKey(ev event.Event, bucket time.Duration) string {
+	key := ev.Source() + "\x00" + ev.ID()
+
+	if bucket > 0 {
+		key += "\x00" + strconv.FormatInt(ev.Time().Truncate(bucket).Unix(), 10)
+	}
+
+	return key
+}
+
+// DedupFilterConfig configures a DedupFilter.
+type DedupFilterConfig struct {
+	Deduplicator Deduplicator
+
+	// TimeBucket scopes deduplication to events in the same time bucket (see DedupKey).
+	// Zero deduplicates events regardless of their time.
+	TimeBucket time.Duration
+
+	// TypeTTLs overrides how long the keys of events of a type are remembered (eg. short windows for high frequency types).
+	// Other types use the default TTL of the deduplicator.
+	// When set, the keys of all events also include their type, so events of different types never collide.
+	TypeTTLs map[string]time.Duration
+}
+
+// DedupFilter detects events already ingested with a {Deduplicator}, by their source and ID (see DedupKey).
+type DedupFilter struct {
+	deduplicator Deduplicator
+	timeBucket   time.Duration
+	typeTTLs     map[string]time.Duration
+}
+
+// NewDedupFilter returns a new DedupFilter.
+func NewDedupFilter(config DedupFilterConfig) (*DedupFilter, error) {
+	if config.Deduplicator == nil {
+		return nil, errors.New("deduplicator is required")
+	}
+
+	if config.TimeBucket < 0 {
+		return nil, fmt.Errorf("invalid time bucket: %s", config.TimeBucket)
+	}
+
+	for typ, ttl := range config.TypeTTLs {
+		if ttl <= 0 {
+			return nil, fmt.Errorf("deduplication TTL of type %s must be positive", typ)
+		}
+	}
+
+	return &DedupFilter{
+		deduplicator: config.Deduplicator,
+		timeBucket:   config.TimeBucket,
+		typeTTLs:     config.TypeTTLs,
+	}, nil
+}
+
+// Seen records the event and reports whether it was already recorded in the same scope.
+// Scopes separate the events of independent producers sharing the filter (eg. the endpoints of several handlers), the default scope is empty.
+// The returned function forgets the event, so it can be ingested again (eg. after it could not be forwarded).
+func (f *DedupFilter) Seen(ctx context.Context, scope string, ev event.Event) (bool, func(ctx context.Context) error, error) {
+	key := DedupKey(ev, f.timeBucket)
+
+	var ttl time.Duration
+
+	if len(f.typeTTLs) > 0 {
+		key = ev.Type() + "\x00" + key
+		ttl = f.typeTTLs[ev.Type()]
+	}
+
+	if scope != "" {
+		key = scope + "\x00" + key
+	}
+
+	seen, err := f.deduplicator.Seen(ctx, key, ttl)
+	if err != nil {
+		return false, nil, fmt.Errorf("deduplicate event: %w", err)
+	}
+
+	forget := func(ctx context.Context) error {
+		return f.deduplicator.Forget(ctx, key)
+	}
+
+	return seen, forget, nil
+}
+
+// DeduplicatingCollectorConfig configures a DeduplicatingCollector.
+type DeduplicatingCollectorConfig struct {
+	// Collector receives events that are not duplicates.
+	Collector Collector
+
+	Deduplicator Deduplicator
+
+	// TimeBucket scopes deduplication to events in the same time bucket (see DedupKey).
+	// Zero deduplicates events regardless of their time.
+	TimeBucket time.Duration
+
+	// TypeTTLs overrides how long the keys of events of a type are remembered (see DedupFilterConfig.TypeTTLs).
+	TypeTTLs map[string]time.Duration
+
+	Logger *slog.Logger
+}
+
+// DeduplicatingCollector drops events already ingested and forwards the others to a downstream {Collector}.
+type DeduplicatingCollector struct {
+	collector Collector
+	filter    *DedupFilter
+	logger    *slog.Logger
+}
+
+// NewDeduplicatingCollector returns a new DeduplicatingCollector.
+func NewDeduplicatingCollector(config DeduplicatingCollectorConfig) (*DeduplicatingCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	filter, err := NewDedupFilter(DedupFilterConfig{
+		Deduplicator: config.Deduplicator,
+		TimeBucket:   config.TimeBucket,
+		TypeTTLs:     config.TypeTTLs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &DeduplicatingCollector{
+		collector: config.Collector,
+		filter:    filter,
+		logger:    logger,
+	}, nil
+}
+
+func (c *DeduplicatingCollector) Receive(ctx context.Context, ev event.Event) error {
+	seen, forget, err := c.filter.Seen(ctx, "", ev)
+	if err != nil {
+		return err
+	}
+
+	if seen {
+		c.logger.DebugCtx(ctx, "dropping duplicate event", slog.String("event_id", ev.ID()))
+
+		return nil
+	}
+
+	if err := c.collector.Receive(ctx, ev); err != nil {
+		if ferr := forget(ctx); ferr != nil {
+			c.logger.ErrorCtx(ctx, "unable to forget event key", slog.String("event_id", ev.ID()), slog.Any("error", ferr))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// MemoryDeduplicatorConfig configures a MemoryDeduplicator.
+type MemoryDeduplicatorConfig struct {
+	// TTL is how long keys are remembered by default.
+	//
+	// With a time bucket, the TTL should be at least the bucket granularity plus the expected delay of duplicates:
+	// keys expiring earlier let duplicates within the same bucket through.
+	// A longer TTL only uses more memory, as keys of past buckets never match again.
+	TTL time.Duration
+
+	// Size is the maximum number of remembered keys, regardless of their TTL.
+	// The oldest keys are forgotten first when the limit is reached.
+	//
+	// Keys with long TTLs (see DeduplicatingCollectorConfig.TypeTTLs) hold their share of the limit for longer:
+	// size the limit for the rate of each type times its TTL, or bursts of other types evict them before they expire.
+	Size int
+}
+
+// MemoryDeduplicator is a Deduplicator remembering keys in memory (per process).
+//
+// Keys are kept in one list per TTL, in the order they were recorded, so expired keys are found at the front of the lists.
+type MemoryDeduplicator struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	orders  map[time.Duration]*list.List
+}
+
+type dedupEntry struct {
+	key    string
+	ttl    time.Duration
+	seenAt time.Time
+}
+
+// NewMemoryDeduplicator returns a new MemoryDeduplicator.
+func NewMemoryDeduplicator(config MemoryDeduplicatorConfig) (*MemoryDeduplicator, error) {
+	if config.TTL <= 0 {
+		return nil, errors.New("deduplication TTL must be positive")
+	}
+
+	if config.Size <= 0 {
+		return nil, errors.New("deduplication size must be positive")
+	}
+
+	return &MemoryDeduplicator{
+		ttl:     config.TTL,
+		size:    config.Size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		orders:  make(map[time.Duration]*list.List),
+	}, nil
+}
+
+func (d *MemoryDeduplicator) Seen(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = d.ttl
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+
+	// Forget expired keys
+	for orderTTL, order := range d.orders {
+		for e := order.Front(); e != nil; e = order.Front() {
+			if now.Sub(e.Value.(*dedupEntry).seenAt) < orderTTL {
+				break
+			}
+
+			d.remove(e)
+		}
+	}
+
+	if _, ok := d.entries[key]; ok {
+		return true, nil
+	}
+
+	if len(d.entries) >= d.size {
+		d.remove(d.oldest())
+	}
+
+	order, ok := d.orders[ttl]
+	if !ok {
+		order = list.New()
+		d.orders[ttl] = order
+	}
+
+	d.entries[key] = order.PushBack(&dedupEntry{key: key, ttl: ttl, seenAt: now})
+
+	return false, nil
+}
+
+func (d *MemoryDeduplicator) Forget(_ context.Context, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[key]; ok {
+		d.remove(e)
+	}
+
+	return nil
+}
+
+// oldest returns the key recorded first, across TTLs.
+func (d *MemoryDeduplicator) oldest() *list.Element {
+	var oldest *list.Element
+
+	for _, order := range d.orders {
+		if e := order.Front(); e != nil && (oldest == nil || e.Value.(*dedupEntry).seenAt.Before(oldest.Value.(*dedupEntry).seenAt)) {
+			oldest = e
+		}
+	}
+
+	return oldest
+}
+
+func (d *MemoryDeduplicator) remove(e *list.Element) {
+	entry := e.Value.(*dedupEntry)
+
+	order := d.orders[entry.ttl]
+	order.Remove(e)
+
+	if order.Len() == 0 {
+		delete(d.orders, entry.ttl)
+	}
+
+	delete(d.entries, entry.key)
+}
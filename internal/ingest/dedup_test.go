@@ -0,0 +1,181 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupKey(t *testing.T) {
+	now := time.Date(2023, 6, 15, 14, 33, 10, 0, time.UTC)
+
+	newTimedEvent := func(t time.Time) event.Event {
+		ev := newEvent("1")
+		ev.SetTime(t)
+
+		return ev
+	}
+
+	assert.Equal(t, DedupKey(newTimedEvent(now), 0), DedupKey(newTimedEvent(now.Add(time.Hour)), 0))
+
+	assert.Equal(t, DedupKey(newTimedEvent(now), time.Minute), DedupKey(newTimedEvent(now.Add(20*time.Second)), time.Minute))
+	assert.NotEqual(t, DedupKey(newTimedEvent(now), time.Minute), DedupKey(newTimedEvent(now.Add(time.Minute)), time.Minute))
+}
+
+func TestDeduplicatingCollector(t *testing.T) {
+	deduplicator, err := NewMemoryDeduplicator(MemoryDeduplicatorConfig{TTL: time.Hour, Size: 100})
+	require.NoError(t, err)
+
+	fail := false
+
+	var forwarded []time.Time
+
+	collector, err := NewDeduplicatingCollector(DeduplicatingCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			if fail {
+				return errors.New("unavailable")
+			}
+
+			forwarded = append(forwarded, ev.Time())
+
+			return nil
+		}),
+		Deduplicator: deduplicator,
+		TimeBucket:   time.Minute,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2023, 6, 15, 14, 33, 10, 0, time.UTC)
+
+	receive := func(t time.Time) error {
+		ev := newEvent("periodic")
+		ev.SetTime(t)
+
+		return collector.Receive(ctx, ev)
+	}
+
+	require.NoError(t, receive(now))
+	require.NoError(t, receive(now.Add(10*time.Second)))
+
+	// Failed events can be retried
+	fail = true
+	require.Error(t, receive(now.Add(time.Minute)))
+
+	fail = false
+	require.NoError(t, receive(now.Add(time.Minute)))
+
+	assert.Equal(t, []time.Time{now, now.Add(time.Minute)}, forwarded)
+}
+
+func TestMemoryDeduplicator_TTL(t *testing.T) {
+	deduplicator, err := NewMemoryDeduplicator(MemoryDeduplicatorConfig{TTL: time.Minute, Size: 100})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	deduplicator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	seen, err := deduplicator.Seen(ctx, "key", 0)
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	seen, err = deduplicator.Seen(ctx, "key", 0)
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	now = now.Add(time.Minute)
+
+	seen, err = deduplicator.Seen(ctx, "key", 0)
+	require.NoError(t, err)
+	assert.False(t, seen)
+}
+
+func TestDeduplicatingCollector_TypeTTLs(t *testing.T) {
+	deduplicator, err := NewMemoryDeduplicator(MemoryDeduplicatorConfig{TTL: time.Hour, Size: 100})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	deduplicator.now = func() time.Time { return now }
+
+	var forwarded []string
+
+	collector, err := NewDeduplicatingCollector(DeduplicatingCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.Type())
+
+			return nil
+		}),
+		Deduplicator: deduplicator,
+		TypeTTLs:     map[string]time.Duration{"heartbeat": time.Minute},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	receive := func(typ string) {
+		ev := newEvent("1")
+		ev.SetType(typ)
+
+		require.NoError(t, collector.Receive(ctx, ev))
+	}
+
+	// Keys include the type
+	receive("heartbeat")
+	receive("api-calls")
+	receive("heartbeat")
+	receive("api-calls")
+
+	now = now.Add(2 * time.Minute)
+
+	// Only the key of the type with a short TTL expired
+	receive("heartbeat")
+	receive("api-calls")
+
+	assert.Equal(t, []string{"heartbeat", "api-calls", "heartbeat"}, forwarded)
+
+	_, err = NewDeduplicatingCollector(DeduplicatingCollectorConfig{
+		Collector:    collectorFunc(func(context.Context, event.Event) error { return nil }),
+		Deduplicator: deduplicator,
+		TypeTTLs:     map[string]time.Duration{"heartbeat": 0},
+	})
+	assert.Error(t, err)
+}
+
+func TestMemoryDeduplicator_SizeAcrossTTLs(t *testing.T) {
+	deduplicator, err := NewMemoryDeduplicator(MemoryDeduplicatorConfig{TTL: time.Hour, Size: 2})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	deduplicator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	for _, key := range []string{"1", "2", "3"} {
+		now = now.Add(time.Second)
+
+		ttl := time.Duration(0)
+		if key == "2" {
+			ttl = time.Minute
+		}
+
+		seen, err := deduplicator.Seen(ctx, key, ttl)
+		require.NoError(t, err)
+		require.False(t, seen)
+	}
+
+	// The oldest key is evicted, regardless of its TTL
+	seen, err := deduplicator.Seen(ctx, "2", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	seen, err = deduplicator.Seen(ctx, "1", 0)
+	require.NoError(t, err)
+	assert.False(t, seen)
+}
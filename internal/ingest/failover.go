@@ -0,0 +1,201 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+const defaultHealthCheckInterval = 10 * time.Second
+
+// FailoverCollectorConfig configures a FailoverCollector.
+type FailoverCollectorConfig struct {
+	// Primary receives events while it is healthy.
+	Primary Collector
+
+	// Secondaries receive events when the primary (and the secondaries before them) fail, in order.
+	Secondaries []Collector
+
+	// ShouldFailover reports whether an error of a collector fails the event over to the next one (optional).
+	// Defaults to every error except invalid events (ErrInvalidEvent), which no collector would accept.
+	ShouldFailover func(err error) bool
+
+	// HealthCheck checks whether the primary recovered (optional).
+	// When set, events stay with the secondary that took over after a failover until the check succeeds (fail back),
+	// instead of trying the primary first for every event.
+	HealthCheck func(ctx context.Context) error
+
+	// HealthCheckInterval is the minimum interval between health checks of the primary after a failover. Defaults to 10s.
+	HealthCheckInterval time.Duration
+
+	Logger *slog.Logger
+
+	// Registerer registers the metric counting failovers and fail backs (optional).
+	Registerer prometheus.Registerer
+}
+
+// FailoverCollector forwards every event to a single collector: the primary, or the next secondary when it fails.
+// Unlike fan-out, only one downstream receives each event.
+//
+// Without a health check, the primary is tried first for every event (immediate fail back).
+// With one, a failed primary is skipped until it recovers, so events do not wait on a failing primary:
+// the primary is checked in the background (at most every HealthCheckInterval) as events are received.
+//
+// Wrap the collectors in retrying or circuit breaking collectors to retry (or skip) them before failing over.
+type FailoverCollector struct {
+	collectors          []Collector
+	shouldFailover      func(err error) bool
+	healthCheck         func(ctx context.Context) error
+	healthCheckInterval time.Duration
+	logger              *slog.Logger
+	failovers           *prometheus.CounterVec
+
+	// active is the index of the collector events are forwarded to first (with a health check)
+	active atomic.Int32
+
+	checking  atomic.Bool
+	lastCheck atomic.Int64
+}
+
+// NewFailoverCollector returns a new FailoverCollector.
+func NewFailoverCollector(config FailoverCollectorConfig) (*FailoverCollector, error) {
+	if config.Primary == nil {
+		return nil, errors.New("primary collector is required")
+	}
+
+	if len(config.Secondaries) == 0 {
+		return nil, errors.New("at least one secondary collector is required")
+	}
+
+	for _, secondary := range config.Secondaries {
+		if secondary == nil {
+			return nil, errors.New("secondary collectors must not be nil")
+		}
+	}
+
+	if config.HealthCheckInterval < 0 {
+		return nil, errors.New("health check interval must not be negative")
+	}
+
+	shouldFailover := config.ShouldFailover
+	if shouldFailover == nil {
+		shouldFailover = func(err error) bool {
+			return !errors.Is(err, ErrInvalidEvent)
+		}
+	}
+
+	healthCheckInterval := config.HealthCheckInterval
+	if healthCheckInterval == 0 {
+		healthCheckInterval = defaultHealthCheckInterval
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &FailoverCollector{
+		collectors:          append([]Collector{config.Primary}, config.Secondaries...),
+		shouldFailover:      shouldFailover,
+		healthCheck:         config.HealthCheck,
+		healthCheckInterval: healthCheckInterval,
+		logger:              logger,
+		failovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "collector_failovers_total",
+			Help:      "Number of times events failed over from a collector to another (or failed back to the primary).",
+		}, []string{"from", "to"}),
+	}
+
+	if config.Registerer != nil {
+		if err := config.Registerer.Register(c.failovers); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *FailoverCollector) Receive(ctx context.Context, ev event.Event) error {
+	start := 0
+	if c.healthCheck != nil {
+		start = int(c.active.Load())
+
+		if start > 0 {
+			c.checkPrimary()
+		}
+	}
+
+	var errs []error
+
+	for i := start; i < len(c.collectors); i++ {
+		err := c.collectors[i].Receive(ctx, ev)
+		if err == nil {
+			if i != start && c.healthCheck != nil {
+				// Later events go to this collector directly, until the primary recovers
+				if c.active.CompareAndSwap(int32(start), int32(i)) && start == 0 {
+					c.lastCheck.Store(time.Now().UnixNano())
+				}
+			}
+
+			return nil
+		}
+
+		errs = append(errs, err)
+
+		if i == len(c.collectors)-1 || !c.shouldFailover(err) || ctx.Err() != nil {
+			break
+		}
+
+		c.logger.WarnCtx(ctx, "collector failed, failing over", slog.String("from", collectorName(i)), slog.String("to", collectorName(i+1)), slog.Any("error", err))
+		c.failovers.WithLabelValues(collectorName(i), collectorName(i+1)).Inc()
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkPrimary checks the health of the primary in the background, unless it was checked recently (or is being checked),
+// and fails back to it once it is healthy.
+func (c *FailoverCollector) checkPrimary() {
+	now := time.Now()
+
+	if now.Sub(time.Unix(0, c.lastCheck.Load())) < c.healthCheckInterval || !c.checking.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.lastCheck.Store(now.UnixNano())
+
+	go func() {
+		defer c.checking.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.healthCheckInterval)
+		defer cancel()
+
+		if err := c.healthCheck(ctx); err != nil {
+			c.logger.DebugCtx(ctx, "primary collector still unhealthy", slog.Any("error", err))
+
+			return
+		}
+
+		if from := c.active.Swap(0); from != 0 {
+			c.logger.InfoCtx(ctx, "primary collector recovered, failing back", slog.String("from", collectorName(int(from))))
+			c.failovers.WithLabelValues(collectorName(int(from)), collectorName(0)).Inc()
+		}
+	}()
+}
+
+// collectorName names the collector at the index in logs and metrics.
+func collectorName(i int) string {
+	if i == 0 {
+		return "primary"
+	}
+
+	return "secondary" + strconv.Itoa(i)
+}
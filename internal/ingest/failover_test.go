@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// switchableCollector records received events and fails while its error is set.
+type switchableCollector struct {
+	mu       sync.Mutex
+	err      error
+	received []string
+}
+
+func (c *switchableCollector) Receive(_ context.Context, ev event.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	c.received = append(c.received, ev.ID())
+
+	return nil
+}
+
+func (c *switchableCollector) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+}
+
+func (c *switchableCollector) ids() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.received...)
+}
+
+func TestFailoverCollector(t *testing.T) {
+	primary := &switchableCollector{}
+	secondary := &switchableCollector{}
+	tertiary := &switchableCollector{}
+
+	collector, err := NewFailoverCollector(FailoverCollectorConfig{
+		Primary:     primary,
+		Secondaries: []Collector{secondary, tertiary},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+
+	primary.setErr(errors.New("broker unavailable"))
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+
+	secondary.setErr(errors.New("broker unavailable"))
+	require.NoError(t, collector.Receive(ctx, newEvent("3")))
+
+	// Invalid events are not failed over
+	primary.setErr(fmt.Errorf("%w: too large", ErrInvalidEvent))
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("4")), ErrInvalidEvent)
+
+	// Without a health check, the primary is tried first for every event
+	primary.setErr(nil)
+	require.NoError(t, collector.Receive(ctx, newEvent("5")))
+
+	assert.Equal(t, []string{"1", "5"}, primary.ids())
+	assert.Equal(t, []string{"2"}, secondary.ids())
+	assert.Equal(t, []string{"3"}, tertiary.ids())
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(collector.failovers.WithLabelValues("primary", "secondary1")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.failovers.WithLabelValues("secondary1", "secondary2")))
+
+	// Every collector fails
+	primary.setErr(errors.New("primary unavailable"))
+	tertiary.setErr(errors.New("tertiary unavailable"))
+
+	err = collector.Receive(ctx, newEvent("6"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "primary unavailable")
+	assert.Contains(t, err.Error(), "tertiary unavailable")
+}
+
+func TestFailoverCollector_HealthCheck(t *testing.T) {
+	primary := &switchableCollector{}
+	secondary := &switchableCollector{}
+
+	var healthy atomic.Bool
+
+	collector, err := NewFailoverCollector(FailoverCollectorConfig{
+		Primary:     primary,
+		Secondaries: []Collector{secondary},
+		HealthCheck: func(_ context.Context) error {
+			if !healthy.Load() {
+				return errors.New("unhealthy")
+			}
+
+			return nil
+		},
+		HealthCheckInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	primary.setErr(errors.New("broker unavailable"))
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+
+	// The primary recovered, but events stay with the secondary until the health check succeeds
+	primary.setErr(nil)
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+
+	assert.Empty(t, primary.ids())
+	assert.Equal(t, []string{"1", "2"}, secondary.ids())
+
+	healthy.Store(true)
+
+	assert.Eventually(t, func() bool {
+		require.NoError(t, collector.Receive(ctx, newEvent("3")))
+
+		return len(primary.ids()) > 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.failovers.WithLabelValues("secondary1", "primary")))
+}
+
+func TestNewFailoverCollector(t *testing.T) {
+	_, err := NewFailoverCollector(FailoverCollectorConfig{Primary: &switchableCollector{}})
+	assert.Error(t, err)
+
+	_, err = NewFailoverCollector(FailoverCollectorConfig{Secondaries: []Collector{&switchableCollector{}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// FlagRoute routes the events with a flag to a collector.
+type FlagRoute struct {
+	// Flag is the flag of the events routed to the collector (case-insensitive).
+	Flag string
+
+	Collector Collector
+}
+
+// FlagRoutingCollectorConfig configures a FlagRoutingCollector.
+type FlagRoutingCollectorConfig struct {
+	// Routes are matched in order: events are routed to the collector of the first flag they have.
+	Routes []FlagRoute
+
+	// Default receives the events without any of the flags.
+	Default Collector
+}
+
+// FlagRoutingCollector routes events to a downstream {Collector} based on their flags (see FlagsFromContext).
+type FlagRoutingCollector struct {
+	routes   []FlagRoute
+	fallback Collector
+}
+
+// NewFlagRoutingCollector returns a new FlagRoutingCollector.
+func NewFlagRoutingCollector(config FlagRoutingCollectorConfig) (*FlagRoutingCollector, error) {
+	if config.Default == nil {
+		return nil, errors.New("default collector is required")
+	}
+
+	for i, route := range config.Routes {
+		if route.Flag == "" {
+			return nil, fmt.Errorf("route %d: flag is required", i)
+		}
+
+		if route.Collector == nil {
+			return nil, fmt.Errorf("route %d: collector is required", i)
+		}
+	}
+
+	return &FlagRoutingCollector{
+		routes:   config.Routes,
+		fallback: config.Default,
+	}, nil
+}
+
+func (c *FlagRoutingCollector) Receive(ctx context.Context, ev event.Event) error {
+	flags := FlagsFromContext(ctx)
+
+	for _, route := range c.routes {
+		if flags.Has(route.Flag) {
+			return route.Collector.Receive(ctx, ev)
+		}
+	}
+
+	return c.fallback.Receive(ctx, ev)
+}
@@ -0,0 +1,50 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagRoutingCollector(t *testing.T) {
+	routed := make(map[string][]string)
+
+	route := func(name string) Collector {
+		return collectorFunc(func(_ context.Context, ev event.Event) error {
+			routed[name] = append(routed[name], ev.ID())
+
+			return nil
+		})
+	}
+
+	collector, err := NewFlagRoutingCollector(FlagRoutingCollectorConfig{
+		Routes: []FlagRoute{
+			{Flag: FlagAudit, Collector: route("audit")},
+			{Flag: "beta", Collector: route("beta")},
+		},
+		Default: route("default"),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Receive(ContextWithFlags(ctx, ParseFlags("beta")), newEvent("2")))
+	require.NoError(t, collector.Receive(ContextWithFlags(ctx, ParseFlags("beta,audit")), newEvent("3")))
+	require.NoError(t, collector.Receive(ContextWithFlags(ctx, ParseFlags("unknown")), newEvent("4")))
+
+	assert.Equal(t, map[string][]string{
+		"default": {"1", "4"},
+		"beta":    {"2"},
+		"audit":   {"3"},
+	}, routed)
+
+	_, err = NewFlagRoutingCollector(FlagRoutingCollectorConfig{
+		Routes:  []FlagRoute{{Flag: FlagAudit}},
+		Default: route("default"),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// FlagAudit marks events that must be kept for auditing: they are never dropped by sampling
+// (nor as unchanged by a StateChangeCollector), and can be audited on their own (see AuditCollectorConfig.Flag).
+const FlagAudit = "audit"
+
+// Flags is the set of feature flags of an event.
+//
+// Flags are carried by an event extension as a comma separated list (eg. "audit,beta").
+// Names are case-insensitive, whitespace around names and empty names are ignored.
+type Flags map[string]struct{}
+
+// ParseFlags parses a comma separated list of flags.
+func ParseFlags(value string) Flags {
+	var flags Flags
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		if flags == nil {
+			flags = make(Flags)
+		}
+
+		flags[name] = struct{}{}
+	}
+
+	return flags
+}
+
+// Has reports whether the flag is set.
+func (f Flags) Has(name string) bool {
+	_, ok := f[strings.ToLower(name)]
+
+	return ok
+}
+
+// String returns the flags as a sorted, comma separated list.
+func (f Flags) String() string {
+	names := make([]string, 0, len(f))
+
+	for name := range f {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+type flagsContextKey struct{}
+
+// ContextWithFlags returns a context carrying the flags of the event being ingested.
+func ContextWithFlags(ctx context.Context, flags Flags) context.Context {
+	return context.WithValue(ctx, flagsContextKey{}, flags)
+}
+
+// FlagsFromContext returns the flags of the event being ingested.
+// Collectors receive the flags of the event in the context passed to Receive.
+func FlagsFromContext(ctx context.Context) Flags {
+	flags, _ := ctx.Value(flagsContextKey{}).(Flags)
+
+	return flags
+}
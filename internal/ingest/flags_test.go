@@ -0,0 +1,27 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFlags(t *testing.T) {
+	flags := ParseFlags(" Audit,beta,, beta ")
+
+	assert.Equal(t, Flags{"audit": {}, "beta": {}}, flags)
+	assert.True(t, flags.Has("AUDIT"))
+	assert.False(t, flags.Has("alpha"))
+	assert.Equal(t, "audit,beta", flags.String())
+
+	assert.Nil(t, ParseFlags(""))
+}
+
+func TestFlagsFromContext(t *testing.T) {
+	assert.Nil(t, FlagsFromContext(context.Background()))
+
+	ctx := ContextWithFlags(context.Background(), ParseFlags("audit"))
+
+	assert.True(t, FlagsFromContext(ctx).Has(FlagAudit))
+}
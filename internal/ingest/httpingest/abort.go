@@ -0,0 +1,24 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// checkClientAbort returns a 400 error if reading the request body failed because the client aborted the request:
+// the body ended in the middle of a value (the connection was closed mid-upload) or the request context is canceled.
+//
+// Client aborts are not server errors: they are logged at debug level and answered (if the client is still there) with 400.
+func checkClientAbort(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || ctx.Err() != nil {
+		return NewEventErrorf(http.StatusBadRequest, "request body ended unexpectedly, the client may have aborted the request: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_ClientAbort(t *testing.T) {
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "Single", contentType: ContentTypeSingle, body: ev},
+		{name: "Batch", contentType: ContentTypeBatch, body: "[" + ev + "," + ev + "]"},
+		{name: "Stream", contentType: ContentTypeNDJSON, body: ev + "\n" + ev + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			handler := Handler{
+				Collector: &testcollector.Collector{},
+				Logger:    slog.New(slog.NewJSONHandler(&logs, nil)),
+			}
+
+			// The connection is closed in the middle of the second event
+			truncated := io.MultiReader(
+				strings.NewReader(test.body[:len(test.body)-20]),
+				iotest.ErrReader(io.ErrUnexpectedEOF),
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/", truncated)
+			req.Header.Set("Content-Type", test.contentType)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if test.contentType == ContentTypeNDJSON {
+				assert.Contains(t, w.Body.String(), `"statusCode":400`)
+			} else {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			}
+
+			// Aborts are only logged at debug level
+			assert.NotContains(t, logs.String(), `"level":"ERROR"`)
+		})
+	}
+}
+
+func TestCheckClientAbort(t *testing.T) {
+	assert.NoError(t, checkClientAbort(context.Background(), nil))
+	assert.NoError(t, checkClientAbort(context.Background(), io.ErrClosedPipe))
+	assert.Error(t, checkClientAbort(context.Background(), io.ErrUnexpectedEOF))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := checkClientAbort(ctx, io.ErrClosedPipe)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+}
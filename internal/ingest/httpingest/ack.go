@@ -0,0 +1,16 @@
+package httpingest
+
+import (
+	"net/http"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// successStatus returns the status of events forwarded to the {Collector} successfully with the acknowledgement mode.
+func (h Handler) successStatus(mode ingest.AckMode) int {
+	if h.AcceptedStatus && mode == ingest.AckEnqueued {
+		return http.StatusAccepted
+	}
+
+	return http.StatusOK
+}
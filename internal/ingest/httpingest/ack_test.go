@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_AcceptedStatus(t *testing.T) {
+	single := `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	batch, err := json.Marshal(newTestEvents(t, 2))
+	require.NoError(t, err)
+
+	send := func(handler Handler, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		acceptedStatus bool
+		ack            ingest.AckMode
+		status         int
+	}{
+		{name: "Delivered", acceptedStatus: true, ack: ingest.AckDelivered, status: http.StatusOK},
+		{name: "Enqueued", acceptedStatus: true, ack: ingest.AckEnqueued, status: http.StatusAccepted},
+		{name: "Disabled", acceptedStatus: false, ack: ingest.AckEnqueued, status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := Handler{
+				Collector:      &testcollector.Collector{Ack: test.ack},
+				AcceptedStatus: test.acceptedStatus,
+			}
+
+			w := send(handler, ContentTypeSingle, []byte(single))
+			assert.Equal(t, test.status, w.Code, w.Body.String())
+
+			w = send(handler, ContentTypeBatch, batch)
+			assert.Equal(t, test.status, w.Code, w.Body.String())
+
+			// Results of streamed events carry the status of each event
+			w = send(handler, ContentTypeNDJSON, []byte(single+"\n"))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var result EventResult
+			require.NoError(t, json.NewDecoder(strings.NewReader(w.Body.String())).Decode(&result))
+			assert.Equal(t, test.status, result.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package httpingest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// BearerTokenAuthenticator returns an authentication function (see ChainConfig.Authenticate) accepting requests
+// with the token in an Authorization: Bearer header, eg. to protect admin endpoints (FlushHandler, MaintenanceHandler and LastErrorHandler).
+func BearerTokenAuthenticator(token string) (func(r *http.Request) error, error) {
+	if token == "" {
+		return nil, errors.New("bearer token is required")
+	}
+
+	return func(r *http.Request) error {
+		scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(credentials), []byte(token)) != 1 {
+			return NewEventErrorf(http.StatusUnauthorized, "unauthorized: invalid bearer token")
+		}
+
+		return nil
+	}, nil
+}
@@ -0,0 +1,42 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerTokenAuthenticator(t *testing.T) {
+	authenticate, err := BearerTokenAuthenticator("secret")
+	require.NoError(t, err)
+
+	handler, err := NewChain(LastErrorHandler{Tracker: &LastErrorTracker{}}, ChainConfig{Authenticate: authenticate})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		authorization string
+		status        int
+	}{
+		{authorization: "Bearer secret", status: http.StatusOK},
+		{authorization: "bearer secret", status: http.StatusOK},
+		{authorization: "Bearer other", status: http.StatusUnauthorized},
+		{authorization: "Basic secret", status: http.StatusUnauthorized},
+		{status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, test.status, w.Code, test.authorization)
+	}
+
+	_, err = BearerTokenAuthenticator("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// ambiguousDataField is the field error recorded on events with both data and data_base64 (see dataCheckedEvent).
+const ambiguousDataField = "data_base64"
+
+var errAmbiguousData = errors.New("data and data_base64 must not both be set")
+
+// dataCheckedEvent is an event recording whether it was sent with both data and data_base64.
+//
+// The SDK keeps whichever comes last: the other one is silently dropped.
+// The event is decoded nevertheless (so it is reported by its ID), and rejected by checkAmbiguousData.
+type dataCheckedEvent struct {
+	event.Event
+}
+
+func (e *dataCheckedEvent) UnmarshalJSON(b []byte) error {
+	if err := e.Event.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	if hasDataAndDataBase64(b) {
+		if e.FieldErrors == nil {
+			e.FieldErrors = make(map[string]error)
+		}
+
+		e.FieldErrors[ambiguousDataField] = errAmbiguousData
+	}
+
+	return nil
+}
+
+// hasDataAndDataBase64 reports whether the JSON object has both (non null) data and data_base64 members.
+func hasDataAndDataBase64(b []byte) bool {
+	// Most events have neither
+	if !bytes.Contains(b, []byte(`"data_base64"`)) {
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+
+	var data, dataBase64 bool
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return false
+		}
+
+		if string(value) == "null" {
+			continue
+		}
+
+		switch key {
+		case "data":
+			data = true
+		case "data_base64":
+			dataBase64 = true
+		}
+	}
+
+	return data && dataBase64
+}
+
+// decodeEvent decodes the next event of the decoder, recording whether it has both data and data_base64 with RejectAmbiguousData.
+func (h Handler) decodeEvent(decoder *json.Decoder, ev *event.Event) error {
+	if !h.RejectAmbiguousData {
+		return decoder.Decode(ev)
+	}
+
+	var checked dataCheckedEvent
+
+	err := decoder.Decode(&checked)
+	*ev = checked.Event
+
+	return err
+}
+
+// decodeEvents decodes a batch of events, see decodeEvent.
+func (h Handler) decodeEvents(decoder *json.Decoder, events *[]event.Event) error {
+	if h.BatchBudget != nil {
+		return h.BatchBudget.decode(decoder, events, h.RejectAmbiguousData)
+	}
+
+	if !h.RejectAmbiguousData {
+		return decoder.Decode(events)
+	}
+
+	var checked []dataCheckedEvent
+
+	err := decoder.Decode(&checked)
+
+	*events = make([]event.Event, 0, len(checked))
+	for _, ev := range checked {
+		*events = append(*events, ev.Event)
+	}
+
+	return err
+}
+
+// checkAmbiguousData rejects events sent with both data and data_base64 with 422: the spec forbids it,
+// and it indicates a bug of the client serializer.
+func checkAmbiguousData(ev event.Event) error {
+	if errors.Is(ev.FieldErrors[ambiguousDataField], errAmbiguousData) {
+		return NewEventErrorf(http.StatusUnprocessableEntity, "event %s: %w", ev.ID(), errAmbiguousData)
+	}
+
+	return nil
+}
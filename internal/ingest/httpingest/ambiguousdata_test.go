@@ -0,0 +1,98 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_RejectAmbiguousData(t *testing.T) {
+	const base = `"specversion":"1.0","source":"test","type":"api-calls"`
+
+	events := []string{
+		`{` + base + `,"id":"data","data":{"a":1}}`,
+		`{` + base + `,"id":"data-base64","data_base64":"AAEC"}`,
+		`{` + base + `,"id":"both","data":{"a":1},"data_base64":"AAEC"}`,
+		`{` + base + `,"id":"null-data","data":null,"data_base64":"AAEC"}`,
+		`{` + base + `,"id":"nested","data":{"data_base64":"AAEC"}}`,
+	}
+
+	t.Run("Batch", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:           collector,
+			RejectAmbiguousData: true,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+strings.Join(events, ",")+"]"))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+		var results []EventResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		require.Len(t, results, len(events))
+
+		for _, result := range results {
+			if result.ID == "both" {
+				assert.Equal(t, http.StatusUnprocessableEntity, result.StatusCode)
+				assert.Equal(t, "event both: data and data_base64 must not both be set", result.Error)
+			} else {
+				assert.Equal(t, http.StatusOK, result.StatusCode, result.ID)
+			}
+		}
+
+		assert.ElementsMatch(t, []string{"data", "data-base64", "null-data", "nested"}, collector.IDs())
+	})
+
+	t.Run("Single", func(t *testing.T) {
+		for _, enabled := range []bool{true, false} {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:           collector,
+				RejectAmbiguousData: enabled,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(events[2]))
+			req.Header.Set("Content-Type", ContentTypeSingle)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if enabled {
+				assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+				testcollector.AssertReceived(t, collector)
+			} else {
+				assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				testcollector.AssertReceived(t, collector, "both")
+			}
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:           collector,
+			RejectAmbiguousData: true,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(events[0]+"\n"+events[2]+"\n"))
+		req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Contains(t, w.Body.String(), `"statusCode":422`)
+		testcollector.AssertReceived(t, collector, "data")
+	})
+}
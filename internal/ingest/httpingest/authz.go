@@ -0,0 +1,48 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// Authorizer decides whether the principal of a request (see {Handler.Identity}) may ingest an event.
+// The principal is empty for requests without an identity.
+//
+// An error denies the event. Errors created with NewEventErrorf are reported with their status,
+// other errors with 403 Forbidden (per event in batches).
+type Authorizer interface {
+	Authorize(ctx context.Context, principal string, ev event.Event) error
+}
+
+// AuthorizerFunc is a function implementing Authorizer.
+type AuthorizerFunc func(ctx context.Context, principal string, ev event.Event) error
+
+func (fn AuthorizerFunc) Authorize(ctx context.Context, principal string, ev event.Event) error {
+	return fn(ctx, principal, ev)
+}
+
+// checkAuthorization rejects events the Authorizer denies.
+func (h Handler) checkAuthorization(ctx context.Context, ev event.Event) error {
+	if h.Authorizer == nil {
+		return nil
+	}
+
+	principal, _ := ingest.PrincipalFromContext(ctx)
+
+	err := h.Authorizer.Authorize(ctx, principal, ev)
+	if err == nil {
+		return nil
+	}
+
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return err
+	}
+
+	return NewEventErrorf(http.StatusForbidden, "event not authorized: %w", err)
+}
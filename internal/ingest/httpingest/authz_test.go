@@ -0,0 +1,73 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_Authorizer(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+
+		wantStatus int
+	}{
+		{
+			name:       "Allowed",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "UntypedError",
+			err:        errors.New("denied"),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "TypedError",
+			err:        NewEventErrorf(http.StatusServiceUnavailable, "unavailable"),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			var principals []string
+
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector: collector,
+				Identity:  HeaderIdentity("X-Principal"),
+				Authorizer: AuthorizerFunc(func(_ context.Context, principal string, _ event.Event) error {
+					principals = append(principals, principal)
+
+					return test.err
+				}),
+			}
+
+			r := newSingleEventRequest(t, newTestEvents(t, 1)[0])
+			r.Header.Set("X-Principal", "alice")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, test.wantStatus, w.Code)
+			assert.Equal(t, []string{"alice"}, principals)
+
+			if test.wantStatus == http.StatusOK {
+				testcollector.AssertReceived(t, collector, "0")
+			} else {
+				assert.Equal(t, 0, collector.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,507 @@
+package httpingest
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/actgardner/gogen-avro/v10/compiler"
+	"github.com/actgardner/gogen-avro/v10/generic"
+	"github.com/actgardner/gogen-avro/v10/schema"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// ContentTypeAvro is the content type of single CloudEvents in Avro format.
+// See https://github.com/cloudevents/spec/blob/main/cloudevents/formats/avro-format.md
+const ContentTypeAvro = "application/cloudevents+avro"
+
+// avroCloudEventSchema is the Avro schema of CloudEvents defined by the Avro event format.
+const avroCloudEventSchema = `{
+  "namespace": "io.cloudevents",
+  "type": "record",
+  "name": "AvroCloudEvent",
+  "version": "1.0",
+  "doc": "Avro Event Format for CloudEvents",
+  "fields": [
+    {
+      "name": "attribute",
+      "type": {"type": "map", "values": ["null", "boolean", "int", "string", "bytes"]}
+    },
+    {
+      "name": "data",
+      "type": [
+        "bytes",
+        "null",
+        "boolean",
+        {
+          "type": "map",
+          "values": [
+            "null",
+            "boolean",
+            {
+              "type": "record",
+              "name": "CloudEventData",
+              "doc": "Representation of a JSON Value",
+              "fields": [
+                {
+                  "name": "value",
+                  "type": {
+                    "type": "map",
+                    "values": [
+                      "null",
+                      "boolean",
+                      {"type": "map", "values": "CloudEventData"},
+                      {"type": "array", "items": "CloudEventData"},
+                      "double",
+                      "string"
+                    ]
+                  }
+                }
+              ]
+            },
+            "double",
+            "string"
+          ]
+        },
+        {"type": "array", "items": "CloudEventData"},
+        "double",
+        "string"
+      ]
+    }
+  ]
+}`
+
+// avroMagicByte prefixes the schema ID of bodies in the Confluent wire format.
+const avroMagicByte = 0
+
+const (
+	defaultAvroCacheSize = 1000
+	defaultAvroErrorTTL  = 10 * time.Second
+)
+
+// AvroSchemaRegistry resolves the schemas Avro encoded events are written with.
+type AvroSchemaRegistry interface {
+	// AvroSchema returns the Avro schema (in JSON) with the ID.
+	AvroSchema(id int) (string, error)
+}
+
+// SchemaRegistryAvroSchemas resolves Avro schemas with a Confluent schema registry.
+type SchemaRegistryAvroSchemas struct {
+	Client schemaregistry.Client
+
+	// Subject is the subject schemas are registered under (optional).
+	Subject string
+}
+
+func (s SchemaRegistryAvroSchemas) AvroSchema(id int) (string, error) {
+	info, err := s.Client.GetBySubjectAndID(s.Subject, id)
+	if err != nil {
+		return "", err
+	}
+
+	if info.SchemaType != "" && info.SchemaType != "AVRO" {
+		return "", fmt.Errorf("schema %d is not an Avro schema: %s", id, info.SchemaType)
+	}
+
+	return info.Schema, nil
+}
+
+// AvroDecoderConfig configures an AvroDecoder.
+type AvroDecoderConfig struct {
+	// Registry resolves the writer schemas of bodies prefixed with a schema ID (optional).
+	// Without a registry, only bodies written with the schema of the Avro event format are accepted.
+	Registry AvroSchemaRegistry
+
+	// CacheSize is the maximum number of cached writer schemas, the least recently used schemas are evicted first. Defaults to 1000.
+	CacheSize int
+
+	// ErrorTTL is how long schema IDs that could not be resolved are cached. Defaults to 10s.
+	ErrorTTL time.Duration
+}
+
+// AvroDecoder decodes single CloudEvents in Avro format (ContentTypeAvro).
+//
+// Bodies are either written with the schema of the Avro event format,
+// or prefixed with the ID of their writer schema in the Confluent wire format (a zero byte and a four byte big endian ID).
+// Writer schemas are resolved against the schema of the Avro event format once per ID,
+// concurrent requests with the same unknown ID wait for the same registry lookup.
+// Schema IDs that could not be resolved are rejected without a lookup for ErrorTTL.
+type AvroDecoder struct {
+	registry AvroSchemaRegistry
+	reader   schema.AvroType
+	codec    *generic.Codec
+	size     int
+	errorTTL time.Duration
+	now      func() time.Time
+
+	lookups singleflight.Group
+
+	mu     sync.Mutex
+	codecs map[int]*list.Element
+	order  *list.List
+}
+
+// avroCodec is a cached writer schema codec, or the reason the schema ID could not be resolved.
+type avroCodec struct {
+	id    int
+	codec *generic.Codec
+
+	err     error
+	expires time.Time
+}
+
+// NewAvroDecoder returns a new AvroDecoder.
+func NewAvroDecoder(config AvroDecoderConfig) (*AvroDecoder, error) {
+	if config.CacheSize < 0 {
+		return nil, errors.New("avro cache size must not be negative")
+	}
+
+	if config.ErrorTTL < 0 {
+		return nil, errors.New("avro error TTL must not be negative")
+	}
+
+	size := config.CacheSize
+	if size == 0 {
+		size = defaultAvroCacheSize
+	}
+
+	errorTTL := config.ErrorTTL
+	if errorTTL == 0 {
+		errorTTL = defaultAvroErrorTTL
+	}
+
+	reader, err := compiler.ParseSchema([]byte(avroCloudEventSchema))
+	if err != nil {
+		return nil, fmt.Errorf("parse avro cloudevent schema: %w", err)
+	}
+
+	codec, err := generic.NewCodec(reader, reader)
+	if err != nil {
+		return nil, fmt.Errorf("compile avro cloudevent schema: %w", err)
+	}
+
+	return &AvroDecoder{
+		registry: config.Registry,
+		reader:   reader,
+		codec:    codec,
+		size:     size,
+		errorTTL: errorTTL,
+		now:      time.Now,
+		codecs:   make(map[int]*list.Element),
+		order:    list.New(),
+	}, nil
+}
+
+// decode decodes an event from the request body.
+func (d *AvroDecoder) decode(body []byte) (event.Event, error) {
+	codec := d.codec
+
+	// A body in the event format never starts with a zero byte: it would be an event without attributes
+	if len(body) > 0 && body[0] == avroMagicByte {
+		if len(body) < 5 {
+			return event.Event{}, NewEventErrorf(http.StatusBadRequest, "invalid avro body: missing schema id")
+		}
+
+		var err error
+
+		codec, err = d.schemaCodec(int(binary.BigEndian.Uint32(body[1:5])))
+		if err != nil {
+			return event.Event{}, err
+		}
+
+		body = body[5:]
+	}
+
+	datum, err := deserializeAvro(codec, body)
+	if err != nil {
+		return event.Event{}, NewEventErrorf(http.StatusBadRequest, "invalid avro body: %w", err)
+	}
+
+	return avroEvent(datum)
+}
+
+// schemaCodec returns the codec of events written with the schema.
+func (d *AvroDecoder) schemaCodec(id int) (*generic.Codec, error) {
+	if d.registry == nil {
+		return nil, NewEventErrorf(http.StatusBadRequest, "avro schema registry not configured")
+	}
+
+	if cached, ok := d.cached(id); ok {
+		return cached.codec, cached.err
+	}
+
+	// The registry is queried without holding the cache lock
+	v, _, _ := d.lookups.Do(strconv.Itoa(id), func() (interface{}, error) {
+		codec, err := d.resolve(id)
+
+		cached := avroCodec{id: id, codec: codec, err: err}
+		d.store(cached)
+
+		return cached, nil
+	})
+
+	cached := v.(avroCodec)
+
+	return cached.codec, cached.err
+}
+
+// resolve looks up the schema in the registry and compiles its codec.
+func (d *AvroDecoder) resolve(id int) (*generic.Codec, error) {
+	s, err := d.registry.AvroSchema(id)
+	if err != nil {
+		return nil, fmt.Errorf("resolve avro schema %d: %w", id, err)
+	}
+
+	writer, err := compiler.ParseSchema([]byte(s))
+	if err != nil {
+		return nil, NewEventErrorf(http.StatusBadRequest, "invalid avro schema %d: %w", id, err)
+	}
+
+	codec, err := generic.NewCodec(writer, d.reader)
+	if err != nil {
+		return nil, NewEventErrorf(http.StatusBadRequest, "avro schema %d is incompatible with the cloudevent schema: %w", id, err)
+	}
+
+	return codec, nil
+}
+
+// cached returns the cached codec of the schema, unless it is missing or an expired error.
+func (d *AvroDecoder) cached(id int) (avroCodec, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.codecs[id]
+	if !ok {
+		return avroCodec{}, false
+	}
+
+	cached := e.Value.(*avroCodec)
+	if cached.err != nil && !d.now().Before(cached.expires) {
+		d.order.Remove(e)
+		delete(d.codecs, id)
+
+		return avroCodec{}, false
+	}
+
+	d.order.MoveToFront(e)
+
+	return *cached, true
+}
+
+// store caches the codec, evicting the least recently used codec if the cache is full.
+func (d *AvroDecoder) store(cached avroCodec) {
+	if cached.err != nil {
+		cached.expires = d.now().Add(d.errorTTL)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.codecs[cached.id]; ok {
+		e.Value = &cached
+		d.order.MoveToFront(e)
+
+		return
+	}
+
+	if d.order.Len() >= d.size {
+		oldest := d.order.Back()
+
+		d.order.Remove(oldest)
+		delete(d.codecs, oldest.Value.(*avroCodec).id)
+	}
+
+	d.codecs[cached.id] = d.order.PushFront(&cached)
+}
+
+// deserializeAvro deserializes the body, recovering from panics of the generic decoder on malformed bodies.
+func deserializeAvro(codec *generic.Codec, body []byte) (datum interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	reader := bytes.NewReader(body)
+
+	datum, err = codec.Deserialize(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes of trailing data", reader.Len())
+	}
+
+	return datum, nil
+}
+
+// avroEvent converts a decoded Avro CloudEvent to an event by way of its JSON format,
+// so attributes are validated the same way as the attributes of events in JSON format.
+func avroEvent(datum interface{}) (event.Event, error) {
+	record, ok := datum.(map[string]interface{})
+	if !ok {
+		return event.Event{}, errors.New("unexpected avro datum")
+	}
+
+	attributes, _ := record["attribute"].(map[string]interface{})
+
+	obj := make(map[string]interface{}, len(attributes)+1)
+	for name, value := range attributes {
+		if value != nil {
+			obj[name] = value
+		}
+	}
+
+	switch data := record["data"].(type) {
+	case nil:
+	case []byte:
+		obj["data_base64"] = base64.StdEncoding.EncodeToString(data)
+	case map[string]interface{}:
+		obj["data"] = avroDataObject(data)
+	case []interface{}:
+		obj["data"] = avroDataArray(data)
+	default:
+		obj["data"] = data
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return event.Event{}, err
+	}
+
+	var ev event.Event
+
+	if err := json.Unmarshal(b, &ev); err != nil {
+		return event.Event{}, NewEventErrorf(http.StatusBadRequest, "invalid avro event: %w", err)
+	}
+
+	return ev, nil
+}
+
+// avroDataObject converts the top level map of event data: its values are CloudEventData records or primitives.
+func avroDataObject(data map[string]interface{}) map[string]interface{} {
+	obj := make(map[string]interface{}, len(data))
+
+	for k, v := range data {
+		if record, ok := v.(map[string]interface{}); ok {
+			obj[k] = avroDataRecord(record)
+
+			continue
+		}
+
+		obj[k] = v
+	}
+
+	return obj
+}
+
+// avroDataArray converts an array of CloudEventData records.
+func avroDataArray(data []interface{}) []interface{} {
+	arr := make([]interface{}, len(data))
+
+	for i, v := range data {
+		record, _ := v.(map[string]interface{})
+		arr[i] = avroDataRecord(record)
+	}
+
+	return arr
+}
+
+// avroDataRecord converts a CloudEventData record: its value is a map of primitives, arrays and maps of records.
+func avroDataRecord(record map[string]interface{}) map[string]interface{} {
+	value, _ := record["value"].(map[string]interface{})
+
+	obj := make(map[string]interface{}, len(value))
+
+	for k, v := range value {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for mk, mv := range v {
+				record, _ := mv.(map[string]interface{})
+				m[mk] = avroDataRecord(record)
+			}
+
+			obj[k] = m
+
+		case []interface{}:
+			obj[k] = avroDataArray(v)
+
+		default:
+			obj[k] = v
+		}
+	}
+
+	return obj
+}
+
+// processAvroRequest processes a single event in Avro format.
+func (h Handler) processAvroRequest(w http.ResponseWriter, r *http.Request) {
+	logger := h.getLogger()
+
+	if h.Avro == nil {
+		h.metrics().RecordRejection(r.Context(), RejectionScopeRequest, RejectionUnsupported)
+
+		renderError(w, r, NewEventErrorf(http.StatusUnsupportedMediaType, "avro events are not accepted"))
+
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if tooLarge := checkBodySize(err); tooLarge != nil {
+		err = tooLarge
+	}
+
+	if aborted := checkClientAbort(r.Context(), err); aborted != nil {
+		logger.DebugCtx(r.Context(), "event aborted", "error", aborted)
+		h.recordRejection(r.Context(), RejectionScopeRequest, aborted)
+
+		renderError(w, r, aborted)
+
+		return
+	}
+
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "unable to read event", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	ev, err := h.Avro.decode(body)
+	if err != nil {
+		logger.DebugCtx(r.Context(), "unable to parse event", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	ctx, ack := ingest.WithAck(r.Context())
+
+	err = h.processEvent(ctx, ev)
+	if err != nil {
+		renderError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(h.successStatus(ack()))
+}
@@ -0,0 +1,290 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+// avroWriter writes Avro binary encoded values.
+type avroWriter struct {
+	bytes.Buffer
+}
+
+func (w *avroWriter) long(n int64) *avroWriter {
+	var buf [binary.MaxVarintLen64]byte
+
+	w.Write(buf[:binary.PutVarint(buf[:], n)])
+
+	return w
+}
+
+func (w *avroWriter) string(s string) *avroWriter {
+	w.long(int64(len(s)))
+	w.WriteString(s)
+
+	return w
+}
+
+func (w *avroWriter) double(f float64) *avroWriter {
+	var buf [8]byte
+
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	w.Write(buf[:])
+
+	return w
+}
+
+// attributes writes the attribute map of the Avro event format (string values).
+func (w *avroWriter) attributes(union bool, attributes ...string) *avroWriter {
+	w.long(int64(len(attributes) / 2))
+
+	for i := 0; i < len(attributes); i += 2 {
+		w.string(attributes[i])
+
+		if union {
+			w.long(3)
+		}
+
+		w.string(attributes[i+1])
+	}
+
+	return w.long(0)
+}
+
+type fakeAvroSchemaRegistry struct {
+	schemas map[int]string
+	calls   int
+
+	// release blocks lookups until it is closed (optional)
+	release chan struct{}
+
+	mu sync.Mutex
+}
+
+func (r *fakeAvroSchemaRegistry) AvroSchema(id int) (string, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+
+	if r.release != nil {
+		<-r.release
+	}
+
+	s, ok := r.schemas[id]
+	if !ok {
+		return "", errors.New("schema not found")
+	}
+
+	return s, nil
+}
+
+func postAvro(handler Handler, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeAvro)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestHandler_Avro(t *testing.T) {
+	decoder, err := NewAvroDecoder(AvroDecoderConfig{})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector: collector,
+		Avro:      decoder,
+	}
+
+	var body avroWriter
+
+	body.attributes(true,
+		"specversion", "1.0",
+		"id", "1",
+		"source", "test",
+		"type", "api-calls",
+		"subject", "customer-1",
+		"time", "2023-06-15T14:33:00Z",
+	)
+
+	// data: map of CloudEventData values
+	body.long(3).long(3)
+	body.string("duration_ms").long(3).double(12.5)
+	body.string("method").long(4).string("GET")
+	body.string("tags").long(2).long(1).string("env").long(5).string("prod").long(0)
+	body.long(0)
+
+	w := postAvro(handler, body.Bytes())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.Len(t, collector.Events(), 1)
+
+	ev := collector.Events()[0]
+	assert.Equal(t, "1", ev.ID())
+	assert.Equal(t, "customer-1", ev.Subject())
+	assert.Equal(t, time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC), ev.Time())
+	assert.JSONEq(t, `{"duration_ms":12.5,"method":"GET","tags":{"env":"prod"}}`, string(ev.Data()))
+
+	// Malformed bodies are rejected
+	w = postAvro(handler, body.Bytes()[:20])
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Schema IDs cannot be resolved without a registry
+	w = postAvro(handler, []byte{0, 0, 0, 0, 1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Avro events are not accepted without a decoder
+	w = postAvro(Handler{Collector: collector}, body.Bytes())
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// avroWriterSchema is a schema compatible with the Avro event format, with string attributes and data.
+const avroWriterSchema = `{
+  "namespace": "io.cloudevents",
+  "type": "record",
+  "name": "AvroCloudEvent",
+  "fields": [
+    {"name": "attribute", "type": {"type": "map", "values": "string"}},
+    {"name": "data", "type": ["null", "string"]}
+  ]
+}`
+
+func TestHandler_AvroSchemaRegistry(t *testing.T) {
+	registry := &fakeAvroSchemaRegistry{schemas: map[int]string{42: avroWriterSchema}}
+
+	decoder, err := NewAvroDecoder(AvroDecoderConfig{Registry: registry})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector: collector,
+		Avro:      decoder,
+	}
+
+	var body avroWriter
+
+	body.Write([]byte{0, 0, 0, 0, 42})
+	body.attributes(false,
+		"specversion", "1.0",
+		"id", "1",
+		"source", "test",
+		"type", "api-calls",
+		"datacontenttype", "text/plain",
+	)
+	body.long(1).string("hello")
+
+	for i := 0; i < 2; i++ {
+		w := postAvro(handler, body.Bytes())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	require.Len(t, collector.Events(), 2)
+	assert.Equal(t, "hello", string(collector.Events()[0].Data()))
+
+	// Schemas are resolved once
+	assert.Equal(t, 1, registry.calls)
+}
+
+func TestAvroDecoder_SchemaCache(t *testing.T) {
+	registry := &fakeAvroSchemaRegistry{
+		schemas: map[int]string{1: avroWriterSchema, 2: avroWriterSchema},
+		release: make(chan struct{}),
+	}
+
+	decoder, err := NewAvroDecoder(AvroDecoderConfig{
+		Registry:  registry,
+		CacheSize: 1,
+		ErrorTTL:  time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	decoder.now = func() time.Time {
+		return now
+	}
+
+	// Concurrent lookups of the same schema query the registry once
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := decoder.schemaCodec(1)
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Lookups don't hold the cache lock
+	require.Eventually(t, func() bool {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+
+		return registry.calls == 1
+	}, time.Second, time.Millisecond)
+
+	_, ok := decoder.cached(1)
+	assert.False(t, ok)
+
+	close(registry.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, registry.calls)
+
+	// Unknown schemas are cached for the error TTL
+	for i := 0; i < 2; i++ {
+		_, err = decoder.schemaCodec(3)
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, 2, registry.calls)
+
+	now = now.Add(time.Minute)
+
+	_, err = decoder.schemaCodec(3)
+	require.Error(t, err)
+
+	assert.Equal(t, 3, registry.calls)
+
+	// Schema 1 has been evicted
+	_, err = decoder.schemaCodec(1)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, registry.calls)
+}
+
+func TestSchemaRegistryAvroSchemas(t *testing.T) {
+	client, err := schemaregistry.NewClient(schemaregistry.NewConfig("mock://"))
+	require.NoError(t, err)
+
+	id, err := client.Register("events-value", schemaregistry.SchemaInfo{Schema: avroWriterSchema}, false)
+	require.NoError(t, err)
+
+	schemas := SchemaRegistryAvroSchemas{Client: client, Subject: "events-value"}
+
+	s, err := schemas.AvroSchema(id)
+	require.NoError(t, err)
+	assert.Equal(t, avroWriterSchema, s)
+
+	_, err = schemas.AvroSchema(id + 1)
+	assert.Error(t, err)
+}
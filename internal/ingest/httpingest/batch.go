@@ -0,0 +1,737 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/go-chi/render"
+
+	"github.com/openmeterio/openmeter/api"
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const (
+	// ContentTypeSingle is the content type of single CloudEvents in JSON format.
+	ContentTypeSingle = "application/cloudevents+json"
+
+	// ContentTypeBatch is the content type of CloudEvents batches in JSON format.
+	// See https://github.com/cloudevents/spec/blob/main/cloudevents/formats/json-format.md#4-json-batch-format
+	ContentTypeBatch = "application/cloudevents-batch+json"
+
+	// ContentTypeNDJSON is the content type of newline delimited CloudEvents streams.
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// Trailers reporting the outcome of a streamed batch.
+const (
+	TrailerEventsTotal     = "OpenMeter-Events-Total"
+	TrailerEventsSucceeded = "OpenMeter-Events-Succeeded"
+	TrailerEventsFailed    = "OpenMeter-Events-Failed"
+)
+
+const defaultMaxConcurrency = 10
+
+// EventResult is the outcome of processing a single event of a batch.
+type EventResult struct {
+	Index      int    `json:"index"`
+	ID         string `json:"id,omitempty"`
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+
+	// Event is the event as it would be forwarded to the {Collector}, in responses to dry-run requests only (see Handler.DryRun).
+	Event *event.Event `json:"event,omitempty"`
+}
+
+// BatchSummary summarizes the outcome of a streamed batch.
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+func (s *BatchSummary) add(result EventResult) {
+	s.Total++
+
+	if result.Error != "" {
+		s.Failed++
+	} else {
+		s.Succeeded++
+	}
+}
+
+// newEventResult returns the result of an event: the status of successful events is status (see successStatus).
+func newEventResult(index int, ev event.Event, status int, err error) EventResult {
+	result := EventResult{
+		Index:      index,
+		ID:         ev.ID(),
+		StatusCode: status,
+	}
+
+	if err != nil {
+		result.StatusCode = statusCode(err)
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+type batchResult struct {
+	index int
+	err   error
+}
+
+// processBatchRequest processes a batch of events in CloudEvents JSON batch format.
+//
+// Events are processed concurrently (bounded by MaxConcurrency), see processEvents.
+// The response is 200 (or 202, see AcceptedStatus) if every event has been forwarded to the {Collector},
+// otherwise 207 with the result of every event in the batch (see writeBatchResults).
+// Events rejected as invalid can be reported in a single 422 problem details document instead (see wantsBatchProblem).
+//
+// When the {Collector} is an ingest.Transactor, the batch is forwarded atomically: if any event fails, the batch is aborted
+// and the other events fail with 424. The Idempotency-Key header identifies the batch, retries of a committed batch respond 200.
+func (h Handler) processBatchRequest(w http.ResponseWriter, r *http.Request) {
+	events, ok := h.decodeBatchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.forwardBatch(w, r, events)
+}
+
+// decodeBatchRequest decodes and checks the batch of the request, bounded by the DecodeLimiter and BatchBudget.
+// The error response is written when the batch is rejected.
+func (h Handler) decodeBatchRequest(w http.ResponseWriter, r *http.Request) ([]event.Event, bool) {
+	logger := h.getLogger()
+
+	var events []event.Event
+
+	release, err := h.DecodeLimiter.acquire(r.Context())
+	if err != nil {
+		logger.WarnCtx(r.Context(), "unable to decode event batch", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	err = h.decodeEvents(json.NewDecoder(r.Body), &events)
+	release()
+
+	if err := checkBodySize(err); err != nil {
+		logger.DebugCtx(r.Context(), "event batch rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	if err := checkClientAbort(r.Context(), err); err != nil {
+		logger.DebugCtx(r.Context(), "event batch aborted", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	var budgetErr *BatchBudgetError
+	if errors.As(err, &budgetErr) {
+		logger.DebugCtx(r.Context(), "event batch rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "unable to parse event batch", "error", err)
+		h.metrics().RecordRejection(r.Context(), RejectionScopeRequest, RejectionInternal)
+
+		_ = render.Render(w, r, api.ErrInternalServerError(err))
+
+		return nil, false
+	}
+
+	if err := h.checkBodyRemainder(r.Body); err != nil {
+		logger.DebugCtx(r.Context(), "event batch rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	if err := h.checkBatchSize(len(events)); err != nil {
+		logger.DebugCtx(r.Context(), "event batch rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return nil, false
+	}
+
+	if h.SingleNamespaceBatch {
+		if err := h.checkSingleNamespace(r.Context(), events); err != nil {
+			logger.DebugCtx(r.Context(), "event batch rejected", "error", err)
+			h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+			renderError(w, r, err)
+
+			return nil, false
+		}
+	}
+
+	return events, true
+}
+
+// forwardBatch forwards the events of a decoded batch and writes the response.
+func (h Handler) forwardBatch(w http.ResponseWriter, r *http.Request, events []event.Event) {
+	logger := h.getLogger()
+
+	ctx, ack := ingest.WithAck(r.Context())
+	ctx = h.withReceipts(ctx)
+
+	if run, ok := dryRunFromContext(ctx); ok {
+		// Nothing is forwarded: dry-run batches are neither transactions nor reported to OnBatchComplete
+		writeDryRunResults(w, events, h.processEvents(ctx, events), run)
+
+		return
+	}
+
+	ctx, endBatch, err := h.beginBatch(ctx, r)
+	if isBatchCommitted(err) {
+		logger.DebugCtx(r.Context(), "event batch already committed")
+
+		h.writeReceipt(r.Context(), w, http.StatusOK, events...)
+
+		return
+	}
+
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "unable to begin event batch", "error", err)
+		h.metrics().RecordRejection(r.Context(), RejectionScopeRequest, RejectionCollector)
+
+		renderError(w, r, h.collectorError(err))
+
+		return
+	}
+
+	// Transactions and problem documents depend on every failure: only other batches are streamed
+	if endBatch == nil && !h.wantsBatchProblem(r) {
+		status := func() int { return h.successStatus(ack()) }
+
+		failures, streamed := h.streamEvents(ctx, w, events, status)
+		if h.OnBatchComplete != nil {
+			defer h.completeBatch(r.Context(), batchResults(events, failures, status()))
+		}
+
+		if !streamed {
+			h.writeReceipt(ctx, w, status(), events...)
+		}
+
+		return
+	}
+
+	failures := h.processEvents(ctx, events)
+	if endBatch != nil {
+		failures = h.endBatch(ctx, endBatch, events, failures)
+	}
+
+	status := h.successStatus(ack())
+
+	if h.OnBatchComplete != nil {
+		defer h.completeBatch(r.Context(), batchResults(events, failures, status))
+	}
+
+	if len(failures) == 0 {
+		h.writeReceipt(ctx, w, status, events...)
+
+		return
+	}
+
+	var wait time.Duration
+
+	for _, failure := range failures {
+		if d := retryAfter(failure.err); d > wait {
+			wait = d
+		}
+	}
+
+	setRetryAfter(w, wait)
+
+	setReceipt := h.receiptTrailer(ctx, w, events)
+	defer setReceipt(failures)
+
+	if h.wantsBatchProblem(r) && isValidationFailure(failures) {
+		writeBatchProblem(w, events, failures)
+
+		return
+	}
+
+	writeBatchResults(w, http.StatusMultiStatus, events, failures, status, nil)
+}
+
+// resultsFlushInterval is the number of results written between flushes of a streamed 207 response.
+const resultsFlushInterval = 1000
+
+// writeBatchResults streams the result of every event in the batch as a JSON array in a response with the code (207 unless dry-run).
+// The results of dry-run requests carry the events recorded by run.
+func writeBatchResults(w http.ResponseWriter, code int, events []event.Event, failures []batchResult, status int, run *dryRun) {
+	results := newBatchResultWriter(w, code, events, func() int { return status }, run)
+
+	for i := range events {
+		var err error
+
+		// Failures are sorted by index
+		if len(failures) > 0 && failures[0].index == i {
+			err = failures[0].err
+			failures = failures[1:]
+		}
+
+		results.write(err)
+	}
+
+	results.close()
+}
+
+// batchResultWriter writes the results of a batch as a JSON array, in batch order.
+//
+// Results are encoded one by one instead of building (and marshaling) the results of the whole batch,
+// so the memory used does not depend on the size of the batch.
+type batchResultWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	encoder *json.Encoder
+	events  []event.Event
+	status  func() int
+	run     *dryRun
+
+	// written is the number of results written (the index of the next result)
+	written int
+	gone    bool
+}
+
+// newBatchResultWriter writes the header of a response with the code and starts the array of results.
+// Successful results have the status returned by status when they are written.
+func newBatchResultWriter(w http.ResponseWriter, code int, events []event.Event, status func() int, run *dryRun) *batchResultWriter {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+
+	_, _ = io.WriteString(w, "[")
+
+	return &batchResultWriter{
+		w:       w,
+		flusher: flusher,
+		encoder: json.NewEncoder(w),
+		events:  events,
+		status:  status,
+		run:     run,
+	}
+}
+
+// write writes the result of the next event of the batch.
+func (b *batchResultWriter) write(err error) {
+	i := b.written
+	b.written++
+
+	if b.gone {
+		return
+	}
+
+	if i > 0 {
+		_, _ = io.WriteString(b.w, ",")
+	}
+
+	result := newEventResult(i, b.events[i], b.status(), err)
+	if b.run != nil && err == nil {
+		result.Event = b.run.event(i)
+	}
+
+	if b.encoder.Encode(result) != nil {
+		// The client is gone
+		b.gone = true
+
+		return
+	}
+
+	if b.flusher != nil && b.written%resultsFlushInterval == 0 {
+		b.flusher.Flush()
+	}
+}
+
+// close ends the array of results.
+func (b *batchResultWriter) close() {
+	if b.gone {
+		return
+	}
+
+	_, _ = io.WriteString(b.w, "]")
+}
+
+// streamEvents forwards events to the {Collector} (see forwardEvents) and streams the results of a 207 response as events complete,
+// in batch order: the response starts with the first failure, the results of later events are written as soon as the events before them complete.
+// Nothing is written when every event is forwarded (streamed is false), the caller responds.
+//
+// Retry-After is set from the failure starting the response: later failures are reported in their results only.
+func (h Handler) streamEvents(ctx context.Context, w http.ResponseWriter, events []event.Event, status func() int) (failures []batchResult, streamed bool) {
+	results := make(chan batchResult, h.maxConcurrency())
+	go h.forwardEvents(ctx, events, results)
+
+	var out *batchResultWriter
+	var setReceipt func(failures []batchResult)
+
+	// Results of events completed before some of the events before them
+	pending := make(map[int]error)
+	next := 0
+
+	for result := range results {
+		if result.err != nil {
+			failures = append(failures, result)
+
+			if out == nil {
+				setRetryAfter(w, retryAfter(result.err))
+				setReceipt = h.receiptTrailer(ctx, w, events)
+
+				out = newBatchResultWriter(w, http.StatusMultiStatus, events, status, nil)
+
+				// Events completed until now were forwarded
+				for out.written < next {
+					out.write(nil)
+				}
+			}
+		}
+
+		pending[result.index] = result.err
+
+		for {
+			err, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+			next++
+
+			if out != nil {
+				out.write(err)
+			}
+		}
+	}
+
+	if out == nil {
+		return nil, false
+	}
+
+	out.close()
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].index < failures[j].index
+	})
+
+	setReceipt(failures)
+
+	return failures, true
+}
+
+// processEvents forwards events to the {Collector} (see forwardEvents) and returns the failures, ordered by their index in the batch.
+//
+// Only failures are retained, so processing a successful batch does not allocate anything proportional to its size.
+func (h Handler) processEvents(ctx context.Context, events []event.Event) []batchResult {
+	results := make(chan batchResult, h.maxConcurrency())
+	go h.forwardEvents(ctx, events, results)
+
+	var failures []batchResult
+
+	for result := range results {
+		if result.err != nil {
+			failures = append(failures, result)
+		}
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].index < failures[j].index
+	})
+
+	return failures
+}
+
+// forwardEvents forwards events to the {Collector} using a bounded pool of workers,
+// sending the result of every event as it completes (in no particular order) and closing results once every event is processed.
+//
+// When OrderSubjectEvents is enabled, the events of each subject are forwarded sequentially, ordered by time.
+func (h Handler) forwardEvents(ctx context.Context, events []event.Event, results chan<- batchResult) {
+	defer close(results)
+
+	sem := make(chan struct{}, h.maxConcurrency())
+
+	var wg sync.WaitGroup
+
+	process := func(indexes ...int) {
+		sem <- struct{}{}
+
+		// Workers are acquired before starting goroutines, so the pool bounds goroutines across requests
+		release, err := h.Workers.acquire(ctx)
+		if err != nil {
+			<-sem
+
+			h.getLogger().DebugCtx(ctx, "events rejected", "error", err)
+
+			for _, i := range indexes {
+				h.recordRejection(ctx, RejectionScopeEvent, err)
+
+				results <- batchResult{index: i, err: err}
+			}
+
+			return
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() {
+				release()
+				<-sem
+			}()
+
+			for _, i := range indexes {
+				results <- batchResult{index: i, err: h.processEvent(withReceiptIndex(withDryRunIndex(ctx, i), i), events[i])}
+			}
+		}()
+	}
+
+	if h.OrderSubjectEvents {
+		for _, indexes := range subjectEventsByTime(events) {
+			process(indexes...)
+		}
+	} else {
+		for i := range events {
+			process(i)
+		}
+	}
+
+	wg.Wait()
+}
+
+// subjectEventsByTime groups the indexes of events by subject, ordered by event time.
+// The order of events with equal times (including events without a time, which come first) is preserved.
+func subjectEventsByTime(events []event.Event) [][]int {
+	var groups [][]int
+
+	subjects := make(map[string]int)
+
+	for i, ev := range events {
+		group, ok := subjects[ev.Subject()]
+		if !ok {
+			group = len(groups)
+			subjects[ev.Subject()] = group
+			groups = append(groups, nil)
+		}
+
+		groups[group] = append(groups[group], i)
+	}
+
+	for _, indexes := range groups {
+		indexes := indexes
+
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return events[indexes[i]].Time().Before(events[indexes[j]].Time())
+		})
+	}
+
+	return groups
+}
+
+// processStreamRequest processes a newline delimited stream of events.
+//
+// Events are processed in order as they are read from the request body
+// and the result of each event is written as a line of the response (once its window is forwarded, see StreamWindow).
+// Results are streamed back as soon as they are available when the connection allows writing the response
+// while the request body is still being read (see enableFullDuplex), otherwise they are written once the body is consumed.
+//
+// Clients advertising trailer support (TE: trailers) receive a summary of the batch in HTTP trailers.
+// Other clients receive the summary as the last line of the response instead.
+func (h Handler) processStreamRequest(w http.ResponseWriter, r *http.Request) {
+	logger := h.getLogger()
+
+	trailers := acceptsTrailers(r)
+	if trailers {
+		w.Header().Set("Trailer", strings.Join([]string{TrailerEventsTotal, TrailerEventsSucceeded, TrailerEventsFailed}, ", "))
+	}
+
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+
+	flusher, _ := w.(http.Flusher)
+	decoder := json.NewDecoder(r.Body)
+	encoder := json.NewEncoder(w)
+
+	streaming := enableFullDuplex(w, r)
+	if streaming {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	var summary BatchSummary
+	var pending []EventResult
+	var results []EventResult
+
+	if h.OnBatchComplete != nil {
+		defer func() {
+			h.completeBatch(r.Context(), results)
+		}()
+	}
+
+	// Results of windowed events are reported when their window is forwarded, concurrently with the stream
+	var mu sync.Mutex
+
+	report := func(result EventResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		summary.add(result)
+
+		if h.OnBatchComplete != nil {
+			results = append(results, result)
+		}
+
+		if streaming {
+			_ = encoder.Encode(result)
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		} else {
+			pending = append(pending, result)
+		}
+	}
+
+	window := h.newStreamWindow(report)
+
+	for index := 0; ; index++ {
+		var ev event.Event
+
+		err := h.decodeEvent(decoder, &ev)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if tooLarge := checkBodySize(err); tooLarge != nil {
+			logger.DebugCtx(r.Context(), "event stream aborted", "error", tooLarge)
+
+			err = tooLarge
+		} else if aborted := checkClientAbort(r.Context(), err); aborted != nil {
+			logger.DebugCtx(r.Context(), "event stream aborted", "error", aborted)
+
+			err = aborted
+		} else if err != nil {
+			logger.ErrorCtx(r.Context(), "unable to parse event", "error", err)
+
+			// The stream cannot be resynchronized after a decoding error
+			err = NewEventError(http.StatusBadRequest, err)
+		} else if err = h.checkBatchSize(index + 1); err != nil {
+			logger.DebugCtx(r.Context(), "event stream aborted", "error", err)
+		}
+
+		var result EventResult
+
+		if err != nil {
+			h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+			result = EventResult{
+				Index:      index,
+				StatusCode: statusCode(err),
+				Error:      err.Error(),
+			}
+		} else {
+			ctx, ack := ingest.WithAck(r.Context())
+			processErr := h.processPooledEvent(window.withEvent(ctx, index, ack), ev)
+			if errors.Is(processErr, errWindowed) {
+				continue
+			}
+
+			result = newEventResult(index, ev, h.successStatus(ack()), processErr)
+		}
+
+		report(result)
+
+		if err != nil {
+			break
+		}
+	}
+
+	window.close()
+
+	if !streaming {
+		w.WriteHeader(http.StatusOK)
+
+		for _, result := range pending {
+			_ = encoder.Encode(result)
+		}
+	}
+
+	if !trailers {
+		_ = encoder.Encode(summary)
+
+		return
+	}
+
+	w.Header().Set(TrailerEventsTotal, strconv.Itoa(summary.Total))
+	w.Header().Set(TrailerEventsSucceeded, strconv.Itoa(summary.Succeeded))
+	w.Header().Set(TrailerEventsFailed, strconv.Itoa(summary.Failed))
+}
+
+// enableFullDuplex reports whether the response can be written while the request body is still being read.
+//
+// HTTP/2 connections are always full duplex.
+// HTTP/1.x connections require the server to support enabling full duplex mode (Go 1.21 or later).
+func enableFullDuplex(w http.ResponseWriter, r *http.Request) bool {
+	if r.ProtoMajor >= 2 {
+		return true
+	}
+
+	for {
+		switch rw := w.(type) {
+		case interface{ EnableFullDuplex() error }:
+			return rw.EnableFullDuplex() == nil
+
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+
+		default:
+			return false
+		}
+	}
+}
+
+func acceptsTrailers(r *http.Request) bool {
+	for _, te := range r.Header.Values("TE") {
+		for _, value := range strings.Split(te, ",") {
+			if strings.EqualFold(strings.TrimSpace(value), "trailers") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (h Handler) maxConcurrency() int {
+	if h.MaxConcurrency > 0 {
+		return h.MaxConcurrency
+	}
+
+	return defaultMaxConcurrency
+}
@@ -0,0 +1,390 @@
+package httpingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type collectorFunc func(ev event.Event) error
+
+func (f collectorFunc) Receive(_ context.Context, ev event.Event) error {
+	return f(ev)
+}
+
+func newTestEvents(t testing.TB, n int) []event.Event {
+	t.Helper()
+
+	events := make([]event.Event, 0, n)
+
+	for i := 0; i < n; i++ {
+		ev := event.New()
+		ev.SetID(strconv.Itoa(i))
+		ev.SetType("api-calls")
+		ev.SetSubject("sub")
+		ev.SetSource("test")
+
+		events = append(events, ev)
+	}
+
+	return events
+}
+
+// eventIDs returns the IDs of the events.
+func eventIDs(events []event.Event) []string {
+	ids := make([]string, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID())
+	}
+
+	return ids
+}
+
+func TestHandler_Batch(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 3))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, collector.Events(), 3)
+}
+
+func TestHandler_BatchPartialFailure(t *testing.T) {
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			if ev.ID() == "1" {
+				return errors.New("downstream failure")
+			}
+
+			return nil
+		}),
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 3))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+
+	err = json.NewDecoder(w.Body).Decode(&results)
+	require.NoError(t, err)
+
+	assert.Equal(t, []EventResult{
+		{Index: 0, ID: "0", StatusCode: http.StatusOK},
+		{Index: 1, ID: "1", StatusCode: http.StatusInternalServerError, Error: "unable to forward event"},
+		{Index: 2, ID: "2", StatusCode: http.StatusOK},
+	}, results)
+}
+
+// startedResponseWriter records the response and closes started once the response header is written.
+type startedResponseWriter struct {
+	*httptest.ResponseRecorder
+
+	started chan struct{}
+}
+
+func (w *startedResponseWriter) WriteHeader(statusCode int) {
+	w.ResponseRecorder.WriteHeader(statusCode)
+	close(w.started)
+}
+
+func TestHandler_BatchStreamedResults(t *testing.T) {
+	w := &startedResponseWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		started:          make(chan struct{}),
+	}
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			switch ev.ID() {
+			case "0":
+				return errors.New("downstream failure")
+
+			case "1":
+				// Forwarded once the response started with the failure of the first event
+				select {
+				case <-w.started:
+					return nil
+
+				case <-time.After(5 * time.Second):
+					return errors.New("response not started")
+				}
+			}
+
+			return nil
+		}),
+		MaxConcurrency: 2,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 3))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+
+	err = json.NewDecoder(w.Body).Decode(&results)
+	require.NoError(t, err)
+
+	assert.Equal(t, []EventResult{
+		{Index: 0, ID: "0", StatusCode: http.StatusInternalServerError, Error: "unable to forward event"},
+		{Index: 1, ID: "1", StatusCode: http.StatusOK},
+		{Index: 2, ID: "2", StatusCode: http.StatusOK},
+	}, results)
+}
+
+func TestHandler_BatchOrderSubjectEvents(t *testing.T) {
+	var mu sync.Mutex
+	forwarded := make(map[string][]string)
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			forwarded[ev.Subject()] = append(forwarded[ev.Subject()], ev.ID())
+
+			return nil
+		}),
+		OrderSubjectEvents: true,
+	}
+
+	now := time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC)
+
+	events := newTestEvents(t, 6)
+	for i, offset := range []int{3, 0, 2, 1, 0, 1} {
+		events[i].SetTime(now.Add(time.Duration(offset) * time.Minute))
+	}
+
+	for i, subject := range []string{"a", "a", "b", "a", "a", "b"} {
+		events[i].SetSubject(subject)
+	}
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Events with equal times keep their order in the batch
+	assert.Equal(t, map[string][]string{
+		"a": {"1", "4", "3", "0"},
+		"b": {"5", "2"},
+	}, forwarded)
+}
+
+func newStreamBody(t *testing.T, events []event.Event) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, ev := range events {
+		require.NoError(t, encoder.Encode(ev))
+	}
+
+	return &buf
+}
+
+func TestHandler_StreamTrailers(t *testing.T) {
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			if ev.ID() == "2" {
+				return errors.New("downstream failure")
+			}
+
+			return nil
+		}),
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, newStreamBody(t, newTestEvents(t, 3)))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+	req.Header.Set("TE", "trailers")
+
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var results []EventResult
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result EventResult
+
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+
+		results = append(results, result)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, results, 3)
+	assert.Equal(t, http.StatusInternalServerError, results[2].StatusCode)
+
+	assert.Equal(t, "3", resp.Trailer.Get(TrailerEventsTotal))
+	assert.Equal(t, "2", resp.Trailer.Get(TrailerEventsSucceeded))
+	assert.Equal(t, "1", resp.Trailer.Get(TrailerEventsFailed))
+}
+
+func TestHandler_StreamWithoutTrailers(t *testing.T) {
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+	}
+
+	body := newStreamBody(t, newTestEvents(t, 2))
+	body.WriteString("{invalid\n")
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Trailer"))
+
+	decoder := json.NewDecoder(w.Body)
+
+	for i := 0; i < 3; i++ {
+		var result EventResult
+		require.NoError(t, decoder.Decode(&result))
+
+		assert.Equal(t, i, result.Index)
+	}
+
+	var summary BatchSummary
+	require.NoError(t, decoder.Decode(&summary))
+
+	assert.Equal(t, BatchSummary{Total: 3, Succeeded: 2, Failed: 1}, summary)
+
+	_, err := decoder.Token()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func BenchmarkHandler_Batch(b *testing.B) {
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			return nil
+		}),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	body, err := json.Marshal(newTestEvents(b, 10000))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			b.Fatalf("unexpected status code: %d", w.Code)
+		}
+	}
+}
+
+// discardResponseWriter discards the response, so benchmarks only measure the memory used by the handler.
+type discardResponseWriter struct {
+	header     http.Header
+	statusCode int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+func BenchmarkHandler_BatchPartialFailure(b *testing.B) {
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			if ev.ID() == "0" {
+				return errors.New("downstream failure")
+			}
+
+			return nil
+		}),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	body, err := json.Marshal(newTestEvents(b, 100000))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := &discardResponseWriter{}
+		handler.ServeHTTP(w, req)
+
+		if w.statusCode != http.StatusMultiStatus {
+			b.Fatalf("unexpected status code: %d", w.statusCode)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const defaultBatchBudgetEventSize = 1024
+
+// Dimensions of a BatchBudget, reported when a batch exceeds it.
+const (
+	BatchBudgetCount = "count"
+	BatchBudgetBytes = "bytes"
+)
+
+// BatchBudget bounds the memory used by decoding a batch, combining its event count and its size:
+// a batch is rejected with 413 as soon as either its event count times the estimated size of an event,
+// or the running total size of its events, exceeds the budget.
+//
+// Batches are checked while they are decoded, event by event, so they are rejected before being fully buffered.
+// Unlike MaxBatchSize (count only) and MaxBodySize (bytes only), many small events and a few large ones share a single budget.
+type BatchBudget struct {
+	// MaxBytes is the budget (in bytes).
+	MaxBytes int64
+
+	// EstimatedEventSize is the estimated memory size (in bytes) of a decoded event, regardless of its data. Defaults to 1024.
+	EstimatedEventSize int64
+}
+
+// BatchBudgetError is returned by batches exceeding a BatchBudget.
+type BatchBudgetError struct {
+	// Dimension is the exceeded dimension of the budget: BatchBudgetCount or BatchBudgetBytes.
+	Dimension string
+
+	// Events is the number of events decoded when the budget was exceeded.
+	Events int
+
+	// Bytes is the size of the events decoded when the budget was exceeded.
+	Bytes int64
+
+	MaxBytes int64
+}
+
+func (e *BatchBudgetError) Error() string {
+	if e.Dimension == BatchBudgetCount {
+		return fmt.Sprintf("batch exceeds the budget of %d bytes: %d events exceed the estimated %s budget", e.MaxBytes, e.Events, e.Dimension)
+	}
+
+	return fmt.Sprintf("batch exceeds the budget of %d bytes: %d bytes of events exceed the %s budget", e.MaxBytes, e.Bytes, e.Dimension)
+}
+
+// Validate validates the budget.
+func (b BatchBudget) Validate() error {
+	if b.MaxBytes <= 0 {
+		return errors.New("batch budget must be positive")
+	}
+
+	if b.EstimatedEventSize < 0 {
+		return errors.New("batch budget estimated event size must not be negative")
+	}
+
+	return nil
+}
+
+// check returns an error if a batch of the given count and size of events exceeds the budget.
+func (b *BatchBudget) check(count int, size int64) error {
+	eventSize := b.EstimatedEventSize
+	if eventSize == 0 {
+		eventSize = defaultBatchBudgetEventSize
+	}
+
+	dimension := ""
+
+	switch {
+	case int64(count)*eventSize > b.MaxBytes:
+		dimension = BatchBudgetCount
+	case size > b.MaxBytes:
+		dimension = BatchBudgetBytes
+	default:
+		return nil
+	}
+
+	return NewEventError(http.StatusRequestEntityTooLarge, &BatchBudgetError{
+		Dimension: dimension,
+		Events:    count,
+		Bytes:     size,
+		MaxBytes:  b.MaxBytes,
+	})
+}
+
+// decode decodes a JSON array of events one by one, checking the budget after every event.
+// checkAmbiguousData decodes events detecting ambiguous data (see RejectAmbiguousData).
+func (b *BatchBudget) decode(decoder *json.Decoder, events *[]event.Event, checkAmbiguousData bool) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		// null
+		return nil
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("invalid event batch: expected an array, got %v", token)
+	}
+
+	*events = []event.Event{}
+
+	var size int64
+
+	for decoder.More() {
+		start := decoder.InputOffset()
+
+		var ev event.Event
+
+		if checkAmbiguousData {
+			var checked dataCheckedEvent
+
+			err = decoder.Decode(&checked)
+			ev = checked.Event
+		} else {
+			err = decoder.Decode(&ev)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		*events = append(*events, ev)
+
+		size += decoder.InputOffset() - start
+
+		if err := b.check(len(*events), size); err != nil {
+			return err
+		}
+	}
+
+	// Closing bracket
+	_, err = decoder.Token()
+
+	return err
+}
@@ -0,0 +1,99 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_BatchBudget(t *testing.T) {
+	small := newTestEvents(t, 10)
+
+	large := newTestEvents(t, 2)
+	for i := range large {
+		require.NoError(t, large[i].SetData("application/json", map[string]string{"padding": strings.Repeat("x", 1000)}))
+	}
+
+	tests := []struct {
+		name   string
+		events interface{}
+
+		wantStatus    int
+		wantDimension string
+	}{
+		{name: "WithinBudget", events: small[:2], wantStatus: http.StatusOK},
+		{name: "Count", events: small, wantStatus: http.StatusRequestEntityTooLarge, wantDimension: BatchBudgetCount},
+		{name: "Bytes", events: large, wantStatus: http.StatusRequestEntityTooLarge, wantDimension: BatchBudgetBytes},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:   collector,
+				BatchBudget: &BatchBudget{MaxBytes: 1500, EstimatedEventSize: 500},
+			}
+
+			body, err := json.Marshal(test.events)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", ContentTypeBatch)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, test.wantStatus, w.Code)
+
+			if test.wantDimension != "" {
+				assert.Contains(t, w.Body.String(), " "+test.wantDimension+" budget")
+				assert.Equal(t, 0, collector.Len())
+			}
+		})
+	}
+}
+
+func TestBatchBudget_Decode(t *testing.T) {
+	budget := &BatchBudget{MaxBytes: 1 << 20}
+
+	tests := []struct {
+		name    string
+		body    string
+		events  int
+		wantErr bool
+	}{
+		{name: "Null", body: "null"},
+		{name: "Empty", body: "[]"},
+		{name: "Events", body: `[{"specversion":"1.0","id":"1","source":"test","type":"t"},{"specversion":"1.0","id":"2","source":"test","type":"t"}]`, events: 2},
+		{name: "Object", body: `{}`, wantErr: true},
+		{name: "Unterminated", body: `[{"specversion":"1.0","id":"1","source":"test","type":"t"}`, events: 1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			var events []event.Event
+
+			err := budget.decode(json.NewDecoder(strings.NewReader(test.body)), &events, false)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Len(t, events, test.events)
+		})
+	}
+}
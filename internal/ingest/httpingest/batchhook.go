@@ -0,0 +1,76 @@
+package httpingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// BatchCompleteFunc is called with the result of every event of a batch once the batch is processed
+// (eg. to emit a rollup event or notify a quota service).
+type BatchCompleteFunc func(ctx context.Context, results []EventResult) error
+
+// completeBatch calls the OnBatchComplete hook without blocking the response.
+// Errors (and panics) of the hook are logged.
+func (h Handler) completeBatch(ctx context.Context, results []EventResult) {
+	if h.OnBatchComplete == nil {
+		return
+	}
+
+	// The request context is canceled once the response is written
+	ctx = detachedContext{ctx}
+
+	go func() {
+		logger := h.getLogger()
+
+		defer func() {
+			if v := recover(); v != nil {
+				logger.ErrorCtx(ctx, "batch complete hook panicked", "panic", v)
+			}
+		}()
+
+		if err := h.OnBatchComplete(ctx, results); err != nil {
+			logger.ErrorCtx(ctx, "batch complete hook failed", "error", err)
+		}
+	}()
+}
+
+// batchResults returns the result of every event of a batch from the failures (ordered by index).
+func batchResults(events []event.Event, failures []batchResult, status int) []EventResult {
+	results := make([]EventResult, 0, len(events))
+
+	for i, ev := range events {
+		var err error
+
+		if len(failures) > 0 && failures[0].index == i {
+			err = failures[0].err
+			failures = failures[1:]
+		}
+
+		results = append(results, newEventResult(i, ev, status, err))
+	}
+
+	return results
+}
+
+// detachedContext carries the values of its parent but is never canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,64 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_OnBatchComplete(t *testing.T) {
+	completed := make(chan []EventResult, 1)
+
+	handler := Handler{
+		Collector: ingestCollectorFunc(func(_ context.Context, ev event.Event) error {
+			if ev.ID() == "2" {
+				return NewEventErrorf(http.StatusForbidden, "forbidden")
+			}
+
+			return nil
+		}),
+		OnBatchComplete: func(ctx context.Context, results []EventResult) error {
+			// The hook runs after the response is written
+			assert.NoError(t, ctx.Err())
+
+			completed <- results
+
+			return errors.New("quota service unavailable")
+		},
+	}
+
+	const body = `[
+		{"specversion":"1.0","id":"1","source":"test","type":"api-calls"},
+		{"specversion":"1.0","id":"2","source":"test","type":"api-calls"}
+	]`
+
+	for _, contentType := range []string{ContentTypeBatch, ContentTypeNDJSON} {
+		t.Run(contentType, func(t *testing.T) {
+			payload := body
+			if contentType == ContentTypeNDJSON {
+				payload = strings.ReplaceAll(strings.Trim(strings.TrimSpace(body), "[]"), "},", "}\n")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload)).WithContext(ctx)
+			req.Header.Set("Content-Type", contentType)
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			cancel()
+
+			results := <-completed
+
+			assert.Equal(t, []EventResult{
+				{Index: 0, ID: "1", StatusCode: http.StatusOK},
+				{Index: 1, ID: "2", StatusCode: http.StatusForbidden, Error: "forbidden"},
+			}, results)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package httpingest
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Content encodings of request bodies supported by the handler.
+const (
+	contentEncodingIdentity = "identity"
+	contentEncodingGzip     = "gzip"
+)
+
+// DefaultMaxDecodedBodySize is the maximum size (in bytes) of decompressed request bodies when MaxBodySize is not set.
+const DefaultMaxDecodedBodySize int64 = 32 << 20
+
+// gzipBody closes both the gzip reader and the compressed body.
+type gzipBody struct {
+	*gzip.Reader
+
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	return errors.Join(b.Reader.Close(), b.body.Close())
+}
+
+// decodeBody returns the decompressed request body.
+//
+// When MaxBodySize is set, both the compressed and the decompressed bodies are limited.
+// Decompressed bodies are limited to DefaultMaxDecodedBodySize otherwise,
+// so small compressed payloads cannot decompress to arbitrarily large bodies (decompression bombs).
+func (h Handler) decodeBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	body := r.Body
+	if h.MaxBodySize > 0 {
+		body = http.MaxBytesReader(w, body, h.MaxBodySize)
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", contentEncodingIdentity:
+		return body, nil
+
+	case contentEncodingGzip:
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			if err := checkBodySize(err); err != nil {
+				return nil, err
+			}
+
+			return nil, NewEventErrorf(http.StatusBadRequest, "invalid gzip body: %w", err)
+		}
+
+		return http.MaxBytesReader(w, gzipBody{Reader: reader, body: body}, h.maxDecodedBodySize()), nil
+
+	default:
+		w.Header().Set("Accept-Encoding", contentEncodingGzip)
+
+		return nil, NewEventErrorf(http.StatusUnsupportedMediaType, "unsupported content encoding: %s", encoding)
+	}
+}
+
+// maxDecodedBodySize returns the maximum size of decompressed request bodies.
+func (h Handler) maxDecodedBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+
+	return DefaultMaxDecodedBodySize
+}
+
+// checkBodyRemainder reads the rest of the request body after the decoded events when MaxBodySize is set,
+// so bodies exceeding the limit are rejected with 413 even when their events end within it (eg. trailing whitespace):
+// JSON decoders stop reading at the end of the value.
+func (h Handler) checkBodyRemainder(body io.Reader) error {
+	if h.MaxBodySize <= 0 {
+		return nil
+	}
+
+	_, err := io.Copy(io.Discard, body)
+
+	return checkBodySize(err)
+}
+
+// checkBodySize returns a 413 error if reading the request body failed because it exceeds MaxBodySize.
+func checkBodySize(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return NewEventErrorf(http.StatusRequestEntityTooLarge, "request body exceeds the maximum of %d bytes", maxBytesErr.Limit)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package httpingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return buf.Bytes()
+}
+
+func TestHandler_GzipBody(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector:   collector,
+		MaxBodySize: 1 << 20,
+	}
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("test")
+	ev.SetSubject("customer-1")
+
+	data, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipBytes(t, data)))
+	req.Header.Set("Content-Type", ContentTypeSingle)
+	req.Header.Set("Content-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, collector.Events(), 1)
+	assert.Equal(t, "customer-1", collector.Events()[0].Subject())
+}
+
+func TestHandler_GzipBomb(t *testing.T) {
+	// 64MB of whitespace compresses to a few dozen kilobytes
+	bomb := gzipBytes(t, append(bytes.Repeat([]byte(" "), 64<<20), []byte("[]")...))
+	require.Less(t, len(bomb), 1<<20)
+
+	for _, contentType := range []string{ContentTypeSingle, ContentTypeBatch, ContentTypeNDJSON} {
+		t.Run(contentType, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+
+			handler := Handler{
+				Collector:   collector,
+				MaxBodySize: 1 << 20,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bomb))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Content-Encoding", "gzip")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Contains(t, w.Body.String(), "request body exceeds the maximum of 1048576 bytes")
+			assert.Empty(t, collector.Events())
+
+			if contentType != ContentTypeNDJSON {
+				assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_GzipBomb_DefaultLimit(t *testing.T) {
+	bomb := gzipBytes(t, append(bytes.Repeat([]byte(" "), int(DefaultMaxDecodedBodySize)+1), []byte("{}")...))
+
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bomb))
+	req.Header.Set("Content-Type", ContentTypeSingle)
+	req.Header.Set("Content-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestHandler_ContentEncoding(t *testing.T) {
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		req.Header.Set("Content-Encoding", "br")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Accept-Encoding"))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_MaxBodySizeBoundary(t *testing.T) {
+	const limit = 1024
+
+	// body returns a request body of the content type with an event padded to the given size.
+	body := func(t *testing.T, contentType string, size int) []byte {
+		t.Helper()
+
+		ev := newTestEvents(t, 1)[0]
+
+		encode := func(padding int) []byte {
+			require.NoError(t, ev.SetData(event.ApplicationJSON, map[string]string{"padding": strings.Repeat("x", padding)}))
+
+			var data []byte
+			var err error
+
+			switch contentType {
+			case ContentTypeBatch:
+				data, err = json.Marshal([]event.Event{ev})
+			default:
+				data, err = json.Marshal(ev)
+			}
+			require.NoError(t, err)
+
+			if contentType == ContentTypeNDJSON {
+				data = append(data, '\n')
+			}
+
+			return data
+		}
+
+		data := encode(size - len(encode(0)))
+		require.Len(t, data, size)
+
+		return data
+	}
+
+	tests := []struct {
+		name string
+		body func(t *testing.T, contentType string) []byte
+		want int
+	}{
+		{
+			name: "AtLimit",
+			body: func(t *testing.T, contentType string) []byte { return body(t, contentType, limit) },
+			want: http.StatusOK,
+		},
+		{
+			name: "OneByteOver",
+			body: func(t *testing.T, contentType string) []byte { return body(t, contentType, limit+1) },
+			want: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "TrailingByteOver",
+			body: func(t *testing.T, contentType string) []byte { return append(body(t, contentType, limit), ' ') },
+			want: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, contentType := range []string{ContentTypeSingle, ContentTypeBatch, ContentTypeNDJSON} {
+		for _, encoding := range []string{"", "gzip"} {
+			for _, test := range tests {
+				contentType, encoding, test := contentType, encoding, test
+
+				t.Run(contentType+"/"+encoding+"/"+test.name, func(t *testing.T) {
+					collector := &testcollector.Collector{}
+					handler := Handler{
+						Collector:   collector,
+						MaxBodySize: limit,
+					}
+
+					data := test.body(t, contentType)
+					if encoding == "gzip" {
+						data = gzipBytes(t, data)
+					}
+
+					req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+					req.Header.Set("Content-Type", contentType)
+					req.Header.Set("Content-Encoding", encoding)
+
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, req)
+
+					if contentType == ContentTypeNDJSON && test.want != http.StatusOK {
+						// Streams report the event exceeding the limit in their results
+						assert.Contains(t, w.Body.String(), `"statusCode":413`)
+					} else {
+						assert.Equal(t, test.want, w.Code)
+					}
+
+					if test.want == http.StatusOK {
+						assert.Equal(t, 1, collector.Len())
+					} else if contentType != ContentTypeNDJSON {
+						assert.Equal(t, 0, collector.Len())
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package httpingest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Headers advertising the capabilities of the handler (see serveCapabilities).
+const (
+	// HeaderAcceptPost lists the content types accepted by the handler.
+	// See https://www.w3.org/TR/ldp/#header-accept-post
+	HeaderAcceptPost = "Accept-Post"
+
+	// HeaderMaxBatchSize is the maximum number of events in a batch (only set when batches are limited).
+	HeaderMaxBatchSize = "OpenMeter-Max-Batch-Size"
+)
+
+// supportedContentTypes are the content types accepted by the handler.
+var supportedContentTypes = []string{ContentTypeSingle, ContentTypeBatch, ContentTypeNDJSON}
+
+// serveCapabilities responds to capability probes (HEAD requests) without reading the request body.
+func (h Handler) serveCapabilities(w http.ResponseWriter) {
+	w.Header().Set("Allow", strings.Join([]string{http.MethodHead, http.MethodPost}, ", "))
+	w.Header().Set(HeaderAcceptPost, strings.Join(h.acceptedContentTypes(), ", "))
+
+	if h.MaxBatchSize > 0 {
+		w.Header().Set(HeaderMaxBatchSize, strconv.Itoa(h.MaxBatchSize))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// acceptedContentTypes returns the content types accepted by the handler.
+func (h Handler) acceptedContentTypes() []string {
+	if len(h.ContentTypes) > 0 {
+		return h.ContentTypes
+	}
+
+	contentTypes := supportedContentTypes
+
+	if h.Avro != nil {
+		contentTypes = append(contentTypes[:len(contentTypes):len(contentTypes)], ContentTypeAvro)
+	}
+
+	if h.MultipartPart != "" {
+		contentTypes = append(contentTypes[:len(contentTypes):len(contentTypes)], ContentTypeMultipart)
+	}
+
+	return contentTypes
+}
+
+// checkContentType rejects requests with a content type not in ContentTypes.
+func (h Handler) checkContentType(contentType string) error {
+	if len(h.ContentTypes) == 0 {
+		return nil
+	}
+
+	if contentType == "" {
+		contentType = ContentTypeSingle
+	}
+
+	for _, accepted := range h.ContentTypes {
+		if contentType == accepted {
+			return nil
+		}
+	}
+
+	return NewEventErrorf(http.StatusUnsupportedMediaType, "content type %s is not accepted at this route, expected one of: %s", contentType, strings.Join(h.ContentTypes, ", "))
+}
+
+// checkBatchSize rejects batches with more events than MaxBatchSize.
+func (h Handler) checkBatchSize(size int) error {
+	if h.MaxBatchSize > 0 && size > h.MaxBatchSize {
+		return NewEventErrorf(http.StatusRequestEntityTooLarge, "batch exceeds the maximum of %d events", h.MaxBatchSize)
+	}
+
+	return nil
+}
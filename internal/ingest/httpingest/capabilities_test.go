@@ -0,0 +1,124 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_Head(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:    collector,
+		MaxBatchSize: 100,
+	}
+
+	req := httptest.NewRequest(http.MethodHead, "/", nil)
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "HEAD, POST", resp.Header().Get("Allow"))
+	assert.Equal(t, "application/cloudevents+json, application/cloudevents-batch+json, application/x-ndjson", resp.Header().Get(HeaderAcceptPost))
+	assert.Equal(t, "100", resp.Header().Get(HeaderMaxBatchSize))
+	assert.Empty(t, resp.Body.Bytes())
+	assert.Empty(t, collector.Events())
+
+	// Unlimited batches are not advertised
+	resp = httptest.NewRecorder()
+	Handler{Collector: collector}.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Header(), HeaderMaxBatchSize)
+}
+
+func TestHandler_MaxBatchSize(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:    collector,
+		MaxBatchSize: 2,
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+ev+","+ev+","+ev+"]"))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code)
+	assert.Empty(t, collector.Events())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev+"\n"+ev+"\n"+ev+"\n"+ev+"\n"))
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+	require.Len(t, lines, 4, "three results and the summary")
+	assert.Contains(t, lines[2], `"statusCode":413`)
+	assert.Len(t, collector.Events(), 2)
+}
+
+func TestHandler_ContentTypes(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	single := Handler{
+		Collector:    collector,
+		ContentTypes: []string{ContentTypeSingle},
+	}
+
+	batch := Handler{
+		Collector:    collector,
+		ContentTypes: []string{ContentTypeBatch, ContentTypeNDJSON},
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	tests := []struct {
+		name        string
+		handler     Handler
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "Single", handler: single, contentType: ContentTypeSingle, body: ev, status: http.StatusOK},
+		{name: "SingleWithoutContentType", handler: single, body: ev, status: http.StatusOK},
+		{name: "SingleBatch", handler: single, contentType: ContentTypeBatch, body: "[" + ev + "]", status: http.StatusUnsupportedMediaType},
+		{name: "Batch", handler: batch, contentType: ContentTypeBatch, body: "[" + ev + "]", status: http.StatusOK},
+		{name: "BatchNDJSON", handler: batch, contentType: ContentTypeNDJSON, body: ev + "\n", status: http.StatusOK},
+		{name: "BatchSingle", handler: batch, contentType: ContentTypeSingle, body: ev, status: http.StatusUnsupportedMediaType},
+		{name: "BatchWithoutContentType", handler: batch, body: ev, status: http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+
+			resp := httptest.NewRecorder()
+			test.handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.status, resp.Code)
+		})
+	}
+
+	assert.Len(t, collector.Events(), 4)
+
+	// Only accepted content types are advertised
+	resp := httptest.NewRecorder()
+	batch.ServeHTTP(resp, httptest.NewRequest(http.MethodHead, "/", nil))
+
+	assert.Equal(t, "application/cloudevents-batch+json, application/x-ndjson", resp.Header().Get(HeaderAcceptPost))
+}
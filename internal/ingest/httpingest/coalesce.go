@@ -0,0 +1,240 @@
+package httpingest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// BatchCoalescer coalesces concurrent batch requests with the same idempotency key (see IdempotencyKeyHeader):
+// a request received while one with the same key is being processed waits for it, and gets the same response,
+// instead of forwarding the batch a second time.
+//
+// It is meant for clients retrying slow batches before the original request completes.
+// Requests reusing the key of an in-flight request with another body are rejected with 422, instead of getting its response.
+// Keys are only remembered while requests are in flight: retries after completion are processed again
+// (see ingest.Transactor for collectors forwarding a batch exactly once).
+// Requests are only coalesced within a single instance. The zero value is ready to use.
+type BatchCoalescer struct {
+	mu       sync.Mutex
+	inFlight map[coalesceKey]*coalescedRequest
+}
+
+// coalesceKey scopes idempotency keys to the endpoint of the handler (see endpoint.go).
+type coalesceKey struct {
+	endpoint string
+	key      string
+}
+
+type coalescedRequest struct {
+	done chan struct{}
+
+	// digest is the hash of the request body
+	digest [sha256.Size]byte
+
+	// Recorded response, set before done is closed
+	status  int
+	header  http.Header
+	body    []byte
+	trailer http.Header
+}
+
+// processCoalescedBatchRequest processes a batch request, coalescing it with the in-flight request with the same key (if any).
+//
+// The batch is decoded first, within the bounds of the DecodeLimiter and BatchBudget, and hashed as it is read,
+// so coalesced requests are the same batch.
+func (h Handler) processCoalescedBatchRequest(w http.ResponseWriter, r *http.Request) {
+	if h.BatchCoalescer == nil || r.Header.Get(IdempotencyKeyHeader) == "" {
+		h.processBatchRequest(w, r)
+
+		return
+	}
+
+	body := &digestReader{ReadCloser: r.Body, hash: sha256.New()}
+	r.Body = body
+
+	events, ok := h.decodeBatchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Data following the batch is hashed too (it is limited by MaxBodySize, if any, like the batch)
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		if sizeErr := checkBodySize(err); sizeErr != nil {
+			err = sizeErr
+		} else {
+			err = NewEventErrorf(http.StatusBadRequest, "read request body: %w", err)
+		}
+
+		h.getLogger().DebugCtx(r.Context(), "event batch rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	var digest [sha256.Size]byte
+	copy(digest[:], body.hash.Sum(nil))
+
+	// Replayed responses identify the request they answer
+	var exclude []string
+	if h.RequestID != nil {
+		exclude = append(exclude, h.RequestID.responseHeader())
+	}
+
+	h.BatchCoalescer.coalesce(w, r, h.Endpoint, digest, exclude, func(w http.ResponseWriter) {
+		h.forwardBatch(w, r, events)
+	})
+}
+
+// coalesce serves the request with serve, or with the response of the in-flight request with the same key and body digest.
+// Headers named in exclude are not replayed.
+func (c *BatchCoalescer) coalesce(w http.ResponseWriter, r *http.Request, endpoint string, digest [sha256.Size]byte, exclude []string, serve func(w http.ResponseWriter)) {
+	key := coalesceKey{endpoint: endpoint, key: r.Header.Get(IdempotencyKeyHeader)}
+
+	c.mu.Lock()
+
+	if req, ok := c.inFlight[key]; ok {
+		c.mu.Unlock()
+
+		if req.digest != digest {
+			renderError(w, r, NewEventErrorf(http.StatusUnprocessableEntity, "idempotency key is already used by an in-flight request with another body"))
+
+			return
+		}
+
+		select {
+		case <-req.done:
+			req.replay(w, exclude)
+		case <-r.Context().Done():
+			// The client is gone
+		}
+
+		return
+	}
+
+	if c.inFlight == nil {
+		c.inFlight = make(map[coalesceKey]*coalescedRequest)
+	}
+
+	req := &coalescedRequest{done: make(chan struct{}), digest: digest}
+	c.inFlight[key] = req
+
+	c.mu.Unlock()
+
+	recorder := &recordingResponseWriter{ResponseWriter: w}
+
+	defer func() {
+		req.status = recorder.status
+		if req.status == 0 {
+			req.status = http.StatusOK
+		}
+
+		req.header = recorder.header
+		if req.header == nil {
+			req.header = w.Header().Clone()
+		}
+
+		req.body = recorder.body.Bytes()
+		req.trailer = recorder.trailer()
+
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		c.mu.Unlock()
+
+		close(req.done)
+	}()
+
+	serve(recorder)
+}
+
+// replay writes the recorded response, except the headers named in exclude.
+func (req *coalescedRequest) replay(w http.ResponseWriter, exclude []string) {
+	recorded := req.header.Clone()
+	for _, name := range exclude {
+		recorded.Del(name)
+	}
+
+	for name, values := range recorded {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(req.status)
+	_, _ = w.Write(req.body)
+
+	// Trailers declared in the recorded header are sent once the body is written
+	for name, values := range req.trailer {
+		w.Header()[name] = values
+	}
+}
+
+// digestReader hashes the request body as it is read.
+type digestReader struct {
+	io.ReadCloser
+
+	hash hash.Hash
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	_, _ = r.hash.Write(p[:n])
+
+	return n, err
+}
+
+// recordingResponseWriter records the response written to the underlying writer.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(p)
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// trailer returns the values of the trailers declared in the recorded header, once the response is written.
+func (w *recordingResponseWriter) trailer() http.Header {
+	trailer := make(http.Header)
+
+	for _, declared := range w.header.Values("Trailer") {
+		for _, name := range strings.Split(declared, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+
+			if values, ok := w.ResponseWriter.Header()[name]; ok {
+				trailer[name] = values
+			}
+		}
+	}
+
+	return trailer
+}
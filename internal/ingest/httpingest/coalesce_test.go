@@ -0,0 +1,252 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_BatchCoalescer(t *testing.T) {
+	var received atomic.Int32
+	release := make(chan struct{})
+
+	coalescer := &BatchCoalescer{}
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			received.Add(1)
+			<-release
+
+			if ev.ID() == "1" {
+				return NewEventErrorf(http.StatusBadRequest, "invalid value")
+			}
+
+			return nil
+		}),
+		BatchCoalescer: coalescer,
+		RequestID:      &RequestIDConfig{},
+		Receipts:       newTestReceiptSigner(t),
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 3))
+	require.NoError(t, err)
+
+	// read counts the requests whose body was read, before they are coalesced
+	var read atomic.Int32
+
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", &eofReader{Reader: bytes.NewReader(body), eof: func() { read.Add(1) }})
+		req.Header.Set("Content-Type", ContentTypeBatch)
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	const retries = 5
+
+	responses := make([]*httptest.ResponseRecorder, retries+1)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		responses[0] = send("batch-1")
+	}()
+
+	// The original request is being processed
+	require.Eventually(t, func() bool { return received.Load() == 3 }, time.Second, time.Millisecond)
+
+	for i := 1; i <= retries; i++ {
+		i := i
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			responses[i] = send("batch-1")
+		}()
+	}
+
+	require.Eventually(t, func() bool { return read.Load() == retries+1 }, time.Second, time.Millisecond)
+
+	// Retries are waiting for the original request once their body is read
+	time.Sleep(50 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	// The batch is processed once
+	assert.Equal(t, int32(3), received.Load())
+
+	requestIDs := make(map[string]struct{})
+
+	for _, w := range responses {
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, responses[0].Body.String(), w.Body.String())
+
+		// Trailers are replayed, request IDs are not
+		assert.NotEmpty(t, w.Result().Trailer.Get(TrailerReceipt))
+		assert.Equal(t, responses[0].Result().Trailer.Get(TrailerReceipt), w.Result().Trailer.Get(TrailerReceipt))
+
+		requestIDs[w.Header().Get(defaultRequestIDHeader)] = struct{}{}
+	}
+
+	assert.Len(t, requestIDs, retries+1)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(responses[0].Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+
+	// Keys are forgotten once the request completes
+	assert.Equal(t, http.StatusMultiStatus, send("batch-1").Code)
+	assert.Equal(t, int32(6), received.Load())
+
+	assert.Empty(t, coalescer.inFlight)
+}
+
+// eofReader calls eof once the reader is consumed.
+type eofReader struct {
+	io.Reader
+
+	eof  func()
+	once sync.Once
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.once.Do(r.eof)
+	}
+
+	return n, err
+}
+
+func TestHandler_BatchCoalescerBodyMismatch(t *testing.T) {
+	var received atomic.Int32
+	release := make(chan struct{})
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			received.Add(1)
+			<-release
+
+			return nil
+		}),
+		BatchCoalescer: &BatchCoalescer{},
+	}
+
+	events := newTestEvents(t, 2)
+
+	send := func(events []event.Event) *httptest.ResponseRecorder {
+		body, err := json.Marshal(events)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+		req.Header.Set(IdempotencyKeyHeader, "batch-1")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+
+	go func() {
+		done <- send(events)
+	}()
+
+	require.Eventually(t, func() bool { return received.Load() == 2 }, time.Second, time.Millisecond)
+
+	// Another batch with the key of the in-flight batch is not answered with its response
+	assert.Equal(t, http.StatusUnprocessableEntity, send(events[:1]).Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+
+	assert.Equal(t, int32(2), received.Load())
+}
+
+func TestHandler_BatchCoalescerDistinctKeys(t *testing.T) {
+	var received atomic.Int32
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			received.Add(1)
+
+			return nil
+		}),
+		BatchCoalescer: &BatchCoalescer{},
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 2))
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "", "batch-1", "batch-2"} {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, int32(8), received.Load())
+}
+
+func TestHandler_BatchCoalescerDecodeLimiter(t *testing.T) {
+	limiter, err := NewDecodeLimiter(DecodeLimiterConfig{MaxConcurrent: 1})
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector:      collectorFunc(func(ev event.Event) error { return nil }),
+		BatchCoalescer: &BatchCoalescer{},
+		DecodeLimiter:  limiter,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 2))
+	require.NoError(t, err)
+
+	// Occupy the only decode slot
+	release, err := limiter.acquire(context.Background())
+	require.NoError(t, err)
+
+	// read reports whether the body of the request was read
+	var read atomic.Bool
+
+	req := httptest.NewRequest(http.MethodPost, "/", &eofReader{Reader: bytes.NewReader(body), eof: func() { read.Store(true) }})
+	req.Header.Set("Content-Type", ContentTypeBatch)
+	req.Header.Set(IdempotencyKeyHeader, "batch-1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// Requests with an idempotency key are not buffered before they get a decode slot
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.False(t, read.Load())
+
+	release()
+}
@@ -0,0 +1,148 @@
+package httpingest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// DefaultContentHashExtension is the default extension the content hash of events is set in.
+const DefaultContentHashExtension = "contenthash"
+
+// ContentHashAlgorithm is the hash algorithm of content hashes.
+type ContentHashAlgorithm string
+
+// Supported content hash algorithms.
+const (
+	ContentHashSHA256 ContentHashAlgorithm = "sha256"
+	ContentHashSHA512 ContentHashAlgorithm = "sha512"
+)
+
+// ContentHashScope is the part of events content hashes are computed from.
+type ContentHashScope string
+
+const (
+	// ContentHashEvent hashes every attribute of events (except the ID and the content hash itself) and their data.
+	// Retries of an event under a new ID have the same hash.
+	ContentHashEvent ContentHashScope = "event"
+
+	// ContentHashData hashes the data of events only.
+	ContentHashData ContentHashScope = "data"
+)
+
+// ContentHashConfig configures stamping events with a stable hash of their content,
+// so downstream systems can deduplicate events by content without parsing them.
+type ContentHashConfig struct {
+	// Extension is the extension the hash (hex encoded) is set in. Defaults to DefaultContentHashExtension.
+	Extension string
+
+	// Algorithm defaults to ContentHashSHA256.
+	Algorithm ContentHashAlgorithm
+
+	// Scope defaults to ContentHashEvent.
+	Scope ContentHashScope
+}
+
+// Validate validates the configuration.
+func (c ContentHashConfig) Validate() error {
+	if c.Extension != "" && !event.IsExtensionNameValid(c.Extension) {
+		return errors.New("invalid content hash extension name")
+	}
+
+	switch c.Algorithm {
+	case "", ContentHashSHA256, ContentHashSHA512:
+	default:
+		return fmt.Errorf("invalid content hash algorithm: %s", c.Algorithm)
+	}
+
+	switch c.Scope {
+	case "", ContentHashEvent, ContentHashData:
+	default:
+		return fmt.Errorf("invalid content hash scope: %s", c.Scope)
+	}
+
+	return nil
+}
+
+func (c *ContentHashConfig) extension() string {
+	if c.Extension == "" {
+		return DefaultContentHashExtension
+	}
+
+	return c.Extension
+}
+
+func (c *ContentHashConfig) newHash() hash.Hash {
+	if c.Algorithm == ContentHashSHA512 {
+		return sha512.New()
+	}
+
+	return sha256.New()
+}
+
+// hash returns the hex encoded content hash of the event.
+//
+// Event hashes are computed from a canonical encoding of the event (length prefixed attributes, extensions sorted by name),
+// so they do not depend on the JSON representation of the event sent by the client.
+func (c *ContentHashConfig) hash(ev event.Event) (string, error) {
+	h := c.newHash()
+
+	if c.Scope == ContentHashData {
+		h.Write(ev.Data())
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var eventTime string
+	if !ev.Time().IsZero() {
+		eventTime = ev.Time().UTC().Format(time.RFC3339Nano)
+	}
+
+	for _, value := range []string{ev.SpecVersion(), ev.Type(), ev.Source(), ev.Subject(), eventTime, ev.DataContentType(), ev.DataSchema()} {
+		writeHashField(h, value)
+	}
+
+	extension := c.extension()
+	names := make([]string, 0, len(ev.Extensions()))
+
+	for name := range ev.Extensions() {
+		if name != extension {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, err := types.ToString(ev.Extensions()[name])
+		if err != nil {
+			return "", fmt.Errorf("extension %s: %w", name, err)
+		}
+
+		writeHashField(h, name)
+		writeHashField(h, value)
+	}
+
+	writeHashField(h, string(ev.Data()))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeHashField writes a length prefixed field, so field boundaries are part of the hash.
+func writeHashField(h hash.Hash, value string) {
+	var length [8]byte
+
+	binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+
+	h.Write(length[:])
+	h.Write([]byte(value))
+}
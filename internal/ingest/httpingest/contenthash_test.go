@@ -0,0 +1,83 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestContentHashConfig_Hash(t *testing.T) {
+	newEvent := func(id string) event.Event {
+		ev := event.New()
+		ev.SetID(id)
+		ev.SetSource("test")
+		ev.SetType("api-calls")
+		ev.SetSubject("customer-1")
+		ev.SetTime(time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC))
+		ev.SetExtension("tier", "gold")
+		ev.SetExtension("region", "eu")
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(`{"a":1}`)))
+
+		return ev
+	}
+
+	tests := []struct {
+		config   ContentHashConfig
+		expected string
+	}{
+		{
+			config:   ContentHashConfig{},
+			expected: "1eb9e1a30720757e4314b26af106e38c01fdbfc7e8aaaf17580ca24dc0f05c64",
+		},
+		{
+			config:   ContentHashConfig{Scope: ContentHashData},
+			expected: "015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862",
+		},
+		{
+			config:   ContentHashConfig{Scope: ContentHashData, Algorithm: ContentHashSHA512},
+			expected: "efb7a8298f905ae743dbe2152e162415f62a16d2d5ac5c78816dcd57114e7a574729b813988f1d0984cf6f38c4fcc9a37ea9fec3da351983536f72785d7ab707",
+		},
+	}
+
+	for _, test := range tests {
+		hash, err := test.config.hash(newEvent("1"))
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, hash)
+
+		// The ID and the hash itself are not part of the content
+		ev := newEvent("2")
+		ev.SetExtension(DefaultContentHashExtension, "previous")
+
+		hash, err = test.config.hash(ev)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, hash)
+	}
+
+	assert.Error(t, ContentHashConfig{Algorithm: "md5"}.Validate())
+	assert.Error(t, ContentHashConfig{Scope: "headers"}.Validate())
+}
+
+func TestHandler_ContentHash(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:   collector,
+		ContentHash: &ContentHashConfig{Scope: ContentHashData},
+	}
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("test")
+	ev.SetType("api-calls")
+	require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(`{"a":1}`)))
+
+	require.NoError(t, handler.processEvent(context.Background(), ev))
+
+	require.Len(t, collector.Events(), 1)
+	assert.Equal(t, "015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862", collector.Events()[0].Extensions()[DefaultContentHashExtension])
+}
@@ -0,0 +1,66 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+type contextCollectorFunc func(ctx context.Context, ev event.Event) error
+
+func (f contextCollectorFunc) Receive(ctx context.Context, ev event.Event) error {
+	return f(ctx, ev)
+}
+
+func TestHandler_RequestContext(t *testing.T) {
+	type requestValues struct {
+		namespace string
+		principal string
+		requestID string
+	}
+
+	var received []requestValues
+	var extensions []map[string]interface{}
+
+	handler := Handler{
+		Collector: contextCollectorFunc(func(ctx context.Context, ev event.Event) error {
+			var values requestValues
+
+			values.namespace, _ = ingest.NamespaceFromContext(ctx)
+			values.principal, _ = ingest.PrincipalFromContext(ctx)
+			values.requestID, _ = ingest.RequestIDFromContext(ctx)
+
+			received = append(received, values)
+			extensions = append(extensions, ev.Extensions())
+
+			return nil
+		}),
+		RequestID: &RequestIDConfig{},
+		Identity: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls","namespace":"acme"}`))
+	req.Header.Set("Content-Type", ContentTypeSingle)
+	req.Header.Set("X-User", "user-1")
+	req.Header.Set("X-Request-ID", "request-1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, []requestValues{{namespace: "acme", principal: "user-1", requestID: "request-1"}}, received)
+
+	// Only the request ID extension is set by the handler
+	assert.Equal(t, []map[string]interface{}{{"namespace": "acme", RequestIDExtension: "request-1"}}, extensions)
+}
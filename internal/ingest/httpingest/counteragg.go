@@ -0,0 +1,629 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/pkg/models"
+)
+
+const (
+	// DefaultAggregatedCountExtension is the default extension the number of raw events of an aggregated event is set in.
+	DefaultAggregatedCountExtension = "aggregatedcount"
+
+	defaultAggregationWindow        = time.Minute
+	defaultAggregationFlushInterval = time.Second
+	defaultAggregationSource        = "openmeter-aggregator"
+)
+
+var defaultAggregationKeyExtensions = []string{"namespace"}
+
+// CounterAggregatorConfig configures a CounterAggregator.
+type CounterAggregatorConfig struct {
+	// Collector receives aggregated events, and events of other types.
+	Collector Collector
+
+	// Meters are the meters events are ingested for.
+	Meters []*models.Meter
+
+	// Aggregate are the IDs of the meters whose events are aggregated.
+	// They must be SUM meters, like every other meter of the same event type.
+	Aggregate []string
+
+	// Window is the duration events are aggregated over, aligned to the epoch. Defaults to 1m.
+	// It must divide a minute, so aggregated events are attributed to the same meter windows as the raw events.
+	Window time.Duration
+
+	// FlushDelay delays forwarding aggregated events after the end of their window, to aggregate late events (optional).
+	// Events later than that are aggregated into another event of the same window.
+	FlushDelay time.Duration
+
+	// FlushInterval is how often ended windows are forwarded. Defaults to 1s.
+	FlushInterval time.Duration
+
+	// MaxRollups is the maximum number of aggregated events held in memory (optional), including the ones waiting to be retried.
+	// Every aggregated event is forwarded early when the limit is reached.
+	// Events that would start another aggregated event are rejected with ingest.ErrBufferFull while the limit is still reached.
+	MaxRollups int
+
+	// KeyExtensions are the extensions of events aggregated separately, and kept in aggregated events. Defaults to namespace.
+	// Other extensions are dropped.
+	KeyExtensions []string
+
+	// Archive receives every raw event of aggregated types before it is aggregated (optional), eg. to archive them.
+	Archive Collector
+
+	// Source is the source of aggregated events. Defaults to openmeter-aggregator.
+	Source string
+
+	// CountExtension is the extension the number of raw events of an aggregated event is set in.
+	// Defaults to DefaultAggregatedCountExtension.
+	CountExtension string
+
+	// LogMaskPolicy controls how the subjects of aggregated events appear in log records.
+	LogMaskPolicy LogMaskPolicy
+
+	Logger *slog.Logger
+}
+
+// CounterAggregator aggregates the events of counter (SUM) meters in memory, and forwards an aggregated event
+// per subject, type, window (and group by values) to the {Collector} instead of every raw event,
+// cutting downstream volume of high frequency counters.
+//
+// Aggregated events have the time of the start of their window, the sum of the values of every meter of their type,
+// and the group by values of the raw events in their data. Raw events are held in memory until their window is forwarded:
+// they are acknowledged as enqueued (see ingest.AckEnqueued), and lost if the process stops without closing the aggregator.
+// Aggregated events that cannot be forwarded are retried at the next flush, as they are and with the same ID,
+// so downstream deduplication discards retries of aggregated events that were forwarded after all.
+//
+// Events of other types are forwarded as they are.
+type CounterAggregator struct {
+	collector      Collector
+	archive        Collector
+	types          map[string]*aggregatedType
+	window         time.Duration
+	flushDelay     time.Duration
+	maxRollups     int
+	keyExtensions  []string
+	source         string
+	countExtension string
+	logMask        LogMaskPolicy
+	logger         *slog.Logger
+	now            func() time.Time
+
+	mu      sync.Mutex
+	rollups map[string]*rollup
+	retries []*rollup
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	_ ingest.Collector = (*CounterAggregator)(nil)
+	_ ingest.Flusher   = (*CounterAggregator)(nil)
+)
+
+// aggregatedType are the value and group by properties of the meters of an aggregated type.
+type aggregatedType struct {
+	values  []aggregatedProperty
+	groupBy []aggregatedProperty
+}
+
+type aggregatedProperty struct {
+	path string
+	json jsonPath
+}
+
+// rollup is an aggregated event being accumulated.
+type rollup struct {
+	id         string
+	typ        string
+	subject    string
+	start      time.Time
+	groupBy    []interface{}
+	extensions map[string]interface{}
+	values     []float64
+	count      int
+}
+
+// NewCounterAggregator returns a new CounterAggregator and starts forwarding aggregated events in the background.
+func NewCounterAggregator(config CounterAggregatorConfig) (*CounterAggregator, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if len(config.Aggregate) == 0 {
+		return nil, errors.New("at least one meter to aggregate is required")
+	}
+
+	types, err := aggregatedTypes(config.Meters, config.Aggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Window < 0 || config.FlushDelay < 0 || config.FlushInterval < 0 || config.MaxRollups < 0 {
+		return nil, errors.New("aggregation window, flush delay, flush interval and max rollups must not be negative")
+	}
+
+	if err := config.LogMaskPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("log mask policy: %w", err)
+	}
+
+	window := config.Window
+	if window == 0 {
+		window = defaultAggregationWindow
+	}
+
+	if window < time.Millisecond || time.Minute%window != 0 {
+		return nil, fmt.Errorf("aggregation window must divide a minute: %s", window)
+	}
+
+	flushInterval := config.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = defaultAggregationFlushInterval
+	}
+
+	keyExtensions := config.KeyExtensions
+	if keyExtensions == nil {
+		keyExtensions = defaultAggregationKeyExtensions
+	}
+
+	countExtension := config.CountExtension
+	if countExtension == "" {
+		countExtension = DefaultAggregatedCountExtension
+	}
+
+	for _, name := range append([]string{countExtension}, keyExtensions...) {
+		if !event.IsExtensionNameValid(name) {
+			return nil, fmt.Errorf("invalid extension name: %q", name)
+		}
+	}
+
+	source := config.Source
+	if source == "" {
+		source = defaultAggregationSource
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	a := &CounterAggregator{
+		collector:      config.Collector,
+		archive:        config.Archive,
+		types:          types,
+		window:         window,
+		flushDelay:     config.FlushDelay,
+		maxRollups:     config.MaxRollups,
+		keyExtensions:  keyExtensions,
+		source:         source,
+		countExtension: countExtension,
+		logMask:        config.LogMaskPolicy,
+		logger:         logger,
+		now:            time.Now,
+		rollups:        make(map[string]*rollup),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	go a.run(flushInterval)
+
+	return a, nil
+}
+
+// aggregatedTypes returns the properties of the types of the aggregated meters.
+func aggregatedTypes(meters []*models.Meter, aggregate []string) (map[string]*aggregatedType, error) {
+	byID := make(map[string]*models.Meter, len(meters))
+	for _, meter := range meters {
+		byID[meter.ID] = meter
+	}
+
+	aggregated := make(map[string]bool)
+
+	for _, id := range aggregate {
+		meter, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown meter to aggregate: %s", id)
+		}
+
+		aggregated[meter.Type] = true
+	}
+
+	types := make(map[string]*aggregatedType)
+
+	for _, meter := range meters {
+		if !aggregated[meter.Type] {
+			continue
+		}
+
+		// Other meters of the type would be computed from aggregated events too
+		if meter.Aggregation != models.MeterAggregationSum {
+			return nil, fmt.Errorf("events of type %s cannot be aggregated: meter %s is not a SUM meter", meter.Type, meter.ID)
+		}
+
+		typ, ok := types[meter.Type]
+		if !ok {
+			typ = &aggregatedType{}
+			types[meter.Type] = typ
+		}
+
+		var err error
+
+		typ.values, err = appendAggregatedProperty(typ.values, meter.ValueProperty)
+		if err != nil {
+			return nil, fmt.Errorf("meter %s: %w", meter.ID, err)
+		}
+
+		for _, groupBy := range meter.GroupBy {
+			typ.groupBy, err = appendAggregatedProperty(typ.groupBy, groupBy)
+			if err != nil {
+				return nil, fmt.Errorf("meter %s: %w", meter.ID, err)
+			}
+		}
+	}
+
+	return types, nil
+}
+
+// appendAggregatedProperty appends the property unless it is already in the list.
+// Properties are set in the data of aggregated events: array indexes are not supported.
+func appendAggregatedProperty(properties []aggregatedProperty, path string) ([]aggregatedProperty, error) {
+	for _, property := range properties {
+		if property.path == path {
+			return properties, nil
+		}
+	}
+
+	parsed, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("invalid json path %q: a member is required", path)
+	}
+
+	for _, segment := range parsed {
+		if !segment.isKey {
+			return nil, fmt.Errorf("invalid json path %q: array indexes cannot be aggregated", path)
+		}
+	}
+
+	return append(properties, aggregatedProperty{path: path, json: parsed}), nil
+}
+
+// Receive aggregates events of aggregated types, and forwards other events.
+func (a *CounterAggregator) Receive(ctx context.Context, ev event.Event) error {
+	typ, ok := a.types[ev.Type()]
+	if !ok {
+		return a.collector.Receive(ctx, ev)
+	}
+
+	values := make([]float64, 0, len(typ.values))
+
+	for _, property := range typ.values {
+		value, err := property.json.lookupNumber(ev.Data())
+		if err != nil {
+			return fmt.Errorf("%w: value property %s: %s", ingest.ErrInvalidEvent, property.path, err)
+		}
+
+		values = append(values, value)
+	}
+
+	groupBy := make([]interface{}, 0, len(typ.groupBy))
+
+	for _, property := range typ.groupBy {
+		value, err := property.json.lookup(ev.Data())
+		if errors.Is(err, errJSONPathNotFound) {
+			value, err = nil, nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: group by property %s: %s", ingest.ErrInvalidEvent, property.path, err)
+		}
+
+		groupBy = append(groupBy, value)
+	}
+
+	extensions := make(map[string]interface{}, len(a.keyExtensions))
+
+	for _, name := range a.keyExtensions {
+		if value, ok := ev.Extensions()[name]; ok {
+			extensions[name] = value
+		}
+	}
+
+	if a.archive != nil {
+		if err := a.archive.Receive(ctx, ev); err != nil {
+			return fmt.Errorf("archive raw event: %w", err)
+		}
+	}
+
+	key, err := a.key(ev, groupBy, extensions)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ingest.ErrInvalidEvent, err)
+	}
+
+	start := ev.Time().UTC().Truncate(a.window)
+
+	a.mu.Lock()
+
+	if a.closed {
+		a.mu.Unlock()
+
+		return ingest.ErrCollectorClosed
+	}
+
+	r, ok := a.rollups[key]
+	if !ok {
+		if a.maxRollups > 0 && a.pending() >= a.maxRollups {
+			// The flush forwarding every aggregated event failed, they are retried in the background
+			a.mu.Unlock()
+
+			return ingest.ErrBufferFull
+		}
+
+		r = &rollup{
+			id:         rollupID(key, ev),
+			typ:        ev.Type(),
+			subject:    ev.Subject(),
+			start:      start,
+			groupBy:    groupBy,
+			extensions: extensions,
+			values:     make([]float64, len(values)),
+		}
+
+		a.rollups[key] = r
+	}
+
+	for i, value := range values {
+		r.values[i] += value
+	}
+
+	r.count++
+
+	full := a.maxRollups > 0 && a.pending() >= a.maxRollups
+
+	a.mu.Unlock()
+
+	ingest.MarkEnqueued(ctx)
+
+	if full {
+		a.flush(true)
+	}
+
+	return nil
+}
+
+// key returns the aggregation key of an event.
+func (a *CounterAggregator) key(ev event.Event, groupBy []interface{}, extensions map[string]interface{}) (string, error) {
+	formatted := make(map[string]string, len(extensions))
+
+	for name, value := range extensions {
+		s, err := types.Format(value)
+		if err != nil {
+			return "", fmt.Errorf("extension %s: %w", name, err)
+		}
+
+		formatted[name] = s
+	}
+
+	key, err := json.Marshal([]interface{}{
+		ev.Type(),
+		ev.Subject(),
+		ev.Time().UTC().Truncate(a.window).UnixMilli(),
+		groupBy,
+		formatted,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// rollupID returns the ID of the aggregated event started by an event: the same aggregated event always has the same ID.
+// Several aggregated events of a key (and window) are forwarded when events are late or the limit of rollups is reached,
+// the ID of the event starting the aggregated event tells them apart.
+func rollupID(key string, ev event.Event) string {
+	return uuid.NewSHA1(uuid.Nil, []byte(key+"\x00"+ev.Source()+"\x00"+ev.ID())).String()
+}
+
+func (a *CounterAggregator) run(flushInterval time.Duration) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(false)
+
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// flush forwards aggregated events whose window (and flush delay) has ended, or every aggregated event.
+// Aggregated events that cannot be forwarded are kept for the next flush.
+// It returns the number of aggregated events forwarded and the number that could not be.
+func (a *CounterAggregator) flush(all bool) (forwarded int, failed int) {
+	cutoff := a.now().Add(-a.flushDelay)
+
+	a.mu.Lock()
+
+	ended := a.retries
+	a.retries = nil
+
+	for key, r := range a.rollups {
+		if all || !r.start.Add(a.window).After(cutoff) {
+			ended = append(ended, r)
+			delete(a.rollups, key)
+		}
+	}
+
+	a.mu.Unlock()
+
+	// Older windows are forwarded first
+	sort.Slice(ended, func(i, j int) bool {
+		return ended[i].start.Before(ended[j].start)
+	})
+
+	for _, r := range ended {
+		if err := a.forward(r); err != nil {
+			a.logger.Error("unable to forward aggregated event", slog.String("type", r.typ), slog.String("subject", a.logMask.mask("subject", r.subject)), slog.Any("error", err))
+
+			a.retry(r)
+			failed++
+
+			continue
+		}
+
+		forwarded++
+	}
+
+	return forwarded, failed
+}
+
+// retry keeps an aggregated event that could not be forwarded for the next flush.
+// It is not merged with new events, as it may have been forwarded after all (eg. on timeouts).
+func (a *CounterAggregator) retry(r *rollup) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.retries = append(a.retries, r)
+}
+
+func (a *CounterAggregator) forward(r *rollup) error {
+	ev, err := a.event(r)
+	if err != nil {
+		return err
+	}
+
+	return a.collector.Receive(context.Background(), ev)
+}
+
+// event returns the aggregated event of a rollup.
+func (a *CounterAggregator) event(r *rollup) (event.Event, error) {
+	typ := a.types[r.typ]
+
+	data := make(map[string]interface{})
+
+	for i, property := range typ.groupBy {
+		if r.groupBy[i] != nil {
+			setJSONPath(data, property.json, r.groupBy[i])
+		}
+	}
+
+	for i, property := range typ.values {
+		setJSONPath(data, property.json, r.values[i])
+	}
+
+	ev := event.New()
+	ev.SetID(r.id)
+	ev.SetSource(a.source)
+	ev.SetType(r.typ)
+	ev.SetTime(r.start)
+
+	if r.subject != "" {
+		ev.SetSubject(r.subject)
+	}
+
+	for name, value := range r.extensions {
+		ev.SetExtension(name, value)
+	}
+
+	ev.SetExtension(a.countExtension, r.count)
+
+	if err := ev.SetData(event.ApplicationJSON, data); err != nil {
+		return event.Event{}, fmt.Errorf("encode aggregated data: %w", err)
+	}
+
+	return ev, nil
+}
+
+// setJSONPath sets the value referenced by a path of members in a JSON object, creating intermediate objects.
+func setJSONPath(object map[string]interface{}, path jsonPath, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		child, ok := object[segment.key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			object[segment.key] = child
+		}
+
+		object = child
+	}
+
+	object[path[len(path)-1].key] = value
+}
+
+// Pending returns the number of aggregated events waiting to be forwarded.
+func (a *CounterAggregator) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.pending()
+}
+
+func (a *CounterAggregator) pending() int {
+	return len(a.rollups) + len(a.retries)
+}
+
+// Flush forwards every aggregated event, regardless of its window, then flushes the {Collector} if it buffers events.
+// It returns the number of aggregated events forwarded, and the events flushed by the collector.
+// Aggregated events that cannot be forwarded are retried at the next flush.
+func (a *CounterAggregator) Flush(ctx context.Context) (int, error) {
+	// Events aggregated concurrently are not counted: they are forwarded by a later flush
+	flushed, failed := a.flush(true)
+	if failed > 0 {
+		return flushed, fmt.Errorf("unable to forward %d aggregated events", failed)
+	}
+
+	if flusher, ok := a.collector.(ingest.Flusher); ok {
+		n, err := flusher.Flush(ctx)
+
+		return flushed + n, err
+	}
+
+	return flushed, nil
+}
+
+// Close stops accepting events and forwards every aggregated event, regardless of its window.
+func (a *CounterAggregator) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.stop)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	a.flush(true)
+
+	if pending := a.Pending(); pending > 0 {
+		return fmt.Errorf("unable to forward %d aggregated events", pending)
+	}
+
+	return nil
+}
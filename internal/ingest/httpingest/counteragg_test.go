@@ -0,0 +1,408 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+	"github.com/openmeterio/openmeter/pkg/models"
+)
+
+var testAggregatedMeters = []*models.Meter{
+	{
+		ID:            "tokens",
+		Type:          "prompt",
+		Aggregation:   models.MeterAggregationSum,
+		ValueProperty: "$.tokens",
+		GroupBy:       []string{"$.model"},
+	},
+	{
+		ID:            "duration",
+		Type:          "prompt",
+		Aggregation:   models.MeterAggregationSum,
+		ValueProperty: "$.usage.duration_ms",
+	},
+	{
+		ID:          "api_calls",
+		Type:        "api-calls",
+		Aggregation: models.MeterAggregationCount,
+	},
+}
+
+func newTestAggregator(t *testing.T, config CounterAggregatorConfig) *CounterAggregator {
+	t.Helper()
+
+	if config.Meters == nil {
+		config.Meters = testAggregatedMeters
+	}
+
+	if config.Aggregate == nil {
+		config.Aggregate = []string{"tokens"}
+	}
+
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Hour
+	}
+
+	aggregator, err := NewCounterAggregator(config)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = aggregator.Close(context.Background())
+	})
+
+	return aggregator
+}
+
+func newPromptEvent(t *testing.T, id string, at time.Time, data string) event.Event {
+	t.Helper()
+
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetSource("test")
+	ev.SetType("prompt")
+	ev.SetSubject("sub")
+	ev.SetTime(at)
+	ev.SetExtension("namespace", "default")
+	require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+	return ev
+}
+
+func TestCounterAggregator(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator.now = func() time.Time { return start.Add(90 * time.Second) }
+
+	ctx, ack := ingest.WithAck(context.Background())
+
+	require.NoError(t, aggregator.Receive(ctx, newPromptEvent(t, "1", start.Add(time.Second), `{"tokens": 10, "model": "a", "usage": {"duration_ms": 100}}`)))
+	require.NoError(t, aggregator.Receive(ctx, newPromptEvent(t, "2", start.Add(2*time.Second), `{"tokens": "5", "model": "a", "usage": {"duration_ms": 50}}`)))
+	require.NoError(t, aggregator.Receive(ctx, newPromptEvent(t, "3", start.Add(3*time.Second), `{"tokens": 1, "model": "b", "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(ctx, newPromptEvent(t, "4", start.Add(61*time.Second), `{"tokens": 1, "model": "a", "usage": {"duration_ms": 1}}`)))
+
+	assert.Equal(t, ingest.AckEnqueued, ack())
+
+	// Events of other types are forwarded as they are
+	require.NoError(t, aggregator.Receive(ctx, newTestEvents(t, 1)[0]))
+	testcollector.AssertReceived(t, downstream, "0")
+
+	assert.Equal(t, 3, aggregator.Pending())
+
+	// Only the ended window is forwarded
+	aggregator.flush(false)
+
+	require.Equal(t, 3, downstream.Len())
+	assert.Equal(t, 1, aggregator.Pending())
+
+	var data []string
+
+	for _, ev := range downstream.Events()[1:] {
+		assert.Equal(t, "prompt", ev.Type())
+		assert.Equal(t, "sub", ev.Subject())
+		assert.Equal(t, defaultAggregationSource, ev.Source())
+		assert.Equal(t, start, ev.Time())
+		assert.Equal(t, "default", ev.Extensions()["namespace"])
+
+		data = append(data, string(ev.Data()))
+	}
+
+	assert.ElementsMatch(t, []string{
+		`{"model":"a","tokens":15,"usage":{"duration_ms":150}}`,
+		`{"model":"b","tokens":1,"usage":{"duration_ms":1}}`,
+	}, data)
+
+	require.NoError(t, aggregator.Close(context.Background()))
+
+	require.Equal(t, 4, downstream.Len())
+	assert.Equal(t, start.Add(time.Minute), downstream.Events()[3].Time())
+	assert.Equal(t, int32(1), downstream.Events()[3].Extensions()[DefaultAggregatedCountExtension])
+
+	err := aggregator.Receive(ctx, newPromptEvent(t, "5", start, `{"tokens": 1, "usage": {"duration_ms": 1}}`))
+	assert.ErrorIs(t, err, ingest.ErrCollectorClosed)
+}
+
+func TestCounterAggregator_InvalidEvent(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	err := aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": "many", "usage": {"duration_ms": 1}}`))
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	err = aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1}`))
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	assert.Equal(t, 0, aggregator.Pending())
+}
+
+func TestCounterAggregator_Archive(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	archive := &testcollector.Collector{}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream, Archive: archive})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+
+	testcollector.AssertReceived(t, archive, "1", "2")
+
+	archive.Err = errors.New("archive failure")
+
+	err := aggregator.Receive(context.Background(), newPromptEvent(t, "3", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`))
+	assert.Error(t, err)
+
+	require.NoError(t, aggregator.Close(context.Background()))
+
+	require.Equal(t, 1, downstream.Len())
+	assert.Equal(t, int32(2), downstream.Events()[0].Extensions()[DefaultAggregatedCountExtension])
+}
+
+func TestCounterAggregator_MaxRollups(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream, MaxRollups: 2})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "model": "a", "usage": {"duration_ms": 1}}`)))
+	assert.Equal(t, 0, downstream.Len())
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "model": "b", "usage": {"duration_ms": 1}}`)))
+	assert.Equal(t, 2, downstream.Len())
+	assert.Equal(t, 0, aggregator.Pending())
+}
+
+func TestCounterAggregator_MaxRollups_Full(t *testing.T) {
+	downstream := &testcollector.Collector{Err: errors.New("downstream failure")}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream, MaxRollups: 2})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "model": "a", "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "model": "b", "usage": {"duration_ms": 1}}`)))
+	assert.Equal(t, 2, aggregator.Pending())
+
+	// The aggregated events could not be forwarded early
+	err := aggregator.Receive(context.Background(), newPromptEvent(t, "3", time.Now(), `{"tokens": 1, "model": "c", "usage": {"duration_ms": 1}}`))
+	assert.ErrorIs(t, err, ingest.ErrBufferFull)
+	assert.Equal(t, 2, aggregator.Pending())
+
+	downstream.Err = nil
+	aggregator.flush(false)
+
+	assert.Equal(t, 2, downstream.Len())
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "3", time.Now(), `{"tokens": 1, "model": "c", "usage": {"duration_ms": 1}}`)))
+}
+
+func TestCounterAggregator_Retry(t *testing.T) {
+	downstream := &testcollector.Collector{Err: errors.New("downstream failure")}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	now := time.Now()
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", now, `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+
+	aggregator.flush(true)
+	assert.Equal(t, 1, aggregator.Pending())
+
+	// Failed aggregated events are retried as they are, new events of the same window are aggregated separately
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", now, `{"tokens": 2, "usage": {"duration_ms": 1}}`)))
+	assert.Equal(t, 2, aggregator.Pending())
+
+	downstream.Err = nil
+
+	require.NoError(t, aggregator.Close(context.Background()))
+
+	require.Equal(t, 2, downstream.Len())
+
+	var data []string
+
+	for _, ev := range downstream.Events() {
+		data = append(data, string(ev.Data()))
+	}
+
+	assert.ElementsMatch(t, []string{`{"tokens":1,"usage":{"duration_ms":1}}`, `{"tokens":2,"usage":{"duration_ms":1}}`}, data)
+	assert.NotEqual(t, downstream.Events()[0].ID(), downstream.Events()[1].ID())
+}
+
+func TestCounterAggregator_LogMaskPolicy(t *testing.T) {
+	var logs bytes.Buffer
+
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{
+		Collector:     &testcollector.Collector{Err: errors.New("downstream failure")},
+		LogMaskPolicy: LogMaskPolicy{"subject": {Mode: MaskModeRedact}},
+		Logger:        slog.New(slog.NewJSONHandler(&logs, nil)),
+	})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+
+	aggregator.flush(true)
+
+	assert.Contains(t, logs.String(), "unable to forward aggregated event")
+	assert.Contains(t, logs.String(), `"subject":"[REDACTED]"`)
+
+	_, err := NewCounterAggregator(CounterAggregatorConfig{
+		Collector:     &testcollector.Collector{},
+		Meters:        testAggregatedMeters,
+		Aggregate:     []string{"tokens"},
+		LogMaskPolicy: LogMaskPolicy{"subjct": {Mode: MaskModeRedact}},
+	})
+	assert.Error(t, err)
+}
+
+func TestCounterAggregator_RetryID(t *testing.T) {
+	var ids []string
+
+	failures := 1
+	downstream := &testcollector.Collector{
+		ErrFunc: func(ev event.Event) error {
+			ids = append(ids, ev.ID())
+
+			if failures > 0 {
+				failures--
+
+				// The event may have been forwarded anyway
+				return context.DeadlineExceeded
+			}
+
+			return nil
+		},
+	}
+
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+
+	aggregator.flush(true)
+	aggregator.flush(true)
+
+	require.Len(t, ids, 2)
+	assert.Equal(t, ids[0], ids[1], "retries have the same ID")
+	assert.Equal(t, 0, aggregator.Pending())
+}
+
+func TestCounterAggregator_Flush(t *testing.T) {
+	downstream := &testcollector.Collector{}
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "model": "a", "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "model": "b", "usage": {"duration_ms": 1}}`)))
+
+	flushed, err := aggregator.Flush(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, flushed)
+	assert.Equal(t, 2, downstream.Len())
+	assert.Equal(t, 0, aggregator.Pending())
+}
+
+func TestCounterAggregator_FlushConcurrentEvents(t *testing.T) {
+	var aggregator *CounterAggregator
+
+	received := false
+	downstream := &testcollector.Collector{
+		ErrFunc: func(ev event.Event) error {
+			// An event is aggregated while the flush is forwarding
+			if !received {
+				received = true
+
+				return aggregator.Receive(context.Background(), newPromptEvent(t, "3", time.Now(), `{"tokens": 1, "model": "c", "usage": {"duration_ms": 1}}`))
+			}
+
+			return nil
+		},
+	}
+
+	aggregator = newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now(), `{"tokens": 1, "model": "a", "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "model": "b", "usage": {"duration_ms": 1}}`)))
+
+	flushed, err := aggregator.Flush(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, flushed)
+	assert.Equal(t, 1, aggregator.Pending())
+}
+
+func TestCounterAggregator_FlushFailure(t *testing.T) {
+	downstream := &testcollector.Collector{
+		ErrFunc: func(ev event.Event) error {
+			if ev.Time().Before(time.Now().Add(-time.Hour)) {
+				return errors.New("unavailable")
+			}
+
+			return nil
+		},
+	}
+
+	aggregator := newTestAggregator(t, CounterAggregatorConfig{Collector: downstream})
+
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "1", time.Now().Add(-2*time.Hour), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+	require.NoError(t, aggregator.Receive(context.Background(), newPromptEvent(t, "2", time.Now(), `{"tokens": 1, "usage": {"duration_ms": 1}}`)))
+
+	flushed, err := aggregator.Flush(context.Background())
+	assert.EqualError(t, err, "unable to forward 1 aggregated events")
+
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, 1, aggregator.Pending())
+}
+
+func TestNewCounterAggregator(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CounterAggregatorConfig
+		wantErr string
+	}{
+		{
+			name:    "UnknownMeter",
+			config:  CounterAggregatorConfig{Aggregate: []string{"unknown"}},
+			wantErr: "unknown meter to aggregate: unknown",
+		},
+		{
+			name:    "CountMeter",
+			config:  CounterAggregatorConfig{Aggregate: []string{"api_calls"}},
+			wantErr: "events of type api-calls cannot be aggregated: meter api_calls is not a SUM meter",
+		},
+		{
+			name: "ArrayIndex",
+			config: CounterAggregatorConfig{
+				Meters: []*models.Meter{
+					{ID: "m", Type: "t", Aggregation: models.MeterAggregationSum, ValueProperty: "$.values[0]"},
+				},
+				Aggregate: []string{"m"},
+			},
+			wantErr: `meter m: invalid json path "$.values[0]": array indexes cannot be aggregated`,
+		},
+		{
+			name:    "Window",
+			config:  CounterAggregatorConfig{Window: 7 * time.Second},
+			wantErr: "aggregation window must divide a minute: 7s",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config
+			config.Collector = &testcollector.Collector{}
+
+			if config.Meters == nil {
+				config.Meters = testAggregatedMeters
+			}
+
+			if config.Aggregate == nil {
+				config.Aggregate = []string{"tokens"}
+			}
+
+			_, err := NewCounterAggregator(config)
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
+	cuejson "cuelang.org/go/encoding/json"
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// CUEValidatorConfig configures a CUEValidator.
+type CUEValidatorConfig struct {
+	// Schemas are the CUE schemas of the data of event types (type → CUE source).
+	Schemas map[string]string
+
+	// RejectUnknownTypes rejects events of types without a schema. They are accepted otherwise.
+	RejectUnknownTypes bool
+}
+
+// CUEValidator rejects events whose data does not conform to the CUE schema of their type,
+// for teams defining data contracts in CUE rather than JSON Schema.
+//
+// Schemas are compiled once, when the validator is created. Event data must be JSON.
+type CUEValidator struct {
+	rejectUnknownTypes bool
+
+	// CUE values are not safe for concurrent use
+	mu      sync.Mutex
+	schemas map[string]cue.Value
+}
+
+// NewCUEValidator returns a new CUEValidator.
+func NewCUEValidator(config CUEValidatorConfig) (*CUEValidator, error) {
+	if len(config.Schemas) == 0 {
+		return nil, errors.New("at least one cue schema is required")
+	}
+
+	ctx := cuecontext.New()
+
+	schemas := make(map[string]cue.Value, len(config.Schemas))
+
+	for typ, src := range config.Schemas {
+		schema := ctx.CompileString(src, cue.Filename(typ+".cue"))
+		if err := schema.Err(); err != nil {
+			return nil, fmt.Errorf("compile cue schema of type %s: %w", typ, err)
+		}
+
+		schemas[typ] = schema
+	}
+
+	return &CUEValidator{
+		rejectUnknownTypes: config.RejectUnknownTypes,
+		schemas:            schemas,
+	}, nil
+}
+
+// Validate validates the data of the event against the CUE schema of its type.
+func (v *CUEValidator) Validate(ev event.Event) error {
+	schema, ok := v.schemas[ev.Type()]
+	if !ok {
+		if v.rejectUnknownTypes {
+			return NewEventErrorf(http.StatusBadRequest, "no cue schema for event type %s", ev.Type())
+		}
+
+		return nil
+	}
+
+	data := ev.Data()
+	if len(data) == 0 {
+		return NewEventErrorf(http.StatusBadRequest, "event data is required by the cue schema of type %s", ev.Type())
+	}
+
+	expr, err := cuejson.Extract(ev.Type(), data)
+	if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "event data is not valid JSON: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Missing fields are incomplete (not concrete) values
+	value := schema.Unify(schema.Context().BuildExpr(expr))
+	if err := value.Validate(cue.Concrete(true), cue.All()); err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "event data does not conform to the cue schema of type %s: %s", ev.Type(), cueErrorDetails(err))
+	}
+
+	return nil
+}
+
+// cueErrorDetails returns the messages of every CUE error, prefixed with the path of the invalid value.
+func cueErrorDetails(err error) string {
+	var details []string
+
+	for _, e := range cueerrors.Errors(err) {
+		format, args := e.Msg()
+		msg := fmt.Sprintf(format, args...)
+
+		if path := strings.Join(e.Path(), "."); path != "" {
+			msg = path + ": " + msg
+		}
+
+		details = append(details, msg)
+	}
+
+	if len(details) == 0 {
+		return err.Error()
+	}
+
+	return strings.Join(details, "; ")
+}
@@ -0,0 +1,101 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+const apiCallsCUESchema = `
+duration_ms: number & >=0
+method:      "GET" | "POST"
+path?:       string
+`
+
+func TestCUEValidator(t *testing.T) {
+	validator, err := NewCUEValidator(CUEValidatorConfig{
+		Schemas: map[string]string{"api-calls": apiCallsCUESchema},
+	})
+	require.NoError(t, err)
+
+	newDataEvent := func(typ string, data string) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetType(typ)
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+		return ev
+	}
+
+	assert.NoError(t, validator.Validate(newDataEvent("api-calls", `{"duration_ms": 12, "method": "GET"}`)))
+
+	// Unknown types are accepted
+	assert.NoError(t, validator.Validate(newDataEvent("other", `{}`)))
+
+	err = validator.Validate(newDataEvent("api-calls", `{"duration_ms": -1, "method": "PUT"}`))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+	assert.Contains(t, err.Error(), "duration_ms")
+	assert.Contains(t, err.Error(), "method")
+
+	err = validator.Validate(newDataEvent("api-calls", `{"method": "GET"}`))
+	assert.ErrorContains(t, err, "duration_ms")
+
+	validator, err = NewCUEValidator(CUEValidatorConfig{
+		Schemas:            map[string]string{"api-calls": apiCallsCUESchema},
+		RejectUnknownTypes: true,
+	})
+	require.NoError(t, err)
+
+	assert.Error(t, validator.Validate(newDataEvent("other", `{}`)))
+
+	_, err = NewCUEValidator(CUEValidatorConfig{Schemas: map[string]string{"api-calls": "duration_ms: number &"}})
+	assert.Error(t, err)
+}
+
+func TestHandler_CUEBatch(t *testing.T) {
+	validator, err := NewCUEValidator(CUEValidatorConfig{
+		Schemas: map[string]string{"api-calls": apiCallsCUESchema},
+	})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector: collector,
+		CUE:       validator,
+	}
+
+	events := newTestEvents(t, 2)
+	require.NoError(t, events[0].SetData(event.ApplicationJSON, []byte(`{"duration_ms": 12, "method": "GET"}`)))
+	require.NoError(t, events[1].SetData(event.ApplicationJSON, []byte(`{"duration_ms": "12", "method": "GET"}`)))
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+	assert.Contains(t, results[1].Error, "duration_ms")
+
+	assert.Equal(t, 1, collector.Len())
+}
@@ -0,0 +1,37 @@
+package httpingest
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkDataEncoding rejects events whose data is not encoded as their data content type requires (422):
+// binary data (application/octet-stream) must be sent base64 encoded in data_base64,
+// text data (text/*) as a string in data.
+//
+// The SDK decodes both fields into the same data: without this check, binary data sent in data
+// (or text sent in data_base64) is accepted and only noticed mis-encoded by consumers.
+// Events with other (or no) data content types, or without data, are not validated.
+func checkDataEncoding(ev event.Event) error {
+	if len(ev.Data()) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ev.DataContentType())
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/octet-stream" && !ev.DataBase64:
+		return NewEventErrorf(http.StatusUnprocessableEntity, "event %s: binary data (%s) must be base64 encoded in data_base64, not data", ev.ID(), mediaType)
+
+	case strings.HasPrefix(mediaType, "text/") && !isJSONMediaType(mediaType) && ev.DataBase64:
+		return NewEventErrorf(http.StatusUnprocessableEntity, "event %s: text data (%s) must be sent in data, not data_base64", ev.ID(), mediaType)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_ValidateDataEncoding(t *testing.T) {
+	const base = `"specversion":"1.0","source":"test","type":"api-calls"`
+
+	events := []string{
+		`{` + base + `,"id":"binary-base64","datacontenttype":"application/octet-stream","data_base64":"AAEC"}`,
+		`{` + base + `,"id":"binary-data","datacontenttype":"application/octet-stream","data":"AAEC"}`,
+		`{` + base + `,"id":"text-data","datacontenttype":"text/plain","data":"hello"}`,
+		`{` + base + `,"id":"text-base64","datacontenttype":"text/plain; charset=utf-8","data_base64":"aGVsbG8="}`,
+		`{` + base + `,"id":"json-data","datacontenttype":"application/json","data":{"a":1}}`,
+		`{` + base + `,"id":"no-data","datacontenttype":"application/octet-stream"}`,
+	}
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:            collector,
+		ValidateDataEncoding: true,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+strings.Join(events, ",")+"]"))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, len(events))
+
+	statuses := make(map[string]int, len(results))
+	for _, result := range results {
+		statuses[result.ID] = result.StatusCode
+	}
+
+	assert.Equal(t, map[string]int{
+		"binary-base64": http.StatusOK,
+		"binary-data":   http.StatusUnprocessableEntity,
+		"text-data":     http.StatusOK,
+		"text-base64":   http.StatusUnprocessableEntity,
+		"json-data":     http.StatusOK,
+		"no-data":       http.StatusOK,
+	}, statuses)
+
+	assert.Contains(t, results[1].Error, "event binary-data: binary data (application/octet-stream) must be base64 encoded in data_base64")
+
+	assert.ElementsMatch(t, []string{"binary-base64", "text-data", "json-data", "no-data"}, collector.IDs())
+}
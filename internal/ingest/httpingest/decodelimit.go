@@ -0,0 +1,84 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DecodeLimiterConfig configures a DecodeLimiter.
+type DecodeLimiterConfig struct {
+	// MaxConcurrent is the maximum number of batch requests decoded at the same time.
+	MaxConcurrent int
+
+	// Wait makes requests wait for a decode slot instead of being rejected immediately.
+	// Waiting stops when the request context is done or after MaxWait.
+	Wait bool
+
+	// MaxWait is the maximum duration a request waits for a decode slot when Wait is enabled.
+	// Zero means waiting until the request context is done.
+	MaxWait time.Duration
+}
+
+// DecodeLimiter bounds the number of batch requests decoded concurrently,
+// capping the memory used by decoding large bodies at the same time.
+//
+// Requests that do not get a decode slot are rejected with 503.
+type DecodeLimiter struct {
+	sem     chan struct{}
+	wait    bool
+	maxWait time.Duration
+}
+
+// NewDecodeLimiter returns a new DecodeLimiter.
+func NewDecodeLimiter(config DecodeLimiterConfig) (*DecodeLimiter, error) {
+	if config.MaxConcurrent <= 0 {
+		return nil, errors.New("max concurrent decodes must be positive")
+	}
+
+	if config.MaxWait < 0 {
+		return nil, fmt.Errorf("invalid max wait: %s", config.MaxWait)
+	}
+
+	return &DecodeLimiter{
+		sem:     make(chan struct{}, config.MaxConcurrent),
+		wait:    config.Wait,
+		maxWait: config.MaxWait,
+	}, nil
+}
+
+// acquire acquires a decode slot. The returned function releases it.
+// A nil *DecodeLimiter never limits.
+func (l *DecodeLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-l.sem }
+
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if !l.wait {
+		return nil, NewEventErrorf(http.StatusServiceUnavailable, "too many requests being decoded")
+	}
+
+	if l.maxWait > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, l.maxWait)
+		defer cancel()
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, NewEventErrorf(http.StatusServiceUnavailable, "too many requests being decoded: %s", ctx.Err())
+	}
+}
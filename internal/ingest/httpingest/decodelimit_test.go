@@ -0,0 +1,105 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestDecodeLimiter(t *testing.T) {
+	t.Run("Shed", func(t *testing.T) {
+		limiter, err := NewDecodeLimiter(DecodeLimiterConfig{MaxConcurrent: 1})
+		require.NoError(t, err)
+
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		_, err = limiter.acquire(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+
+		release()
+
+		release, err = limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		release()
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		limiter, err := NewDecodeLimiter(DecodeLimiterConfig{MaxConcurrent: 1, Wait: true, MaxWait: time.Second})
+		require.NoError(t, err)
+
+		release, err := limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}()
+
+		release, err = limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		release()
+	})
+
+	t.Run("MaxWait", func(t *testing.T) {
+		limiter, err := NewDecodeLimiter(DecodeLimiterConfig{MaxConcurrent: 1, Wait: true, MaxWait: 10 * time.Millisecond})
+		require.NoError(t, err)
+
+		_, err = limiter.acquire(context.Background())
+		require.NoError(t, err)
+
+		_, err = limiter.acquire(context.Background())
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+	})
+}
+
+func TestHandler_DecodeLimiter(t *testing.T) {
+	limiter, err := NewDecodeLimiter(DecodeLimiterConfig{MaxConcurrent: 1})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:     collector,
+		DecodeLimiter: limiter,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 2))
+	require.NoError(t, err)
+
+	// Occupy the only decode slot
+	release, err := limiter.acquire(context.Background())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, collector.Events())
+
+	release()
+
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, collector.Events(), 2)
+}
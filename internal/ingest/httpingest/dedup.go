@@ -0,0 +1,73 @@
+package httpingest
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// ClientEventIDExtension is the extension the ID sent by the client is preserved in when the handler regenerates event IDs
+// (see Handler.RegenerateIDs). It is reserved by the handler when IDs are regenerated.
+const ClientEventIDExtension = "clienteventid"
+
+type dedupContextKey struct{}
+
+type dedupBatchContextKey struct{}
+
+// dedupBatch collects the functions forgetting the deduplicated events of a batch forwarded atomically,
+// so that every event of the batch can be ingested again when the batch is aborted.
+type dedupBatch struct {
+	mu      sync.Mutex
+	forgets []func(ctx context.Context) error
+}
+
+// withDedupBatch attaches a dedupBatch to the context of a batch forwarded atomically.
+func withDedupBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dedupBatchContextKey{}, &dedupBatch{})
+}
+
+// withDedupForget attaches the function forgetting a deduplicated event to its context,
+// so the event can be ingested again if it cannot be forwarded.
+// The function is also recorded in the batch of the event (if any, see withDedupBatch).
+func withDedupForget(ctx context.Context, forget func(ctx context.Context) error) context.Context {
+	if batch, ok := ctx.Value(dedupBatchContextKey{}).(*dedupBatch); ok {
+		batch.mu.Lock()
+		batch.forgets = append(batch.forgets, forget)
+		batch.mu.Unlock()
+	}
+
+	return context.WithValue(ctx, dedupContextKey{}, forget)
+}
+
+// forgetDeduplicated forgets the deduplicated event of the context (if any), so retries of the event are not dropped as duplicates.
+func forgetDeduplicated(ctx context.Context, logger *slog.Logger) {
+	forget, ok := ctx.Value(dedupContextKey{}).(func(ctx context.Context) error)
+	if !ok {
+		return
+	}
+
+	if err := forget(ctx); err != nil {
+		logger.ErrorCtx(ctx, "unable to forget event key", "error", err)
+	}
+}
+
+// forgetBatchDeduplicated forgets every deduplicated event of the batch of the context (if any),
+// so retries of an aborted batch are not dropped as duplicates.
+func forgetBatchDeduplicated(ctx context.Context, logger *slog.Logger) {
+	batch, ok := ctx.Value(dedupBatchContextKey{}).(*dedupBatch)
+	if !ok {
+		return
+	}
+
+	batch.mu.Lock()
+	forgets := batch.forgets
+	batch.forgets = nil
+	batch.mu.Unlock()
+
+	for _, forget := range forgets {
+		if err := forget(ctx); err != nil {
+			logger.ErrorCtx(ctx, "unable to forget event key", "error", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func newTestDedupFilter(t *testing.T) *ingest.DedupFilter {
+	t.Helper()
+
+	deduplicator, err := ingest.NewMemoryDeduplicator(ingest.MemoryDeduplicatorConfig{TTL: time.Hour, Size: 100})
+	require.NoError(t, err)
+
+	filter, err := ingest.NewDedupFilter(ingest.DedupFilterConfig{Deduplicator: deduplicator})
+	require.NoError(t, err)
+
+	return filter
+}
+
+func TestHandler_Dedup(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		Dedup:     newTestDedupFilter(t),
+	}
+
+	ctx := context.Background()
+	ev := newTestEvents(t, 1)[0]
+
+	require.NoError(t, handler.processEvent(ctx, ev))
+	require.NoError(t, handler.processEvent(ctx, ev))
+
+	testcollector.AssertReceived(t, collector, "0")
+
+	// Events that could not be forwarded are forgotten, so they can be retried
+	collector.Err = errors.New("downstream failure")
+
+	failed := newTestEvents(t, 2)[1]
+	require.Error(t, handler.processEvent(ctx, failed))
+
+	collector.Err = nil
+
+	require.NoError(t, handler.processEvent(ctx, failed))
+	testcollector.AssertReceived(t, collector, "0", "1")
+
+	// Events received without a collector are forgotten too
+	handler.Collector = nil
+
+	unforwarded := newTestEvents(t, 3)[2]
+	require.ErrorIs(t, handler.processEvent(ctx, unforwarded), ErrCollectorNotConfigured)
+
+	handler.Collector = collector
+
+	require.NoError(t, handler.processEvent(ctx, unforwarded))
+	testcollector.AssertReceived(t, collector, "0", "1", "2")
+}
+
+func TestHandler_Dedup_DryRun(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		Dedup:     newTestDedupFilter(t),
+		DryRun:    true,
+	}
+
+	ev := newTestEvents(t, 1)[0]
+
+	req := newSingleEventRequest(t, ev)
+	req.Header.Set(DryRunHeader, "true")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Dry-run events are not recorded
+	require.NoError(t, handler.processEvent(context.Background(), ev))
+	testcollector.AssertReceived(t, collector, "0")
+}
+
+func TestHandler_RegenerateIDs(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:     collector,
+		Dedup:         newTestDedupFilter(t),
+		RegenerateIDs: true,
+	}
+
+	ctx := context.Background()
+
+	require.NoError(t, handler.processEvent(ctx, newTestEvents(t, 1)[0]))
+
+	// Retries are deduplicated with the client ID
+	require.NoError(t, handler.processEvent(ctx, newTestEvents(t, 1)[0]))
+
+	require.Equal(t, 1, collector.Len())
+
+	forwarded := collector.Events()[0]
+	assert.NotEqual(t, "0", forwarded.ID())
+	assert.Equal(t, "0", forwarded.Extensions()[ClientEventIDExtension])
+
+	// The extension is reserved
+	ev := newTestEvents(t, 2)[1]
+	ev.SetExtension(ClientEventIDExtension, "forged")
+
+	err := handler.processEvent(ctx, ev)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+}
@@ -0,0 +1,87 @@
+package httpingest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// SourceDefaults maps event sources to extension values set on events of that source.
+//
+// Defaults never override values set by the event producer.
+type SourceDefaults map[string]map[string]string
+
+// Validate validates the source defaults.
+func (d SourceDefaults) Validate() error {
+	for source, extensions := range d {
+		for name := range extensions {
+			if !event.IsExtensionNameValid(name) {
+				return fmt.Errorf("source %s: invalid extension name: %q", source, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// apply sets the default extension values of the event source that are missing from the event.
+// It returns the names of the extensions that were set.
+func (d SourceDefaults) apply(ev *event.Event) []string {
+	extensions := d[ev.Source()]
+	if len(extensions) == 0 {
+		return nil
+	}
+
+	var applied []string
+
+	for name, value := range extensions {
+		if _, ok := ev.Extensions()[name]; ok {
+			continue
+		}
+
+		ev.SetExtension(name, value)
+
+		applied = append(applied, name)
+	}
+
+	return applied
+}
+
+// ExtensionDefaults maps extension names to values set on events without them (eg. environment: prod),
+// standardizing event metadata without changing producers.
+//
+// Defaults never override values set by the event producer. They are applied after SourceDefaults,
+// so the defaults of a source take precedence.
+type ExtensionDefaults map[string]string
+
+// Validate validates the extension defaults.
+func (d ExtensionDefaults) Validate() error {
+	for name := range d {
+		if !event.IsExtensionNameValid(name) {
+			return fmt.Errorf("invalid extension name: %q", name)
+		}
+	}
+
+	return nil
+}
+
+// apply sets the default extension values that are missing from the event.
+// It returns the names of the extensions that were set, sorted.
+func (d ExtensionDefaults) apply(ev *event.Event) []string {
+	var applied []string
+
+	for name, value := range d {
+		if _, ok := ev.Extensions()[name]; ok {
+			continue
+		}
+
+		ev.SetExtension(name, value)
+
+		applied = append(applied, name)
+	}
+
+	sort.Strings(applied)
+
+	return applied
+}
@@ -0,0 +1,107 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSourceDefaults(t *testing.T) {
+	defaults := SourceDefaults{
+		"service-0": {
+			"region":   "eu",
+			"pipeline": "default",
+		},
+	}
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:      collector,
+		SourceDefaults: defaults,
+	}
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("service-0")
+	ev.SetExtension("region", "us")
+
+	other := event.New()
+	other.SetID("2")
+	other.SetSource("service-1")
+
+	require.NoError(t, handler.processEvent(context.Background(), ev))
+	require.NoError(t, handler.processEvent(context.Background(), other))
+
+	require.Len(t, collector.Events(), 2)
+
+	assert.Equal(t, map[string]interface{}{
+		"region":   "us",
+		"pipeline": "default",
+	}, collector.Events()[0].Extensions(), "values set on the event must win")
+
+	assert.Empty(t, collector.Events()[1].Extensions())
+}
+
+func TestSourceDefaults_Validate(t *testing.T) {
+	assert.NoError(t, SourceDefaults{"service-0": {"region": "eu"}}.Validate())
+	assert.Error(t, SourceDefaults{"service-0": {"Region_Name": "eu"}}.Validate())
+}
+
+func TestExtensionDefaults(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		SourceDefaults: SourceDefaults{
+			"service-0": {"environment": "staging"},
+		},
+		ExtensionDefaults: ExtensionDefaults{
+			"environment": "prod",
+			"pipeline":    "default",
+		},
+	}
+
+	absent := event.New()
+	absent.SetID("1")
+	absent.SetSource("service-1")
+
+	present := event.New()
+	present.SetID("2")
+	present.SetSource("service-1")
+	present.SetExtension("environment", "dev")
+
+	sourceDefault := event.New()
+	sourceDefault.SetID("3")
+	sourceDefault.SetSource("service-0")
+
+	for _, ev := range []event.Event{absent, present, sourceDefault} {
+		require.NoError(t, handler.processEvent(context.Background(), ev))
+	}
+
+	events := collector.Events()
+	require.Len(t, events, 3)
+
+	assert.Equal(t, map[string]interface{}{
+		"environment": "prod",
+		"pipeline":    "default",
+	}, events[0].Extensions())
+
+	assert.Equal(t, map[string]interface{}{
+		"environment": "dev",
+		"pipeline":    "default",
+	}, events[1].Extensions(), "values set on the event must win")
+
+	assert.Equal(t, map[string]interface{}{
+		"environment": "staging",
+		"pipeline":    "default",
+	}, events[2].Extensions(), "source defaults must win")
+}
+
+func TestExtensionDefaults_Validate(t *testing.T) {
+	assert.NoError(t, ExtensionDefaults{"environment": "prod"}.Validate())
+	assert.Error(t, ExtensionDefaults{"Environment_Name": "prod"}.Validate())
+}
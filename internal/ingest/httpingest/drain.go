@@ -0,0 +1,301 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+// Results of events forwarded while draining (see DrainReport).
+const (
+	drainResultConfirmed = "confirmed"
+	drainResultFailed    = "failed"
+	drainResultAbandoned = "abandoned"
+)
+
+// DrainerConfig configures a Drainer.
+type DrainerConfig struct {
+	// Timeout is the maximum duration Shutdown waits for in-flight events. Defaults to 30s.
+	Timeout time.Duration
+
+	// DeadLetter receives the events abandoned at the timeout (optional), so they can be recovered.
+	// Abandoned events are logged either way.
+	DeadLetter Collector
+
+	// LogMaskPolicy controls how the attributes of abandoned events appear in log records.
+	LogMaskPolicy LogMaskPolicy
+
+	Logger *slog.Logger
+
+	// Registerer registers metrics of the progress of draining (optional).
+	Registerer prometheus.Registerer
+}
+
+// Drainer drains a {Handler} during shutdown (eg. rolling deploys): once Shutdown is called,
+// new requests are rejected with 503 while requests in progress and their events are processed to completion.
+//
+// In-flight events are tracked individually, from the time they are processed until the {Collector} returns,
+// so Shutdown can report how many were confirmed and abandon exactly the events still in flight at the timeout.
+// Abandoned events may still be forwarded by the {Collector} afterwards: consumers of the dead letter collector should expect duplicates.
+type Drainer struct {
+	timeout    time.Duration
+	deadLetter Collector
+	logMask    LogMaskPolicy
+	logger     *slog.Logger
+	inFlight   prometheus.GaugeFunc
+	drained    *prometheus.CounterVec
+
+	mu       sync.Mutex
+	draining bool
+	requests int
+	events   map[uint64]event.Event
+	nextID   uint64
+	idle     chan struct{}
+	report   DrainReport
+}
+
+// DrainReport reports the events processed while draining.
+type DrainReport struct {
+	// Confirmed is the number of events forwarded to the {Collector} while draining.
+	Confirmed int
+
+	// Failed is the number of events the {Collector} failed to receive while draining (the error was reported to clients).
+	Failed int
+
+	// Abandoned is the number of events still in flight at the timeout.
+	Abandoned int
+
+	// AbandonedRequests is the number of requests still in progress at the timeout.
+	AbandonedRequests int
+}
+
+var errDraining = NewEventErrorf(http.StatusServiceUnavailable, "server is shutting down, retry later")
+
+// NewDrainer returns a new Drainer.
+func NewDrainer(config DrainerConfig) (*Drainer, error) {
+	if config.Timeout < 0 {
+		return nil, errors.New("drain timeout must not be negative")
+	}
+
+	if err := config.LogMaskPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("log mask policy: %w", err)
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultDrainTimeout
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	d := &Drainer{
+		timeout:    timeout,
+		deadLetter: config.DeadLetter,
+		logMask:    config.LogMaskPolicy,
+		logger:     logger,
+		events:     make(map[uint64]event.Event),
+	}
+
+	if config.Registerer != nil {
+		d.inFlight = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "in_flight_events",
+			Help:      "Number of events being forwarded to the collector.",
+		}, func() float64 {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			return float64(len(d.events))
+		})
+
+		d.drained = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "drained_events_total",
+			Help:      "Number of events processed while draining, by result (confirmed, failed or abandoned).",
+		}, []string{"result"})
+
+		for _, collector := range []prometheus.Collector{d.inFlight, d.drained} {
+			if err := config.Registerer.Register(collector); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// admit admits a request unless draining. Admitted requests must be released with release.
+// A nil *Drainer admits every request.
+func (d *Drainer) admit() error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return errDraining
+	}
+
+	d.requests++
+
+	return nil
+}
+
+func (d *Drainer) release() {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.requests--
+	d.checkIdle()
+}
+
+// track records an event in flight, and returns a function recording the result of forwarding it.
+func (d *Drainer) track(ev event.Event) func(err error) {
+	if d == nil {
+		return func(error) {}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := d.nextID
+	d.nextID++
+	d.events[id] = ev
+
+	return func(err error) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if _, ok := d.events[id]; !ok {
+			// Abandoned
+			return
+		}
+
+		delete(d.events, id)
+
+		if d.draining {
+			result := drainResultConfirmed
+			if err != nil {
+				result = drainResultFailed
+				d.report.Failed++
+			} else {
+				d.report.Confirmed++
+			}
+
+			d.record(result, 1)
+		}
+
+		d.checkIdle()
+	}
+}
+
+func (d *Drainer) record(result string, n int) {
+	if d.drained != nil {
+		d.drained.WithLabelValues(result).Add(float64(n))
+	}
+}
+
+// checkIdle signals Shutdown once nothing is in flight. It must be called with the mutex locked.
+func (d *Drainer) checkIdle() {
+	if d.draining && d.requests == 0 && len(d.events) == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+// Shutdown rejects new requests, and waits until requests in progress and their events are processed,
+// the timeout expires or the context is done. Events still in flight then are abandoned:
+// they are logged and sent to the dead letter collector.
+//
+// Shutdown must not be called more than once.
+func (d *Drainer) Shutdown(ctx context.Context) (DrainReport, error) {
+	d.mu.Lock()
+
+	d.draining = true
+
+	idle := make(chan struct{})
+	d.idle = idle
+	d.checkIdle()
+
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	var err error
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	d.mu.Lock()
+
+	abandoned := d.events
+	d.events = make(map[uint64]event.Event)
+
+	d.report.Abandoned = len(abandoned)
+	d.report.AbandonedRequests = d.requests
+	d.record(drainResultAbandoned, len(abandoned))
+
+	report := d.report
+
+	d.mu.Unlock()
+
+	for _, ev := range abandoned {
+		d.abandon(ev)
+	}
+
+	d.logger.Info("handler drained",
+		slog.Int("confirmed", report.Confirmed),
+		slog.Int("failed", report.Failed),
+		slog.Int("abandoned", report.Abandoned),
+		slog.Int("abandoned_requests", report.AbandonedRequests),
+	)
+
+	return report, err
+}
+
+func (d *Drainer) abandon(ev event.Event) {
+	logger := d.logger.With(d.logMask.logAttrs(ev)...)
+
+	logger.Error("abandoned in-flight event at shutdown")
+
+	if d.deadLetter == nil {
+		return
+	}
+
+	if err := d.deadLetter.Receive(context.Background(), ev); err != nil {
+		logger.Error("unable to send abandoned event to dead letter collector", slog.Any("error", err))
+	}
+}
+
+// Shutdown drains the handler (see Drainer). Handlers without a Drainer return immediately.
+func (h Handler) Shutdown(ctx context.Context) (DrainReport, error) {
+	if h.Drain == nil {
+		return DrainReport{}, nil
+	}
+
+	return h.Drain.Shutdown(ctx)
+}
@@ -0,0 +1,158 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func newSingleEventRequest(t *testing.T, ev event.Event) *http.Request {
+	t.Helper()
+
+	body, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func TestHandler_Shutdown(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+
+	registry := prometheus.NewRegistry()
+
+	drainer, err := NewDrainer(DrainerConfig{Timeout: 5 * time.Second, Registerer: registry})
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			received <- struct{}{}
+			<-release
+
+			return nil
+		}),
+		Drain: drainer,
+	}
+
+	events := newTestEvents(t, 2)
+
+	done := make(chan int)
+
+	go func() {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newSingleEventRequest(t, events[0]))
+
+		done <- w.Code
+	}()
+
+	<-received
+
+	reports := make(chan DrainReport)
+
+	go func() {
+		report, err := handler.Shutdown(context.Background())
+		assert.NoError(t, err)
+
+		reports <- report
+	}()
+
+	// New requests are rejected while draining
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newSingleEventRequest(t, events[1]))
+
+		return w.Code == http.StatusServiceUnavailable
+	}, time.Second, time.Millisecond)
+
+	close(release)
+
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, DrainReport{Confirmed: 1}, <-reports)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(drainer.drained.WithLabelValues(drainResultConfirmed)))
+}
+
+func TestHandler_Shutdown_Abandoned(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	received := make(chan struct{}, 1)
+
+	deadLetter := &testcollector.Collector{}
+
+	drainer, err := NewDrainer(DrainerConfig{Timeout: 10 * time.Millisecond, DeadLetter: deadLetter})
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: collectorFunc(func(ev event.Event) error {
+			received <- struct{}{}
+			<-release
+
+			return nil
+		}),
+		Drain: drainer,
+	}
+
+	go handler.ServeHTTP(httptest.NewRecorder(), newSingleEventRequest(t, newTestEvents(t, 1)[0]))
+
+	<-received
+
+	report, err := handler.Shutdown(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Equal(t, DrainReport{Abandoned: 1, AbandonedRequests: 1}, report)
+	testcollector.AssertReceived(t, deadLetter, "0")
+}
+
+func TestDrainer_AbandonLogMaskPolicy(t *testing.T) {
+	var logs bytes.Buffer
+
+	drainer, err := NewDrainer(DrainerConfig{
+		DeadLetter:    &testcollector.Collector{Err: errors.New("dead letter failure")},
+		LogMaskPolicy: LogMaskPolicy{"subject": {Mode: MaskModeRedact}, "source": {Mode: MaskModeHash}},
+		Logger:        slog.New(slog.NewJSONHandler(&logs, nil)),
+	})
+	require.NoError(t, err)
+
+	ev := newTestEvents(t, 1)[0]
+	ev.SetSubject("customer-1")
+	ev.SetSource("service-1")
+
+	drainer.abandon(ev)
+
+	assert.Contains(t, logs.String(), "unable to send abandoned event to dead letter collector")
+	assert.NotContains(t, logs.String(), "customer-1")
+	assert.NotContains(t, logs.String(), "service-1")
+
+	_, err = NewDrainer(DrainerConfig{LogMaskPolicy: LogMaskPolicy{"subjct": {Mode: MaskModeRedact}}})
+	assert.Error(t, err)
+}
+
+func TestHandler_Shutdown_Idle(t *testing.T) {
+	drainer, err := NewDrainer(DrainerConfig{})
+	require.NoError(t, err)
+
+	handler := Handler{Collector: &testcollector.Collector{}, Drain: drainer}
+
+	report, err := handler.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, DrainReport{}, report)
+
+	// Handlers without a drainer shut down immediately
+	_, err = Handler{}.Shutdown(context.Background())
+	require.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// ClockDriftConfig configures observing the drift between the time of events and the time they are ingested.
+//
+// Drift is observational: events are never rejected because of it.
+type ClockDriftConfig struct {
+	// Extension is the extension the drift (in milliseconds, positive for events in the past) is set in (optional).
+	Extension string
+
+	// Threshold is the drift (in either direction) above which events are flagged in metrics and logs.
+	// Disabled when zero.
+	Threshold time.Duration
+}
+
+// Validate validates the configuration.
+func (c ClockDriftConfig) Validate() error {
+	if c.Extension != "" && !event.IsExtensionNameValid(c.Extension) {
+		return errors.New("invalid clock drift extension name")
+	}
+
+	if c.Threshold < 0 {
+		return errors.New("clock drift threshold must not be negative")
+	}
+
+	return nil
+}
+
+// exceeds reports whether the drift is beyond the threshold.
+func (c *ClockDriftConfig) exceeds(drift time.Duration) bool {
+	if c.Threshold == 0 {
+		return false
+	}
+
+	if drift < 0 {
+		drift = -drift
+	}
+
+	return drift > c.Threshold
+}
+
+// observeClockDrift records the drift between the time of the event (set by the producer) and the ingest time.
+// It returns whether the drift exceeds the threshold.
+func (h Handler) observeClockDrift(ctx context.Context, ev *event.Event) (time.Duration, bool) {
+	drift := h.now().Sub(ev.Time())
+
+	exceeded := h.ClockDrift.exceeds(drift)
+
+	h.metrics().RecordClockDrift(ctx, drift, exceeded)
+
+	if h.ClockDrift.Extension != "" {
+		h.setServerExtension(ev, h.ClockDrift.Extension, strconv.FormatInt(drift.Milliseconds(), 10))
+	}
+
+	return drift, exceeded
+}
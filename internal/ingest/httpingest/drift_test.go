@@ -0,0 +1,69 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_ClockDrift(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		Metrics:   metrics,
+		Clock:     func() time.Time { return now },
+		ClockDrift: &ClockDriftConfig{
+			Extension: "drift",
+			Threshold: time.Minute,
+		},
+	}
+
+	newEvent := func(id string, t time.Time) event.Event {
+		ev := event.New()
+		ev.SetID(id)
+		ev.SetSource("test")
+
+		if !t.IsZero() {
+			ev.SetTime(t)
+		}
+
+		return ev
+	}
+
+	ctx := context.Background()
+
+	require.NoError(t, handler.processEvent(ctx, newEvent("past", now.Add(-1500*time.Millisecond))))
+	require.NoError(t, handler.processEvent(ctx, newEvent("future", now.Add(2*time.Minute))))
+	require.NoError(t, handler.processEvent(ctx, newEvent("missing", time.Time{})))
+
+	require.Len(t, collector.Events(), 3)
+
+	assert.Equal(t, "1500", collector.Events()[0].Extensions()["drift"])
+	assert.Equal(t, "-120000", collector.Events()[1].Extensions()["drift"])
+	assert.NotContains(t, collector.Events()[2].Extensions(), "drift")
+
+	past := gatherHistogram(t, registry, "openmeter_ingest_event_clock_drift_seconds", map[string]string{"direction": "past"})
+	assert.Equal(t, uint64(1), past.GetSampleCount())
+	assert.Equal(t, 1.5, past.GetSampleSum())
+
+	future := gatherHistogram(t, registry, "openmeter_ingest_event_clock_drift_seconds", map[string]string{"direction": "future"})
+	assert.Equal(t, uint64(1), future.GetSampleCount())
+	assert.Equal(t, float64(120), future.GetSampleSum())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.clockDriftHigh))
+}
@@ -0,0 +1,134 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DropRule matches events by attributes. Every attribute set in the rule must match.
+//
+// Type and Source match exactly, or by prefix when ending with * (eg. "debug.*").
+type DropRule struct {
+	// Name identifies the rule in metrics (optional). Defaults to the index of the rule.
+	Name string
+
+	Type   string
+	Source string
+
+	// Extensions match the values of extensions (compared as strings).
+	Extensions map[string]string
+}
+
+// DropFilterConfig configures a DropFilter.
+type DropFilterConfig struct {
+	// Rules match the events to drop: events matching any rule are dropped.
+	Rules []DropRule
+
+	// Registerer registers the metric of dropped events (optional).
+	Registerer prometheus.Registerer
+}
+
+// DropFilter drops events matching its rules before they are validated or forwarded to the {Collector},
+// eg. to suppress known noise at the edge. Dropped events are acknowledged to clients as if they were forwarded.
+type DropFilter struct {
+	rules   []DropRule
+	names   []string
+	dropped *prometheus.CounterVec
+}
+
+// NewDropFilter returns a new DropFilter.
+func NewDropFilter(config DropFilterConfig) (*DropFilter, error) {
+	if len(config.Rules) == 0 {
+		return nil, errors.New("at least one drop rule is required")
+	}
+
+	names := make([]string, 0, len(config.Rules))
+
+	for i, rule := range config.Rules {
+		if rule.Type == "" && rule.Source == "" && len(rule.Extensions) == 0 {
+			return nil, fmt.Errorf("drop rule %d matches every event", i)
+		}
+
+		name := rule.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+
+		names = append(names, name)
+	}
+
+	f := &DropFilter{
+		rules: config.Rules,
+		names: names,
+	}
+
+	if config.Registerer != nil {
+		f.dropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "filtered_events_total",
+			Help:      "Number of events dropped by the drop filter, by rule.",
+		}, []string{"rule"})
+
+		if err := config.Registerer.Register(f.dropped); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// drop reports whether the event matches a rule (and counts it), and returns the name of the rule.
+func (f *DropFilter) drop(ev event.Event) (string, bool) {
+	for i, rule := range f.rules {
+		if !rule.match(ev) {
+			continue
+		}
+
+		if f.dropped != nil {
+			f.dropped.WithLabelValues(f.names[i]).Inc()
+		}
+
+		return f.names[i], true
+	}
+
+	return "", false
+}
+
+func (r DropRule) match(ev event.Event) bool {
+	if r.Type != "" && !matchDropPattern(r.Type, ev.Type()) {
+		return false
+	}
+
+	if r.Source != "" && !matchDropPattern(r.Source, ev.Source()) {
+		return false
+	}
+
+	for name, want := range r.Extensions {
+		value, ok := ev.Extensions()[name]
+		if !ok {
+			return false
+		}
+
+		s, err := types.Format(value)
+		if err != nil || s != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchDropPattern(pattern string, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+
+	return pattern == value
+}
@@ -0,0 +1,92 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestDropRule(t *testing.T) {
+	ev := newTestEvents(t, 1)[0]
+	ev.SetType("debug.trace")
+	ev.SetExtension("environment", "development")
+	ev.SetExtension("attempt", 2)
+
+	tests := []struct {
+		name  string
+		rule  DropRule
+		match bool
+	}{
+		{name: "Type", rule: DropRule{Type: "debug.trace"}, match: true},
+		{name: "TypePrefix", rule: DropRule{Type: "debug.*"}, match: true},
+		{name: "OtherType", rule: DropRule{Type: "debug"}, match: false},
+		{name: "Source", rule: DropRule{Source: "test"}, match: true},
+		{name: "Extensions", rule: DropRule{Extensions: map[string]string{"environment": "development", "attempt": "2"}}, match: true},
+		{name: "MissingExtension", rule: DropRule{Extensions: map[string]string{"region": "eu"}}, match: false},
+		{name: "Every", rule: DropRule{Type: "debug.*", Source: "other"}, match: false},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, test.rule.match(ev))
+		})
+	}
+}
+
+func TestHandler_DropFilter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	filter, err := NewDropFilter(DropFilterConfig{
+		Rules: []DropRule{
+			{Name: "noise", Type: "noise"},
+		},
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:    collector,
+		DropFilter:   filter,
+		ValidateUTF8: true,
+	}
+
+	events := newTestEvents(t, 3)
+	events[1].SetType("noise")
+
+	// Dropped before validation
+	events[1].SetExtension("invalid", string([]byte{0xff}))
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.ElementsMatch(t, []string{"0", "2"}, collector.IDs())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(filter.dropped.WithLabelValues("noise")))
+}
+
+func TestNewDropFilter(t *testing.T) {
+	_, err := NewDropFilter(DropFilterConfig{})
+	assert.Error(t, err)
+
+	_, err = NewDropFilter(DropFilterConfig{Rules: []DropRule{{Name: "all"}}})
+	assert.EqualError(t, err, "drop rule 0 matches every event")
+}
@@ -0,0 +1,116 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// DryRunHeader is the request header selecting dry-run mode (see Handler.DryRun), eg. OpenMeter-Dry-Run: true.
+const DryRunHeader = "OpenMeter-Dry-Run"
+
+type dryRunContextKey struct{}
+
+type dryRunIndexContextKey struct{}
+
+// dryRun collects the events of a dry-run request as they would be forwarded to the {Collector}.
+type dryRun struct {
+	mu     sync.Mutex
+	events map[int]event.Event
+}
+
+// withDryRun marks dry-run requests in their context.
+// Requests are rejected with 400 if the header is invalid, dry-run mode is not enabled, or the content type does not support it.
+func (h Handler) withDryRun(r *http.Request, contentType string) (*http.Request, error) {
+	value := strings.TrimSpace(r.Header.Get(DryRunHeader))
+	if value == "" {
+		return r, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return r, NewEventErrorf(http.StatusBadRequest, "invalid %s header: %q", DryRunHeader, value)
+	}
+
+	if !enabled {
+		return r, nil
+	}
+
+	if !h.DryRun {
+		return r, NewEventErrorf(http.StatusBadRequest, "dry-run requests are not enabled")
+	}
+
+	switch contentType {
+	case "", ContentTypeSingle, ContentTypeBatch:
+	default:
+		return r, NewEventErrorf(http.StatusBadRequest, "dry-run requests are not supported for content type %s", contentType)
+	}
+
+	run := &dryRun{events: make(map[int]event.Event)}
+
+	return r.WithContext(context.WithValue(r.Context(), dryRunContextKey{}, run)), nil
+}
+
+func dryRunFromContext(ctx context.Context) (*dryRun, bool) {
+	run, ok := ctx.Value(dryRunContextKey{}).(*dryRun)
+
+	return run, ok
+}
+
+// withDryRunIndex attaches the index of an event in its batch to the context of dry-run requests.
+func withDryRunIndex(ctx context.Context, index int) context.Context {
+	if _, ok := dryRunFromContext(ctx); !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, dryRunIndexContextKey{}, index)
+}
+
+// record records the event instead of forwarding it.
+func (d *dryRun) record(ctx context.Context, ev event.Event) {
+	index, _ := ctx.Value(dryRunIndexContextKey{}).(int)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.events[index] = ev
+}
+
+// event returns the event recorded at the index, if any (dropped events are not recorded).
+func (d *dryRun) event(index int) *event.Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ev, ok := d.events[index]
+	if !ok {
+		return nil
+	}
+
+	return &ev
+}
+
+// writeDryRunEvent writes the result of a dry-run single event request.
+func writeDryRunEvent(w http.ResponseWriter, run *dryRun, ev event.Event) {
+	result := newEventResult(0, ev, http.StatusOK, nil)
+	result.Event = run.event(0)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// writeDryRunResults writes the results of a dry-run batch request: 200 if every event is valid, 207 otherwise.
+func writeDryRunResults(w http.ResponseWriter, events []event.Event, failures []batchResult, run *dryRun) {
+	code := http.StatusOK
+	if len(failures) > 0 {
+		code = http.StatusMultiStatus
+	}
+
+	writeBatchResults(w, code, events, failures, http.StatusOK, run)
+}
@@ -0,0 +1,173 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func newDryRunHandler(collector *testcollector.Collector) Handler {
+	return Handler{
+		Collector:         collector,
+		DryRun:            true,
+		RequestID:         &RequestIDConfig{},
+		ExtensionDefaults: ExtensionDefaults{"environment": "prod"},
+		MaxSubjectLength:  5,
+	}
+}
+
+func TestHandler_DryRun(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := newDryRunHandler(collector)
+
+	ev := newTestEvents(t, 1)[0]
+	ev.SetTime(time.Time{})
+
+	r := newSingleEventRequest(t, ev)
+	r.Header.Set(DryRunHeader, "true")
+	r.Header.Set("X-Request-ID", "request-1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, collector.Len())
+
+	var result EventResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+
+	assert.Equal(t, "0", result.ID)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	require.NotNil(t, result.Event)
+	assert.False(t, result.Event.Time().IsZero())
+	assert.Equal(t, "prod", result.Event.Extensions()["environment"])
+	assert.Equal(t, "request-1", result.Event.Extensions()[RequestIDExtension])
+}
+
+func TestHandler_DryRun_Batch(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := newDryRunHandler(collector)
+
+	events := newTestEvents(t, 3)
+	events[1].SetSubject("too long")
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeBatch)
+	r.Header.Set(DryRunHeader, "true")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, 0, collector.Len())
+
+	var results []EventResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&results))
+	require.Len(t, results, 3)
+
+	for _, i := range []int{0, 2} {
+		assert.Equal(t, http.StatusOK, results[i].StatusCode)
+
+		require.NotNil(t, results[i].Event)
+		assert.Equal(t, events[i].ID(), results[i].Event.ID())
+		assert.Equal(t, "prod", results[i].Event.Extensions()["environment"])
+	}
+
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+	assert.Nil(t, results[1].Event)
+
+	// Batches of valid events succeed
+	body, err = json.Marshal([]event.Event{events[0], events[2]})
+	require.NoError(t, err)
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeBatch)
+	r.Header.Set(DryRunHeader, "true")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&results))
+	assert.Len(t, results, 2)
+	assert.Equal(t, 0, collector.Len())
+}
+
+func TestHandler_DryRun_Rejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		dryRun      bool
+		header      string
+		contentType string
+
+		wantStatus int
+	}{
+		{
+			name:       "Disabled",
+			header:     "true",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "InvalidHeader",
+			dryRun:     true,
+			header:     "maybe",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Stream",
+			dryRun:      true,
+			header:      "true",
+			contentType: ContentTypeNDJSON,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:       "Off",
+			header:     "false",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{Collector: collector, DryRun: test.dryRun}
+
+			body, err := json.Marshal(newTestEvents(t, 1)[0])
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)+"\n"))
+			r.Header.Set(DryRunHeader, test.header)
+
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.wantStatus, w.Code)
+
+			if test.wantStatus == http.StatusOK {
+				testcollector.AssertReceived(t, collector, "0")
+			} else {
+				assert.Equal(t, 0, collector.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package httpingest
+
+import (
+	"container/list"
+	"errors"
+	"hash"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+)
+
+// DuplicateRequestDetectorConfig configures a DuplicateRequestDetector.
+type DuplicateRequestDetectorConfig struct {
+	// Window is how long the hash of a request body is remembered.
+	Window time.Duration
+
+	// Size is the maximum number of remembered request body hashes.
+	// The oldest hashes are forgotten first when the limit is reached.
+	Size int
+}
+
+// DuplicateRequestDetector detects requests with a body identical to a recently received request.
+//
+// It is a cheap signal of client retry storms: duplicates are only counted (see Metrics), never rejected.
+// It is not a replacement for event deduplication.
+type DuplicateRequestDetector struct {
+	window time.Duration
+	size   int
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[uint64]*list.Element
+	order   *list.List
+}
+
+type duplicateEntry struct {
+	hash   uint64
+	seenAt time.Time
+}
+
+// NewDuplicateRequestDetector returns a new DuplicateRequestDetector.
+func NewDuplicateRequestDetector(config DuplicateRequestDetectorConfig) (*DuplicateRequestDetector, error) {
+	if config.Window <= 0 {
+		return nil, errors.New("duplicate request window must be positive")
+	}
+
+	if config.Size <= 0 {
+		return nil, errors.New("duplicate request size must be positive")
+	}
+
+	return &DuplicateRequestDetector{
+		window:  config.Window,
+		size:    config.Size,
+		now:     time.Now,
+		entries: make(map[uint64]*list.Element, config.Size),
+		order:   list.New(),
+	}, nil
+}
+
+// seen records the hash and reports whether it was seen within the window.
+func (d *DuplicateRequestDetector) seen(hash uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+
+	// Forget expired hashes
+	for e := d.order.Front(); e != nil; e = d.order.Front() {
+		entry := e.Value.(*duplicateEntry)
+		if now.Sub(entry.seenAt) < d.window {
+			break
+		}
+
+		d.order.Remove(e)
+		delete(d.entries, entry.hash)
+	}
+
+	if e, ok := d.entries[hash]; ok {
+		e.Value.(*duplicateEntry).seenAt = now
+		d.order.MoveToBack(e)
+
+		return true
+	}
+
+	if d.order.Len() >= d.size {
+		e := d.order.Front()
+
+		d.order.Remove(e)
+		delete(d.entries, e.Value.(*duplicateEntry).hash)
+	}
+
+	d.entries[hash] = d.order.PushBack(&duplicateEntry{hash: hash, seenAt: now})
+
+	return false
+}
+
+// hashingReader hashes the request body as it is read.
+type hashingReader struct {
+	io.ReadCloser
+
+	hash hash.Hash64
+	n    int64
+}
+
+// newHashingReader returns a hashingReader. The hash is seeded with the endpoint (if any),
+// so identical bodies sent to different endpoints are not duplicates.
+func newHashingReader(r io.ReadCloser, endpoint string) *hashingReader {
+	h := fnv.New64a()
+
+	if endpoint != "" {
+		_, _ = h.Write([]byte(endpoint))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return &hashingReader{
+		ReadCloser: r,
+		hash:       h,
+	}
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	r.n += int64(n)
+	_, _ = r.hash.Write(p[:n])
+
+	return n, err
+}
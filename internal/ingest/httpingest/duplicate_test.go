@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestDuplicateRequestDetector(t *testing.T) {
+	detector, err := NewDuplicateRequestDetector(DuplicateRequestDetectorConfig{
+		Window: time.Minute,
+		Size:   2,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 06, 15, 14, 33, 00, 00, time.UTC)
+	detector.now = func() time.Time { return now }
+
+	assert.False(t, detector.seen(1))
+	assert.True(t, detector.seen(1))
+
+	assert.False(t, detector.seen(2))
+	assert.False(t, detector.seen(3))
+	assert.True(t, detector.seen(3))
+	assert.False(t, detector.seen(1), "oldest hash must be evicted when full")
+
+	now = now.Add(time.Minute)
+
+	assert.False(t, detector.seen(3), "hashes must expire after the window")
+}
+
+func TestHandler_DuplicateRequests(t *testing.T) {
+	detector, err := NewDuplicateRequestDetector(DuplicateRequestDetectorConfig{
+		Window: time.Minute,
+		Size:   10,
+	})
+	require.NoError(t, err)
+
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector:         &testcollector.Collector{},
+		DuplicateRequests: detector,
+		Metrics:           metrics,
+	}
+
+	const body = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.duplicateRequests))
+}
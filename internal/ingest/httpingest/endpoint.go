@@ -0,0 +1,33 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+)
+
+// Several handlers (eg. endpoints with different authentication or validation) may share a Collector,
+// Metrics and caches (DuplicateRequests, Sequences, Dedup, SourceSequences, SubjectRates):
+//   - collectors must be safe for concurrent use (see ingest.Collector)
+//   - metrics recorded by the handler are labeled with its Endpoint
+//   - caches are keyed by Endpoint, so requests and events of different endpoints never collide
+//
+// Handlers sharing metrics or caches should have distinct endpoint names.
+
+type endpointContextKey struct{}
+
+// withEndpoint stores the endpoint of the handler in the request context.
+func (h Handler) withEndpoint(r *http.Request) *http.Request {
+	if h.Endpoint == "" {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), endpointContextKey{}, h.Endpoint))
+}
+
+// endpointFromContext returns the endpoint of the handler processing the request of the context.
+// It is empty outside of requests and for handlers without an endpoint name.
+func endpointFromContext(ctx context.Context) string {
+	endpoint, _ := ctx.Value(endpointContextKey{}).(string)
+
+	return endpoint
+}
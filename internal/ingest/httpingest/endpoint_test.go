@@ -0,0 +1,95 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_SharedEndpoints(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	duplicates, err := NewDuplicateRequestDetector(DuplicateRequestDetectorConfig{
+		Window: time.Minute,
+		Size:   10,
+	})
+	require.NoError(t, err)
+
+	sequences, err := NewSequenceTracker(SequenceTrackerConfig{})
+	require.NoError(t, err)
+
+	sourceSequences, err := NewSourceSequencer(SourceSequencerConfig{})
+	require.NoError(t, err)
+
+	dedup := newTestDedupFilter(t)
+
+	newHandler := func(endpoint string) Handler {
+		return Handler{
+			Collector:         collector,
+			Metrics:           metrics,
+			DuplicateRequests: duplicates,
+			Sequences:         sequences,
+			Dedup:             dedup,
+			SourceSequences:   sourceSequences,
+			Endpoint:          endpoint,
+		}
+	}
+
+	handlers := []Handler{newHandler("public"), newHandler("internal")}
+
+	// The same events are sent to both endpoints concurrently
+	bodies := []string{
+		`{"specversion":"1.0","id":"1","source":"test","type":"api-calls","subject":"customer-1","sequence":1}`,
+		`{"specversion":"1.0","id":"2","source":"test","type":"api-calls","subject":"customer-1","sequence":2}`,
+	}
+
+	var wg sync.WaitGroup
+
+	for _, handler := range handlers {
+		wg.Add(1)
+
+		go func(handler Handler) {
+			defer wg.Done()
+
+			for _, body := range bodies {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+				req.Header.Set("Content-Type", ContentTypeSingle)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}(handler)
+	}
+
+	wg.Wait()
+
+	// Events are deduplicated and numbered per endpoint
+	var numbers []interface{}
+
+	for _, ev := range collector.Events() {
+		numbers = append(numbers, ev.Extensions()[DefaultSourceSequenceExtension])
+	}
+
+	assert.ElementsMatch(t, []interface{}{int32(1), int32(2), int32(1), int32(2)}, numbers)
+
+	for _, endpoint := range []string{"public", "internal"} {
+		assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues(endpoint, ContentTypeSingle, requestModeSingle)), endpoint)
+		assert.Equal(t, float64(0), testutil.ToFloat64(metrics.duplicateRequests.WithLabelValues(endpoint)), endpoint)
+		assert.Equal(t, float64(0), testutil.ToFloat64(metrics.sequenceAnomalies.WithLabelValues(endpoint, SequenceOutOfOrder)), endpoint)
+	}
+}
@@ -0,0 +1,164 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/openmeterio/openmeter/api"
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// ErrCollectorNotConfigured is returned when events are received by a {Handler} without a {Collector}.
+var ErrCollectorNotConfigured = errors.New("collector not configured")
+
+// EventError is returned when an event is rejected.
+// It carries the HTTP status code reported to the client.
+type EventError struct {
+	StatusCode int
+	Err        error
+
+	// RetryAfter is the duration the client should wait before retrying (optional).
+	RetryAfter time.Duration
+}
+
+// NewEventError returns a new EventError.
+func NewEventError(statusCode int, err error) *EventError {
+	return &EventError{
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// NewEventErrorf returns a new EventError with a formatted message.
+func NewEventErrorf(statusCode int, format string, a ...any) *EventError {
+	return NewEventError(statusCode, fmt.Errorf(format, a...))
+}
+
+func (e *EventError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStatusFunc maps errors returned by the {Collector} to the HTTP status code reported to the client.
+// Returning zero falls back to DefaultErrorStatus.
+type ErrorStatusFunc func(err error) int
+
+// DefaultErrorStatus maps collector errors to HTTP status codes:
+//   - events rejected by collectors (ingest.ErrInvalidEvent) are bad requests
+//   - throttled collectors (ingest.ErrThrottled) are reported as too many requests
+//   - collectors running out of capacity or closed are reported as unavailable
+//   - collectors timing out are reported as gateway timeouts
+//
+// Other errors (except EventError) are reported as internal server errors.
+func DefaultErrorStatus(err error) int {
+	return statusCode(err)
+}
+
+// statusCode returns the HTTP status code reported to the client for an error (see DefaultErrorStatus).
+func statusCode(err error) int {
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return eventErr.StatusCode
+	}
+
+	switch {
+	case errors.Is(err, ingest.ErrInvalidEvent):
+		return http.StatusBadRequest
+
+	case errors.Is(err, ingest.ErrThrottled):
+		return http.StatusTooManyRequests
+
+	case errors.Is(err, ingest.ErrBufferFull), errors.Is(err, ingest.ErrCollectorClosed):
+		return http.StatusServiceUnavailable
+
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
+
+// collectorFailure hides the message of a server error returned by the {Collector} from clients:
+// it may expose details of downstream systems (eg. broker addresses). The error is still logged as is.
+type collectorFailure struct {
+	err error
+}
+
+func (e collectorFailure) Error() string {
+	return "unable to forward event"
+}
+
+func (e collectorFailure) Unwrap() error {
+	return e.err
+}
+
+// collectorError attaches the status code reported to the client to an error returned by the {Collector}.
+// The messages of server errors are replaced by a generic one.
+func (h Handler) collectorError(err error) error {
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return err
+	}
+
+	var status int
+	if h.ErrorStatus != nil {
+		status = h.ErrorStatus(err)
+	}
+
+	if status == 0 {
+		status = statusCode(err)
+	}
+
+	if status >= http.StatusInternalServerError {
+		err = collectorFailure{err: err}
+	}
+
+	return NewEventError(status, err)
+}
+
+// retryAfter returns the duration the client should wait before retrying after an error.
+func retryAfter(err error) time.Duration {
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return eventErr.RetryAfter
+	}
+
+	return 0
+}
+
+// setRetryAfter sets the Retry-After header of the response (in whole seconds, rounded up).
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// renderError renders the error response of a rejected request or event.
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	setRetryAfter(w, retryAfter(err))
+
+	_ = render.Render(w, r, errResponse(err))
+}
+
+func errResponse(err error) *api.ErrResponse {
+	code := statusCode(err)
+
+	return &api.ErrResponse{
+		Err:        err,
+		StatusCode: code,
+		StatusText: http.StatusText(code),
+		Message:    err.Error(),
+	}
+}
@@ -0,0 +1,82 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestDefaultErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{err: NewEventErrorf(http.StatusUnprocessableEntity, "rejected"), status: http.StatusUnprocessableEntity},
+		{err: fmt.Errorf("schema: %w", ingest.ErrInvalidEvent), status: http.StatusBadRequest},
+		{err: fmt.Errorf("broker: %w", ingest.ErrThrottled), status: http.StatusTooManyRequests},
+		{err: ingest.ErrBufferFull, status: http.StatusServiceUnavailable},
+		{err: ingest.ErrCollectorClosed, status: http.StatusServiceUnavailable},
+		{err: fmt.Errorf("produce: %w", context.DeadlineExceeded), status: http.StatusGatewayTimeout},
+		{err: errors.New("unknown"), status: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.status, DefaultErrorStatus(test.err), test.err.Error())
+	}
+}
+
+func TestHandler_ErrorStatus(t *testing.T) {
+	errSinkDown := errors.New("sink down")
+
+	handler := Handler{
+		Collector: &testcollector.Collector{Err: errSinkDown},
+		ErrorStatus: func(err error) int {
+			if errors.Is(err, errSinkDown) {
+				return http.StatusBadGateway
+			}
+
+			return 0
+		},
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev)))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.NotContains(t, w.Body.String(), "sink down", "messages of server errors are hidden")
+
+	// Unmapped errors fall back to the default mapping
+	handler.Collector = &testcollector.Collector{Err: ingest.ErrBufferFull}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestHandler_CollectorError(t *testing.T) {
+	handler := Handler{}
+
+	err := handler.collectorError(fmt.Errorf("broker kafka-0:9092: %w", ingest.ErrBufferFull))
+
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+	assert.Equal(t, "unable to forward event", err.Error())
+	assert.ErrorIs(t, err, ingest.ErrBufferFull)
+
+	// Client errors are reported as is
+	err = handler.collectorError(fmt.Errorf("schema: %w", ingest.ErrInvalidEvent))
+
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+	assert.Equal(t, "schema: invalid event", err.Error())
+}
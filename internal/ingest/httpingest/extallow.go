@@ -0,0 +1,40 @@
+package httpingest
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkAllowedExtensions rejects events with extensions not in AllowedExtensions (in strict extensions mode).
+// Extensions controlled by the server (see reservedExtensions) are always allowed.
+func (h Handler) checkAllowedExtensions(ev event.Event) error {
+	if !h.StrictExtensions {
+		return nil
+	}
+
+	allowed := make(map[string]struct{}, len(h.AllowedExtensions))
+
+	for _, names := range [][]string{h.AllowedExtensions, h.reservedExtensions()} {
+		for _, name := range names {
+			allowed[name] = struct{}{}
+		}
+	}
+
+	var disallowed []string
+
+	for name := range ev.Extensions() {
+		if _, ok := allowed[name]; !ok {
+			disallowed = append(disallowed, name)
+		}
+	}
+
+	if len(disallowed) == 0 {
+		return nil
+	}
+
+	sort.Strings(disallowed)
+
+	return NewEventErrorf(http.StatusBadRequest, "event sets extensions that are not allowed: %v", disallowed)
+}
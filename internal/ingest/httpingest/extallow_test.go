@@ -0,0 +1,54 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_StrictExtensions(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:         collector,
+		StrictExtensions:  true,
+		AllowedExtensions: []string{"namespace"},
+		RequestID:         &RequestIDConfig{},
+
+		// Reserved extensions set by clients are stripped before the check
+		ReservedExtensionPolicy: ReservedExtensionOverwrite,
+	}
+
+	events := newTestEvents(t, 4)
+	events[0].SetExtension("namespace", "default")
+	events[1].SetExtension("region", "eu")
+	events[1].SetExtension("env", "prod")
+	events[2].SetExtension(RequestIDExtension, "client")
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "event sets extensions that are not allowed: [env region]")
+	assert.ElementsMatch(t, []string{"0", "2", "3"}, collector.IDs())
+}
+
+func TestHandler_StrictExtensions_Off(t *testing.T) {
+	ev := newTestEvents(t, 1)[0]
+	ev.SetExtension("region", "eu")
+
+	assert.NoError(t, Handler{AllowedExtensions: []string{"namespace"}}.checkAllowedExtensions(ev))
+	assert.Error(t, Handler{StrictExtensions: true}.checkAllowedExtensions(ev))
+}
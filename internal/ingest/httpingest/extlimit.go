@@ -0,0 +1,21 @@
+package httpingest
+
+import (
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkExtensionCount rejects events with more extension attributes than MaxExtensions.
+// Standard CloudEvents attributes do not count toward the limit.
+func (h Handler) checkExtensionCount(ev event.Event) error {
+	if h.MaxExtensions <= 0 {
+		return nil
+	}
+
+	if count := len(ev.Extensions()); count > h.MaxExtensions {
+		return NewEventErrorf(http.StatusUnprocessableEntity, "event has %d extension attributes, the maximum is %d", count, h.MaxExtensions)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestMaxExtensions(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:     collector,
+		MaxExtensions: 2,
+		RequestID:     &RequestIDConfig{},
+	}
+
+	ctx := ingest.ContextWithRequestID(context.Background(), "request-1")
+
+	newEvent := func(extensions ...string) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetType("api-calls")
+		ev.SetSubject("customer-1")
+
+		for _, name := range extensions {
+			ev.SetExtension(name, "value")
+		}
+
+		return ev
+	}
+
+	// Extensions set by the handler do not count toward the limit
+	require.NoError(t, handler.processEvent(ctx, newEvent("region", "tier")))
+
+	err := handler.processEvent(ctx, newEvent("region", "tier", "plan"))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, statusCode(err))
+	assert.EqualError(t, err, "event has 3 extension attributes, the maximum is 2")
+
+	assert.Len(t, collector.Events(), 1)
+}
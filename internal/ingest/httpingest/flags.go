@@ -0,0 +1,96 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// FlagsConfig configures parsing event feature flags (see ingest.Flags).
+//
+// The flags of an event are available to the {Collector} via ingest.FlagsFromContext:
+// route flagged events with an ingest.FlagRoutingCollector, or audit them with an ingest.AuditCollector (see AuditCollectorConfig.Flag).
+// Events flagged with ingest.FlagAudit are never dropped by the Sampler (eg. a ValueSampler) nor by an ingest.StateChangeCollector.
+// Flags do not affect per-subject tracking (eg. SubjectRates): flagged events are counted like any other event.
+type FlagsConfig struct {
+	// Extension is the name of the extension carrying the flags (eg. flags).
+	Extension string
+
+	// Known is the list of supported flags. Other flags are ignored (unless RejectUnknown is set).
+	// Every flag is supported when empty.
+	Known []string
+
+	// RejectUnknown rejects events with unknown flags instead of ignoring them.
+	RejectUnknown bool
+}
+
+// Validate validates the configuration.
+func (c FlagsConfig) Validate() error {
+	if !event.IsExtensionNameValid(c.Extension) {
+		return errors.New("invalid flags extension name")
+	}
+
+	return nil
+}
+
+// parse returns the flags of the event.
+// A nil *FlagsConfig parses no flags.
+func (c *FlagsConfig) parse(ev event.Event) (ingest.Flags, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	value, ok := ev.Extensions()[c.Extension]
+	if !ok {
+		return nil, nil
+	}
+
+	s, err := types.ToString(value)
+	if err != nil {
+		return nil, NewEventErrorf(http.StatusBadRequest, "invalid %s extension: %w", c.Extension, err)
+	}
+
+	flags := ingest.ParseFlags(s)
+
+	if len(c.Known) == 0 {
+		return flags, nil
+	}
+
+	for name := range flags {
+		if c.isKnown(name) {
+			continue
+		}
+
+		if c.RejectUnknown {
+			return nil, NewEventErrorf(http.StatusBadRequest, "unknown flag: %q", name)
+		}
+
+		delete(flags, name)
+	}
+
+	return flags, nil
+}
+
+func (c *FlagsConfig) isKnown(name string) bool {
+	for _, known := range c.Known {
+		if strings.EqualFold(known, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withFlags(ctx context.Context, flags ingest.Flags) context.Context {
+	if len(flags) == 0 {
+		return ctx
+	}
+
+	return ingest.ContextWithFlags(ctx, flags)
+}
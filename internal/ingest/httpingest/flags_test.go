@@ -0,0 +1,129 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type dropSampler struct{}
+
+func (dropSampler) Sample(ev event.Event) (bool, int) {
+	return false, 10
+}
+
+func TestHandler_Flags(t *testing.T) {
+	var flags []ingest.Flags
+
+	newHandler := func(config FlagsConfig) Handler {
+		return Handler{
+			Collector: collectorFunc(func(ev event.Event) error {
+				return nil
+			}),
+			Flags: &config,
+		}
+	}
+
+	newEvent := func(value string) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetExtension("flags", value)
+
+		return ev
+	}
+
+	t.Run("Collector", func(t *testing.T) {
+		flags = nil
+
+		handler := Handler{
+			Collector: ingestCollectorFunc(func(ctx context.Context, ev event.Event) error {
+				flags = append(flags, ingest.FlagsFromContext(ctx))
+
+				return nil
+			}),
+			Flags: &FlagsConfig{Extension: "flags"},
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("beta, Audit")))
+
+		assert.Equal(t, []ingest.Flags{{"audit": {}, "beta": {}}}, flags)
+	})
+
+	t.Run("IgnoreUnknown", func(t *testing.T) {
+		flags = nil
+
+		handler := Handler{
+			Collector: ingestCollectorFunc(func(ctx context.Context, ev event.Event) error {
+				flags = append(flags, ingest.FlagsFromContext(ctx))
+
+				return nil
+			}),
+			Flags: &FlagsConfig{Extension: "flags", Known: []string{"audit"}},
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("beta,audit")))
+
+		assert.Equal(t, []ingest.Flags{{"audit": {}}}, flags)
+	})
+
+	t.Run("RejectUnknown", func(t *testing.T) {
+		handler := newHandler(FlagsConfig{Extension: "flags", Known: []string{"audit"}, RejectUnknown: true})
+
+		err := handler.processEvent(context.Background(), newEvent("beta"))
+		require.Error(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, statusCode(err))
+	})
+
+	t.Run("AuditBypassesSampling", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+
+		handler := Handler{
+			Collector: collector,
+			Flags:     &FlagsConfig{Extension: "flags"},
+			Sampler:   dropSampler{},
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("beta")))
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("audit")))
+
+		require.Len(t, collector.Events(), 1)
+		assert.NotContains(t, collector.Events()[0].Extensions(), SampleRateExtension)
+	})
+
+	t.Run("Routing", func(t *testing.T) {
+		audit := &testcollector.Collector{}
+		fallback := &testcollector.Collector{}
+
+		collector, err := ingest.NewFlagRoutingCollector(ingest.FlagRoutingCollectorConfig{
+			Routes:  []ingest.FlagRoute{{Flag: ingest.FlagAudit, Collector: audit}},
+			Default: fallback,
+		})
+		require.NoError(t, err)
+
+		handler := Handler{
+			Collector: collector,
+			Flags:     &FlagsConfig{Extension: "flags"},
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("beta")))
+		require.NoError(t, handler.processEvent(context.Background(), newEvent("AUDIT")))
+
+		assert.Equal(t, 1, audit.Len())
+		assert.Equal(t, 1, fallback.Len())
+	})
+}
+
+type ingestCollectorFunc func(ctx context.Context, ev event.Event) error
+
+func (f ingestCollectorFunc) Receive(ctx context.Context, ev event.Event) error {
+	return f(ctx, ev)
+}
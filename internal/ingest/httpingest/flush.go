@@ -0,0 +1,56 @@
+package httpingest
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// FlushResponse is the response of a FlushHandler.
+type FlushResponse struct {
+	// Flushed is the number of events sent downstream.
+	Flushed int `json:"flushed"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// FlushHandler flushes the events buffered by a {Collector} on demand (eg. before a deploy).
+//
+// The collector must implement ingest.Flusher, otherwise requests are rejected with 501.
+// Whether events that could not be flushed (500) are retried later depends on the collector.
+// The handler does not authenticate requests, protect it (eg. with NewChain and BearerTokenAuthenticator).
+type FlushHandler struct {
+	Collector Collector
+	Logger    *slog.Logger
+}
+
+func (h FlushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	flusher, ok := h.Collector.(ingest.Flusher)
+	if !ok {
+		renderError(w, r, NewEventErrorf(http.StatusNotImplemented, "collector does not buffer events"))
+
+		return
+	}
+
+	flushed, err := flusher.Flush(r.Context())
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "unable to flush collector", "flushed", flushed, "error", err)
+
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, FlushResponse{Flushed: flushed, Error: err.Error()})
+
+		return
+	}
+
+	logger.InfoCtx(r.Context(), "collector flushed", "flushed", flushed)
+
+	render.JSON(w, r, FlushResponse{Flushed: flushed})
+}
@@ -0,0 +1,50 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type flushingCollector struct {
+	testcollector.Collector
+
+	flushed int
+	err     error
+}
+
+func (c *flushingCollector) Flush(_ context.Context) (int, error) {
+	return c.flushed, c.err
+}
+
+func TestFlushHandler(t *testing.T) {
+	flush := func(handler FlushHandler) (*httptest.ResponseRecorder, FlushResponse) {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/ingest/flush", nil))
+
+		var body FlushResponse
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+
+		return resp, body
+	}
+
+	resp, body := flush(FlushHandler{Collector: &flushingCollector{flushed: 12}})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, FlushResponse{Flushed: 12}, body)
+
+	resp, body = flush(FlushHandler{Collector: &flushingCollector{flushed: 3, err: errors.New("broker unavailable")}})
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, FlushResponse{Flushed: 3, Error: "broker unavailable"}, body)
+
+	resp = httptest.NewRecorder()
+	FlushHandler{Collector: &testcollector.Collector{}}.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/ingest/flush", nil))
+	assert.Equal(t, http.StatusNotImplemented, resp.Code)
+}
@@ -0,0 +1,62 @@
+package httpingest
+
+import (
+	"errors"
+	"net/http"
+)
+
+// RequestGateFunc inspects a request before it is processed.
+// Requests are rejected with the returned status code and message when ok is false.
+//
+// It is an escape hatch for deployment specific policies (eg. maintenance windows).
+type RequestGateFunc func(r *http.Request) (ok bool, status int, msg string)
+
+func (f RequestGateFunc) check(r *http.Request) error {
+	if f == nil {
+		return nil
+	}
+
+	ok, status, msg := f(r)
+	if ok {
+		return nil
+	}
+
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+
+	return NewEventError(status, errors.New(msg))
+}
+
+// admitRequest identifies the request and applies the checks every request must pass before its body is read.
+// It renders the error response and returns false if the request is rejected.
+func (h Handler) admitRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	r = h.withEndpoint(r)
+	r = h.identifyRequest(w, r)
+	r = h.withPrincipal(r)
+	r = h.withTrustedSource(r)
+	r = h.withDerivedSource(r)
+	r = h.withGeoLocation(r)
+
+	for _, check := range []func(r *http.Request) error{
+		h.Maintenance.check,
+		checkConflictingHeaders,
+		h.checkRequestDate,
+		h.RequestGate.check,
+	} {
+		if err := check(r); err != nil {
+			h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+			h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+			renderError(w, r, err)
+
+			return r, false
+		}
+	}
+
+	return r, true
+}
@@ -0,0 +1,59 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_RequestGate(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		RequestGate: func(r *http.Request) (bool, int, string) {
+			if r.Header.Get("X-Source") == "flagged" {
+				return false, http.StatusServiceUnavailable, "source is temporarily blocked"
+			}
+
+			return true, 0, ""
+		},
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 1)[0])
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("X-Source", "flagged")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "source is temporarily blocked")
+	assert.Empty(t, collector.Events())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, collector.Events(), 1)
+}
+
+func TestRequestGateFunc_DefaultStatus(t *testing.T) {
+	gate := RequestGateFunc(func(r *http.Request) (bool, int, string) {
+		return false, 0, ""
+	})
+
+	err := gate.check(httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Error(t, err)
+
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+	assert.EqualError(t, err, http.StatusText(http.StatusForbidden))
+}
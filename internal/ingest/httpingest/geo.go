@@ -0,0 +1,138 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// Extensions the location of the client is set in.
+// CloudEvents extension names are limited to lowercase letters and digits.
+const (
+	GeoCountryExtension = "geocountry"
+	GeoRegionExtension  = "georegion"
+)
+
+// Location fields attached to events.
+const (
+	GeoFieldCountry = "country"
+	GeoFieldRegion  = "region"
+)
+
+// GeoLocation is the location of an IP address.
+type GeoLocation struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country.
+	Country string
+
+	// Region is the ISO 3166-2 code of the subdivision (without the country prefix).
+	Region string
+}
+
+// GeoResolver resolves the location of IP addresses (eg. MaxMindGeoResolver).
+type GeoResolver interface {
+	Resolve(ip net.IP) (GeoLocation, error)
+}
+
+// GeoEnricherConfig configures a GeoEnricher.
+type GeoEnricherConfig struct {
+	Resolver GeoResolver
+
+	// Fields lists the location fields attached to events: country and/or region. Defaults to both.
+	Fields []string
+}
+
+// GeoEnricher attaches the location of the client (resolved from the IP address of the request) to events.
+//
+// Enrichment fails open: events are forwarded without a location when it cannot be resolved.
+type GeoEnricher struct {
+	resolver GeoResolver
+	country  bool
+	region   bool
+}
+
+// NewGeoEnricher returns a new GeoEnricher.
+func NewGeoEnricher(config GeoEnricherConfig) (*GeoEnricher, error) {
+	if config.Resolver == nil {
+		return nil, errors.New("geo resolver is required")
+	}
+
+	e := &GeoEnricher{
+		resolver: config.Resolver,
+	}
+
+	fields := config.Fields
+	if len(fields) == 0 {
+		fields = []string{GeoFieldCountry, GeoFieldRegion}
+	}
+
+	for _, field := range fields {
+		switch field {
+		case GeoFieldCountry:
+			e.country = true
+		case GeoFieldRegion:
+			e.region = true
+		default:
+			return nil, fmt.Errorf("invalid geo field: %s", field)
+		}
+	}
+
+	return e, nil
+}
+
+// extensions returns the extensions set by the enricher.
+func (e *GeoEnricher) extensions() []string {
+	var extensions []string
+
+	if e.country {
+		extensions = append(extensions, GeoCountryExtension)
+	}
+
+	if e.region {
+		extensions = append(extensions, GeoRegionExtension)
+	}
+
+	return extensions
+}
+
+type geoLocationContextKey struct{}
+
+// withGeoLocation resolves the location of the client once per request and stores it in the request context.
+func (h Handler) withGeoLocation(r *http.Request) *http.Request {
+	if h.Geo == nil {
+		return r
+	}
+
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return r
+	}
+
+	location, err := h.Geo.resolver.Resolve(ip)
+	if err != nil {
+		h.getLogger().DebugCtx(r.Context(), "unable to resolve client location", "error", err)
+
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), geoLocationContextKey{}, location))
+}
+
+// enrichGeoLocation sets the location of the client of the request of the context in the event.
+func (h Handler) enrichGeoLocation(ctx context.Context, ev *event.Event) {
+	location, ok := ctx.Value(geoLocationContextKey{}).(GeoLocation)
+	if !ok {
+		return
+	}
+
+	if h.Geo.country && location.Country != "" {
+		h.setServerExtension(ev, GeoCountryExtension, location.Country)
+	}
+
+	if h.Geo.region && location.Region != "" {
+		h.setServerExtension(ev, GeoRegionExtension, location.Region)
+	}
+}
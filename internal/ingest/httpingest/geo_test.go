@@ -0,0 +1,89 @@
+package httpingest
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type fakeGeoResolver struct {
+	locations map[string]GeoLocation
+}
+
+func (r fakeGeoResolver) Resolve(ip net.IP) (GeoLocation, error) {
+	location, ok := r.locations[ip.String()]
+	if !ok {
+		return GeoLocation{}, errors.New("lookup failed")
+	}
+
+	return location, nil
+}
+
+func TestHandler_Geo(t *testing.T) {
+	resolver := fakeGeoResolver{locations: map[string]GeoLocation{
+		"203.0.113.7": {Country: "US", Region: "CA"},
+	}}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	post := func(t *testing.T, handler Handler, remoteAddr string) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev))
+		req.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	t.Run("Enriched", func(t *testing.T) {
+		geo, err := NewGeoEnricher(GeoEnricherConfig{Resolver: resolver})
+		require.NoError(t, err)
+
+		collector := &testcollector.Collector{}
+
+		post(t, Handler{Collector: collector, Geo: geo}, "203.0.113.7:51234")
+
+		require.Len(t, collector.Events(), 1)
+		assert.Equal(t, "US", collector.Events()[0].Extensions()[GeoCountryExtension])
+		assert.Equal(t, "CA", collector.Events()[0].Extensions()[GeoRegionExtension])
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		geo, err := NewGeoEnricher(GeoEnricherConfig{Resolver: resolver, Fields: []string{GeoFieldCountry}})
+		require.NoError(t, err)
+
+		collector := &testcollector.Collector{}
+
+		post(t, Handler{Collector: collector, Geo: geo}, "203.0.113.7:51234")
+
+		require.Len(t, collector.Events(), 1)
+		assert.Equal(t, "US", collector.Events()[0].Extensions()[GeoCountryExtension])
+		assert.NotContains(t, collector.Events()[0].Extensions(), GeoRegionExtension)
+	})
+
+	t.Run("FailOpen", func(t *testing.T) {
+		geo, err := NewGeoEnricher(GeoEnricherConfig{Resolver: resolver})
+		require.NoError(t, err)
+
+		collector := &testcollector.Collector{}
+
+		post(t, Handler{Collector: collector, Geo: geo}, "198.51.100.1:51234")
+
+		require.Len(t, collector.Events(), 1)
+		assert.NotContains(t, collector.Events()[0].Extensions(), GeoCountryExtension)
+	})
+
+	_, err := NewGeoEnricher(GeoEnricherConfig{Resolver: resolver, Fields: []string{"city"}})
+	assert.Error(t, err)
+}
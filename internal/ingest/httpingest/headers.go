@@ -0,0 +1,35 @@
+package httpingest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// singleValueHeaders are request headers the handler reads a single value of.
+var singleValueHeaders = []string{"Content-Type", "Content-Encoding"}
+
+// checkConflictingHeaders rejects requests with conflicting values of headers read as a single value,
+// typically caused by misconfigured proxies, instead of silently using the first value.
+// Repeated identical values are accepted.
+func checkConflictingHeaders(r *http.Request) error {
+	var conflicts []string
+
+	for _, name := range singleValueHeaders {
+		values := r.Header.Values(name)
+
+		for i := 1; i < len(values); i++ {
+			if !strings.EqualFold(strings.TrimSpace(values[i]), strings.TrimSpace(values[0])) {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%s)", name, strings.Join(values, ", ")))
+
+				break
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return NewEventErrorf(http.StatusBadRequest, "conflicting request headers: %s", strings.Join(conflicts, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_ConflictingHeaders(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector: collector,
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		status  int
+		message string
+	}{
+		{
+			name:    "ContentType",
+			headers: map[string][]string{"Content-Type": {ContentTypeSingle, ContentTypeBatch}},
+			status:  http.StatusBadRequest,
+			message: "conflicting request headers: Content-Type (application/cloudevents+json, application/cloudevents-batch+json)",
+		},
+		{
+			name: "ContentEncoding",
+			headers: map[string][]string{
+				"Content-Type":     {ContentTypeSingle},
+				"Content-Encoding": {"gzip", "identity"},
+			},
+			status:  http.StatusBadRequest,
+			message: "conflicting request headers: Content-Encoding (gzip, identity)",
+		},
+		{
+			name:    "Identical",
+			headers: map[string][]string{"Content-Type": {ContentTypeSingle, ContentTypeSingle}},
+			status:  http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev))
+			for name, values := range test.headers {
+				req.Header[name] = values
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, test.status, w.Code)
+			assert.Contains(t, w.Body.String(), test.message)
+		})
+	}
+}
@@ -1,67 +1,780 @@
 package httpingest
 
 import (
+	"context"
 	"encoding/json"
+	"mime"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/go-chi/render"
+	"github.com/google/uuid"
 	"golang.org/x/exp/slog"
 
 	"github.com/openmeterio/openmeter/api"
+	"github.com/openmeterio/openmeter/internal/ingest"
 )
 
-// Handler receives an event in CloudEvents format and forwards it to a {Collector}.
+// Handler receives events in CloudEvents format and forwards them to a {Collector}.
+//
+// Besides single events, the handler accepts batches in CloudEvents JSON batch format (ContentTypeBatch)
+// and newline delimited streams of events (ContentTypeNDJSON).
 type Handler struct {
 	Collector Collector
 
 	Logger *slog.Logger
+
+	// LogMaskPolicy controls how event attributes appear in log records.
+	LogMaskPolicy LogMaskPolicy
+
+	// ReservedExtensions are extension names controlled by the server (in addition to the ones set by the handler).
+	ReservedExtensions []string
+
+	// ReservedExtensionPolicy defines how client provided reserved extensions are handled.
+	// Defaults to ReservedExtensionReject.
+	ReservedExtensionPolicy ReservedExtensionPolicy
+
+	// SourceDefaults are extension values set on events based on their source.
+	SourceDefaults SourceDefaults
+
+	// ExtensionDefaults are extension values set on events without them, whatever their source.
+	ExtensionDefaults ExtensionDefaults
+
+	// ValueRange rejects events with invalid numeric values (optional).
+	ValueRange *ValueRangeValidator
+
+	// CUE rejects events whose data does not conform to the CUE schema of their type (optional).
+	CUE *CUEValidator
+
+	// Validators are custom validators run in order after the DefaultValidators (optional, see Validator).
+	Validators []Validator
+
+	// SchemaVersions rejects events with a schema version below the minimum version of their type (optional).
+	SchemaVersions *SchemaVersionValidator
+
+	// TypePrefix resolves the event type prefix events of a request must use (optional).
+	TypePrefix TypePrefixFunc
+
+	// DuplicateRequests detects requests with a body identical to a recent request (optional).
+	DuplicateRequests *DuplicateRequestDetector
+
+	// BatchCoalescer coalesces concurrent batch requests with the same Idempotency-Key header (optional):
+	// retries received while the original request is in flight get its response instead of being processed again.
+	BatchCoalescer *BatchCoalescer
+
+	// Receipts signs receipts of accepted events returned to producers (optional), see ReceiptSigner.
+	Receipts *ReceiptSigner
+
+	// Metrics records ingestion metrics (optional).
+	Metrics MetricsRecorder
+
+	// DropFilter drops matching events before they are validated or forwarded (optional).
+	DropFilter *DropFilter
+
+	// Dedup drops events already ingested, by the source and ID sent by the client (optional).
+	// Duplicates are acknowledged to clients as if they were forwarded.
+	Dedup *ingest.DedupFilter
+
+	// RegenerateIDs forwards events with a new, server generated ID (a UUID) once they are deduplicated with the ID sent by the client,
+	// decoupling client idempotency keys from storage keys. The client ID is preserved in the ClientEventIDExtension extension.
+	RegenerateIDs bool
+
+	// Sampler samples events before forwarding them to the {Collector} (optional).
+	// Kept events sampled at a rate above 1 carry the rate in the samplerate extension.
+	Sampler Sampler
+
+	// OrderSubjectEvents forwards the events of a subject in a batch sequentially, in chronological order,
+	// instead of forwarding every event concurrently in no particular order.
+	// Streamed events are always forwarded in the order they are received.
+	OrderSubjectEvents bool
+
+	// RequestID identifies requests (optional).
+	RequestID *RequestIDConfig
+
+	// DecodeLimiter bounds the number of batch requests decoded concurrently (optional).
+	DecodeLimiter *DecodeLimiter
+
+	// MaxRequestDateSkew is the maximum difference between the Date header of requests and the clock of the server.
+	// Requests without a Date header or outside the range are rejected. Disabled when zero.
+	MaxRequestDateSkew time.Duration
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+
+	// StampReceivedAt sets the time events are received by the server in the ReceivedAtExtension extension.
+	StampReceivedAt bool
+
+	// Flags configures parsing event feature flags (optional).
+	Flags *FlagsConfig
+
+	// RateLimiter limits the number of events processed per second globally (optional).
+	RateLimiter *RateLimiter
+
+	// SourceLimit limits the number of distinct sources of each tenant (optional).
+	SourceLimit *SourceLimiter
+
+	// RequestGate rejects requests based on deployment specific policies (optional).
+	RequestGate RequestGateFunc
+
+	// MeterExtractor rejects events without a meter name and value, and sets them in extensions (optional).
+	MeterExtractor *MeterExtractor
+
+	// Namespace resolves the namespace of events (optional).
+	// Defaults to the value of the namespace extension.
+	Namespace NamespaceFunc
+
+	// SingleNamespaceBatch rejects batches with events of more than one namespace.
+	SingleNamespaceBatch bool
+
+	// ClockDrift configures observing the drift between the time of events and the ingest time (optional).
+	ClockDrift *ClockDriftConfig
+
+	// TimePrecision truncates the time of events to a multiple of the precision (optional), eg. time.Second
+	// when downstream windows are at second or minute granularity. Clock drift and lag are observed before truncation.
+	TimePrecision time.Duration
+
+	// SourcePattern rejects events with a source not matching the pattern (optional).
+	// Patterns match anywhere in the source unless anchored (eg. ^https://[a-z0-9-]+\.example\.com/).
+	SourcePattern *regexp.Regexp
+
+	// IDFormat rejects events with an ID not conforming to the format (optional), eg. when downstream systems expect UUIDs.
+	// Any non-empty ID is accepted otherwise.
+	IDFormat *IDFormat
+
+	// MaxBatchSize is the maximum number of events in a batch (optional).
+	// Larger batches are rejected, streams are aborted after the maximum number of events.
+	MaxBatchSize int
+
+	// BatchBudget bounds the combined event count and size of batches while they are decoded (optional).
+	BatchBudget *BatchBudget
+
+	// MaxExtensions is the maximum number of extension attributes of events (optional).
+	MaxExtensions int
+
+	// StrictExtensions rejects events with extensions not in AllowedExtensions with 400, surfacing producer mistakes.
+	// Extensions set by the server are always allowed. Events may carry any extension otherwise.
+	StrictExtensions bool
+
+	// AllowedExtensions are the extensions events may carry in strict extensions mode.
+	AllowedExtensions []string
+
+	// MaxSubjectLength is the maximum length (in bytes) of the subject of events (optional).
+	// Events with longer subjects are rejected with 400.
+	MaxSubjectLength int
+
+	// SubjectLag configures recording the ingestion lag by subject prefix (optional).
+	SubjectLag *SubjectLagConfig
+
+	// ValidateJSONData rejects events declaring JSON data (by their data content type) that cannot be parsed.
+	ValidateJSONData bool
+
+	// ValidateDataEncoding rejects events with binary data (application/octet-stream) not sent in data_base64,
+	// and events with text data (text/*) not sent in data, with 422.
+	ValidateDataEncoding bool
+
+	// ValidateUTF8 rejects events with string attributes or extensions that are not valid UTF-8 with 422.
+	ValidateUTF8 bool
+
+	// RejectAmbiguousData rejects events with both data and data_base64 with 422.
+	// The SDK keeps the last of them otherwise, silently dropping the other.
+	RejectAmbiguousData bool
+
+	// OnBatchComplete is called with the result of every event once a batch (or stream) is processed (optional).
+	// It runs in the background, after the response is written.
+	OnBatchComplete BatchCompleteFunc
+
+	// StreamWindow coalesces the events of streams into windows forwarded together (optional).
+	StreamWindow *StreamWindowConfig
+
+	// TrustedSourceHeader is a request header overriding the source of events when present (optional).
+	// The header must only be set by trusted infrastructure (eg. an authenticating gateway stripping it from client requests),
+	// otherwise clients can spoof the source of their events.
+	TrustedSourceHeader string
+
+	// SourceTemplate derives the source of events without one from their request (optional).
+	SourceTemplate *SourceTemplate
+
+	// Identity resolves the authenticated identity of requests, used by source templates and passed to the {Collector} (optional).
+	Identity IdentityFunc
+
+	// Authorizer rejects events the principal of the request may not ingest (optional), eg. OPAAuthorizer.
+	Authorizer Authorizer
+
+	// ContentHash configures stamping events with a hash of their content (optional).
+	ContentHash *ContentHashConfig
+
+	// Sequences detects gaps and out of order sequence numbers of subjects (optional).
+	Sequences *SequenceTracker
+
+	// Geo attaches the location of the client to events (optional).
+	Geo *GeoEnricher
+
+	// ContentTypes restricts the content types accepted by the handler (optional), eg. to serve single events and batches at separate routes.
+	// Requests with other content types are rejected with 415. Requests without a content type are single events.
+	// Defaults to every supported content type (and treating unknown content types as single events).
+	ContentTypes []string
+
+	// Avro decodes events in Avro format (optional). Without a decoder, Avro events are rejected with 415.
+	Avro *AvroDecoder
+
+	// SourceSequences stamps events with a sequence number per source (optional).
+	SourceSequences *SourceSequencer
+
+	// SubjectRates attaches the moving average of the ingest rate of the subject to events (optional).
+	SubjectRates *SubjectRateTracker
+
+	// ErrorStatus maps errors returned by the Collector to HTTP status codes (optional).
+	// Defaults to DefaultErrorStatus.
+	ErrorStatus ErrorStatusFunc
+
+	// Endpoint names the handler when several handlers share metrics or caches (see endpoint.go).
+	// Metrics are labeled with it and cache keys are scoped to it.
+	Endpoint string
+
+	// MaxBodySize is the maximum size (in bytes) of request bodies, both before and after decompression (optional).
+	// Larger requests are rejected with 413. Decompressed bodies are limited to DefaultMaxDecodedBodySize when not set.
+	MaxBodySize int64
+
+	// AcceptedStatus responds 202 Accepted instead of 200 OK to events the {Collector} acknowledged
+	// before delivering them downstream (ingest.AckEnqueued, eg. buffered collectors or the Kafka producer queue).
+	// 200 OK then confirms the delivery of events, 202 Accepted that they may still be lost.
+	// The status of events in batch results follows the same rule.
+	AcceptedStatus bool
+
+	// LastError tracks the last error returned by the {Collector} for diagnostics (optional).
+	LastError *LastErrorTracker
+
+	// Maintenance rejects requests with 503 while maintenance mode is on (optional).
+	Maintenance *Maintenance
+
+	// StrictSingle rejects single event requests with data after the event with 400, eg. concatenated events.
+	// Trailing data is ignored otherwise, which can mask bugs of client serializers. Batches and streams are not affected.
+	StrictSingle bool
+
+	// ProblemBatchErrors reports batches whose events were rejected as invalid in a single 422 problem details document (RFC 7807)
+	// with an errors array, instead of a 207 with the result of every event. Clients can also select it with Accept: application/problem+json.
+	ProblemBatchErrors bool
+
+	// MultipartPart is the name of the part of multipart/form-data requests carrying the events (optional), eg. for form uploads.
+	// The part is processed like a request body of its content type. Requests without the part are rejected with 400.
+	MultipartPart string
+
+	// DryRun enables dry-run requests (with the OpenMeter-Dry-Run: true header) of single events and batches:
+	// events are processed (including normalization, validation and enrichment) but not forwarded to the {Collector},
+	// and the response carries the result of every event as it would be forwarded (see EventResult), so producers can verify their events.
+	// Requests selecting dry-run mode are rejected with 400 when disabled.
+	DryRun bool
+
+	// MaxConcurrency is the maximum number of events of a batch forwarded to the {Collector} concurrently.
+	// Defaults to 10.
+	MaxConcurrency int
+
+	// Drain drains the handler during shutdown, accounting for in-flight events (optional, see Shutdown).
+	Drain *Drainer
+
+	// Workers bounds the number of events of batches forwarded to the {Collector} concurrently across requests (optional).
+	Workers *WorkerPool
 }
 
 // Collector is a receiver of events that handles sending those events to some downstream broker.
-type Collector interface {
-	Receive(ev event.Event) error
-}
+type Collector = ingest.Collector
 
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, ok := h.admitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Drain.admit(); err != nil {
+		h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+	defer h.Drain.release()
+
+	if r.Method == http.MethodHead {
+		h.serveCapabilities(w)
+
+		return
+	}
+
+	if h.DuplicateRequests != nil {
+		body := newHashingReader(r.Body, h.Endpoint)
+		r.Body = body
+
+		defer h.detectDuplicateRequest(r, body)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	h.metrics().RecordRequest(r.Context(), contentType)
+
+	if err := h.checkContentType(contentType); err != nil {
+		h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	var encoded *countingReader
+	if h.Metrics != nil {
+		encoded = newCountingReader(r.Body)
+		r.Body = encoded
+	}
+
+	body, err := h.decodeBody(w, r)
+	if err != nil {
+		h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	r.Body = body
+
+	if h.Metrics != nil {
+		decoded := newCountingReader(r.Body)
+		r.Body = decoded
+
+		defer func() {
+			h.Metrics.RecordRequestBodySize(r.Context(), contentType, encoded.n, decoded.n)
+		}()
+	}
+
+	if contentType == ContentTypeMultipart && h.MultipartPart != "" {
+		body, partType, err := h.multipartEvents(r)
+		if err == nil {
+			err = h.checkContentType(partType)
+		}
+
+		if err != nil {
+			h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+			h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+			renderError(w, r, err)
+
+			return
+		}
+
+		r.Body = body
+		contentType = partType
+	}
+
+	r, err = h.withDryRun(r, contentType)
+	if err != nil {
+		h.getLogger().DebugCtx(r.Context(), "request rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	_, isDryRun := dryRunFromContext(r.Context())
+
+	switch contentType {
+	case ContentTypeBatch:
+		if isDryRun {
+			// Dry-run requests must not share the response of identical requests
+			h.processBatchRequest(w, r)
+
+			break
+		}
+
+		h.processCoalescedBatchRequest(w, r)
+
+	case ContentTypeNDJSON:
+		h.processStreamRequest(w, r)
+
+	case ContentTypeAvro:
+		h.processAvroRequest(w, r)
+
+	default:
+		h.processSingleRequest(w, r)
+	}
+}
+
+func (h Handler) processSingleRequest(w http.ResponseWriter, r *http.Request) {
 	logger := h.getLogger()
 
 	var event event.Event
 
-	err := json.NewDecoder(r.Body).Decode(&event)
+	decoder := json.NewDecoder(r.Body)
+
+	err := h.decodeEvent(decoder, &event)
+	if err := checkBodySize(err); err != nil {
+		logger.DebugCtx(r.Context(), "event rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	if err := checkClientAbort(r.Context(), err); err != nil {
+		logger.DebugCtx(r.Context(), "event aborted", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
 	if err != nil {
 		logger.ErrorCtx(r.Context(), "unable to parse event", "error", err)
+		h.metrics().RecordRejection(r.Context(), RejectionScopeRequest, RejectionInternal)
 
 		_ = render.Render(w, r, api.ErrInternalServerError(err))
 
 		return
 	}
 
-	logger = logger.With(
-		slog.String("event_id", event.ID()),
-		slog.String("event_subject", event.Subject()),
-		slog.String("event_source", event.Source()),
-	)
+	if h.StrictSingle {
+		if err := checkTrailingData(decoder); err != nil {
+			logger.DebugCtx(r.Context(), "event rejected", "error", err)
+			h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+			renderError(w, r, err)
+
+			return
+		}
+	}
+
+	if err := h.checkBodyRemainder(r.Body); err != nil {
+		logger.DebugCtx(r.Context(), "event rejected", "error", err)
+		h.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	ctx, ack := ingest.WithAck(r.Context())
+	ctx = h.withReceipts(ctx)
+
+	err = h.processEvent(ctx, event)
+	if err != nil {
+		renderError(w, r, err)
+
+		return
+	}
+
+	if run, ok := dryRunFromContext(ctx); ok {
+		writeDryRunEvent(w, run, event)
+
+		return
+	}
+
+	h.writeReceipt(ctx, w, h.successStatus(ack()), event)
+}
+
+func (h Handler) processEvent(ctx context.Context, event event.Event) error {
+	logger := h.getLogger().With(h.LogMaskPolicy.logAttrs(event)...)
+
+	requestID, hasRequestID := ingest.RequestIDFromContext(ctx)
+	if hasRequestID {
+		logger = logger.With(slog.String("request_id", requestID))
+	}
+
+	ctx = h.withNamespace(ctx, logger, event)
+
+	// Receipts identify the event as forwarded, once the handler stops modifying it
+	defer func() { recordReceiptEvent(ctx, event) }()
+
+	if source, ok := trustedSourceFromContext(ctx); ok && source != event.Source() {
+		logger.DebugCtx(ctx, "overriding event source from trusted header", slog.String("trusted_source", source))
+
+		event.SetSource(source)
+	}
+
+	if event.Source() == "" && h.SourceTemplate != nil {
+		source, err := derivedSourceFromContext(ctx)
+		if err != nil {
+			err = NewEventErrorf(http.StatusBadRequest, "event source is missing: %w", err)
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionValidation)
+
+			return err
+		}
+
+		event.SetSource(source)
+	}
+
+	if h.DropFilter != nil {
+		if rule, ok := h.DropFilter.drop(event); ok {
+			logger.DebugCtx(ctx, "event dropped by filter", slog.String("rule", rule))
+
+			return nil
+		}
+	}
+
+	// Reserved extensions are stripped (or rejected) before validation: validators cannot modify events
+	if err := h.checkReservedExtensions(&event); err != nil {
+		logger.DebugCtx(ctx, "event rejected", "error", err)
+		h.recordRejection(ctx, RejectionScopeEvent, err)
+
+		return err
+	}
+
+	for _, validator := range h.validators() {
+		if err := validator.Validate(ctx, event); err != nil {
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.recordRejection(ctx, RejectionScopeEvent, err)
+
+			return err
+		}
+	}
+
+	var meter string
+	var value float64
+
+	if h.MeterExtractor != nil {
+		var err error
+
+		meter, value, err = h.MeterExtractor.extract(event)
+		if err != nil {
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionValidation)
+
+			return err
+		}
+	}
+
+	if h.SourceLimit != nil {
+		if err := h.checkSourceLimit(ctx, event); err != nil {
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionQuota)
+
+			return err
+		}
+	}
+
+	if h.ContentHash != nil {
+		// Hashed before the server sets anything on the event, so retries of an event have the same hash
+		contentHash, err := h.ContentHash.hash(event)
+		if err != nil {
+			err = NewEventErrorf(http.StatusBadRequest, "hash event content: %w", err)
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionValidation)
+
+			return err
+		}
+
+		h.setServerExtension(&event, h.ContentHash.extension(), contentHash)
+	}
+
+	flags, err := h.Flags.parse(event)
+	if err != nil {
+		logger.DebugCtx(ctx, "event rejected", "error", err)
+		h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionValidation)
+
+		return err
+	}
+
+	ctx = withFlags(ctx, flags)
+
+	// Taken once the event is valid, so rejected events do not consume tokens
+	if h.RateLimiter != nil {
+		tokens, err := h.RateLimiter.allow()
+		h.metrics().RecordRateLimitTokens(ctx, tokens)
+
+		if err != nil {
+			logger.DebugCtx(ctx, "event rejected", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionRateLimited)
+
+			return err
+		}
+	}
 
 	if event.Time().IsZero() {
-		logger.DebugCtx(r.Context(), "event does not have a timestamp")
+		logger.DebugCtx(ctx, "event does not have a timestamp")
+
+		event.SetTime(h.now().UTC())
+	} else {
+		if h.ClockDrift != nil {
+			if drift, exceeded := h.observeClockDrift(ctx, &event); exceeded {
+				logger.DebugCtx(ctx, "event clock drift exceeds threshold", slog.Duration("drift", drift))
+			}
+		}
+
+		if h.SubjectLag != nil {
+			h.observeSubjectLag(ctx, event)
+		}
+	}
+
+	if h.TimePrecision > 0 {
+		event.SetTime(event.Time().Truncate(h.TimePrecision))
+	}
+
+	if applied := h.SourceDefaults.apply(&event); len(applied) > 0 {
+		logger.DebugCtx(ctx, "applied source default extensions", slog.Any("extensions", applied))
+	}
+
+	if applied := h.ExtensionDefaults.apply(&event); len(applied) > 0 {
+		logger.DebugCtx(ctx, "applied default extensions", slog.Any("extensions", applied))
+	}
+
+	if h.Geo != nil {
+		h.enrichGeoLocation(ctx, &event)
+	}
+
+	if h.MeterExtractor != nil {
+		h.applyMeter(&event, meter, value)
+	}
+
+	if hasRequestID {
+		h.setServerExtension(&event, RequestIDExtension, requestID)
+	}
+
+	if h.StampReceivedAt {
+		h.setServerExtension(&event, ReceivedAtExtension, h.now().UTC())
+	}
+
+	// Only namespaces resolved by the server are set: otherwise the extension is the one sent by the client
+	if namespace, ok := ingest.NamespaceFromContext(ctx); ok && namespace != "" && h.Namespace != nil {
+		h.setServerExtension(&event, NamespaceExtension, namespace)
+	}
+
+	// Deduplicated with the ID sent by the client, before it is regenerated
+	if _, dryRun := dryRunFromContext(ctx); h.Dedup != nil && !dryRun {
+		seen, forget, err := h.Dedup.Seen(ctx, endpointFromContext(ctx), event)
+		if err != nil {
+			logger.ErrorCtx(ctx, "unable to deduplicate event", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionInternal)
+
+			return err
+		}
+
+		if seen {
+			logger.DebugCtx(ctx, "dropping duplicate event")
+
+			return nil
+		}
+
+		ctx = withDedupForget(ctx, forget)
+	}
+
+	if h.RegenerateIDs {
+		h.setServerExtension(&event, ClientEventIDExtension, event.ID())
+		event.SetID(uuid.NewString())
+	}
+
+	// Tracked before sampling, as dropped events are not lost
+	if h.Sequences != nil {
+		h.observeSequence(ctx, logger, event)
+	}
+
+	if h.SubjectRates != nil {
+		h.attachSubjectRate(ctx, &event)
+	}
 
-		event.SetTime(time.Now().UTC())
+	if h.Sampler != nil && !flags.Has(ingest.FlagAudit) {
+		keep, rate := h.Sampler.Sample(event)
+		if !keep {
+			logger.DebugCtx(ctx, "event dropped by sampler", slog.Int("sample_rate", rate))
+
+			return nil
+		}
+
+		if rate > 1 {
+			h.setServerExtension(&event, SampleRateExtension, rate)
+		}
+	}
+
+	// Stamped after sampling, so sampled out events do not leave gaps
+	if h.SourceSequences != nil {
+		if err := h.stampSourceSequence(ctx, &event); err != nil {
+			logger.ErrorCtx(ctx, "unable to stamp event", "error", err)
+			h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionInternal)
+			forgetDeduplicated(ctx, logger)
+
+			return err
+		}
+	}
+
+	if run, ok := dryRunFromContext(ctx); ok {
+		run.record(ctx, event)
+
+		logger.DebugCtx(ctx, "dry-run event not forwarded to downstream collector")
+
+		return nil
 	}
 
-	err = h.Collector.Receive(event)
+	if h.Collector == nil {
+		logger.ErrorCtx(ctx, "unable to forward event to collector", "error", ErrCollectorNotConfigured)
+		h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionInternal)
+		forgetDeduplicated(ctx, logger)
+
+		return ErrCollectorNotConfigured
+	}
+
+	if windowed, ok := streamWindowFromContext(ctx); ok {
+		windowed.window.add(ctx, logger, event)
+
+		return errWindowed
+	}
+
+	return h.forward(ctx, logger, event)
+}
+
+// forward forwards the event to the {Collector}.
+func (h Handler) forward(ctx context.Context, logger *slog.Logger, event event.Event) error {
+	drained := h.Drain.track(event)
+
+	err := h.Collector.Receive(ctx, event)
+	h.LastError.observe(err)
+	drained(err)
+
 	if err != nil {
-		logger.ErrorCtx(r.Context(), "unable to forward event to collector", "error", err)
+		logger.ErrorCtx(ctx, "unable to forward event to collector", "error", err)
+		h.metrics().RecordRejection(ctx, RejectionScopeEvent, RejectionCollector)
+		forgetDeduplicated(ctx, logger)
 
-		_ = render.Render(w, r, api.ErrInternalServerError(err))
+		return h.collectorError(err)
+	}
+
+	logger.InfoCtx(ctx, "event forwarded to downstream collector")
+
+	return nil
+}
+
+// identifyRequest attaches the ID of the request to its context (see ingest.RequestIDFromContext) and the response.
+// Requests already identified (eg. by the RequestID middleware of NewChain) keep their ID.
+func (h Handler) identifyRequest(w http.ResponseWriter, r *http.Request) *http.Request {
+	if h.RequestID == nil {
+		return r
+	}
+
+	if _, ok := ingest.RequestIDFromContext(r.Context()); ok {
+		return r
+	}
+
+	id := h.RequestID.requestID(r)
 
+	w.Header().Set(h.RequestID.responseHeader(), id)
+
+	return r.WithContext(ingest.ContextWithRequestID(r.Context(), id))
+}
+
+func (h Handler) detectDuplicateRequest(r *http.Request, body *hashingReader) {
+	if body.n == 0 {
 		return
 	}
 
-	logger.InfoCtx(r.Context(), "event forwarded to downstream collector")
+	if h.DuplicateRequests.seen(body.hash.Sum64()) {
+		h.getLogger().DebugCtx(r.Context(), "received duplicate request")
 
-	w.WriteHeader(http.StatusOK)
+		h.metrics().RecordDuplicateRequest(r.Context())
+	}
 }
 
 func (h Handler) getLogger() *slog.Logger {
@@ -5,32 +5,18 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"sync"
 	"testing"
 	"time"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-)
-
-type inMemoryCollector struct {
-	events []event.Event
-
-	mu sync.Mutex
-}
-
-func (s *inMemoryCollector) Receive(event event.Event) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	s.events = append(s.events, event)
-
-	return nil
-}
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
 
 func TestHandler(t *testing.T) {
-	collector := &inMemoryCollector{}
+	collector := &testcollector.Collector{}
 	handler := Handler{
 		Collector: collector,
 	}
@@ -55,12 +41,100 @@ func TestHandler(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	require.Len(t, collector.events, 1)
+	require.Len(t, collector.Events(), 1)
 
-	receivedEvent := collector.events[0]
+	receivedEvent := collector.Events()[0]
 
 	assert.Equal(t, ev.ID(), receivedEvent.ID())
 	assert.Equal(t, ev.Subject(), receivedEvent.Subject())
 	assert.Equal(t, ev.Source(), receivedEvent.Source())
 	assert.Equal(t, receivedEvent.Time(), ev.Time())
 }
+
+func TestHandler_NoCollector(t *testing.T) {
+	server := httptest.NewServer(Handler{})
+	client := server.Client()
+
+	ev := event.New()
+	ev.SetID("id")
+	ev.SetSubject("sub")
+	ev.SetSource("test")
+
+	var buf bytes.Buffer
+
+	err := json.NewEncoder(&buf).Encode(ev)
+	require.NoError(t, err)
+
+	resp, err := client.Post(server.URL, "", &buf)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrCollectorNotConfigured.Error(), body.Message)
+}
+
+func TestHandler_TimePrecision(t *testing.T) {
+	now := time.Date(2023, 6, 15, 14, 33, 12, 345678901, time.UTC)
+
+	tests := []struct {
+		name      string
+		precision time.Duration
+		time      time.Time
+
+		want time.Time
+	}{
+		{
+			name: "Disabled",
+			time: now,
+			want: now,
+		},
+		{
+			name:      "Second",
+			precision: time.Second,
+			time:      now,
+			want:      time.Date(2023, 6, 15, 14, 33, 12, 0, time.UTC),
+		},
+		{
+			name:      "Minute",
+			precision: time.Minute,
+			time:      now,
+			want:      time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC),
+		},
+		{
+			name:      "IngestTime",
+			precision: time.Millisecond,
+			want:      time.Date(2023, 6, 15, 14, 33, 12, 345000000, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:     collector,
+				Clock:         func() time.Time { return now },
+				TimePrecision: test.precision,
+			}
+
+			ev := newTestEvents(t, 1)[0]
+			ev.SetTime(test.time)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newSingleEventRequest(t, ev))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Len(t, collector.Events(), 1)
+			assert.True(t, test.want.Equal(collector.Events()[0].Time()), "got %v", collector.Events()[0].Time())
+		})
+	}
+}
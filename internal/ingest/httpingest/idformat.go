@@ -0,0 +1,94 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/uuid"
+)
+
+const (
+	// IDFormatUUID requires event IDs to be UUIDs in their canonical form (eg. 3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3e).
+	IDFormatUUID = "uuid"
+
+	// IDFormatULID requires event IDs to be ULIDs (eg. 01ARZ3NDEKTSV4RRFFQ69G5FAV).
+	IDFormatULID = "ulid"
+)
+
+// IDFormat is the format event IDs must conform to: a UUID, a ULID or a custom regular expression.
+type IDFormat struct {
+	name  string
+	match func(id string) bool
+}
+
+// ParseIDFormat returns the ID format named by s (IDFormatUUID or IDFormatULID), or else the format matching s as a regular expression.
+func ParseIDFormat(s string) (*IDFormat, error) {
+	switch strings.ToLower(s) {
+	case IDFormatUUID:
+		return &IDFormat{name: "a UUID", match: isUUID}, nil
+
+	case IDFormatULID:
+		return &IDFormat{name: "a ULID", match: isULID}, nil
+	}
+
+	if s == "" {
+		return nil, errors.New("id format is required")
+	}
+
+	pattern, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id format: %w", err)
+	}
+
+	return &IDFormat{name: fmt.Sprintf("match %q", pattern.String()), match: pattern.MatchString}, nil
+}
+
+// String returns the expected format.
+func (f *IDFormat) String() string {
+	return f.name
+}
+
+// validate rejects events whose ID does not conform to the format. A nil *IDFormat accepts any ID.
+func (f *IDFormat) validate(ev event.Event) error {
+	if f == nil || f.match(ev.ID()) {
+		return nil
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "invalid event id %q: id must be %s", ev.ID(), f.name)
+}
+
+func isUUID(id string) bool {
+	// uuid.Parse also accepts the URN and braced forms
+	if len(id) != 36 {
+		return false
+	}
+
+	_, err := uuid.Parse(id)
+
+	return err == nil
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func isULID(id string) bool {
+	if len(id) != 26 {
+		return false
+	}
+
+	// The 48 bit timestamp overflows with a first character above 7
+	if id[0] > '7' {
+		return false
+	}
+
+	for _, c := range strings.ToUpper(id) {
+		if !strings.ContainsRune(crockfordBase32, c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,105 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestIDFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		valid    []string
+		invalid  []string
+		expected string
+	}{
+		{
+			format:   "uuid",
+			valid:    []string{"3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3e", "3F7C6FD0-8A4B-4F0E-9C55-2B1C1F0A5D3E"},
+			invalid:  []string{"1", "3f7c6fd08a4b4f0e9c552b1c1f0a5d3e", "{3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3e}", "3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3x"},
+			expected: "a UUID",
+		},
+		{
+			format:   "ULID",
+			valid:    []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "01arz3ndektsv4rrffq69g5fav"},
+			invalid:  []string{"1", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"},
+			expected: "a ULID",
+		},
+		{
+			format:   `^evt_[0-9]+$`,
+			valid:    []string{"evt_1", "evt_123"},
+			invalid:  []string{"1", "evt_", "evt_1a"},
+			expected: `match "^evt_[0-9]+$"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			format, err := ParseIDFormat(test.format)
+			require.NoError(t, err)
+
+			for _, id := range test.valid {
+				ev := event.New()
+				ev.SetID(id)
+
+				assert.NoError(t, format.validate(ev), id)
+			}
+
+			for _, id := range test.invalid {
+				ev := event.New()
+				ev.SetID(id)
+
+				err := format.validate(ev)
+				if assert.Error(t, err, id) {
+					assert.Equal(t, http.StatusBadRequest, statusCode(err))
+					assert.Contains(t, err.Error(), "id must be "+test.expected)
+				}
+			}
+		})
+	}
+
+	_, err := ParseIDFormat("[")
+	assert.Error(t, err)
+}
+
+func TestHandler_IDFormatBatch(t *testing.T) {
+	format, err := ParseIDFormat(IDFormatUUID)
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		IDFormat:  format,
+	}
+
+	events := newTestEvents(t, 2)
+	events[0].SetID("3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3e")
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+
+	testcollector.AssertReceived(t, collector, "3f7c6fd0-8a4b-4f0e-9c55-2b1c1f0a5d3e")
+}
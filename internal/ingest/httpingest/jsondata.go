@@ -0,0 +1,43 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// isJSONMediaType reports whether the data content type declares JSON data.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// checkJSONData rejects events declaring JSON data (by their data content type) that cannot be parsed.
+// Events with other (or no) data content types are not validated.
+func checkJSONData(ev event.Event) error {
+	if len(ev.Data()) == 0 || !isJSONMediaType(ev.DataContentType()) {
+		return nil
+	}
+
+	var data json.RawMessage
+
+	err := json.Unmarshal(ev.Data(), &data)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return NewEventErrorf(http.StatusBadRequest, "invalid %s data at offset %d: %s", ev.DataContentType(), syntaxErr.Offset, syntaxErr)
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "invalid %s data: %s", ev.DataContentType(), err)
+}
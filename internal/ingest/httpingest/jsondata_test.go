@@ -0,0 +1,56 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestIsJSONMediaType(t *testing.T) {
+	assert.True(t, isJSONMediaType("application/json"))
+	assert.True(t, isJSONMediaType("application/json; charset=utf-8"))
+	assert.True(t, isJSONMediaType("application/vnd.api+json"))
+	assert.False(t, isJSONMediaType("application/octet-stream"))
+	assert.False(t, isJSONMediaType(""))
+}
+
+func TestHandler_ValidateJSONData(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:        collector,
+		ValidateJSONData: true,
+	}
+
+	// base64 encoded `{"a":` and `{"a":1}`
+	const body = `[
+		{"specversion":"1.0","id":"1","source":"test","type":"api-calls","datacontenttype":"application/json","data_base64":"eyJhIjo="},
+		{"specversion":"1.0","id":"2","source":"test","type":"api-calls","datacontenttype":"application/json","data_base64":"eyJhIjoxfQ=="},
+		{"specversion":"1.0","id":"3","source":"test","type":"api-calls","datacontenttype":"application/octet-stream","data_base64":"eyJhIjo="}
+	]`
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	require.Equal(t, http.StatusMultiStatus, resp.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+
+	assert.Equal(t, http.StatusBadRequest, results[0].StatusCode)
+	assert.Equal(t, "invalid application/json data at offset 5: unexpected end of JSON input", results[0].Error)
+	assert.Equal(t, http.StatusOK, results[1].StatusCode)
+	assert.Equal(t, http.StatusOK, results[2].StatusCode)
+
+	assert.Len(t, collector.Events(), 2)
+}
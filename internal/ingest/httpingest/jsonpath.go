@@ -0,0 +1,132 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath expression supporting the subset used for referencing event data fields:
+// child members ($.a.b) and array indexes ($.a[0]).
+type jsonPath []jsonPathSegment
+
+type jsonPathSegment struct {
+	key   string
+	index int
+	isKey bool
+}
+
+var errJSONPathNotFound = errors.New("value not found")
+
+func parseJSONPath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid json path %q: must start with $", path)
+	}
+
+	rest := path[1:]
+
+	var segments jsonPath
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("invalid json path %q: empty member name", path)
+			}
+
+			segments = append(segments, jsonPathSegment{key: rest[:end], isKey: true})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid json path %q: unterminated index", path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid json path %q: invalid index", path)
+			}
+
+			segments = append(segments, jsonPathSegment{index: index})
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid json path %q: unexpected character %q", path, rest[0])
+		}
+	}
+
+	return segments, nil
+}
+
+// lookup returns the value referenced by the path in a JSON document.
+// Numbers are returned as json.Number.
+func (p jsonPath) lookup(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("parse data: %w", err)
+	}
+
+	for _, segment := range p {
+		if segment.isKey {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errJSONPathNotFound
+			}
+
+			value, ok = object[segment.key]
+			if !ok {
+				return nil, errJSONPathNotFound
+			}
+
+			continue
+		}
+
+		array, ok := value.([]interface{})
+		if !ok || segment.index >= len(array) {
+			return nil, errJSONPathNotFound
+		}
+
+		value = array[segment.index]
+	}
+
+	return value, nil
+}
+
+// lookupNumber returns the numeric value referenced by the path in a JSON document.
+// Numbers encoded as strings (eg. "12") are accepted as well.
+func (p jsonPath) lookupNumber(data []byte) (float64, error) {
+	value, err := p.lookup(data)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		return strconv.ParseFloat(v.String(), 64)
+
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value is not a number: %q", v)
+		}
+
+		return f, nil
+	}
+
+	return 0, fmt.Errorf("value is not a number: %v", value)
+}
@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// subjectPrefixOther is the subject prefix label of subjects matching none of the configured prefixes.
+const subjectPrefixOther = "other"
+
+// SubjectLagConfig configures recording the ingestion lag (the difference between the ingest time and the time of events) by subject.
+//
+// To bound the cardinality of the metric, lag is recorded by subject prefix instead of subject.
+type SubjectLagConfig struct {
+	// Prefixes of subjects (eg. "customer-", "internal-").
+	// Events are recorded with the longest matching prefix, other events are recorded as "other".
+	Prefixes []string
+}
+
+// Validate validates the configuration.
+func (c SubjectLagConfig) Validate() error {
+	prefixes := make(map[string]bool, len(c.Prefixes))
+
+	for _, prefix := range c.Prefixes {
+		if prefix == "" {
+			return errors.New("subject prefix must not be empty")
+		}
+
+		if prefixes[prefix] {
+			return fmt.Errorf("duplicate subject prefix: %s", prefix)
+		}
+
+		prefixes[prefix] = true
+	}
+
+	return nil
+}
+
+// prefix returns the longest configured prefix of the subject.
+func (c *SubjectLagConfig) prefix(subject string) string {
+	match := subjectPrefixOther
+	length := 0
+
+	for _, prefix := range c.Prefixes {
+		if len(prefix) > length && strings.HasPrefix(subject, prefix) {
+			match = prefix
+			length = len(prefix)
+		}
+	}
+
+	return match
+}
+
+// observeSubjectLag records the lag of an event with a time set by the producer.
+// Events in the future (eg. because of clock drift) are recorded with no lag.
+func (h Handler) observeSubjectLag(ctx context.Context, ev event.Event) {
+	lag := h.now().Sub(ev.Time())
+	if lag < 0 {
+		lag = 0
+	}
+
+	h.metrics().RecordSubjectLag(ctx, h.SubjectLag.prefix(ev.Subject()), lag)
+}
@@ -0,0 +1,71 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSubjectLagConfig_Prefix(t *testing.T) {
+	config := &SubjectLagConfig{Prefixes: []string{"customer-", "customer-eu-", "internal-"}}
+
+	assert.Equal(t, "customer-", config.prefix("customer-1"))
+	assert.Equal(t, "customer-eu-", config.prefix("customer-eu-1"))
+	assert.Equal(t, "internal-", config.prefix("internal-batch"))
+	assert.Equal(t, "other", config.prefix("test"))
+
+	assert.Error(t, SubjectLagConfig{Prefixes: []string{"customer-", "customer-"}}.Validate())
+}
+
+func TestHandler_SubjectLag(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+
+	handler := Handler{
+		Collector:  &testcollector.Collector{},
+		Metrics:    metrics,
+		Clock:      func() time.Time { return now },
+		SubjectLag: &SubjectLagConfig{Prefixes: []string{"customer-"}},
+	}
+
+	newEvent := func(subject string, t time.Time) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetSubject(subject)
+
+		if !t.IsZero() {
+			ev.SetTime(t)
+		}
+
+		return ev
+	}
+
+	ctx := context.Background()
+
+	require.NoError(t, handler.processEvent(ctx, newEvent("customer-1", now.Add(-2*time.Second))))
+	require.NoError(t, handler.processEvent(ctx, newEvent("customer-2", now.Add(-3*time.Second))))
+	require.NoError(t, handler.processEvent(ctx, newEvent("test", now.Add(time.Minute))))
+
+	// Events without a time are not delayed
+	require.NoError(t, handler.processEvent(ctx, newEvent("test", time.Time{})))
+
+	customers := gatherHistogram(t, registry, "openmeter_ingest_event_lag_seconds", map[string]string{"subject_prefix": "customer-"})
+	assert.Equal(t, uint64(2), customers.GetSampleCount())
+	assert.Equal(t, float64(5), customers.GetSampleSum())
+
+	other := gatherHistogram(t, registry, "openmeter_ingest_event_lag_seconds", map[string]string{"subject_prefix": "other"})
+	assert.Equal(t, uint64(1), other.GetSampleCount())
+	assert.Equal(t, float64(0), other.GetSampleSum())
+}
@@ -0,0 +1,79 @@
+package httpingest
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// LastError is the last error returned by the {Collector}.
+type LastError struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// LastErrorTracker remembers the last error returned by the {Collector} and when it happened,
+// so operators can tell whether the downstream is failing without tailing logs.
+//
+// Only the last error is kept. The zero value is ready to use. It is safe for concurrent use.
+type LastErrorTracker struct {
+	// ClearOnSuccess forgets the last error once an event is forwarded successfully,
+	// so an error is only reported while the downstream is failing.
+	ClearOnSuccess bool
+
+	last atomic.Pointer[LastError]
+}
+
+// Last returns the last error, if any.
+func (t *LastErrorTracker) Last() (LastError, bool) {
+	if t == nil {
+		return LastError{}, false
+	}
+
+	last := t.last.Load()
+	if last == nil {
+		return LastError{}, false
+	}
+
+	return *last, true
+}
+
+// observe records the outcome of forwarding an event. A nil *LastErrorTracker records nothing.
+func (t *LastErrorTracker) observe(err error) {
+	if t == nil {
+		return
+	}
+
+	if err != nil {
+		t.last.Store(&LastError{Error: err.Error(), Time: time.Now().UTC()})
+
+		return
+	}
+
+	// Avoid contended writes on the hot path when there is nothing to clear
+	if t.ClearOnSuccess && t.last.Load() != nil {
+		t.last.Store(nil)
+	}
+}
+
+// LastErrorResponse is the response of a LastErrorHandler.
+type LastErrorResponse struct {
+	LastError *LastError `json:"lastError"`
+}
+
+// LastErrorHandler reports the last error tracked by a LastErrorTracker (null without one).
+type LastErrorHandler struct {
+	Tracker *LastErrorTracker
+}
+
+func (h LastErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resp LastErrorResponse
+
+	if last, ok := h.Tracker.Last(); ok {
+		resp.LastError = &last
+	}
+
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,82 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestLastErrorTracker(t *testing.T) {
+	for _, clearOnSuccess := range []bool{true, false} {
+		name := "Keep"
+		if clearOnSuccess {
+			name = "ClearOnSuccess"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			tracker := &LastErrorTracker{ClearOnSuccess: clearOnSuccess}
+
+			handler := Handler{
+				Collector: collector,
+				LastError: tracker,
+			}
+
+			ev := event.New()
+			ev.SetID("1")
+			ev.SetSource("test")
+
+			require.NoError(t, handler.processEvent(context.Background(), ev))
+
+			_, ok := tracker.Last()
+			assert.False(t, ok)
+
+			collector.Err = errors.New("broker unavailable")
+			require.Error(t, handler.processEvent(context.Background(), ev))
+
+			last, ok := tracker.Last()
+			require.True(t, ok)
+			assert.Equal(t, "broker unavailable", last.Error)
+			assert.False(t, last.Time.IsZero())
+
+			collector.Err = nil
+			require.NoError(t, handler.processEvent(context.Background(), ev))
+
+			_, ok = tracker.Last()
+			assert.Equal(t, !clearOnSuccess, ok)
+		})
+	}
+}
+
+func TestLastErrorHandler(t *testing.T) {
+	tracker := &LastErrorTracker{}
+
+	get := func() LastErrorResponse {
+		w := httptest.NewRecorder()
+		LastErrorHandler{Tracker: tracker}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ingest/lasterror", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp LastErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	assert.Nil(t, get().LastError)
+
+	tracker.observe(errors.New("broker unavailable"))
+
+	resp := get()
+	require.NotNil(t, resp.LastError)
+	assert.Equal(t, "broker unavailable", resp.LastError.Error)
+}
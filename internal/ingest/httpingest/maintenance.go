@@ -0,0 +1,154 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+)
+
+const defaultMaintenanceRetryAfter = time.Minute
+
+// MaintenanceWindow describes a planned maintenance.
+type MaintenanceWindow struct {
+	// Until is the expected end of the maintenance (optional): maintenance mode ends automatically then.
+	// Requests are rejected until maintenance mode is stopped otherwise.
+	Until time.Time `json:"until,omitempty"`
+
+	// RetryAfter is the delay advertised to clients when the end of the maintenance is unknown. Defaults to 1 minute.
+	RetryAfter time.Duration `json:"-"`
+
+	// Message is reported to clients (optional).
+	Message string `json:"message,omitempty"`
+}
+
+// Maintenance toggles the maintenance mode of a {Handler} at runtime:
+// requests are rejected with 503 and a Retry-After header while it is on (including HEAD requests), before their body is read.
+// Clients retry later instead of having their events accepted and then lost, so the collector can be drained safely.
+//
+// The zero value is ready to use, with maintenance mode off. It is safe for concurrent use.
+type Maintenance struct {
+	mu     sync.RWMutex
+	window *MaintenanceWindow
+}
+
+// Start turns maintenance mode on (or replaces the window of the maintenance in progress).
+func (m *Maintenance) Start(window MaintenanceWindow) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.window = &window
+}
+
+// Stop turns maintenance mode off.
+func (m *Maintenance) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.window = nil
+}
+
+// Window returns the window of the maintenance in progress, if any.
+func (m *Maintenance) Window() (MaintenanceWindow, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.window == nil || (!m.window.Until.IsZero() && !time.Now().Before(m.window.Until)) {
+		return MaintenanceWindow{}, false
+	}
+
+	return *m.window, true
+}
+
+// check rejects requests during maintenance. A nil *Maintenance never rejects requests.
+func (m *Maintenance) check(_ *http.Request) error {
+	if m == nil {
+		return nil
+	}
+
+	window, ok := m.Window()
+	if !ok {
+		return nil
+	}
+
+	msg := window.Message
+	if msg == "" {
+		msg = "ingestion is unavailable during maintenance, retry later"
+	}
+
+	retryAfter := window.RetryAfter
+	if !window.Until.IsZero() {
+		retryAfter = time.Until(window.Until)
+	}
+
+	if retryAfter <= 0 {
+		retryAfter = defaultMaintenanceRetryAfter
+	}
+
+	err := NewEventError(http.StatusServiceUnavailable, errors.New(msg))
+	err.RetryAfter = retryAfter
+
+	return err
+}
+
+// MaintenanceResponse is the response of a MaintenanceHandler.
+type MaintenanceResponse struct {
+	Maintenance bool               `json:"maintenance"`
+	Window      *MaintenanceWindow `json:"window,omitempty"`
+}
+
+// MaintenanceHandler toggles maintenance mode (see Maintenance) on demand:
+// PUT starts a maintenance with the window in the request body (optional), DELETE stops it and GET reports it.
+type MaintenanceHandler struct {
+	Maintenance *Maintenance
+	Logger      *slog.Logger
+}
+
+func (h MaintenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		var window MaintenanceWindow
+
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&window); err != nil {
+				renderError(w, r, NewEventErrorf(http.StatusBadRequest, "invalid maintenance window: %w", err))
+
+				return
+			}
+		}
+
+		h.Maintenance.Start(window)
+
+		logger.InfoCtx(r.Context(), "maintenance mode started", "until", window.Until, "message", window.Message)
+
+	case http.MethodDelete:
+		h.Maintenance.Stop()
+
+		logger.InfoCtx(r.Context(), "maintenance mode stopped")
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		renderError(w, r, NewEventErrorf(http.StatusMethodNotAllowed, "method not allowed"))
+
+		return
+	}
+
+	var resp MaintenanceResponse
+
+	if window, ok := h.Maintenance.Window(); ok {
+		resp = MaintenanceResponse{Maintenance: true, Window: &window}
+	}
+
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,100 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_Maintenance(t *testing.T) {
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	collector := &testcollector.Collector{}
+	maintenance := &Maintenance{}
+
+	handler := Handler{
+		Collector:   collector,
+		Maintenance: maintenance,
+	}
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	maintenance.Start(MaintenanceWindow{Message: "planned maintenance"})
+
+	w = send()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "planned maintenance")
+
+	maintenance.Start(MaintenanceWindow{Until: time.Now().Add(90 * time.Second)})
+
+	w = send()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+
+	maintenance.Stop()
+
+	w = send()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Maintenance ends automatically at the end of the window
+	maintenance.Start(MaintenanceWindow{Until: time.Now().Add(-time.Second)})
+
+	w = send()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	testcollector.AssertReceived(t, collector, "1", "1", "1")
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	maintenance := &Maintenance{}
+	handler := MaintenanceHandler{Maintenance: maintenance}
+
+	serve := func(method string, body string) MaintenanceResponse {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/ingest/maintenance", strings.NewReader(body)))
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp MaintenanceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	assert.Equal(t, MaintenanceResponse{}, serve(http.MethodGet, ""))
+
+	resp := serve(http.MethodPut, `{"message":"planned maintenance"}`)
+	assert.Equal(t, MaintenanceResponse{Maintenance: true, Window: &MaintenanceWindow{Message: "planned maintenance"}}, resp)
+
+	_, ok := maintenance.Window()
+	assert.True(t, ok)
+
+	assert.Equal(t, MaintenanceResponse{}, serve(http.MethodDelete, ""))
+
+	_, ok = maintenance.Window()
+	assert.False(t, ok)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/ingest/maintenance", strings.NewReader("{")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,146 @@
+package httpingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+)
+
+// MaskMode defines how an event attribute is masked when it is written to the logs.
+type MaskMode string
+
+const (
+	// MaskModePlain logs the attribute as is.
+	MaskModePlain MaskMode = "plain"
+
+	// MaskModeHash logs a (truncated) SHA-256 hash of the attribute.
+	// The same value always results in the same hash, so log records remain correlatable.
+	MaskModeHash MaskMode = "hash"
+
+	// MaskModeTruncate logs the first few characters of the attribute.
+	MaskModeTruncate MaskMode = "truncate"
+
+	// MaskModeRedact replaces the attribute with a fixed placeholder.
+	MaskModeRedact MaskMode = "redact"
+)
+
+// Validate validates the mask mode.
+func (m MaskMode) Validate() error {
+	switch m {
+	case MaskModePlain, MaskModeHash, MaskModeTruncate, MaskModeRedact:
+		return nil
+	}
+
+	return fmt.Errorf("invalid mask mode: %q", m)
+}
+
+const (
+	defaultMaskTruncateLength = 4
+	maskHashLength            = 16
+	maskRedacted              = "[REDACTED]"
+)
+
+// LogMask configures how a single event attribute is masked.
+type LogMask struct {
+	Mode MaskMode
+
+	// Length is the number of leading characters kept by MaskModeTruncate.
+	// Defaults to 4.
+	Length int
+}
+
+// Validate validates the mask.
+func (m LogMask) Validate() error {
+	if err := m.Mode.Validate(); err != nil {
+		return err
+	}
+
+	if m.Length < 0 {
+		return fmt.Errorf("invalid truncate length: %d", m.Length)
+	}
+
+	return nil
+}
+
+func (m LogMask) apply(value string) string {
+	switch m.Mode {
+	case MaskModeHash:
+		sum := sha256.Sum256([]byte(value))
+
+		return hex.EncodeToString(sum[:])[:maskHashLength]
+
+	case MaskModeTruncate:
+		length := m.Length
+		if length == 0 {
+			length = defaultMaskTruncateLength
+		}
+
+		runes := []rune(value)
+		if len(runes) <= length {
+			return value
+		}
+
+		return string(runes[:length]) + "..."
+
+	case MaskModeRedact:
+		return maskRedacted
+	}
+
+	return value
+}
+
+// LogMaskPolicy maps event attribute names (id, subject, source) to the mask applied to them in log records.
+// Attributes missing from the policy are logged as is, other attribute names are invalid (eg. a misspelled subjct would leave subjects unmasked).
+//
+// The policy only affects logs: events are always forwarded to the {Collector} unchanged.
+type LogMaskPolicy map[string]LogMask
+
+// maskedAttributes are the event attributes a LogMaskPolicy applies to.
+var maskedAttributes = []string{"id", "subject", "source"}
+
+// Validate validates the policy.
+func (p LogMaskPolicy) Validate() error {
+	for attr, mask := range p {
+		if !isMaskedAttribute(attr) {
+			return fmt.Errorf("unsupported event attribute %q: must be one of %s", attr, strings.Join(maskedAttributes, ", "))
+		}
+
+		if err := mask.Validate(); err != nil {
+			return fmt.Errorf("event attribute %s: %w", attr, err)
+		}
+	}
+
+	return nil
+}
+
+func isMaskedAttribute(attr string) bool {
+	for _, masked := range maskedAttributes {
+		if attr == masked {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p LogMaskPolicy) mask(attr string, value string) string {
+	mask, ok := p[attr]
+	if !ok || value == "" {
+		return value
+	}
+
+	return mask.apply(value)
+}
+
+// logAttrs returns the event attributes attached to every log record related to an event.
+func (p LogMaskPolicy) logAttrs(ev event.Event) []any {
+	return []any{
+		slog.String("event_id", p.mask("id", ev.ID())),
+		slog.String("event_subject", p.mask("subject", ev.Subject())),
+		slog.String("event_source", p.mask("source", ev.Source())),
+	}
+}
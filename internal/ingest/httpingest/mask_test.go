@@ -0,0 +1,110 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestLogMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		mask     LogMask
+		value    string
+		expected string
+	}{
+		{
+			name:     "plain",
+			mask:     LogMask{Mode: MaskModePlain},
+			value:    "customer-1",
+			expected: "customer-1",
+		},
+		{
+			name:     "hash",
+			mask:     LogMask{Mode: MaskModeHash},
+			value:    "customer-1",
+			expected: "e83f10dcd2c68747",
+		},
+		{
+			name:     "truncate",
+			mask:     LogMask{Mode: MaskModeTruncate},
+			value:    "customer-1",
+			expected: "cust...",
+		},
+		{
+			name:     "truncate with length",
+			mask:     LogMask{Mode: MaskModeTruncate, Length: 8},
+			value:    "customer-1",
+			expected: "customer...",
+		},
+		{
+			name:     "truncate short value",
+			mask:     LogMask{Mode: MaskModeTruncate},
+			value:    "cus",
+			expected: "cus",
+		},
+		{
+			name:     "redact",
+			mask:     LogMask{Mode: MaskModeRedact},
+			value:    "customer-1",
+			expected: "[REDACTED]",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.mask.apply(test.value))
+		})
+	}
+}
+
+func TestLogMaskPolicy_Validate(t *testing.T) {
+	assert.NoError(t, LogMaskPolicy{"subject": {Mode: MaskModeHash}}.Validate())
+	assert.Error(t, LogMaskPolicy{"subject": {Mode: "scramble"}}.Validate())
+	assert.Error(t, LogMaskPolicy{"subject": {Mode: MaskModeTruncate, Length: -1}}.Validate())
+	assert.Error(t, LogMaskPolicy{"subjct": {Mode: MaskModeHash}}.Validate(), "unknown attributes would be logged unmasked")
+}
+
+func TestHandler_LogMaskPolicy(t *testing.T) {
+	var logs bytes.Buffer
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		Logger:    slog.New(slog.NewJSONHandler(&logs, nil)),
+		LogMaskPolicy: LogMaskPolicy{
+			"subject": {Mode: MaskModeRedact},
+		},
+	}
+
+	ev := event.New()
+	ev.SetID("id")
+	ev.SetSubject("customer-1")
+	ev.SetSource("test")
+
+	body, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.NotContains(t, logs.String(), "customer-1")
+	assert.Contains(t, logs.String(), `"event_subject":"[REDACTED]"`)
+	assert.Contains(t, logs.String(), `"event_source":"test"`)
+
+	require.Len(t, collector.Events(), 1)
+	assert.Equal(t, "customer-1", collector.Events()[0].Subject(), "masking must not affect forwarded events")
+}
@@ -0,0 +1,118 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const (
+	defaultMeterExtension = "meter"
+	defaultValueExtension = "value"
+)
+
+// MeterExtractorConfig configures a MeterExtractor.
+type MeterExtractorConfig struct {
+	// MeterPath is the JSONPath of the meter name in the event data (eg. $.meter).
+	MeterPath string
+
+	// ValuePath is the JSONPath of the numeric metering value in the event data (eg. $.value).
+	ValuePath string
+
+	// MeterExtension is the extension the meter name is set in. Defaults to meter.
+	MeterExtension string
+
+	// ValueExtension is the extension the metering value is set in. Defaults to value.
+	ValueExtension string
+}
+
+// MeterExtractor enforces the metering contract of events: every event must have a meter name and a numeric value in its data.
+//
+// The extracted meter name and value are set in extensions of the event
+// (values are formatted as strings, as CloudEvents extensions do not support floating point numbers).
+type MeterExtractor struct {
+	meterPath      jsonPath
+	valuePath      jsonPath
+	config         MeterExtractorConfig
+	meterExtension string
+	valueExtension string
+}
+
+// NewMeterExtractor returns a new MeterExtractor.
+func NewMeterExtractor(config MeterExtractorConfig) (*MeterExtractor, error) {
+	meterPath, err := parseJSONPath(config.MeterPath)
+	if err != nil {
+		return nil, fmt.Errorf("meter path: %w", err)
+	}
+
+	valuePath, err := parseJSONPath(config.ValuePath)
+	if err != nil {
+		return nil, fmt.Errorf("value path: %w", err)
+	}
+
+	e := &MeterExtractor{
+		meterPath:      meterPath,
+		valuePath:      valuePath,
+		config:         config,
+		meterExtension: config.MeterExtension,
+		valueExtension: config.ValueExtension,
+	}
+
+	if e.meterExtension == "" {
+		e.meterExtension = defaultMeterExtension
+	}
+
+	if e.valueExtension == "" {
+		e.valueExtension = defaultValueExtension
+	}
+
+	for _, name := range []string{e.meterExtension, e.valueExtension} {
+		if !event.IsExtensionNameValid(name) {
+			return nil, fmt.Errorf("invalid extension name: %q", name)
+		}
+	}
+
+	return e, nil
+}
+
+// extract returns the meter name and the metering value of the event.
+func (e *MeterExtractor) extract(ev event.Event) (string, float64, error) {
+	value, err := e.meterPath.lookup(ev.Data())
+	if errors.Is(err, errJSONPathNotFound) {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "missing meter at %s", e.config.MeterPath)
+	} else if err != nil {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "invalid meter at %s: %w", e.config.MeterPath, err)
+	}
+
+	meter, ok := value.(string)
+	if !ok || meter == "" {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "meter at %s must be a non-empty string", e.config.MeterPath)
+	}
+
+	number, err := e.valuePath.lookupNumber(ev.Data())
+	if errors.Is(err, errJSONPathNotFound) {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "missing value at %s", e.config.ValuePath)
+	} else if err != nil {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "invalid value at %s: %w", e.config.ValuePath, err)
+	}
+
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", 0, NewEventErrorf(http.StatusBadRequest, "invalid value at %s: %v", e.config.ValuePath, number)
+	}
+
+	return meter, number, nil
+}
+
+func (e *MeterExtractor) extensions() []string {
+	return []string{e.meterExtension, e.valueExtension}
+}
+
+// applyMeter sets the extracted meter name and value in the extensions of the event.
+func (h Handler) applyMeter(ev *event.Event, meter string, value float64) {
+	h.setServerExtension(ev, h.MeterExtractor.meterExtension, meter)
+	h.setServerExtension(ev, h.MeterExtractor.valueExtension, strconv.FormatFloat(value, 'f', -1, 64))
+}
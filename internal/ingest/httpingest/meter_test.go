@@ -0,0 +1,71 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_MeterExtractor(t *testing.T) {
+	extractor, err := NewMeterExtractor(MeterExtractorConfig{
+		MeterPath: "$.meter",
+		ValuePath: "$.usage.value",
+	})
+	require.NoError(t, err)
+
+	newEvent := func(t *testing.T, data string) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+		return ev
+	}
+
+	tests := []struct {
+		name       string
+		data       string
+		statusCode int
+		meter      string
+		value      string
+	}{
+		{name: "Number", data: `{"meter": "tokens", "usage": {"value": 12.5}}`, statusCode: http.StatusOK, meter: "tokens", value: "12.5"},
+		{name: "NumericString", data: `{"meter": "tokens", "usage": {"value": "3"}}`, statusCode: http.StatusOK, meter: "tokens", value: "3"},
+		{name: "MissingMeter", data: `{"usage": {"value": 1}}`, statusCode: http.StatusBadRequest},
+		{name: "InvalidMeter", data: `{"meter": 1, "usage": {"value": 1}}`, statusCode: http.StatusBadRequest},
+		{name: "MissingValue", data: `{"meter": "tokens"}`, statusCode: http.StatusBadRequest},
+		{name: "InvalidValue", data: `{"meter": "tokens", "usage": {"value": "many"}}`, statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:      collector,
+				MeterExtractor: extractor,
+			}
+
+			err := handler.processEvent(context.Background(), newEvent(t, tt.data))
+			if tt.statusCode != http.StatusOK {
+				require.Error(t, err)
+				assert.Equal(t, tt.statusCode, statusCode(err))
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, collector.Events(), 1)
+
+			assert.Equal(t, tt.meter, collector.Events()[0].Extensions()["meter"])
+			assert.Equal(t, tt.value, collector.Events()[0].Extensions()["value"])
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package httpingest
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "openmeter"
+	metricsSubsystem = "ingest"
+
+	// labelEndpoint labels metrics with the endpoint of the handler (see Handler.Endpoint).
+	labelEndpoint = "endpoint"
+)
+
+// MetricsRecorder records ingestion metrics with some metrics backend.
+//
+// Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// RecordRequest counts an ingest request with the content type.
+	RecordRequest(ctx context.Context, contentType string)
+
+	// RecordRequestBodySize records the size of a request body as received (encoded) and after decoding the content encoding (decoded).
+	RecordRequestBodySize(ctx context.Context, contentType string, encoded int64, decoded int64)
+
+	// RecordDuplicateRequest counts a request with a body identical to a recently received request.
+	RecordDuplicateRequest(ctx context.Context)
+
+	// RecordRateLimitTokens records the number of tokens left in the rate limiter bucket.
+	RecordRateLimitTokens(ctx context.Context, tokens float64)
+
+	// RecordClockDrift records the drift between the time of an event and the ingest time (positive when the event is in the past).
+	RecordClockDrift(ctx context.Context, drift time.Duration, exceeded bool)
+
+	// RecordSubjectLag records the ingestion lag of an event by subject prefix.
+	RecordSubjectLag(ctx context.Context, subjectPrefix string, lag time.Duration)
+
+	// RecordSequenceAnomaly counts a gap or an out of order sequence number (see SequenceTracker).
+	RecordSequenceAnomaly(ctx context.Context, anomaly string)
+
+	// RecordRejection counts a rejected request or event (see RejectionScopeRequest and RejectionScopeEvent) by reason.
+	RecordRejection(ctx context.Context, scope string, reason RejectionReason)
+}
+
+// MultiMetricsRecorder records ingestion metrics with every recorder (eg. with both Prometheus and OpenTelemetry).
+type MultiMetricsRecorder []MetricsRecorder
+
+func (r MultiMetricsRecorder) RecordRequest(ctx context.Context, contentType string) {
+	for _, recorder := range r {
+		recorder.RecordRequest(ctx, contentType)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordRequestBodySize(ctx context.Context, contentType string, encoded int64, decoded int64) {
+	for _, recorder := range r {
+		recorder.RecordRequestBodySize(ctx, contentType, encoded, decoded)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordDuplicateRequest(ctx context.Context) {
+	for _, recorder := range r {
+		recorder.RecordDuplicateRequest(ctx)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordRateLimitTokens(ctx context.Context, tokens float64) {
+	for _, recorder := range r {
+		recorder.RecordRateLimitTokens(ctx, tokens)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordClockDrift(ctx context.Context, drift time.Duration, exceeded bool) {
+	for _, recorder := range r {
+		recorder.RecordClockDrift(ctx, drift, exceeded)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordSubjectLag(ctx context.Context, subjectPrefix string, lag time.Duration) {
+	for _, recorder := range r {
+		recorder.RecordSubjectLag(ctx, subjectPrefix, lag)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordSequenceAnomaly(ctx context.Context, anomaly string) {
+	for _, recorder := range r {
+		recorder.RecordSequenceAnomaly(ctx, anomaly)
+	}
+}
+
+func (r MultiMetricsRecorder) RecordRejection(ctx context.Context, scope string, reason RejectionReason) {
+	for _, recorder := range r {
+		recorder.RecordRejection(ctx, scope, reason)
+	}
+}
+
+// Metrics records ingestion metrics with Prometheus.
+//
+// A nil *Metrics is valid and records nothing.
+type Metrics struct {
+	duplicateRequests *prometheus.CounterVec
+	requestBodySize   *prometheus.HistogramVec
+	rateLimitTokens   *prometheus.GaugeVec
+	requests          *prometheus.CounterVec
+	clockDrift        *prometheus.HistogramVec
+	clockDriftHigh    *prometheus.CounterVec
+	subjectLag        *prometheus.HistogramVec
+	sequenceAnomalies *prometheus.CounterVec
+	rejections        *prometheus.CounterVec
+}
+
+// NewMetrics creates ingestion metrics and registers them in the registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		duplicateRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "duplicate_requests_total",
+			Help:      "Number of requests with a body identical to a recently received request.",
+		}, []string{labelEndpoint}),
+		requestBodySize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "request_body_size_bytes",
+			Help:      "Size of request bodies as received (encoded) and after decoding the content encoding (decoded).",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 10), // 256B - 64MB
+		}, []string{labelEndpoint, "content_type", "stage"}),
+		rateLimitTokens: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rate_limit_tokens",
+			Help:      "Number of tokens left in the global event rate limiter bucket (as of the last processed event).",
+		}, []string{labelEndpoint}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "requests_total",
+			Help:      "Number of ingest requests by content type and mode (single or batch).",
+		}, []string{labelEndpoint, "content_type", "mode"}),
+		clockDrift: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "event_clock_drift_seconds",
+			Help:      "Absolute difference between the time of events and the time they are ingested, by direction (past or future).",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600, 21600, 86400},
+		}, []string{labelEndpoint, "direction"}),
+		clockDriftHigh: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "event_clock_drift_exceeded_total",
+			Help:      "Number of events with a clock drift beyond the configured threshold.",
+		}, []string{labelEndpoint}),
+		subjectLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "event_lag_seconds",
+			Help:      "Difference between the time events are ingested and the time of events, by subject prefix.",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600, 21600, 86400},
+		}, []string{labelEndpoint, "subject_prefix"}),
+		sequenceAnomalies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "event_sequence_anomalies_total",
+			Help:      "Number of events with a sequence number out of order or after a gap, by anomaly.",
+		}, []string{labelEndpoint, "anomaly"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "rejections_total",
+			Help:      "Number of rejected requests and events, by scope (request or event) and reason.",
+		}, []string{labelEndpoint, "scope", "reason"}),
+	}
+
+	for _, collector := range []prometheus.Collector{
+		m.duplicateRequests,
+		m.requestBodySize,
+		m.rateLimitTokens,
+		m.requests,
+		m.clockDrift,
+		m.clockDriftHigh,
+		m.subjectLag,
+		m.sequenceAnomalies,
+		m.rejections,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) RecordDuplicateRequest(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	m.duplicateRequests.WithLabelValues(endpointFromContext(ctx)).Inc()
+}
+
+func (m *Metrics) RecordRateLimitTokens(ctx context.Context, tokens float64) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimitTokens.WithLabelValues(endpointFromContext(ctx)).Set(tokens)
+}
+
+func (m *Metrics) RecordClockDrift(ctx context.Context, drift time.Duration, exceeded bool) {
+	if m == nil {
+		return
+	}
+
+	endpoint := endpointFromContext(ctx)
+	direction, drift := clockDriftDirection(drift)
+
+	m.clockDrift.WithLabelValues(endpoint, direction).Observe(drift.Seconds())
+
+	if exceeded {
+		m.clockDriftHigh.WithLabelValues(endpoint).Inc()
+	}
+}
+
+func (m *Metrics) RecordSubjectLag(ctx context.Context, subjectPrefix string, lag time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.subjectLag.WithLabelValues(endpointFromContext(ctx), subjectPrefix).Observe(lag.Seconds())
+}
+
+func (m *Metrics) RecordSequenceAnomaly(ctx context.Context, anomaly string) {
+	if m == nil {
+		return
+	}
+
+	m.sequenceAnomalies.WithLabelValues(endpointFromContext(ctx), anomaly).Inc()
+}
+
+func (m *Metrics) RecordRejection(ctx context.Context, scope string, reason RejectionReason) {
+	if m == nil {
+		return
+	}
+
+	m.rejections.WithLabelValues(endpointFromContext(ctx), scope, string(reason)).Inc()
+}
+
+// clockDriftDirection returns the direction (past or future) and the absolute value of a drift.
+func clockDriftDirection(drift time.Duration) (string, time.Duration) {
+	if drift < 0 {
+		return "future", -drift
+	}
+
+	return "past", drift
+}
+
+// Modes of ingest requests.
+const (
+	requestModeSingle = "single"
+	requestModeBatch  = "batch"
+)
+
+// requestMode returns the mode of requests with the content type.
+func requestMode(contentType string) string {
+	switch contentType {
+	case ContentTypeBatch, ContentTypeNDJSON:
+		return requestModeBatch
+	}
+
+	return requestModeSingle
+}
+
+func (m *Metrics) RecordRequest(ctx context.Context, contentType string) {
+	if m == nil {
+		return
+	}
+
+	m.requests.WithLabelValues(endpointFromContext(ctx), contentTypeLabel(contentType), requestMode(contentType)).Inc()
+}
+
+// Stages of reading a request body.
+const (
+	bodyStageEncoded = "encoded"
+	bodyStageDecoded = "decoded"
+)
+
+func (m *Metrics) RecordRequestBodySize(ctx context.Context, contentType string, encoded int64, decoded int64) {
+	if m == nil {
+		return
+	}
+
+	endpoint := endpointFromContext(ctx)
+
+	m.requestBodySize.WithLabelValues(endpoint, contentTypeLabel(contentType), bodyStageEncoded).Observe(float64(encoded))
+	m.requestBodySize.WithLabelValues(endpoint, contentTypeLabel(contentType), bodyStageDecoded).Observe(float64(decoded))
+}
+
+// noopMetrics records nothing.
+type noopMetrics struct{}
+
+func (noopMetrics) RecordRequest(context.Context, string) {}
+
+func (noopMetrics) RecordRequestBodySize(context.Context, string, int64, int64) {}
+
+func (noopMetrics) RecordDuplicateRequest(context.Context) {}
+
+func (noopMetrics) RecordRateLimitTokens(context.Context, float64) {}
+
+func (noopMetrics) RecordClockDrift(context.Context, time.Duration, bool) {}
+
+func (noopMetrics) RecordSubjectLag(context.Context, string, time.Duration) {}
+
+func (noopMetrics) RecordSequenceAnomaly(context.Context, string) {}
+
+func (noopMetrics) RecordRejection(context.Context, string, RejectionReason) {}
+
+// metrics returns the metrics recorder of the handler.
+func (h Handler) metrics() MetricsRecorder {
+	if h.Metrics == nil {
+		return noopMetrics{}
+	}
+
+	return h.Metrics
+}
+
+// contentTypeLabel bounds the cardinality of content type labels to the supported content types.
+func contentTypeLabel(contentType string) string {
+	switch contentType {
+	case ContentTypeSingle, ContentTypeBatch, ContentTypeNDJSON, ContentTypeAvro:
+		return contentType
+	case "":
+		return "none"
+	}
+
+	return "other"
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+
+	n int64
+}
+
+func newCountingReader(r io.ReadCloser) *countingReader {
+	return &countingReader{
+		ReadCloser: r,
+	}
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	r.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,130 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+// gatherHistogram returns the histogram with the given name and labels from the registry.
+func gatherHistogram(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) *dto.Histogram {
+	t.Helper()
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+
+			return metric.GetHistogram()
+		}
+	}
+
+	t.Fatalf("histogram %s %v not found", name, labels)
+
+	return nil
+}
+
+func TestMetrics_RequestBodySize(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+		Metrics:   metrics,
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	requests := []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: ContentTypeSingle, body: ev},
+		{contentType: ContentTypeNDJSON, body: ev + "\n" + ev + "\n"},
+		{contentType: "text/plain", body: ev},
+	}
+
+	for _, r := range requests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(r.body))
+		req.Header.Set("Content-Type", r.contentType)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	for contentType, size := range map[string]int{
+		ContentTypeSingle: len(ev),
+		ContentTypeNDJSON: 2*len(ev) + 2,
+		"other":           len(ev),
+	} {
+		for _, stage := range []string{bodyStageEncoded, bodyStageDecoded} {
+			histogram := gatherHistogram(t, registry, "openmeter_ingest_request_body_size_bytes", map[string]string{
+				"content_type": contentType,
+				"stage":        stage,
+			})
+
+			assert.Equal(t, uint64(1), histogram.GetSampleCount(), contentType)
+			assert.Equal(t, float64(size), histogram.GetSampleSum(), contentType)
+		}
+	}
+}
+
+func TestMetrics_Requests(t *testing.T) {
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+		Metrics:   metrics,
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	for _, r := range []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: ContentTypeSingle, body: ev},
+		{contentType: ContentTypeSingle + "; charset=utf-8", body: ev},
+		{contentType: ContentTypeBatch, body: "[" + ev + "]"},
+		{contentType: ContentTypeNDJSON, body: ev + "\n"},
+		{contentType: "", body: ev},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(r.body))
+		req.Header.Set("Content-Type", r.contentType)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues("", ContentTypeSingle, requestModeSingle)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("", ContentTypeBatch, requestModeBatch)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("", ContentTypeNDJSON, requestModeBatch)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("", "none", requestModeSingle)))
+}
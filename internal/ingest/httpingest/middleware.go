@@ -0,0 +1,359 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// Middleware wraps an http.Handler.
+type Middleware func(next http.Handler) http.Handler
+
+// ChainConfig configures the middlewares composed by NewChain. Every middleware is optional.
+//
+// Middlewares are applied in the following order (from the outermost):
+//
+//  1. Recover: panics of every later middleware are recovered.
+//  2. RequestID: log records and error responses of every later middleware carry the request ID.
+//  3. Logger: rejected requests are logged too.
+//  4. TLS: plain HTTP requests are rejected before anything else is inspected.
+//  5. CORS: preflight requests (which carry no credentials) are answered before authentication,
+//     and rejections of later middlewares carry CORS headers so browsers can read them.
+//  6. Authenticate: only authenticated requests consume the rate limit.
+//  7. RateLimiter
+//  8. Middlewares
+type ChainConfig struct {
+	// Recover recovers panics, responding with 500.
+	Recover bool
+
+	// RequestID identifies requests: the ID is attached to the request context and the response,
+	// and the handler reuses it (in the requestid extension of events).
+	RequestID *RequestIDConfig
+
+	// Logger logs every request (method, path, status and duration).
+	Logger *slog.Logger
+
+	// TLS rejects plain HTTP requests.
+	TLS *TLSPolicy
+
+	// CORS allows browsers to send events from other origins.
+	CORS *CORSConfig
+
+	// Authenticate authenticates requests. Errors reject the request:
+	// errors created with NewEventErrorf are reported with their status, other errors with 401 Unauthorized.
+	Authenticate func(r *http.Request) error
+
+	// RateLimiter limits the number of requests (rather than events, see {Handler.RateLimiter}) per second globally.
+	// Sharing a limiter with the handler counts every request and event against the same bucket.
+	RateLimiter *RateLimiter
+
+	// Middlewares are custom middlewares applied after the built-in ones (closest to the handler), in order.
+	Middlewares []Middleware
+}
+
+// TLSPolicy configures how requests without TLS are handled.
+type TLSPolicy struct {
+	// TrustForwardedProto trusts the X-Forwarded-Proto header set by a TLS terminating proxy.
+	TrustForwardedProto bool
+
+	// HSTSMaxAge sets the Strict-Transport-Security header of responses to TLS requests (optional).
+	HSTSMaxAge time.Duration
+}
+
+// CORSConfig configures cross-origin requests.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to send events. "*" allows every origin.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers allowed in cross-origin requests. Defaults to Content-Type and Authorization.
+	AllowedHeaders []string
+
+	// MaxAge is the duration browsers may cache the response of a preflight request (optional).
+	MaxAge time.Duration
+}
+
+// Validate validates the configuration.
+func (c ChainConfig) Validate() error {
+	if c.RequestID != nil {
+		if err := c.RequestID.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.TLS != nil && c.TLS.HSTSMaxAge < 0 {
+		return errors.New("HSTS max age must not be negative")
+	}
+
+	if c.CORS != nil {
+		if len(c.CORS.AllowedOrigins) == 0 {
+			return errors.New("at least one allowed CORS origin is required")
+		}
+
+		if c.CORS.MaxAge < 0 {
+			return errors.New("CORS max age must not be negative")
+		}
+	}
+
+	for i, middleware := range c.Middlewares {
+		if middleware == nil {
+			return fmt.Errorf("middleware %d is nil", i)
+		}
+	}
+
+	return nil
+}
+
+// NewChain wraps the handler in the middlewares of the configuration, in the recommended order (see ChainConfig).
+func NewChain(handler http.Handler, config ChainConfig) (http.Handler, error) {
+	if handler == nil {
+		return nil, errors.New("handler is required")
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var middlewares []Middleware
+
+	if config.Recover {
+		middlewares = append(middlewares, recoverMiddleware(config.Logger))
+	}
+
+	if config.RequestID != nil {
+		middlewares = append(middlewares, requestIDMiddleware(*config.RequestID))
+	}
+
+	if config.Logger != nil {
+		middlewares = append(middlewares, loggerMiddleware(config.Logger))
+	}
+
+	if config.TLS != nil {
+		middlewares = append(middlewares, config.TLS.middleware)
+	}
+
+	if config.CORS != nil {
+		middlewares = append(middlewares, config.CORS.middleware)
+	}
+
+	if config.Authenticate != nil {
+		middlewares = append(middlewares, authenticateMiddleware(config.Authenticate))
+	}
+
+	if config.RateLimiter != nil {
+		middlewares = append(middlewares, config.RateLimiter.middleware)
+	}
+
+	middlewares = append(middlewares, config.Middlewares...)
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler, nil
+}
+
+func recoverMiddleware(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				// The server aborts the response without logging
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger.ErrorCtx(r.Context(), "request panicked", "panic", recovered)
+
+				renderError(w, r, NewEventErrorf(http.StatusInternalServerError, "internal server error"))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func requestIDMiddleware(config RequestIDConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Requests are identified the same way as by the handler
+			next.ServeHTTP(w, Handler{RequestID: &config}.identifyRequest(w, r))
+		})
+	}
+}
+
+func loggerMiddleware(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusResponseWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				slog.Duration("duration", time.Since(start)),
+			}
+
+			if requestID, ok := ingest.RequestIDFromContext(r.Context()); ok {
+				attrs = append(attrs, slog.String("request_id", requestID))
+			}
+
+			logger.InfoCtx(r.Context(), "request served", attrs...)
+		})
+	}
+}
+
+func (p *TLSPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secure := r.TLS != nil
+		if !secure && p.TrustForwardedProto {
+			secure = strings.EqualFold(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto")), "https")
+		}
+
+		if !secure {
+			renderError(w, r, NewEventErrorf(http.StatusForbidden, "TLS is required"))
+
+			return
+		}
+
+		if p.HSTSMaxAge > 0 {
+			w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(p.HSTSMaxAge.Seconds())))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORSConfig) middleware(next http.Handler) http.Handler {
+	allowedHeaders := c.AllowedHeaders
+	if len(allowedHeaders) == 0 {
+		allowedHeaders = []string{"Content-Type", "Authorization"}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !c.allowOrigin(origin) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		// Preflight request
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join([]string{http.MethodPost, http.MethodHead}, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func authenticateMiddleware(authenticate func(r *http.Request) error) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := authenticate(r); err != nil {
+				var eventErr *EventError
+				if !errors.As(err, &eventErr) {
+					err = NewEventErrorf(http.StatusUnauthorized, "unauthorized: %w", err)
+				}
+
+				renderError(w, r, err)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (l *RateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := l.allow(); err != nil {
+			limitErr := NewEventErrorf(http.StatusTooManyRequests, "global request rate limit exceeded")
+			limitErr.RetryAfter = retryAfter(err)
+
+			renderError(w, r, limitErr)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusResponseWriter records the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
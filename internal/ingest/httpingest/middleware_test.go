@@ -0,0 +1,165 @@
+package httpingest
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestNewChain(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	var logs bytes.Buffer
+
+	limiter, err := NewRateLimiter(RateLimiterConfig{Rate: 0.001, Burst: 2})
+	require.NoError(t, err)
+
+	var order []string
+
+	chain, err := NewChain(Handler{Collector: collector}, ChainConfig{
+		Recover:   true,
+		RequestID: &RequestIDConfig{},
+		Logger:    slog.New(slog.NewTextHandler(&logs, nil)),
+		TLS:       &TLSPolicy{TrustForwardedProto: true, HSTSMaxAge: time.Hour},
+		CORS:      &CORSConfig{AllowedOrigins: []string{"https://example.com"}, MaxAge: time.Minute},
+		Authenticate: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				return errors.New("invalid token")
+			}
+
+			return nil
+		},
+		RateLimiter: limiter,
+		Middlewares: []Middleware{
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, "first")
+
+					if r.Header.Get("X-Panic") != "" {
+						panic("boom")
+					}
+
+					next.ServeHTTP(w, r)
+				})
+			},
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, "second")
+
+					next.ServeHTTP(w, r)
+				})
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	events := newTestEvents(t, 3)
+
+	request := func(i int, header http.Header) *http.Request {
+		r := newSingleEventRequest(t, events[i])
+		r.Header.Set("Content-Type", "application/cloudevents+json")
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("Authorization", "Bearer token")
+		r.Header.Set("Origin", "https://example.com")
+
+		for name, values := range header {
+			r.Header.Set(name, values[0])
+		}
+
+		return r
+	}
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		chain.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("PlainHTTP", func(t *testing.T) {
+		w := serve(request(0, http.Header{"X-Forwarded-Proto": {"http"}}))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodOptions, "/", nil)
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+		w := serve(r)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w := serve(request(0, http.Header{"Authorization": {"Bearer invalid"}}))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Accepted", func(t *testing.T) {
+		order = nil
+
+		w := serve(request(0, http.Header{"X-Request-ID": {"request-1"}}))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "request-1", w.Header().Get("X-Request-ID"))
+		assert.Equal(t, "max-age=3600", w.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, []string{"first", "second"}, order)
+
+		testcollector.AssertReceived(t, collector, "0")
+		assert.Equal(t, "request-1", collector.Events()[0].Extensions()[RequestIDExtension])
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		w := serve(request(1, http.Header{"X-Panic": {"1"}}))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		w := serve(request(2, nil))
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Equal(t, 1, collector.Len())
+	})
+
+	assert.Contains(t, logs.String(), "status=401")
+	assert.Contains(t, logs.String(), "request_id=request-1")
+	assert.Contains(t, logs.String(), "request panicked")
+}
+
+func TestNewChain_Validate(t *testing.T) {
+	handler := Handler{Collector: &testcollector.Collector{}}
+
+	_, err := NewChain(nil, ChainConfig{})
+	assert.Error(t, err)
+
+	_, err = NewChain(handler, ChainConfig{CORS: &CORSConfig{}})
+	assert.Error(t, err)
+
+	_, err = NewChain(handler, ChainConfig{Middlewares: []Middleware{nil}})
+	assert.Error(t, err)
+
+	// Without middlewares the handler is served as is
+	chain, err := NewChain(handler, ChainConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, handler, chain)
+}
@@ -0,0 +1,83 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MaxMindGeoResolver resolves the location of IP addresses with a MaxMind DB file (eg. GeoLite2 Country or City).
+// The database is loaded in memory.
+type MaxMindGeoResolver struct {
+	reader *maxminddb.Reader
+}
+
+// NewMaxMindGeoResolver loads the MaxMind DB file at the path.
+func NewMaxMindGeoResolver(path string) (*MaxMindGeoResolver, error) {
+	db, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read maxmind db: %w", err)
+	}
+
+	r, err := newMaxMindGeoResolver(db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxmind db %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+func newMaxMindGeoResolver(db []byte) (*MaxMindGeoResolver, error) {
+	reader, err := maxminddb.FromBytes(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaxMindGeoResolver{reader: reader}, nil
+}
+
+// maxMindRecord is the part of a record of a GeoIP2/GeoLite2 Country or City database the location is resolved from.
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// Resolve returns the location of the IP address.
+// Addresses not in the database have an empty location.
+func (r *MaxMindGeoResolver) Resolve(ip net.IP) (GeoLocation, error) {
+	if ip.To16() == nil {
+		return GeoLocation{}, errors.New("invalid ip address")
+	}
+
+	var record maxMindRecord
+
+	if err := r.reader.Lookup(ip, &record); err != nil {
+		return GeoLocation{}, fmt.Errorf("lookup maxmind record: %w", err)
+	}
+
+	location := GeoLocation{
+		Country: record.Country.ISOCode,
+	}
+
+	if location.Country == "" {
+		location.Country = record.RegisteredCountry.ISOCode
+	}
+
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].ISOCode
+	}
+
+	return location, nil
+}
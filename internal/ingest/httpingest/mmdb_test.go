@@ -0,0 +1,77 @@
+package httpingest
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildMMDB returns a MaxMind DB with a single network located in California, US.
+func buildMMDB(t *testing.T, ipVersion int, recordSize int, network string) []byte {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "Test",
+		IPVersion:               ipVersion,
+		RecordSize:              recordSize,
+		IncludeReservedNetworks: true,
+	})
+	require.NoError(t, err)
+
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+
+	require.NoError(t, tree.Insert(ipNet, mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("US")},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"iso_code": mmdbtype.String("CA")},
+		},
+	}))
+
+	var db bytes.Buffer
+
+	_, err = tree.WriteTo(&db)
+	require.NoError(t, err)
+
+	return db.Bytes()
+}
+
+func TestMaxMindGeoResolver(t *testing.T) {
+	tests := []struct {
+		name       string
+		ipVersion  int
+		recordSize int
+	}{
+		{name: "IPv4", ipVersion: 4, recordSize: 24},
+		{name: "IPv6", ipVersion: 6, recordSize: 28},
+		{name: "IPv6Records32", ipVersion: 6, recordSize: 32},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.mmdb")
+			require.NoError(t, os.WriteFile(path, buildMMDB(t, test.ipVersion, test.recordSize, "203.0.113.0/24"), 0o600))
+
+			resolver, err := NewMaxMindGeoResolver(path)
+			require.NoError(t, err)
+
+			location, err := resolver.Resolve(net.ParseIP("203.0.113.7"))
+			require.NoError(t, err)
+			assert.Equal(t, GeoLocation{Country: "US", Region: "CA"}, location)
+
+			location, err = resolver.Resolve(net.ParseIP("198.51.100.1"))
+			require.NoError(t, err)
+			assert.Equal(t, GeoLocation{}, location)
+		})
+	}
+
+	_, err := newMaxMindGeoResolver([]byte("not a database"))
+	assert.Error(t, err)
+}
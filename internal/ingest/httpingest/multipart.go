@@ -0,0 +1,58 @@
+package httpingest
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// ContentTypeMultipart is the content type of form uploads carrying events in a part (see Handler.MultipartPart).
+const ContentTypeMultipart = "multipart/form-data"
+
+// multipartBody closes both the part and the request body.
+type multipartBody struct {
+	*multipart.Part
+
+	body io.Closer
+}
+
+func (b multipartBody) Close() error {
+	return errors.Join(b.Part.Close(), b.body.Close())
+}
+
+// multipartEvents returns the body and the content type of the part of a multipart/form-data request named MultipartPart.
+// The part is processed like a request body of its content type (a single event if it has none).
+// Other parts are skipped.
+func (h Handler) multipartEvents(r *http.Request) (io.ReadCloser, string, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, "", NewEventErrorf(http.StatusBadRequest, "invalid multipart content type: boundary is missing")
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", NewEventErrorf(http.StatusBadRequest, "multipart body has no %q part", h.MultipartPart)
+		}
+
+		if err != nil {
+			if err := checkBodySize(err); err != nil {
+				return nil, "", err
+			}
+
+			return nil, "", NewEventErrorf(http.StatusBadRequest, "invalid multipart body: %w", err)
+		}
+
+		if part.FormName() != h.MultipartPart {
+			continue
+		}
+
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		return multipartBody{Part: part, body: r.Body}, contentType, nil
+	}
+}
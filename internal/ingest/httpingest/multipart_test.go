@@ -0,0 +1,114 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type testPart struct {
+	name        string
+	contentType string
+	body        []byte
+}
+
+func newMultipartRequest(t *testing.T, parts ...testPart) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+part.name+`"`)
+
+		if part.contentType != "" {
+			header.Set("Content-Type", part.contentType)
+		}
+
+		w, err := writer.CreatePart(header)
+		require.NoError(t, err)
+
+		_, err = w.Write(part.body)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req
+}
+
+func TestHandler_Multipart(t *testing.T) {
+	events := newTestEvents(t, 2)
+
+	single, err := json.Marshal(events[0])
+	require.NoError(t, err)
+
+	batch, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	t.Run("Single", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{Collector: collector, MultipartPart: "events"}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newMultipartRequest(t,
+			testPart{name: "comment", body: []byte("ignored")},
+			testPart{name: "events", contentType: ContentTypeSingle, body: single},
+		))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		testcollector.AssertReceived(t, collector, "0")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{Collector: collector, MultipartPart: "events"}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newMultipartRequest(t, testPart{name: "events", contentType: ContentTypeBatch, body: batch}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.ElementsMatch(t, []string{"0", "1"}, collector.IDs())
+	})
+
+	t.Run("MissingPart", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{Collector: collector, MultipartPart: "events"}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newMultipartRequest(t, testPart{name: "other", body: single}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `multipart body has no \"events\" part`)
+		assert.Equal(t, 0, collector.Len())
+	})
+
+	t.Run("ContentTypes", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:     collector,
+			MultipartPart: "events",
+			ContentTypes:  []string{ContentTypeMultipart, ContentTypeSingle},
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newMultipartRequest(t, testPart{name: "events", contentType: ContentTypeBatch, body: batch}))
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, 0, collector.Len())
+	})
+}
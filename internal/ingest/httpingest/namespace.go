@@ -0,0 +1,81 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// NamespaceExtension is the extension events declare their namespace in (by default, see NamespaceFunc).
+const NamespaceExtension = "namespace"
+
+// NamespaceFunc resolves the namespace of an event received in a request.
+type NamespaceFunc func(ctx context.Context, ev event.Event) (string, error)
+
+// namespace resolves the namespace of the event.
+// Without a NamespaceFunc, the namespace is read from the namespace extension (events without it are in the default, empty namespace).
+func (h Handler) namespace(ctx context.Context, ev event.Event) (string, error) {
+	if h.Namespace != nil {
+		return h.Namespace(ctx, ev)
+	}
+
+	value, ok := ev.Extensions()[NamespaceExtension]
+	if !ok {
+		return "", nil
+	}
+
+	namespace, err := types.ToString(value)
+	if err != nil {
+		return "", NewEventErrorf(http.StatusBadRequest, "invalid %s extension: %w", NamespaceExtension, err)
+	}
+
+	return namespace, nil
+}
+
+// withNamespace passes the namespace of the event to the {Collector} (see ingest.NamespaceFromContext).
+// Events whose namespace cannot be resolved are forwarded without one: the namespace is informational here.
+func (h Handler) withNamespace(ctx context.Context, logger *slog.Logger, ev event.Event) context.Context {
+	namespace, err := h.namespace(ctx, ev)
+	if err != nil {
+		logger.DebugCtx(ctx, "unable to resolve event namespace", "error", err)
+
+		return ctx
+	}
+
+	return ingest.ContextWithNamespace(ctx, namespace)
+}
+
+// checkSingleNamespace rejects batches with events of more than one namespace.
+func (h Handler) checkSingleNamespace(ctx context.Context, events []event.Event) error {
+	namespaces := make(map[string]struct{})
+
+	for _, ev := range events {
+		namespace, err := h.namespace(ctx, ev)
+		if err != nil {
+			return err
+		}
+
+		namespaces[namespace] = struct{}{}
+	}
+
+	if len(namespaces) <= 1 {
+		return nil
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		names = append(names, strconv.Quote(namespace))
+	}
+
+	sort.Strings(names)
+
+	return NewEventErrorf(http.StatusBadRequest, "batch contains events of multiple namespaces: %s", strings.Join(names, ", "))
+}
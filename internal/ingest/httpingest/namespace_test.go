@@ -0,0 +1,69 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_SingleNamespaceBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		statusCode int
+		message    string
+	}{
+		{name: "Single", namespaces: []string{"a", "a"}, statusCode: http.StatusOK},
+		{name: "Default", namespaces: []string{"", ""}, statusCode: http.StatusOK},
+		{name: "Mixed", namespaces: []string{"b", "a", "b"}, statusCode: http.StatusBadRequest, message: `"a", "b"`},
+		{name: "MixedDefault", namespaces: []string{"a", ""}, statusCode: http.StatusBadRequest, message: `"", "a"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:            collector,
+				SingleNamespaceBatch: true,
+			}
+
+			events := newTestEvents(t, len(tt.namespaces))
+			for i, namespace := range tt.namespaces {
+				if namespace != "" {
+					events[i].SetExtension(NamespaceExtension, namespace)
+				}
+			}
+
+			body, err := json.Marshal(events)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", ContentTypeBatch)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+
+			if tt.statusCode != http.StatusOK {
+				var resp struct {
+					Message string `json:"message"`
+				}
+
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+				assert.Equal(t, "batch contains events of multiple namespaces: "+tt.message, resp.Message)
+				assert.Empty(t, collector.Events())
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package httpingest
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+const (
+	defaultOPACacheTTL  = 5 * time.Second
+	defaultOPACacheSize = 10000
+	defaultOPATimeout   = time.Second
+)
+
+// OPAAuthorizerConfig configures an OPAAuthorizer.
+type OPAAuthorizerConfig struct {
+	// URL is the base URL of the OPA server, eg. http://localhost:8181.
+	URL string
+
+	// Policy is the path of the decision document in the OPA data API, eg. openmeter/ingest/allow.
+	Policy string
+
+	// CacheTTL is how long decisions are cached. Defaults to 5s.
+	CacheTTL time.Duration
+
+	// CacheSize is the maximum number of cached decisions, the least recently used decisions are evicted first. Defaults to 10000.
+	CacheSize int
+
+	// Timeout is the timeout of policy queries. Defaults to 1s.
+	Timeout time.Duration
+
+	// Client sends policy queries (optional). Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// OPAAuthorizer authorizes events with an Open Policy Agent policy, queried with the OPA data API.
+//
+// The input of the policy is the principal and the attributes of the event:
+//
+//	{"principal": "...", "event": {"type": "...", "source": "...", "subject": "...", "extensions": {...}}}
+//
+// The ID, time and data of events are not part of the input, so decisions can be cached.
+// The decision is either a boolean, or an object with an allow boolean and an optional reason reported to clients.
+// Undefined decisions deny events. Events are rejected with 503 when the policy cannot be queried.
+type OPAAuthorizer struct {
+	url     string
+	ttl     time.Duration
+	size    int
+	timeout time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// opaInput is the input of the policy.
+type opaInput struct {
+	Principal string   `json:"principal"`
+	Event     opaEvent `json:"event"`
+}
+
+type opaEvent struct {
+	Type       string            `json:"type"`
+	Source     string            `json:"source"`
+	Subject    string            `json:"subject,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// opaDecision is a cached decision.
+type opaDecision struct {
+	key     string
+	allow   bool
+	reason  string
+	expires time.Time
+}
+
+// NewOPAAuthorizer returns a new OPAAuthorizer.
+func NewOPAAuthorizer(config OPAAuthorizerConfig) (*OPAAuthorizer, error) {
+	if config.URL == "" {
+		return nil, errors.New("OPA URL is required")
+	}
+
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPA URL: %w", err)
+	}
+
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OPA URL: unsupported scheme %q", base.Scheme)
+	}
+
+	policy := strings.Trim(config.Policy, "/")
+	if policy == "" {
+		return nil, errors.New("OPA policy is required")
+	}
+
+	if config.CacheTTL < 0 {
+		return nil, errors.New("OPA cache TTL must not be negative")
+	}
+
+	if config.CacheSize < 0 {
+		return nil, errors.New("OPA cache size must not be negative")
+	}
+
+	if config.Timeout < 0 {
+		return nil, errors.New("OPA timeout must not be negative")
+	}
+
+	ttl := config.CacheTTL
+	if ttl == 0 {
+		ttl = defaultOPACacheTTL
+	}
+
+	size := config.CacheSize
+	if size == 0 {
+		size = defaultOPACacheSize
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultOPATimeout
+	}
+
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &OPAAuthorizer{
+		url:     strings.TrimSuffix(base.String(), "/") + "/v1/data/" + policy,
+		ttl:     ttl,
+		size:    size,
+		timeout: timeout,
+		client:  client,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}, nil
+}
+
+// Authorize implements Authorizer.
+func (a *OPAAuthorizer) Authorize(ctx context.Context, principal string, ev event.Event) error {
+	input, err := newOPAInput(principal, ev)
+	if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "invalid event extensions: %w", err)
+	}
+
+	key, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	decision, ok := a.cached(string(key))
+	if !ok {
+		decision, err = a.query(ctx, key)
+		if err != nil {
+			return NewEventErrorf(http.StatusServiceUnavailable, "authorization policy unavailable: %w", err)
+		}
+
+		decision.key = string(key)
+		a.store(decision)
+	}
+
+	if decision.allow {
+		return nil
+	}
+
+	if decision.reason != "" {
+		return NewEventErrorf(http.StatusForbidden, "event not authorized: %s", decision.reason)
+	}
+
+	return NewEventErrorf(http.StatusForbidden, "event not authorized")
+}
+
+func newOPAInput(principal string, ev event.Event) (opaInput, error) {
+	input := opaInput{
+		Principal: principal,
+		Event: opaEvent{
+			Type:    ev.Type(),
+			Source:  ev.Source(),
+			Subject: ev.Subject(),
+		},
+	}
+
+	if len(ev.Extensions()) > 0 {
+		input.Event.Extensions = make(map[string]string, len(ev.Extensions()))
+
+		for name, value := range ev.Extensions() {
+			s, err := types.Format(value)
+			if err != nil {
+				return input, fmt.Errorf("%s: %w", name, err)
+			}
+
+			input.Event.Extensions[name] = s
+		}
+	}
+
+	return input, nil
+}
+
+// query queries the decision of the policy for the (JSON encoded) input.
+func (a *OPAAuthorizer) query(ctx context.Context, input []byte) (opaDecision, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	body := make([]byte, 0, len(input)+len(`{"input":}`))
+	body = append(body, `{"input":`...)
+	body = append(body, input...)
+	body = append(body, '}')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return opaDecision{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return opaDecision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return opaDecision{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return opaDecision{}, fmt.Errorf("decode decision: %w", err)
+	}
+
+	return parseOPADecision(response.Result)
+}
+
+func parseOPADecision(result json.RawMessage) (opaDecision, error) {
+	if len(result) == 0 {
+		// Undefined decision
+		return opaDecision{}, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(result, &allow); err == nil {
+		return opaDecision{allow: allow}, nil
+	}
+
+	var object struct {
+		Allow  bool   `json:"allow"`
+		Reason string `json:"reason"`
+	}
+
+	if err := json.Unmarshal(result, &object); err != nil {
+		return opaDecision{}, fmt.Errorf("unexpected decision: %s", result)
+	}
+
+	return opaDecision{allow: object.Allow, reason: object.Reason}, nil
+}
+
+// cached returns the cached decision of the input, if not expired.
+func (a *OPAAuthorizer) cached(key string) (opaDecision, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, ok := a.entries[key]
+	if !ok {
+		return opaDecision{}, false
+	}
+
+	decision := e.Value.(*opaDecision)
+	if !a.now().Before(decision.expires) {
+		a.order.Remove(e)
+		delete(a.entries, key)
+
+		return opaDecision{}, false
+	}
+
+	a.order.MoveToFront(e)
+
+	return *decision, true
+}
+
+// store caches the decision, evicting the least recently used decision if the cache is full.
+func (a *OPAAuthorizer) store(decision opaDecision) {
+	decision.expires = a.now().Add(a.ttl)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e, ok := a.entries[decision.key]; ok {
+		e.Value = &decision
+		a.order.MoveToFront(e)
+
+		return
+	}
+
+	if a.order.Len() >= a.size {
+		oldest := a.order.Back()
+
+		a.order.Remove(oldest)
+		delete(a.entries, oldest.Value.(*opaDecision).key)
+	}
+
+	a.entries[decision.key] = a.order.PushFront(&decision)
+}
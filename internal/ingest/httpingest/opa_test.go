@@ -0,0 +1,190 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+// newOPAServer returns a fake OPA server allowing events by subject: "allowed" (boolean decision),
+// "denied" (object decision with a reason), and undefined decisions otherwise.
+func newOPAServer(t *testing.T, queries *int64) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(queries, 1)
+
+		assert.Equal(t, "/v1/data/openmeter/ingest/allow", r.URL.Path)
+
+		var body struct {
+			Input opaInput `json:"input"`
+		}
+
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&body)) {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		assert.Equal(t, "api-calls", body.Input.Event.Type)
+
+		switch body.Input.Event.Subject {
+		case "allowed":
+			_, _ = w.Write([]byte(`{"result": true}`))
+		case "denied":
+			_, _ = w.Write([]byte(`{"result": {"allow": false, "reason": "subject is blocked"}}`))
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestOPAAuthorizer(t *testing.T) {
+	var queries int64
+
+	server := newOPAServer(t, &queries)
+
+	authorizer, err := NewOPAAuthorizer(OPAAuthorizerConfig{
+		URL:      server.URL + "/",
+		Policy:   "/openmeter/ingest/allow",
+		CacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	authorizer.now = func() time.Time { return now }
+
+	ev := newTestEvents(t, 2)
+
+	ev[0].SetSubject("allowed")
+	assert.NoError(t, authorizer.Authorize(context.Background(), "alice", ev[0]))
+
+	ev[1].SetSubject("denied")
+	err = authorizer.Authorize(context.Background(), "alice", ev[1])
+	assert.EqualError(t, err, "event not authorized: subject is blocked")
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+
+	ev[1].SetSubject("unknown")
+	err = authorizer.Authorize(context.Background(), "alice", ev[1])
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+
+	ev[1].SetSubject("error")
+	err = authorizer.Authorize(context.Background(), "alice", ev[1])
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode(err))
+
+	assert.Equal(t, int64(4), atomic.LoadInt64(&queries))
+
+	// Decisions are cached, regardless of the ID and time of events
+	ev[0].SetID("other")
+	ev[0].SetTime(now)
+	assert.NoError(t, authorizer.Authorize(context.Background(), "alice", ev[0]))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&queries))
+
+	// Errors are not cached
+	_ = authorizer.Authorize(context.Background(), "alice", ev[1])
+	assert.Equal(t, int64(5), atomic.LoadInt64(&queries))
+
+	// Decisions depend on the principal
+	assert.NoError(t, authorizer.Authorize(context.Background(), "bob", ev[0]))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&queries))
+
+	// Expired decisions are queried again
+	now = now.Add(time.Minute)
+	assert.NoError(t, authorizer.Authorize(context.Background(), "alice", ev[0]))
+	assert.Equal(t, int64(7), atomic.LoadInt64(&queries))
+}
+
+func TestOPAAuthorizer_CacheSize(t *testing.T) {
+	var queries int64
+
+	server := newOPAServer(t, &queries)
+
+	authorizer, err := NewOPAAuthorizer(OPAAuthorizerConfig{
+		URL:       server.URL,
+		Policy:    "openmeter/ingest/allow",
+		CacheSize: 1,
+	})
+	require.NoError(t, err)
+
+	ev := newTestEvents(t, 1)[0]
+	ev.SetSubject("allowed")
+
+	for _, principal := range []string{"alice", "bob", "alice"} {
+		require.NoError(t, authorizer.Authorize(context.Background(), principal, ev))
+	}
+
+	// alice was evicted by bob
+	assert.Equal(t, int64(3), atomic.LoadInt64(&queries))
+}
+
+func TestHandler_OPAAuthorizer_Batch(t *testing.T) {
+	var queries int64
+
+	server := newOPAServer(t, &queries)
+
+	authorizer, err := NewOPAAuthorizer(OPAAuthorizerConfig{
+		URL:    server.URL,
+		Policy: "openmeter/ingest/allow",
+	})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{Collector: collector, Authorizer: authorizer}
+
+	events := newTestEvents(t, 3)
+	events[0].SetSubject("allowed")
+	events[1].SetSubject("denied")
+	events[2].SetSubject("allowed")
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&results))
+	require.Len(t, results, 3)
+
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, http.StatusForbidden, results[1].StatusCode)
+	assert.Equal(t, "event not authorized: subject is blocked", results[1].Error)
+	assert.Equal(t, http.StatusOK, results[2].StatusCode)
+
+	assert.ElementsMatch(t, []string{"0", "2"}, eventIDs(collector.Events()))
+}
+
+func TestNewOPAAuthorizer(t *testing.T) {
+	for _, config := range []OPAAuthorizerConfig{
+		{Policy: "a/b"},
+		{URL: "ftp://localhost", Policy: "a/b"},
+		{URL: "http://localhost:8181"},
+		{URL: "http://localhost:8181", Policy: "a/b", CacheTTL: -time.Second},
+		{URL: "http://localhost:8181", Policy: "a/b", CacheSize: -1},
+		{URL: "http://localhost:8181", Policy: "a/b", Timeout: -time.Second},
+	} {
+		_, err := NewOPAAuthorizer(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
@@ -0,0 +1,183 @@
+package httpingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics records ingestion metrics with OpenTelemetry.
+//
+// Instruments are named after the Prometheus metrics (see Metrics), without the openmeter namespace
+// (the meter identifies the instrumentation scope), so both can be exported by the same Prometheus registry.
+type OTelMetrics struct {
+	duplicateRequests metric.Int64Counter
+	requestBodySize   metric.Int64Histogram
+	requests          metric.Int64Counter
+	clockDrift        metric.Float64Histogram
+	clockDriftHigh    metric.Int64Counter
+	subjectLag        metric.Float64Histogram
+	sequenceAnomalies metric.Int64Counter
+	rejections        metric.Int64Counter
+
+	// rateLimitTokens holds the last recorded number of tokens by endpoint (reported by an observable gauge).
+	mu              sync.Mutex
+	rateLimitTokens map[string]float64
+}
+
+// NewOTelMetrics creates ingestion metric instruments with the meter.
+func NewOTelMetrics(meter metric.Meter) (*OTelMetrics, error) {
+	m := &OTelMetrics{
+		rateLimitTokens: make(map[string]float64),
+	}
+
+	var err error
+
+	m.duplicateRequests, err = meter.Int64Counter(
+		"ingest.duplicate_requests",
+		metric.WithDescription("Number of requests with a body identical to a recently received request."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.requestBodySize, err = meter.Int64Histogram(
+		"ingest.request_body_size",
+		metric.WithDescription("Size of request bodies as received (encoded) and after decoding the content encoding (decoded)."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.requests, err = meter.Int64Counter(
+		"ingest.requests",
+		metric.WithDescription("Number of ingest requests by content type and mode (single or batch)."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.clockDrift, err = meter.Float64Histogram(
+		"ingest.event_clock_drift",
+		metric.WithDescription("Absolute difference between the time of events and the time they are ingested, by direction (past or future)."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.clockDriftHigh, err = meter.Int64Counter(
+		"ingest.event_clock_drift_exceeded",
+		metric.WithDescription("Number of events with a clock drift beyond the configured threshold."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.subjectLag, err = meter.Float64Histogram(
+		"ingest.event_lag",
+		metric.WithDescription("Difference between the time events are ingested and the time of events, by subject prefix."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.sequenceAnomalies, err = meter.Int64Counter(
+		"ingest.event_sequence_anomalies",
+		metric.WithDescription("Number of events with a sequence number out of order or after a gap, by anomaly."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	m.rejections, err = meter.Int64Counter(
+		"ingest.rejections",
+		metric.WithDescription("Number of rejected requests and events, by scope (request or event) and reason."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Float64ObservableGauge(
+		"ingest.rate_limit_tokens",
+		metric.WithDescription("Number of tokens left in the global event rate limiter bucket (as of the last processed event)."),
+		metric.WithFloat64Callback(func(_ context.Context, observer metric.Float64Observer) error {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			for endpoint, tokens := range m.rateLimitTokens {
+				observer.Observe(tokens, metric.WithAttributes(endpointAttr(endpoint)))
+			}
+
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *OTelMetrics) RecordRequest(ctx context.Context, contentType string) {
+	m.requests.Add(ctx, 1, metric.WithAttributes(
+		endpointAttr(endpointFromContext(ctx)),
+		attribute.String("content_type", contentTypeLabel(contentType)),
+		attribute.String("mode", requestMode(contentType)),
+	))
+}
+
+func (m *OTelMetrics) RecordRequestBodySize(ctx context.Context, contentType string, encoded int64, decoded int64) {
+	endpoint := endpointAttr(endpointFromContext(ctx))
+	contentTypeAttr := attribute.String("content_type", contentTypeLabel(contentType))
+
+	m.requestBodySize.Record(ctx, encoded, metric.WithAttributes(endpoint, contentTypeAttr, attribute.String("stage", bodyStageEncoded)))
+	m.requestBodySize.Record(ctx, decoded, metric.WithAttributes(endpoint, contentTypeAttr, attribute.String("stage", bodyStageDecoded)))
+}
+
+func (m *OTelMetrics) RecordDuplicateRequest(ctx context.Context) {
+	m.duplicateRequests.Add(ctx, 1, metric.WithAttributes(endpointAttr(endpointFromContext(ctx))))
+}
+
+func (m *OTelMetrics) RecordRateLimitTokens(ctx context.Context, tokens float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rateLimitTokens[endpointFromContext(ctx)] = tokens
+}
+
+func (m *OTelMetrics) RecordClockDrift(ctx context.Context, drift time.Duration, exceeded bool) {
+	endpoint := endpointAttr(endpointFromContext(ctx))
+	direction, drift := clockDriftDirection(drift)
+
+	m.clockDrift.Record(ctx, drift.Seconds(), metric.WithAttributes(endpoint, attribute.String("direction", direction)))
+
+	if exceeded {
+		m.clockDriftHigh.Add(ctx, 1, metric.WithAttributes(endpoint))
+	}
+}
+
+func (m *OTelMetrics) RecordSubjectLag(ctx context.Context, subjectPrefix string, lag time.Duration) {
+	m.subjectLag.Record(ctx, lag.Seconds(), metric.WithAttributes(endpointAttr(endpointFromContext(ctx)), attribute.String("subject_prefix", subjectPrefix)))
+}
+
+func (m *OTelMetrics) RecordSequenceAnomaly(ctx context.Context, anomaly string) {
+	m.sequenceAnomalies.Add(ctx, 1, metric.WithAttributes(endpointAttr(endpointFromContext(ctx)), attribute.String("anomaly", anomaly)))
+}
+
+func (m *OTelMetrics) RecordRejection(ctx context.Context, scope string, reason RejectionReason) {
+	m.rejections.Add(ctx, 1, metric.WithAttributes(
+		endpointAttr(endpointFromContext(ctx)),
+		attribute.String("scope", scope),
+		attribute.String("reason", string(reason)),
+	))
+}
+
+func endpointAttr(endpoint string) attribute.KeyValue {
+	return attribute.String(labelEndpoint, endpoint)
+}
@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	metrics, err := NewOTelMetrics(provider.Meter("test"))
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: &testcollector.Collector{},
+		Metrics:   metrics,
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	for _, body := range []string{ev, ev} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	metrics.RecordRateLimitTokens(context.Background(), 42)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	found := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			found[m.Name] = m.Data
+		}
+	}
+
+	requests, ok := found["ingest.requests"].(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, requests.DataPoints, 1)
+	assert.Equal(t, int64(2), requests.DataPoints[0].Value)
+
+	bodySize, ok := found["ingest.request_body_size"].(metricdata.Histogram[int64])
+	require.True(t, ok)
+	assert.Len(t, bodySize.DataPoints, 2)
+
+	tokens, ok := found["ingest.rate_limit_tokens"].(metricdata.Gauge[float64])
+	require.True(t, ok)
+	require.Len(t, tokens.DataPoints, 1)
+	assert.Equal(t, float64(42), tokens.DataPoints[0].Value)
+}
@@ -0,0 +1,90 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// ContentTypeProblem is the content type of problem details documents.
+// See https://www.rfc-editor.org/rfc/rfc7807
+const ContentTypeProblem = "application/problem+json"
+
+// BatchProblem is a problem details document reporting the events rejected in a batch.
+type BatchProblem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+
+	// Errors are the rejected events, ordered by their index in the batch.
+	Errors []EventProblem `json:"errors"`
+}
+
+// EventProblem is an event rejected in a batch.
+type EventProblem struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// wantsBatchProblem reports whether the failures of a batch are reported as a problem details document:
+// when ProblemBatchErrors is enabled or the client accepts application/problem+json.
+func (h Handler) wantsBatchProblem(r *http.Request) bool {
+	if h.ProblemBatchErrors {
+		return true
+	}
+
+	for _, value := range r.Header.Values("Accept") {
+		for _, accept := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(accept)
+			if err == nil && mediaType == ContentTypeProblem {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isValidationFailure reports whether every event of a batch failed with a client error.
+// Other failures (eg. the collector being unavailable) are reported with 207, so clients retry the events that can be retried.
+func isValidationFailure(failures []batchResult) bool {
+	for _, failure := range failures {
+		if status := statusCode(failure.err); status < 400 || status >= 500 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeBatchProblem writes a 422 problem details document listing the events rejected in the batch.
+// Successful events are not listed: they have been forwarded to the {Collector}.
+func writeBatchProblem(w http.ResponseWriter, events []event.Event, failures []batchResult) {
+	problem := BatchProblem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Detail: "events of the batch were rejected",
+		Errors: make([]EventProblem, 0, len(failures)),
+	}
+
+	for _, failure := range failures {
+		problem.Errors = append(problem.Errors, EventProblem{
+			Index:  failure.index,
+			ID:     events[failure.index].ID(),
+			Status: statusCode(failure.err),
+			Reason: failure.err.Error(),
+		})
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	_ = json.NewEncoder(w).Encode(problem)
+}
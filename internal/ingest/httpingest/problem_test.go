@@ -0,0 +1,101 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+func TestHandler_ProblemBatchErrors(t *testing.T) {
+	invalid := collectorFunc(func(ev event.Event) error {
+		switch ev.ID() {
+		case "1":
+			return NewEventErrorf(http.StatusBadRequest, "missing value")
+		case "3":
+			return ingest.ErrInvalidEvent
+		}
+
+		return nil
+	})
+
+	tests := []struct {
+		name      string
+		collector ingest.Collector
+		enabled   bool
+		accept    string
+
+		want int
+	}{
+		{name: "Enabled", collector: invalid, enabled: true, want: http.StatusUnprocessableEntity},
+		{name: "Accept", collector: invalid, accept: "application/json, application/problem+json;q=0.9", want: http.StatusUnprocessableEntity},
+		{name: "Disabled", collector: invalid, accept: "application/json", want: http.StatusMultiStatus},
+		{
+			name: "ServerError",
+			collector: collectorFunc(func(ev event.Event) error {
+				if ev.ID() == "1" {
+					return errors.New("downstream failure")
+				}
+
+				return nil
+			}),
+			enabled: true,
+			want:    http.StatusMultiStatus,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := Handler{
+				Collector:          test.collector,
+				ProblemBatchErrors: test.enabled,
+			}
+
+			body, err := json.Marshal(newTestEvents(t, 4))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", ContentTypeBatch)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, test.want, w.Code, w.Body.String())
+
+			if test.want != http.StatusUnprocessableEntity {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+				return
+			}
+
+			assert.Equal(t, ContentTypeProblem, w.Header().Get("Content-Type"))
+
+			var problem BatchProblem
+
+			err = json.NewDecoder(w.Body).Decode(&problem)
+			require.NoError(t, err)
+
+			assert.Equal(t, BatchProblem{
+				Type:   "about:blank",
+				Title:  "Unprocessable Entity",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "events of the batch were rejected",
+				Errors: []EventProblem{
+					{Index: 1, ID: "1", Status: http.StatusBadRequest, Reason: "missing value"},
+					{Index: 3, ID: "3", Status: http.StatusBadRequest, Reason: ingest.ErrInvalidEvent.Error()},
+				},
+			}, problem)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiterConfig configures a RateLimiter.
+type RateLimiterConfig struct {
+	// Rate is the number of events processed per second.
+	Rate float64
+
+	// Burst is the maximum number of events processed at once. Defaults to Rate (rounded up).
+	Burst int
+}
+
+// RateLimiter is a token bucket limiting the number of events processed per second globally,
+// across every request and subject.
+//
+// Events over the limit are rejected with 429 and a Retry-After header.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new RateLimiter with a full bucket.
+func NewRateLimiter(config RateLimiterConfig) (*RateLimiter, error) {
+	if config.Rate <= 0 || math.IsInf(config.Rate, 0) || math.IsNaN(config.Rate) {
+		return nil, fmt.Errorf("invalid rate: %v", config.Rate)
+	}
+
+	if config.Burst < 0 {
+		return nil, errors.New("burst must not be negative")
+	}
+
+	burst := float64(config.Burst)
+	if burst == 0 {
+		burst = math.Ceil(config.Rate)
+	}
+
+	return &RateLimiter{
+		rate:   config.Rate,
+		burst:  burst,
+		now:    time.Now,
+		tokens: burst,
+	}, nil
+}
+
+// allow takes a token from the bucket.
+// It returns the remaining tokens and an error if the bucket is empty.
+func (l *RateLimiter) allow() (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+
+	l.last = now
+
+	if l.tokens < 1 {
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+
+		err := NewEventErrorf(http.StatusTooManyRequests, "global event rate limit exceeded")
+		err.RetryAfter = wait
+
+		return l.tokens, err
+	}
+
+	l.tokens--
+
+	return l.tokens, nil
+}
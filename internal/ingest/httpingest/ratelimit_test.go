@@ -0,0 +1,135 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{Rate: 2})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := limiter.allow()
+		require.NoError(t, err)
+	}
+
+	tokens, err := limiter.allow()
+	require.Error(t, err)
+
+	assert.Equal(t, float64(0), tokens)
+	assert.Equal(t, http.StatusTooManyRequests, statusCode(err))
+	assert.Equal(t, 500*time.Millisecond, retryAfter(err))
+
+	now = now.Add(500 * time.Millisecond)
+
+	tokens, err = limiter.allow()
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), tokens)
+
+	// The bucket never holds more than the burst
+	now = now.Add(time.Hour)
+
+	tokens, err = limiter.allow()
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), tokens)
+}
+
+func TestHandler_RateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{Rate: 1, Burst: 2})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:   collector,
+		RateLimiter: limiter,
+		Metrics:     metrics,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 3))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Len(t, collector.Events(), 2)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.rateLimitTokens))
+
+	var results []EventResult
+
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&results))
+
+	var limited int
+
+	for _, result := range results {
+		if result.StatusCode == http.StatusTooManyRequests {
+			limited++
+		}
+	}
+
+	assert.Equal(t, 1, limited)
+
+	t.Run("Single", func(t *testing.T) {
+		body, err := json.Marshal(newTestEvents(t, 1)[0])
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+}
+
+func TestHandler_RateLimiter_Rejected(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimiterConfig{Rate: 1})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:   collector,
+		RateLimiter: limiter,
+		Flags:       &FlagsConfig{Extension: "flags", Known: []string{"audit"}, RejectUnknown: true},
+	}
+
+	events := newTestEvents(t, 2)
+	events[0].SetExtension("flags", "unknown")
+
+	// Invalid events do not consume tokens
+	err = handler.processEvent(context.Background(), events[0])
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+
+	require.NoError(t, handler.processEvent(context.Background(), events[1]))
+
+	testcollector.AssertReceived(t, collector, "1")
+}
@@ -0,0 +1,291 @@
+package httpingest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// TrailerReceipt is the trailer of multi-status batch responses carrying the receipt of the accepted events
+// (base64url encoded JSON).
+const TrailerReceipt = "OpenMeter-Receipt"
+
+// minReceiptKeySize is the minimum size (in bytes) of receipt signing keys.
+const minReceiptKeySize = 32
+
+// Receipt proves that the server accepted events.
+type Receipt struct {
+	// Events identify the accepted events.
+	Events []ReceiptEvent `json:"events"`
+
+	AcceptedAt time.Time `json:"acceptedAt"`
+
+	// KeyID identifies the key the receipt is signed with (optional).
+	KeyID string `json:"keyId,omitempty"`
+
+	// Signature is the base64url encoded HMAC-SHA256 of the other fields.
+	Signature string `json:"signature"`
+}
+
+// ReceiptEvent identifies an accepted event: event IDs are only unique within a source (and a namespace).
+type ReceiptEvent struct {
+	// Namespace is the namespace of the event (omitted in the default, empty namespace).
+	Namespace string `json:"namespace,omitempty"`
+
+	Source string `json:"source"`
+	ID     string `json:"id"`
+}
+
+// signedReceipt is the payload of receipt signatures.
+type signedReceipt struct {
+	Events     []ReceiptEvent `json:"events"`
+	AcceptedAt time.Time      `json:"acceptedAt"`
+	KeyID      string         `json:"keyId,omitempty"`
+}
+
+// ReceiptSignerConfig configures a ReceiptSigner.
+type ReceiptSignerConfig struct {
+	// Key is the secret key receipts are signed with (at least 32 bytes).
+	Key []byte
+
+	// KeyID identifies the key in receipts (optional), eg. to rotate keys.
+	KeyID string
+}
+
+// ReceiptSigner signs the receipts of accepted events, so producers can later prove the server accepted them.
+//
+// Receipts are returned in the body of successful responses (200 or 202) to single events and batches.
+// Multi-status batch responses carry the receipt of the accepted events in the OpenMeter-Receipt trailer instead.
+// Streams are not receipted.
+//
+// Receipts are signed with HMAC-SHA256: only holders of the key (the server) can sign and verify them (see Verify).
+type ReceiptSigner struct {
+	key   []byte
+	keyID string
+	now   func() time.Time
+}
+
+// NewReceiptSigner returns a new ReceiptSigner.
+func NewReceiptSigner(config ReceiptSignerConfig) (*ReceiptSigner, error) {
+	if len(config.Key) < minReceiptKeySize {
+		return nil, fmt.Errorf("receipt key must be at least %d bytes", minReceiptKeySize)
+	}
+
+	return &ReceiptSigner{
+		key:   config.Key,
+		keyID: config.KeyID,
+		now:   time.Now,
+	}, nil
+}
+
+// sign returns the signed receipt of the events.
+func (s *ReceiptSigner) sign(events []ReceiptEvent) (Receipt, error) {
+	receipt := Receipt{
+		Events:     events,
+		AcceptedAt: s.now().UTC(),
+		KeyID:      s.keyID,
+	}
+
+	signature, err := s.signature(receipt)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	receipt.Signature = base64.RawURLEncoding.EncodeToString(signature)
+
+	return receipt, nil
+}
+
+func (s *ReceiptSigner) signature(receipt Receipt) ([]byte, error) {
+	payload, err := json.Marshal(signedReceipt{
+		Events:     receipt.Events,
+		AcceptedAt: receipt.AcceptedAt,
+		KeyID:      receipt.KeyID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode receipt: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write(payload)
+
+	return mac.Sum(nil), nil
+}
+
+// Verify verifies the signature of a receipt presented by a producer.
+func (s *ReceiptSigner) Verify(receipt Receipt) error {
+	signature, err := base64.RawURLEncoding.DecodeString(receipt.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid receipt signature: %w", err)
+	}
+
+	expected, err := s.signature(receipt)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(signature, expected) {
+		return errors.New("invalid receipt signature")
+	}
+
+	return nil
+}
+
+type receiptContextKey struct{}
+
+type receiptIndexContextKey struct{}
+
+// receiptRecorder records the events of a request as they are forwarded to the {Collector},
+// so receipts identify the events stored by the server rather than the events sent by the client
+// (eg. with a source set from the TrustedSourceHeader or an ID regenerated by the server).
+type receiptRecorder struct {
+	mu     sync.Mutex
+	events map[int]ReceiptEvent
+}
+
+// withReceipts records the events processed in the context, when receipts are enabled.
+func (h Handler) withReceipts(ctx context.Context) context.Context {
+	if h.Receipts == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, receiptContextKey{}, &receiptRecorder{events: make(map[int]ReceiptEvent)})
+}
+
+// withReceiptIndex attaches the index of an event of a batch to its context, when receipts are enabled.
+func withReceiptIndex(ctx context.Context, index int) context.Context {
+	if _, ok := ctx.Value(receiptContextKey{}).(*receiptRecorder); !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, receiptIndexContextKey{}, index)
+}
+
+// recordReceiptEvent records the identity of an event as processed, when receipts are enabled.
+func recordReceiptEvent(ctx context.Context, ev event.Event) {
+	recorder, ok := ctx.Value(receiptContextKey{}).(*receiptRecorder)
+	if !ok {
+		return
+	}
+
+	index, _ := ctx.Value(receiptIndexContextKey{}).(int)
+	namespace, _ := ingest.NamespaceFromContext(ctx)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	recorder.events[index] = ReceiptEvent{
+		Namespace: namespace,
+		Source:    ev.Source(),
+		ID:        ev.ID(),
+	}
+}
+
+// writeReceipt writes the success status with the receipt of the events in the body, when receipts are enabled.
+func (h Handler) writeReceipt(ctx context.Context, w http.ResponseWriter, status int, events ...event.Event) {
+	if h.Receipts == nil {
+		w.WriteHeader(status)
+
+		return
+	}
+
+	receipt, err := h.Receipts.sign(h.receiptEvents(ctx, events, nil))
+	if err != nil {
+		// Events are accepted regardless
+		h.getLogger().Error("unable to sign receipt", "error", err)
+
+		w.WriteHeader(status)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(receipt)
+}
+
+// receiptTrailer declares the receipt trailer of a multi-status batch response (when receipts are enabled),
+// and returns a function setting it once the response is written (when events were accepted).
+func (h Handler) receiptTrailer(ctx context.Context, w http.ResponseWriter, events []event.Event) func(failures []batchResult) {
+	if h.Receipts == nil {
+		return func([]batchResult) {}
+	}
+
+	w.Header().Set("Trailer", TrailerReceipt)
+
+	return func(failures []batchResult) {
+		accepted := h.receiptEvents(ctx, events, failures)
+		if len(accepted) == 0 {
+			return
+		}
+
+		receipt, err := h.Receipts.sign(accepted)
+		if err != nil {
+			h.getLogger().Error("unable to sign receipt", "error", err)
+
+			return
+		}
+
+		encoded, err := json.Marshal(receipt)
+		if err != nil {
+			h.getLogger().Error("unable to encode receipt", "error", err)
+
+			return
+		}
+
+		w.Header().Set(TrailerReceipt, base64.RawURLEncoding.EncodeToString(encoded))
+	}
+}
+
+// receiptEvents returns the identity of the events of a request that did not fail (failures are sorted by index),
+// as recorded when they were processed.
+//
+// Events that were not processed (retries of committed batches) are receipted as sent by the client,
+// in the namespace resolved for them (the default namespace if it cannot be resolved, like in withNamespace).
+func (h Handler) receiptEvents(ctx context.Context, events []event.Event, failures []batchResult) []ReceiptEvent {
+	recorder, _ := ctx.Value(receiptContextKey{}).(*receiptRecorder)
+
+	receipted := make([]ReceiptEvent, 0, len(events)-len(failures))
+
+	for i, ev := range events {
+		if len(failures) > 0 && failures[0].index == i {
+			failures = failures[1:]
+
+			continue
+		}
+
+		if recorder != nil {
+			recorder.mu.Lock()
+			recorded, ok := recorder.events[i]
+			recorder.mu.Unlock()
+
+			if ok {
+				receipted = append(receipted, recorded)
+
+				continue
+			}
+		}
+
+		namespace, _ := h.namespace(ctx, ev)
+
+		receipted = append(receipted, ReceiptEvent{
+			Namespace: namespace,
+			Source:    ev.Source(),
+			ID:        ev.ID(),
+		})
+	}
+
+	return receipted
+}
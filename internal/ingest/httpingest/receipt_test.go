@@ -0,0 +1,245 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func newTestReceiptSigner(t *testing.T) *ReceiptSigner {
+	t.Helper()
+
+	signer, err := NewReceiptSigner(ReceiptSignerConfig{
+		Key:   []byte(strings.Repeat("k", 32)),
+		KeyID: "key-1",
+	})
+	require.NoError(t, err)
+
+	signer.now = func() time.Time {
+		return time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC)
+	}
+
+	return signer
+}
+
+func TestHandler_Receipts(t *testing.T) {
+	signer := newTestReceiptSigner(t)
+
+	t.Run("Single", func(t *testing.T) {
+		handler := Handler{
+			Collector: &testcollector.Collector{},
+			Receipts:  signer,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+
+		assert.Equal(t, []ReceiptEvent{{Source: "test", ID: "1"}}, receipt.Events)
+		assert.Equal(t, time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC), receipt.AcceptedAt)
+		assert.Equal(t, "key-1", receipt.KeyID)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		handler := Handler{
+			Collector: &testcollector.Collector{},
+			Receipts:  signer,
+		}
+
+		body, err := json.Marshal(newTestEvents(t, 3))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+
+		assert.Equal(t, []ReceiptEvent{{Source: "test", ID: "0"}, {Source: "test", ID: "1"}, {Source: "test", ID: "2"}}, receipt.Events)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("PartialBatch", func(t *testing.T) {
+		handler := Handler{
+			Collector: &testcollector.Collector{
+				ErrFunc: func(ev event.Event) error {
+					if ev.ID() == "1" {
+						return NewEventErrorf(http.StatusBadRequest, "invalid value")
+					}
+
+					return nil
+				},
+			},
+			Receipts: signer,
+		}
+
+		body, err := json.Marshal(newTestEvents(t, 3))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		res := w.Result()
+		require.Equal(t, http.StatusMultiStatus, res.StatusCode)
+
+		var results []EventResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		assert.Len(t, results, 3)
+
+		encoded, err := base64.RawURLEncoding.DecodeString(res.Trailer.Get(TrailerReceipt))
+		require.NoError(t, err)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(encoded, &receipt))
+
+		assert.Equal(t, []ReceiptEvent{{Source: "test", ID: "0"}, {Source: "test", ID: "2"}}, receipt.Events)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		handler := Handler{
+			Collector: &testcollector.Collector{},
+			Receipts:  signer,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls","namespace":"acme"}`))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+
+		assert.Equal(t, []ReceiptEvent{{Namespace: "acme", Source: "test", ID: "1"}}, receipt.Events)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("TrustedSource", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:           collector,
+			Receipts:            signer,
+			TrustedSourceHeader: "X-Event-Source",
+		}
+
+		body, err := json.Marshal(newTestEvents(t, 2))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+		req.Header.Set("X-Event-Source", "gateway")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+
+		assert.Equal(t, []ReceiptEvent{{Source: "gateway", ID: "0"}, {Source: "gateway", ID: "1"}}, receipt.Events)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("RegenerateIDs", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:     collector,
+			Receipts:      signer,
+			RegenerateIDs: true,
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var receipt Receipt
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &receipt))
+
+		require.Len(t, collector.Events(), 1)
+		assert.NotEqual(t, "1", collector.Events()[0].ID())
+		assert.Equal(t, []ReceiptEvent{{Source: "test", ID: collector.Events()[0].ID()}}, receipt.Events)
+		assert.NoError(t, signer.Verify(receipt))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		handler := Handler{
+			Collector: &testcollector.Collector{},
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+}
+
+func TestReceiptSigner_Verify(t *testing.T) {
+	signer := newTestReceiptSigner(t)
+
+	receipt, err := signer.sign([]ReceiptEvent{{Source: "test", ID: "1"}, {Namespace: "acme", Source: "test", ID: "2"}})
+	require.NoError(t, err)
+
+	require.NoError(t, signer.Verify(receipt))
+
+	tampered := receipt
+	tampered.Events = append(tampered.Events, ReceiptEvent{Source: "test", ID: "3"})
+	assert.Error(t, signer.Verify(tampered))
+
+	tampered = receipt
+	tampered.Events = []ReceiptEvent{{Source: "other", ID: "1"}, receipt.Events[1]}
+	assert.Error(t, signer.Verify(tampered))
+
+	tampered = receipt
+	tampered.Events = []ReceiptEvent{receipt.Events[0], {Source: "test", ID: "2"}}
+	assert.Error(t, signer.Verify(tampered))
+
+	tampered = receipt
+	tampered.AcceptedAt = receipt.AcceptedAt.Add(time.Hour)
+	assert.Error(t, signer.Verify(tampered))
+
+	other, err := NewReceiptSigner(ReceiptSignerConfig{Key: []byte(strings.Repeat("o", 32)), KeyID: "key-1"})
+	require.NoError(t, err)
+	assert.Error(t, other.Verify(receipt))
+
+	_, err = NewReceiptSigner(ReceiptSignerConfig{Key: []byte("short")})
+	assert.Error(t, err)
+}
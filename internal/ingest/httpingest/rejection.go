@@ -0,0 +1,71 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+)
+
+// RejectionReason is the reason a request or an event is rejected.
+// Reasons are a bounded set of values, labeling rejection metrics.
+type RejectionReason string
+
+const (
+	// RejectionValidation is the reason of malformed or invalid requests and events (eg. failing a Validator).
+	RejectionValidation RejectionReason = "validation"
+
+	// RejectionUnauthorized is the reason of requests and events the client may not send (401 and 403, eg. denied by the Authorizer).
+	RejectionUnauthorized RejectionReason = "unauthorized"
+
+	// RejectionRateLimited is the reason of requests and events over a rate limit (429).
+	RejectionRateLimited RejectionReason = "rate_limited"
+
+	// RejectionQuota is the reason of events over the source limit of their tenant (see SourceLimiter).
+	RejectionQuota RejectionReason = "quota"
+
+	// RejectionTooLarge is the reason of requests over a size limit (413).
+	RejectionTooLarge RejectionReason = "too_large"
+
+	// RejectionUnsupported is the reason of requests with a content type that is not accepted (415).
+	RejectionUnsupported RejectionReason = "unsupported"
+
+	// RejectionUnavailable is the reason of requests and events rejected while the server is unavailable
+	// (eg. in maintenance mode, draining, or out of workers).
+	RejectionUnavailable RejectionReason = "unavailable"
+
+	// RejectionCollector is the reason of events the {Collector} failed to receive.
+	RejectionCollector RejectionReason = "collector"
+
+	// RejectionInternal is the reason of requests and events rejected because of other server errors.
+	RejectionInternal RejectionReason = "internal"
+)
+
+// Scopes of rejections: requests rejected as a whole, or single events (including the events of batches).
+const (
+	RejectionScopeRequest = "request"
+	RejectionScopeEvent   = "event"
+)
+
+// rejectionReason classifies a rejection by the status code reported for the error.
+func rejectionReason(err error) RejectionReason {
+	switch status := statusCode(err); {
+	case status == http.StatusRequestEntityTooLarge:
+		return RejectionTooLarge
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return RejectionUnauthorized
+	case status == http.StatusTooManyRequests:
+		return RejectionRateLimited
+	case status == http.StatusUnsupportedMediaType:
+		return RejectionUnsupported
+	case status == http.StatusServiceUnavailable:
+		return RejectionUnavailable
+	case status >= http.StatusInternalServerError:
+		return RejectionInternal
+	}
+
+	return RejectionValidation
+}
+
+// recordRejection counts a rejected request or event, classified by its error.
+func (h Handler) recordRejection(ctx context.Context, scope string, err error) {
+	h.metrics().RecordRejection(ctx, scope, rejectionReason(err))
+}
@@ -0,0 +1,84 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestMetrics_Rejections(t *testing.T) {
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	limiter, err := NewRateLimiter(RateLimiterConfig{Rate: 1, Burst: 2})
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector:        &testcollector.Collector{},
+		Metrics:          metrics,
+		ContentTypes:     []string{ContentTypeBatch},
+		MaxSubjectLength: 5,
+		RateLimiter:      limiter,
+	}
+
+	events := newTestEvents(t, 4)
+	events[0].SetSubject("too long")
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", ContentTypeSingle)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	rejections := func(scope string, reason RejectionReason) float64 {
+		return testutil.ToFloat64(metrics.rejections.WithLabelValues("", scope, string(reason)))
+	}
+
+	assert.Equal(t, float64(1), rejections(RejectionScopeEvent, RejectionValidation))
+	assert.Equal(t, float64(1), rejections(RejectionScopeEvent, RejectionRateLimited))
+	assert.Equal(t, float64(1), rejections(RejectionScopeRequest, RejectionUnsupported))
+	assert.Equal(t, float64(0), rejections(RejectionScopeRequest, RejectionValidation))
+}
+
+func TestRejectionReason(t *testing.T) {
+	tests := []struct {
+		err    error
+		reason RejectionReason
+	}{
+		{err: NewEventErrorf(http.StatusBadRequest, "invalid"), reason: RejectionValidation},
+		{err: NewEventErrorf(http.StatusForbidden, "denied"), reason: RejectionUnauthorized},
+		{err: NewEventErrorf(http.StatusTooManyRequests, "slow down"), reason: RejectionRateLimited},
+		{err: NewEventErrorf(http.StatusRequestEntityTooLarge, "too large"), reason: RejectionTooLarge},
+		{err: NewEventErrorf(http.StatusUnsupportedMediaType, "unsupported"), reason: RejectionUnsupported},
+		{err: NewEventErrorf(http.StatusServiceUnavailable, "maintenance"), reason: RejectionUnavailable},
+		{err: errors.New("unexpected"), reason: RejectionInternal},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.reason, rejectionReason(test.err), test.err.Error())
+	}
+}
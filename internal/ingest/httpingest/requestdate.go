@@ -0,0 +1,43 @@
+package httpingest
+
+import (
+	"net/http"
+	"time"
+)
+
+// checkRequestDate rejects requests whose Date header is missing or too far from the clock of the server,
+// mitigating the replay of captured requests.
+func (h Handler) checkRequestDate(r *http.Request) error {
+	if h.MaxRequestDateSkew <= 0 {
+		return nil
+	}
+
+	header := r.Header.Get("Date")
+	if header == "" {
+		return NewEventErrorf(http.StatusBadRequest, "date header is required")
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "invalid date header: %w", err)
+	}
+
+	skew := h.now().Sub(date)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > h.MaxRequestDateSkew {
+		return NewEventErrorf(http.StatusBadRequest, "date header is out of the accepted range (%s)", h.MaxRequestDateSkew)
+	}
+
+	return nil
+}
+
+func (h Handler) now() time.Time {
+	if h.Clock != nil {
+		return h.Clock()
+	}
+
+	return time.Now()
+}
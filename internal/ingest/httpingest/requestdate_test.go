@@ -0,0 +1,57 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_MaxRequestDateSkew(t *testing.T) {
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		date       string
+		statusCode int
+	}{
+		{name: "Current", date: now.Format(http.TimeFormat), statusCode: http.StatusOK},
+		{name: "WithinSkew", date: now.Add(-4 * time.Minute).Format(http.TimeFormat), statusCode: http.StatusOK},
+		{name: "Future", date: now.Add(4 * time.Minute).Format(http.TimeFormat), statusCode: http.StatusOK},
+		{name: "Stale", date: now.Add(-6 * time.Minute).Format(http.TimeFormat), statusCode: http.StatusBadRequest},
+		{name: "Missing", statusCode: http.StatusBadRequest},
+		{name: "Invalid", date: "yesterday", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Handler{
+				Collector:          &testcollector.Collector{},
+				MaxRequestDateSkew: 5 * time.Minute,
+				Clock:              func() time.Time { return now },
+			}
+
+			body, err := json.Marshal(newTestEvents(t, 1)[0])
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			if tt.date != "" {
+				req.Header.Set("Date", tt.date)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
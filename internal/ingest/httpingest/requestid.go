@@ -0,0 +1,122 @@
+package httpingest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDExtension is the extension carrying the ID of the request an event was received in.
+const RequestIDExtension = "requestid"
+
+// RequestIDFormat is the format of generated request IDs.
+type RequestIDFormat string
+
+const (
+	// RequestIDFormatUUID generates random (version 4) UUIDs.
+	RequestIDFormatUUID RequestIDFormat = "uuid"
+
+	// RequestIDFormatShort generates 16 character random hex strings.
+	RequestIDFormatShort RequestIDFormat = "short"
+)
+
+// Validate validates the format.
+func (f RequestIDFormat) Validate() error {
+	switch f {
+	case "", RequestIDFormatUUID, RequestIDFormatShort:
+		return nil
+	}
+
+	return fmt.Errorf("invalid request ID format: %q", f)
+}
+
+const (
+	defaultRequestIDHeader = "X-Request-ID"
+	traceparentHeader      = "traceparent"
+)
+
+// RequestIDConfig configures how requests are identified.
+//
+// The request ID is attached to log records, set in the requestid extension of events
+// and returned in the first header of the list.
+type RequestIDConfig struct {
+	// Headers are the names of headers the request ID is read from, the first present header wins.
+	// The trace ID of a traceparent header is used as the request ID.
+	// Defaults to X-Request-ID.
+	Headers []string
+
+	// Format of request IDs generated for requests without any of the headers.
+	// Defaults to RequestIDFormatUUID.
+	Format RequestIDFormat
+}
+
+// Validate validates the configuration.
+func (c RequestIDConfig) Validate() error {
+	for _, header := range c.Headers {
+		if header == "" {
+			return errors.New("empty request ID header name")
+		}
+	}
+
+	return c.Format.Validate()
+}
+
+func (c RequestIDConfig) headers() []string {
+	if len(c.Headers) == 0 {
+		return []string{defaultRequestIDHeader}
+	}
+
+	return c.Headers
+}
+
+// requestID returns the request ID sent by the client or generates a new one.
+func (c RequestIDConfig) requestID(r *http.Request) string {
+	for _, header := range c.headers() {
+		value := strings.TrimSpace(r.Header.Get(header))
+		if value == "" {
+			continue
+		}
+
+		if strings.EqualFold(header, traceparentHeader) {
+			// version-traceid-parentid-flags
+			parts := strings.Split(value, "-")
+			if len(parts) != 4 || parts[1] == "" {
+				continue
+			}
+
+			return parts[1]
+		}
+
+		return value
+	}
+
+	return c.generate()
+}
+
+func (c RequestIDConfig) generate() string {
+	if c.Format == RequestIDFormatShort {
+		var b [8]byte
+
+		// crypto/rand never fails on supported platforms
+		_, _ = rand.Read(b[:])
+
+		return hex.EncodeToString(b[:])
+	}
+
+	return uuid.NewString()
+}
+
+// responseHeader is the header the request ID is returned in.
+func (c RequestIDConfig) responseHeader() string {
+	header := c.headers()[0]
+	if strings.EqualFold(header, traceparentHeader) {
+		return defaultRequestIDHeader
+	}
+
+	return header
+}
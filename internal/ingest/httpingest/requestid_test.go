@@ -0,0 +1,99 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestRequestIDConfig_RequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RequestIDConfig
+		headers map[string]string
+		id      string
+	}{
+		{
+			name:    "Default",
+			headers: map[string]string{"X-Request-ID": "abc"},
+			id:      "abc",
+		},
+		{
+			name:    "FirstMatchWins",
+			config:  RequestIDConfig{Headers: []string{"X-Correlation-ID", "X-Request-ID"}},
+			headers: map[string]string{"X-Request-ID": "abc", "X-Correlation-ID": "def"},
+			id:      "def",
+		},
+		{
+			name:    "Fallback",
+			config:  RequestIDConfig{Headers: []string{"X-Correlation-ID", "X-Request-ID"}},
+			headers: map[string]string{"X-Request-ID": "abc"},
+			id:      "abc",
+		},
+		{
+			name:    "Traceparent",
+			config:  RequestIDConfig{Headers: []string{"traceparent"}},
+			headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			id:      "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			assert.Equal(t, tt.id, tt.config.requestID(req))
+		})
+	}
+
+	t.Run("GenerateUUID", func(t *testing.T) {
+		id := RequestIDConfig{}.requestID(httptest.NewRequest(http.MethodPost, "/", nil))
+
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("GenerateShort", func(t *testing.T) {
+		id := RequestIDConfig{Format: RequestIDFormatShort}.requestID(httptest.NewRequest(http.MethodPost, "/", nil))
+
+		assert.Len(t, id, 16)
+	})
+}
+
+func TestHandler_RequestID(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		RequestID: &RequestIDConfig{
+			Headers: []string{"X-Correlation-ID"},
+		},
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 1)[0])
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("X-Correlation-ID", "abc")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abc", w.Header().Get("X-Correlation-ID"))
+
+	require.Len(t, collector.Events(), 1)
+	assert.Equal(t, "abc", collector.Events()[0].Extensions()[RequestIDExtension])
+}
@@ -0,0 +1,137 @@
+package httpingest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// ReceivedAtExtension is the extension carrying the time an event was received by the server (see Handler.StampReceivedAt).
+const ReceivedAtExtension = "receivedat"
+
+// ReservedExtensionPolicy defines how events carrying extensions reserved by the server are handled.
+type ReservedExtensionPolicy string
+
+const (
+	// ReservedExtensionReject rejects events carrying reserved extensions. This is the default.
+	ReservedExtensionReject ReservedExtensionPolicy = "reject"
+
+	// ReservedExtensionOverwrite removes reserved extensions set by the client,
+	// so that only values set by the server are forwarded.
+	ReservedExtensionOverwrite ReservedExtensionPolicy = "overwrite"
+
+	// ReservedExtensionPreserve keeps reserved extensions set by the client:
+	// the server does not override them during enrichment.
+	ReservedExtensionPreserve ReservedExtensionPolicy = "preserve"
+)
+
+// Validate validates the policy.
+func (p ReservedExtensionPolicy) Validate() error {
+	switch p {
+	case "", ReservedExtensionReject, ReservedExtensionOverwrite, ReservedExtensionPreserve:
+		return nil
+	}
+
+	return fmt.Errorf("invalid reserved extension policy: %q", p)
+}
+
+// reservedExtensions returns the names of extensions controlled by the server:
+// the configured ReservedExtensions and every extension set by the handler during enrichment.
+func (h Handler) reservedExtensions() []string {
+	var reserved []string
+
+	if h.Sampler != nil {
+		reserved = append(reserved, SampleRateExtension)
+	}
+
+	if h.RequestID != nil {
+		reserved = append(reserved, RequestIDExtension)
+	}
+
+	if h.StampReceivedAt {
+		reserved = append(reserved, ReceivedAtExtension)
+	}
+
+	// Without a NamespaceFunc, the namespace extension is declared by clients
+	if h.Namespace != nil {
+		reserved = append(reserved, NamespaceExtension)
+	}
+
+	if h.MeterExtractor != nil {
+		reserved = append(reserved, h.MeterExtractor.extensions()...)
+	}
+
+	if h.ClockDrift != nil && h.ClockDrift.Extension != "" {
+		reserved = append(reserved, h.ClockDrift.Extension)
+	}
+
+	if h.ContentHash != nil {
+		reserved = append(reserved, h.ContentHash.extension())
+	}
+
+	if h.SubjectRates != nil {
+		reserved = append(reserved, h.SubjectRates.extension)
+	}
+
+	if h.SourceSequences != nil {
+		reserved = append(reserved, h.SourceSequences.extension)
+	}
+
+	if h.Geo != nil {
+		reserved = append(reserved, h.Geo.extensions()...)
+	}
+
+	if h.RegenerateIDs {
+		reserved = append(reserved, ClientEventIDExtension)
+	}
+
+	if len(reserved) == 0 {
+		return h.ReservedExtensions
+	}
+
+	return append(reserved, h.ReservedExtensions...)
+}
+
+// checkReservedExtensions applies the reserved extension policy to an event received from a client.
+func (h Handler) checkReservedExtensions(ev *event.Event) error {
+	var collisions []string
+
+	for _, name := range h.reservedExtensions() {
+		if _, ok := ev.Extensions()[name]; ok {
+			collisions = append(collisions, name)
+		}
+	}
+
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+
+	switch h.ReservedExtensionPolicy {
+	case ReservedExtensionPreserve:
+		return nil
+
+	case ReservedExtensionOverwrite:
+		for _, name := range collisions {
+			ev.SetExtension(name, nil)
+		}
+
+		return nil
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "event sets extensions reserved by the server: %v", collisions)
+}
+
+// setServerExtension sets an extension controlled by the server, honoring the reserved extension policy.
+func (h Handler) setServerExtension(ev *event.Event, name string, value interface{}) {
+	if h.ReservedExtensionPolicy == ReservedExtensionPreserve {
+		if _, ok := ev.Extensions()[name]; ok {
+			return
+		}
+	}
+
+	ev.SetExtension(name, value)
+}
@@ -0,0 +1,129 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestReservedExtensionPolicy(t *testing.T) {
+	newEvent := func() event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetExtension("namespace", "spoofed")
+		ev.SetExtension("region", "eu")
+
+		return ev
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:          collector,
+			ReservedExtensions: []string{"namespace"},
+		}
+
+		err := handler.processEvent(context.Background(), newEvent())
+		require.Error(t, err)
+
+		assert.Equal(t, http.StatusBadRequest, statusCode(err))
+		assert.Contains(t, err.Error(), "namespace")
+		assert.Empty(t, collector.Events())
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:               collector,
+			ReservedExtensions:      []string{"namespace"},
+			ReservedExtensionPolicy: ReservedExtensionOverwrite,
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent()))
+
+		require.Len(t, collector.Events(), 1)
+		assert.Equal(t, map[string]interface{}{"region": "eu"}, collector.Events()[0].Extensions())
+	})
+
+	t.Run("preserve", func(t *testing.T) {
+		collector := &testcollector.Collector{}
+		handler := Handler{
+			Collector:               collector,
+			ReservedExtensions:      []string{"namespace"},
+			ReservedExtensionPolicy: ReservedExtensionPreserve,
+		}
+
+		require.NoError(t, handler.processEvent(context.Background(), newEvent()))
+
+		require.Len(t, collector.Events(), 1)
+		assert.Equal(t, "spoofed", collector.Events()[0].Extensions()["namespace"])
+
+		ev := newEvent()
+		handler.setServerExtension(&ev, "namespace", "server")
+		handler.setServerExtension(&ev, "tenant", "server")
+
+		assert.Equal(t, "spoofed", ev.Extensions()["namespace"])
+		assert.Equal(t, "server", ev.Extensions()["tenant"])
+	})
+}
+
+func TestHandler_ServerExtensions(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	newEvent := func() event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetType("api-calls")
+		ev.SetSubject("sub")
+
+		return ev
+	}
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:       collector,
+		Clock:           func() time.Time { return now },
+		StampReceivedAt: true,
+		Namespace: func(_ context.Context, _ event.Event) (string, error) {
+			return "tenant", nil
+		},
+	}
+
+	assert.ElementsMatch(t, []string{ReceivedAtExtension, NamespaceExtension}, handler.reservedExtensions())
+
+	require.NoError(t, handler.processEvent(context.Background(), newEvent()))
+
+	require.Len(t, collector.Events(), 1)
+	assert.Equal(t, "tenant", collector.Events()[0].Extensions()[NamespaceExtension])
+	assert.Equal(t, types.Timestamp{Time: now}, collector.Events()[0].Extensions()[ReceivedAtExtension])
+
+	for _, name := range []string{ReceivedAtExtension, NamespaceExtension} {
+		ev := newEvent()
+		ev.SetExtension(name, "spoofed")
+
+		err := handler.processEvent(context.Background(), ev)
+		require.Error(t, err, name)
+
+		assert.Equal(t, http.StatusBadRequest, statusCode(err))
+		assert.Contains(t, err.Error(), name)
+	}
+
+	// Namespaces declared by clients are not reserved
+	handler.Namespace = nil
+
+	ev := newEvent()
+	ev.SetExtension(NamespaceExtension, "declared")
+
+	require.NoError(t, handler.processEvent(context.Background(), ev))
+	assert.Equal(t, "declared", collector.Events()[1].Extensions()[NamespaceExtension])
+}
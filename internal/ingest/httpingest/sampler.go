@@ -0,0 +1,160 @@
+package httpingest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// SampleRateExtension is the extension carrying the sample rate of sampled events:
+// each forwarded event represents samplerate events, so downstream aggregations can scale values accordingly.
+const SampleRateExtension = "samplerate"
+
+// Sampler decides which events are forwarded to the {Collector}.
+type Sampler interface {
+	// Sample returns whether the event is kept and the rate it was sampled at (1 means no sampling).
+	Sample(ev event.Event) (keep bool, rate int)
+}
+
+// Weighting maps the value of an event (relative to the threshold of a ValueSampler) to its probability of being kept.
+type Weighting string
+
+const (
+	// WeightingLinear keeps events with a probability proportional to their value.
+	WeightingLinear Weighting = "linear"
+
+	// WeightingSqrt keeps events with a probability proportional to the square root of their value,
+	// sampling low values less aggressively than WeightingLinear.
+	WeightingSqrt Weighting = "sqrt"
+)
+
+// Validate validates the weighting.
+func (w Weighting) Validate() error {
+	switch w {
+	case "", WeightingLinear, WeightingSqrt:
+		return nil
+	}
+
+	return fmt.Errorf("invalid weighting: %q", w)
+}
+
+func (w Weighting) probability(ratio float64) float64 {
+	if w == WeightingSqrt {
+		return math.Sqrt(ratio)
+	}
+
+	return ratio
+}
+
+const defaultMaxSampleRate = 100
+
+// ValueSamplerConfig configures a ValueSampler.
+type ValueSamplerConfig struct {
+	// Path is the JSONPath of the numeric value in the event data (eg. $.cost).
+	Path string
+
+	// Threshold is the value at or above which events are always kept.
+	Threshold float64
+
+	// Weighting maps values below the threshold to the probability of keeping the event.
+	// Defaults to WeightingLinear.
+	Weighting Weighting
+
+	// MaxRate is the highest sample rate, bounding the error of low (or zero) values. Defaults to 100.
+	MaxRate int
+}
+
+// Validate validates the configuration.
+func (c ValueSamplerConfig) Validate() error {
+	if _, err := parseJSONPath(c.Path); err != nil {
+		return err
+	}
+
+	if c.Threshold <= 0 || math.IsInf(c.Threshold, 0) || math.IsNaN(c.Threshold) {
+		return fmt.Errorf("invalid threshold: %v", c.Threshold)
+	}
+
+	if err := c.Weighting.Validate(); err != nil {
+		return err
+	}
+
+	if c.MaxRate < 0 {
+		return fmt.Errorf("invalid max sample rate: %d", c.MaxRate)
+	}
+
+	return nil
+}
+
+// ValueSampler samples events with a probability inversely proportional to a numeric data value:
+// events with a value at or above the threshold are always kept, lower values are sampled.
+//
+// Events without a valid value are always kept.
+type ValueSampler struct {
+	path      jsonPath
+	threshold float64
+	weighting Weighting
+	maxRate   int
+	random    func() float64
+}
+
+// NewValueSampler returns a new ValueSampler.
+func NewValueSampler(config ValueSamplerConfig) (*ValueSampler, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	path, err := parseJSONPath(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRate := config.MaxRate
+	if maxRate == 0 {
+		maxRate = defaultMaxSampleRate
+	}
+
+	return &ValueSampler{
+		path:      path,
+		threshold: config.Threshold,
+		weighting: config.Weighting,
+		maxRate:   maxRate,
+		random:    rand.Float64,
+	}, nil
+}
+
+// Sample implements Sampler.
+func (s *ValueSampler) Sample(ev event.Event) (bool, int) {
+	rate := s.rate(ev)
+	if rate == 1 {
+		return true, 1
+	}
+
+	return s.random() < 1/float64(rate), rate
+}
+
+// rate returns the sample rate of the event.
+// Rates are integers, so that scaling by the rate is unbiased: an event is kept with the probability of exactly 1/rate.
+func (s *ValueSampler) rate(ev event.Event) int {
+	value, err := s.path.lookupNumber(ev.Data())
+	if err != nil || math.IsNaN(value) || value >= s.threshold {
+		return 1
+	}
+
+	p := s.weighting.probability(math.Max(value, 0) / s.threshold)
+	if p <= 0 {
+		return s.maxRate
+	}
+
+	rate := int(math.Round(1 / p))
+	if rate > s.maxRate {
+		return s.maxRate
+	}
+
+	if rate < 1 {
+		return 1
+	}
+
+	return rate
+}
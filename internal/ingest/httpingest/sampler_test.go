@@ -0,0 +1,105 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestValueSampler_Rate(t *testing.T) {
+	newEvent := func(t *testing.T, data string) event.Event {
+		ev := event.New()
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+		return ev
+	}
+
+	tests := []struct {
+		name      string
+		weighting Weighting
+		data      string
+		rate      int
+	}{
+		{name: "AboveThreshold", data: `{"cost": 150}`, rate: 1},
+		{name: "AtThreshold", data: `{"cost": 100}`, rate: 1},
+		{name: "Linear", data: `{"cost": 25}`, rate: 4},
+		{name: "Sqrt", weighting: WeightingSqrt, data: `{"cost": 25}`, rate: 2},
+		{name: "Zero", data: `{"cost": 0}`, rate: 10},
+		{name: "BelowMaxRate", data: `{"cost": 0.5}`, rate: 10},
+		{name: "Missing", data: `{}`, rate: 1},
+		{name: "Invalid", data: `{"cost": "free"}`, rate: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := NewValueSampler(ValueSamplerConfig{
+				Path:      "$.cost",
+				Threshold: 100,
+				Weighting: tt.weighting,
+				MaxRate:   10,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.rate, sampler.rate(newEvent(t, tt.data)))
+		})
+	}
+}
+
+func TestHandler_Sampler(t *testing.T) {
+	sampler, err := NewValueSampler(ValueSamplerConfig{
+		Path:      "$.cost",
+		Threshold: 100,
+	})
+	require.NoError(t, err)
+
+	var random float64
+	sampler.random = func() float64 { return random }
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		Sampler:   sampler,
+	}
+
+	newEvent := func(t *testing.T, id string, data string) event.Event {
+		ev := event.New()
+		ev.SetID(id)
+		ev.SetSource("test")
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+		return ev
+	}
+
+	ctx := context.Background()
+
+	random = 0.9
+	require.NoError(t, handler.processEvent(ctx, newEvent(t, "high", `{"cost": 200}`)))
+	require.NoError(t, handler.processEvent(ctx, newEvent(t, "dropped", `{"cost": 20}`)))
+
+	random = 0.1
+	require.NoError(t, handler.processEvent(ctx, newEvent(t, "kept", `{"cost": 20}`)))
+
+	require.Len(t, collector.Events(), 2)
+
+	assert.Equal(t, "high", collector.Events()[0].ID())
+	assert.NotContains(t, collector.Events()[0].Extensions(), SampleRateExtension)
+
+	assert.Equal(t, "kept", collector.Events()[1].ID())
+	assert.Equal(t, int32(5), collector.Events()[1].Extensions()[SampleRateExtension])
+
+	t.Run("ClientSampleRate", func(t *testing.T) {
+		ev := newEvent(t, "spoofed", `{"cost": 200}`)
+		ev.SetExtension(SampleRateExtension, 1000)
+
+		err := handler.processEvent(ctx, ev)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+)
+
+// DefaultSchemaVersionExtension is the default extension producers declare the version of the schema of event data in.
+const DefaultSchemaVersionExtension = "dataschemaversion"
+
+// SchemaVersionConfig configures a SchemaVersionValidator.
+type SchemaVersionConfig struct {
+	// Extension carries the schema version of events. Defaults to DefaultSchemaVersionExtension.
+	Extension string
+
+	// MinVersions maps event types to the minimum accepted schema version (eg. 2 or 1.4.0).
+	// Events of other types are accepted whatever their version.
+	MinVersions map[string]string
+
+	// RejectMissing rejects events of types with a minimum version but without a schema version.
+	// They are accepted otherwise.
+	RejectMissing bool
+}
+
+// SchemaVersionValidator rejects events with a schema version below the minimum version of their type,
+// so old payload formats can be deprecated by directing their producers to upgrade.
+//
+// Versions are compared as semantic versions: missing minor and patch versions are zero (2 is 2.0.0), a leading v is allowed.
+type SchemaVersionValidator struct {
+	extension     string
+	minVersions   map[string]*semver.Version
+	rejectMissing bool
+}
+
+// NewSchemaVersionValidator returns a new SchemaVersionValidator.
+func NewSchemaVersionValidator(config SchemaVersionConfig) (*SchemaVersionValidator, error) {
+	if config.Extension != "" && !event.IsExtensionNameValid(config.Extension) {
+		return nil, errors.New("invalid schema version extension name")
+	}
+
+	if len(config.MinVersions) == 0 {
+		return nil, errors.New("at least one minimum schema version is required")
+	}
+
+	extension := config.Extension
+	if extension == "" {
+		extension = DefaultSchemaVersionExtension
+	}
+
+	minVersions := make(map[string]*semver.Version, len(config.MinVersions))
+
+	for typ, v := range config.MinVersions {
+		version, err := semver.NewVersion(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum schema version of type %s: %w", typ, err)
+		}
+
+		minVersions[typ] = version
+	}
+
+	return &SchemaVersionValidator{
+		extension:     extension,
+		minVersions:   minVersions,
+		rejectMissing: config.RejectMissing,
+	}, nil
+}
+
+// Validate validates the schema version of the event.
+func (v *SchemaVersionValidator) Validate(ev event.Event) error {
+	minVersion, ok := v.minVersions[ev.Type()]
+	if !ok {
+		return nil
+	}
+
+	value, ok := ev.Extensions()[v.extension]
+	if !ok {
+		if v.rejectMissing {
+			return NewEventErrorf(http.StatusBadRequest, "missing %s extension: events of type %s must declare a schema version of at least %s", v.extension, ev.Type(), minVersion.Original())
+		}
+
+		return nil
+	}
+
+	// Integer versions are valid extension values
+	s, err := types.Format(value)
+	if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "invalid %s extension: %w", v.extension, err)
+	}
+
+	version, err := semver.NewVersion(s)
+	if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "invalid %s extension %q: %w", v.extension, s, err)
+	}
+
+	if version.LessThan(minVersion) {
+		return NewEventErrorf(http.StatusBadRequest, "schema version %s of type %s is no longer accepted: upgrade the producer to schema version %s or later", s, ev.Type(), minVersion.Original())
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSchemaVersionValidator(t *testing.T) {
+	validator, err := NewSchemaVersionValidator(SchemaVersionConfig{
+		MinVersions: map[string]string{
+			"api-calls": "2.1",
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		typ     string
+		version interface{}
+		valid   bool
+	}{
+		{name: "Newer", typ: "api-calls", version: "2.1.1", valid: true},
+		{name: "Minimum", typ: "api-calls", version: "v2.1.0", valid: true},
+		{name: "Major", typ: "api-calls", version: int32(3), valid: true},
+		{name: "Older", typ: "api-calls", version: "2.0.9", valid: false},
+		{name: "OlderMajor", typ: "api-calls", version: int32(1), valid: false},
+		{name: "Invalid", typ: "api-calls", version: "latest", valid: false},
+		{name: "Missing", typ: "api-calls", valid: true},
+		{name: "OtherType", typ: "storage", version: "1", valid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ev := event.New()
+			ev.SetType(test.typ)
+
+			if test.version != nil {
+				ev.SetExtension(DefaultSchemaVersionExtension, test.version)
+			}
+
+			err := validator.Validate(ev)
+			if test.valid {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, statusCode(err))
+		})
+	}
+
+	ev := event.New()
+	ev.SetType("api-calls")
+	ev.SetExtension(DefaultSchemaVersionExtension, "1.0.0")
+
+	assert.EqualError(t, validator.Validate(ev), "schema version 1.0.0 of type api-calls is no longer accepted: upgrade the producer to schema version 2.1 or later")
+}
+
+func TestSchemaVersionValidator_RejectMissing(t *testing.T) {
+	validator, err := NewSchemaVersionValidator(SchemaVersionConfig{
+		Extension:     "schemaversion",
+		MinVersions:   map[string]string{"api-calls": "2"},
+		RejectMissing: true,
+	})
+	require.NoError(t, err)
+
+	events := newTestEvents(t, 2)
+	events[0].SetExtension("schemaversion", "2.0.0")
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:      collector,
+		SchemaVersions: validator,
+	}
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+	assert.Contains(t, results[1].Error, "missing schemaversion extension")
+
+	testcollector.AssertReceived(t, collector, "0")
+}
+
+func TestNewSchemaVersionValidator(t *testing.T) {
+	_, err := NewSchemaVersionValidator(SchemaVersionConfig{})
+	assert.Error(t, err)
+
+	_, err = NewSchemaVersionValidator(SchemaVersionConfig{MinVersions: map[string]string{"api-calls": "latest"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// DefaultSequenceExtension is the default extension carrying the sequence number of events.
+	DefaultSequenceExtension = "sequence"
+
+	defaultSequenceTrackerSize = 10000
+)
+
+// Sequence anomalies reported by a SequenceTracker.
+const (
+	SequenceGap        = "gap"
+	SequenceOutOfOrder = "out_of_order"
+)
+
+// SequenceTrackerConfig configures a SequenceTracker.
+type SequenceTrackerConfig struct {
+	// Extension carries the sequence number of events. Defaults to DefaultSequenceExtension.
+	Extension string
+
+	// Size is the maximum number of subjects whose last sequence number is remembered. Defaults to 10000.
+	// The least recently seen subjects are forgotten first when the limit is reached.
+	Size int
+
+	// Subjects shares the tracked subjects (and their limit) with other per-subject features (optional).
+	// Size is ignored when set.
+	Subjects *SubjectTracker
+}
+
+// SequenceTracker detects gaps and out of order sequence numbers of subjects at ingest,
+// an early warning of events lost (or reordered) by producers.
+//
+// Detection is observational: events are never rejected, anomalies are only reported in metrics and logs.
+// Events without a (numeric) sequence number are ignored.
+type SequenceTracker struct {
+	extension string
+	subjects  *SubjectTracker
+}
+
+// NewSequenceTracker returns a new SequenceTracker.
+func NewSequenceTracker(config SequenceTrackerConfig) (*SequenceTracker, error) {
+	if config.Extension != "" && !event.IsExtensionNameValid(config.Extension) {
+		return nil, errors.New("invalid sequence extension name")
+	}
+
+	if config.Size < 0 {
+		return nil, errors.New("sequence tracker size must not be negative")
+	}
+
+	extension := config.Extension
+	if extension == "" {
+		extension = DefaultSequenceExtension
+	}
+
+	subjects := config.Subjects
+	if subjects == nil {
+		size := config.Size
+		if size == 0 {
+			size = defaultSequenceTrackerSize
+		}
+
+		var err error
+
+		subjects, err = NewSubjectTracker(SubjectTrackerConfig{Size: size})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &SequenceTracker{
+		extension: extension,
+		subjects:  subjects,
+	}, nil
+}
+
+// track records the sequence number of the event.
+// It returns the anomaly (if any) and the previous sequence number of the subject.
+func (t *SequenceTracker) track(endpoint string, ev event.Event) (string, int64) {
+	value, ok := ev.Extensions()[t.extension]
+	if !ok {
+		return "", 0
+	}
+
+	s, err := types.Format(value)
+	if err != nil {
+		return "", 0
+	}
+
+	sequence, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return "", 0
+	}
+
+	var anomaly string
+	var previous int64
+
+	t.subjects.update(subjectKey{endpoint: endpoint, subject: ev.Subject()}, func(state *subjectState) {
+		if !state.hasSequence {
+			state.hasSequence = true
+			state.sequence = sequence
+
+			return
+		}
+
+		previous = state.sequence
+
+		switch {
+		case sequence == previous+1:
+			state.sequence = sequence
+
+		case sequence > previous+1:
+			state.sequence = sequence
+			anomaly = SequenceGap
+
+		default:
+			anomaly = SequenceOutOfOrder
+		}
+	})
+
+	return anomaly, previous
+}
+
+// observeSequence reports sequence anomalies of the event.
+func (h Handler) observeSequence(ctx context.Context, logger *slog.Logger, ev event.Event) {
+	anomaly, previous := h.Sequences.track(endpointFromContext(ctx), ev)
+	if anomaly == "" {
+		return
+	}
+
+	h.metrics().RecordSequenceAnomaly(ctx, anomaly)
+
+	logger.WarnCtx(ctx, "event sequence anomaly", slog.String("anomaly", anomaly), slog.Int64("previous_sequence", previous), slog.Any("sequence", ev.Extensions()[h.Sequences.extension]))
+}
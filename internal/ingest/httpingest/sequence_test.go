@@ -0,0 +1,77 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_Sequences(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+
+	sequences, err := NewSequenceTracker(SequenceTrackerConfig{Size: 2})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector: collector,
+		Metrics:   metrics,
+		Sequences: sequences,
+	}
+
+	newEvent := func(subject string, sequence any) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetSubject(subject)
+
+		if sequence != nil {
+			ev.SetExtension("sequence", sequence)
+		}
+
+		return ev
+	}
+
+	ctx := context.Background()
+
+	for _, ev := range []event.Event{
+		newEvent("customer-1", 1),
+		newEvent("customer-1", 2),
+		newEvent("customer-1", 5),   // gap
+		newEvent("customer-1", 4),   // out of order
+		newEvent("customer-1", "6"), // in order after the gap
+		newEvent("customer-1", nil), // ignored
+		newEvent("customer-1", "a"), // ignored
+		newEvent("customer-2", 10),
+		newEvent("customer-3", 10), // evicts customer-1
+		newEvent("customer-1", 20), // forgotten, not a gap
+		newEvent("customer-3", 12), // gap
+	} {
+		require.NoError(t, handler.processEvent(ctx, ev))
+	}
+
+	// Anomalies are reported, not rejected
+	assert.Len(t, collector.Events(), 11)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.sequenceAnomalies.WithLabelValues("", SequenceGap)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.sequenceAnomalies.WithLabelValues("", SequenceOutOfOrder)))
+}
+
+func TestNewSequenceTracker(t *testing.T) {
+	_, err := NewSequenceTracker(SequenceTrackerConfig{Extension: "invalid-name"})
+	assert.Error(t, err)
+
+	_, err = NewSequenceTracker(SequenceTrackerConfig{Size: -1})
+	assert.Error(t, err)
+}
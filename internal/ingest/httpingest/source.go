@@ -0,0 +1,41 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkSource rejects events with a source not matching the source pattern.
+func (h Handler) checkSource(ev event.Event) error {
+	if h.SourcePattern == nil || h.SourcePattern.MatchString(ev.Source()) {
+		return nil
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "event source %q is not allowed: source must match %q", ev.Source(), h.SourcePattern.String())
+}
+
+type trustedSourceContextKey struct{}
+
+// withTrustedSource stores the source set by the trusted source header of the request (if any) in the request context.
+func (h Handler) withTrustedSource(r *http.Request) *http.Request {
+	if h.TrustedSourceHeader == "" {
+		return r
+	}
+
+	source := strings.TrimSpace(r.Header.Get(h.TrustedSourceHeader))
+	if source == "" {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), trustedSourceContextKey{}, source))
+}
+
+// trustedSourceFromContext returns the source set by the trusted source header of the request.
+func trustedSourceFromContext(ctx context.Context) (string, bool) {
+	source, ok := ctx.Value(trustedSourceContextKey{}).(string)
+
+	return source, ok
+}
@@ -0,0 +1,70 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSourcePattern(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:     collector,
+		SourcePattern: regexp.MustCompile(`^https://[a-z0-9-]+\.example\.com/`),
+	}
+
+	ctx := context.Background()
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("https://billing.example.com/api")
+	ev.SetType("api-calls")
+
+	require.NoError(t, handler.processEvent(ctx, ev))
+
+	ev.SetSource("https://billing.exampel.com/api")
+
+	err := handler.processEvent(ctx, ev)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+	assert.Contains(t, err.Error(), `^https://[a-z0-9-]+\\.example\\.com/`)
+
+	assert.Len(t, collector.Events(), 1)
+}
+
+func TestTrustedSourceHeader(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:           collector,
+		TrustedSourceHeader: "X-Event-Source",
+	}
+
+	const ev = `{"specversion":"1.0","id":"1","source":"producer","type":"api-calls"}`
+
+	for _, source := range []string{"gateway", ""} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		if source != "" {
+			req.Header.Set("X-Event-Source", source)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	require.Len(t, collector.Events(), 2)
+	assert.Equal(t, "gateway", collector.Events()[0].Source())
+	assert.Equal(t, "producer", collector.Events()[1].Source())
+}
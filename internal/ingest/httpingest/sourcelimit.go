@@ -0,0 +1,177 @@
+package httpingest
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultSourceLimitWindow  = time.Hour
+	defaultSourceLimitTenants = 10000
+)
+
+// SourceLimiterConfig configures a SourceLimiter.
+type SourceLimiterConfig struct {
+	// Limit is the maximum number of distinct sources of a tenant within the window.
+	Limit int
+
+	// Window is how long a source counts towards the limit after the last event of the tenant from it. Defaults to 1h.
+	Window time.Duration
+
+	// Tenants is the maximum number of tenants whose sources are tracked. Defaults to 10000.
+	// The least recently seen tenants are forgotten first when the limit is reached (their sources count from zero again).
+	Tenants int
+
+	// Status is the status events from sources over the limit are rejected with:
+	// 429 Too Many Requests (default) or 403 Forbidden.
+	Status int
+
+	// Registerer registers a metric of events rejected because of the limit (optional).
+	Registerer prometheus.Registerer
+}
+
+// SourceLimiter limits the number of distinct sources of each tenant (the namespace of events, see NamespaceFunc)
+// within a sliding window, protecting the cardinality budgets of downstream systems from tenants inventing sources.
+//
+// Events from sources already seen within the window are always accepted,
+// events from new sources over the limit are rejected until older sources expire.
+type SourceLimiter struct {
+	limit    int
+	window   time.Duration
+	tenants  int
+	status   int
+	rejected prometheus.Counter
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// tenantSources are the sources of a tenant seen within the window.
+type tenantSources struct {
+	tenant   string
+	lastSeen map[string]time.Time
+}
+
+// NewSourceLimiter returns a new SourceLimiter.
+func NewSourceLimiter(config SourceLimiterConfig) (*SourceLimiter, error) {
+	if config.Limit <= 0 {
+		return nil, errors.New("source limit must be positive")
+	}
+
+	if config.Window < 0 {
+		return nil, errors.New("source limit window must not be negative")
+	}
+
+	if config.Tenants < 0 {
+		return nil, errors.New("source limit tenants must not be negative")
+	}
+
+	status := config.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+
+	if status != http.StatusTooManyRequests && status != http.StatusForbidden {
+		return nil, errors.New("source limit status must be 429 or 403")
+	}
+
+	window := config.Window
+	if window == 0 {
+		window = defaultSourceLimitWindow
+	}
+
+	tenants := config.Tenants
+	if tenants == 0 {
+		tenants = defaultSourceLimitTenants
+	}
+
+	l := &SourceLimiter{
+		limit:   config.Limit,
+		window:  window,
+		tenants: tenants,
+		status:  status,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+
+	if config.Registerer != nil {
+		l.rejected = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "source_limit_rejected_events_total",
+			Help:      "Number of events rejected because their tenant reached the limit of distinct sources.",
+		})
+
+		if err := config.Registerer.Register(l.rejected); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// allow records the source of the tenant, and returns an error if it is a new source over the limit.
+func (l *SourceLimiter) allow(tenant string, source string) error {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[tenant]
+	if ok {
+		l.order.MoveToFront(e)
+	} else {
+		if l.order.Len() >= l.tenants {
+			oldest := l.order.Back()
+
+			l.order.Remove(oldest)
+			delete(l.entries, oldest.Value.(*tenantSources).tenant)
+		}
+
+		e = l.order.PushFront(&tenantSources{tenant: tenant, lastSeen: make(map[string]time.Time)})
+		l.entries[tenant] = e
+	}
+
+	sources := e.Value.(*tenantSources)
+
+	if _, ok := sources.lastSeen[source]; !ok && len(sources.lastSeen) >= l.limit {
+		// Expiring lazily: the map of a tenant is never larger than the limit
+		for s, lastSeen := range sources.lastSeen {
+			if now.Sub(lastSeen) >= l.window {
+				delete(sources.lastSeen, s)
+			}
+		}
+
+		if len(sources.lastSeen) >= l.limit {
+			if l.rejected != nil {
+				l.rejected.Inc()
+			}
+
+			return NewEventErrorf(l.status, "too many distinct event sources: the limit is %d per %s", l.limit, l.window)
+		}
+	}
+
+	sources.lastSeen[source] = now
+
+	return nil
+}
+
+// checkSourceLimit rejects events from new sources of tenants over the source limit.
+func (h Handler) checkSourceLimit(ctx context.Context, ev event.Event) error {
+	tenant, err := h.namespace(ctx, ev)
+	if err != nil {
+		return err
+	}
+
+	return h.SourceLimit.allow(tenant, ev.Source())
+}
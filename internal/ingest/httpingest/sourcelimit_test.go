@@ -0,0 +1,105 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSourceLimiter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	limiter, err := NewSourceLimiter(SourceLimiterConfig{Limit: 2, Window: time.Minute, Registerer: registry})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	require.NoError(t, limiter.allow("acme", "a"))
+	require.NoError(t, limiter.allow("acme", "b"))
+
+	err = limiter.allow("acme", "c")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, statusCode(err))
+
+	// Known sources and other tenants are not limited
+	require.NoError(t, limiter.allow("acme", "a"))
+	require.NoError(t, limiter.allow("other", "c"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(limiter.rejected))
+
+	// Sources expire after the window since their last event
+	now = now.Add(time.Minute)
+
+	require.NoError(t, limiter.allow("acme", "a"))
+	require.NoError(t, limiter.allow("acme", "c"))
+
+	err = limiter.allow("acme", "b")
+	require.Error(t, err)
+}
+
+func TestSourceLimiter_Tenants(t *testing.T) {
+	limiter, err := NewSourceLimiter(SourceLimiterConfig{Limit: 1, Tenants: 1, Status: http.StatusForbidden})
+	require.NoError(t, err)
+
+	require.NoError(t, limiter.allow("acme", "a"))
+
+	err = limiter.allow("acme", "b")
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+
+	// The least recently seen tenant is forgotten
+	require.NoError(t, limiter.allow("other", "a"))
+	require.NoError(t, limiter.allow("acme", "b"))
+}
+
+func TestNewSourceLimiter(t *testing.T) {
+	_, err := NewSourceLimiter(SourceLimiterConfig{})
+	assert.Error(t, err)
+
+	_, err = NewSourceLimiter(SourceLimiterConfig{Limit: 1, Status: http.StatusBadRequest})
+	assert.Error(t, err)
+}
+
+func TestHandler_SourceLimit(t *testing.T) {
+	limiter, err := NewSourceLimiter(SourceLimiterConfig{Limit: 1})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:   collector,
+		SourceLimit: limiter,
+	}
+
+	events := newTestEvents(t, 3)
+	events[1].SetSource("other")
+	events[2].SetExtension(NamespaceExtension, "acme")
+	events[2].SetSource("other")
+
+	var statuses []int
+
+	for _, ev := range events {
+		body, err := json.Marshal(ev)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		statuses = append(statuses, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK}, statuses)
+	testcollector.AssertReceived(t, collector, "0", "2")
+}
@@ -0,0 +1,193 @@
+package httpingest
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const (
+	// DefaultSourceSequenceExtension is the default extension the sequence number of events in their source is set in.
+	DefaultSourceSequenceExtension = "seq"
+
+	defaultSourceSequenceSize = 10000
+)
+
+// SourceSequenceStore assigns sequence numbers to the events of sources.
+//
+// Implementations must be safe for concurrent use.
+type SourceSequenceStore interface {
+	// Next returns the next sequence number of the source, starting at 1.
+	Next(ctx context.Context, endpoint string, source string) (int64, error)
+}
+
+// SourceSequencerConfig configures a SourceSequencer.
+type SourceSequencerConfig struct {
+	// Extension is the extension the sequence number is set in. Defaults to DefaultSourceSequenceExtension.
+	Extension string
+
+	// Store assigns sequence numbers. Defaults to a MemorySourceSequenceStore with default settings.
+	Store SourceSequenceStore
+}
+
+// SourceSequencer stamps events with a server assigned sequence number, increasing by one for every event of their source,
+// so consumers can detect events lost between ingestion and consumption by gaps.
+//
+// Events are stamped right before they are forwarded to the {Collector}: events rejected or dropped by the sampler are not numbered.
+// An event that cannot be forwarded leaves a gap, as it is lost unless the client retries it (with a new number).
+//
+// Sequence numbers are only consistent within a single instance with a MemorySourceSequenceStore:
+// instances behind a load balancer number the events of a source independently (and restart at 1 when restarted),
+// unless they share a store backed by a shared database.
+type SourceSequencer struct {
+	extension string
+	store     SourceSequenceStore
+}
+
+// NewSourceSequencer returns a new SourceSequencer.
+func NewSourceSequencer(config SourceSequencerConfig) (*SourceSequencer, error) {
+	if config.Extension != "" && !event.IsExtensionNameValid(config.Extension) {
+		return nil, errors.New("invalid source sequence extension name")
+	}
+
+	extension := config.Extension
+	if extension == "" {
+		extension = DefaultSourceSequenceExtension
+	}
+
+	store := config.Store
+	if store == nil {
+		var err error
+
+		store, err = NewMemorySourceSequenceStore(MemorySourceSequenceStoreConfig{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &SourceSequencer{
+		extension: extension,
+		store:     store,
+	}, nil
+}
+
+// stampSourceSequence sets the next sequence number of the source of the event in the source sequence extension.
+func (h Handler) stampSourceSequence(ctx context.Context, ev *event.Event) error {
+	sequence, err := h.SourceSequences.store.Next(ctx, endpointFromContext(ctx), ev.Source())
+	if err != nil {
+		return fmt.Errorf("assign source sequence number: %w", err)
+	}
+
+	h.setServerExtension(ev, h.SourceSequences.extension, formatSequence(sequence))
+
+	return nil
+}
+
+// formatSequence returns the extension value of a sequence number:
+// integer extensions are limited to 32 bits, larger numbers are set as strings.
+func formatSequence(sequence int64) interface{} {
+	if sequence <= math.MaxInt32 {
+		return int32(sequence)
+	}
+
+	return strconv.FormatInt(sequence, 10)
+}
+
+// MemorySourceSequenceStoreConfig configures a MemorySourceSequenceStore.
+type MemorySourceSequenceStoreConfig struct {
+	// Size is the maximum number of sources whose counter is remembered. Defaults to 10000.
+	// The least recently seen sources are forgotten first when the limit is reached: their counter restarts at 1.
+	Size int
+
+	// ResetAfter restarts the counter of sources without events for the duration at 1 (optional).
+	// Counters are otherwise kept as long as they are remembered.
+	ResetAfter time.Duration
+}
+
+// MemorySourceSequenceStore keeps the counters of sources in memory (per process): counters restart at 1 when the process restarts.
+type MemorySourceSequenceStore struct {
+	size       int
+	resetAfter time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[sourceKey]*list.Element
+	order   *list.List
+}
+
+// sourceKey identifies a source in per-source state, scoped to the endpoint of the handler (see endpoint.go).
+type sourceKey struct {
+	endpoint string
+	source   string
+}
+
+type sourceSequenceEntry struct {
+	key      sourceKey
+	sequence int64
+	lastSeen time.Time
+}
+
+// NewMemorySourceSequenceStore returns a new MemorySourceSequenceStore.
+func NewMemorySourceSequenceStore(config MemorySourceSequenceStoreConfig) (*MemorySourceSequenceStore, error) {
+	if config.Size < 0 {
+		return nil, errors.New("source sequence size must not be negative")
+	}
+
+	if config.ResetAfter < 0 {
+		return nil, errors.New("source sequence reset interval must not be negative")
+	}
+
+	size := config.Size
+	if size == 0 {
+		size = defaultSourceSequenceSize
+	}
+
+	return &MemorySourceSequenceStore{
+		size:       size,
+		resetAfter: config.ResetAfter,
+		now:        time.Now,
+		entries:    make(map[sourceKey]*list.Element),
+		order:      list.New(),
+	}, nil
+}
+
+func (s *MemorySourceSequenceStore) Next(_ context.Context, endpoint string, source string) (int64, error) {
+	key := sourceKey{endpoint: endpoint, source: source}
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		if s.order.Len() >= s.size {
+			oldest := s.order.Back()
+
+			s.order.Remove(oldest)
+			delete(s.entries, oldest.Value.(*sourceSequenceEntry).key)
+		}
+
+		e = s.order.PushFront(&sourceSequenceEntry{key: key})
+		s.entries[key] = e
+	} else {
+		s.order.MoveToFront(e)
+	}
+
+	entry := e.Value.(*sourceSequenceEntry)
+
+	if s.resetAfter > 0 && !entry.lastSeen.IsZero() && now.Sub(entry.lastSeen) >= s.resetAfter {
+		entry.sequence = 0
+	}
+
+	entry.sequence++
+	entry.lastSeen = now
+
+	return entry.sequence, nil
+}
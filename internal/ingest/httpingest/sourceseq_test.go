@@ -0,0 +1,86 @@
+package httpingest
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestMemorySourceSequenceStore(t *testing.T) {
+	store, err := NewMemorySourceSequenceStore(MemorySourceSequenceStoreConfig{Size: 2, ResetAfter: time.Hour})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	next := func(endpoint string, source string) int64 {
+		sequence, err := store.Next(ctx, endpoint, source)
+		require.NoError(t, err)
+
+		return sequence
+	}
+
+	assert.Equal(t, int64(1), next("", "service-1"))
+	assert.Equal(t, int64(2), next("", "service-1"))
+
+	// Sources of different endpoints are numbered separately
+	assert.Equal(t, int64(1), next("internal", "service-1"))
+
+	// service-1 of the default endpoint is evicted (least recently seen)
+	assert.Equal(t, int64(1), next("", "service-2"))
+	assert.Equal(t, int64(1), next("", "service-1"))
+	assert.Equal(t, int64(2), next("", "service-1"))
+
+	// Idle sources restart at 1
+	now = now.Add(time.Hour)
+	assert.Equal(t, int64(1), next("", "service-1"))
+}
+
+func TestHandler_SourceSequences(t *testing.T) {
+	sequencer, err := NewSourceSequencer(SourceSequencerConfig{})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+
+	handler := Handler{
+		Collector:       collector,
+		SourceSequences: sequencer,
+	}
+
+	for _, source := range []string{"service-1", "service-1", "service-2"} {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource(source)
+
+		require.NoError(t, handler.processEvent(context.Background(), ev))
+	}
+
+	var sequences []interface{}
+	for _, ev := range collector.Events() {
+		sequences = append(sequences, ev.Extensions()[DefaultSourceSequenceExtension])
+	}
+
+	assert.Equal(t, []interface{}{int32(1), int32(2), int32(1)}, sequences)
+
+	// Clients cannot set the sequence number
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("service-1")
+	ev.SetExtension(DefaultSourceSequenceExtension, 1)
+
+	assert.Error(t, handler.processEvent(context.Background(), ev))
+
+	assert.Equal(t, "2147483648", formatSequence(math.MaxInt32+1))
+
+	_, err = NewSourceSequencer(SourceSequencerConfig{Extension: "invalid_name"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// IdentityFunc resolves the authenticated identity of a request (eg. from an authentication context).
+// An empty identity means the request is not authenticated.
+type IdentityFunc func(r *http.Request) string
+
+// HeaderIdentity resolves the identity of requests from a header set by a trusted proxy authenticating clients (eg. an API gateway).
+// Clients can set any identity when the header is not overwritten by such a proxy.
+func HeaderIdentity(name string) IdentityFunc {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// Variables of source templates.
+const (
+	sourceTemplateRemoteIP     = "remoteip"
+	sourceTemplateIdentity     = "identity"
+	sourceTemplateHeaderPrefix = "header:"
+)
+
+// SourceTemplateConfig configures a SourceTemplate.
+type SourceTemplateConfig struct {
+	// Template of sources (eg. "myapp/{header:X-Service}/{remoteip}").
+	//
+	// Supported variables:
+	//  - {header:<name>}: the value of a request header
+	//  - {remoteip}: the IP address of the client
+	//  - {identity}: the authenticated identity of the request (see IdentityFunc)
+	Template string
+
+	// Default is the source of events when a template variable cannot be resolved (optional).
+	// Without a default, events are rejected.
+	Default string
+}
+
+// SourceTemplate derives the source of events without one from their request.
+// Sources are resolved once per request.
+type SourceTemplate struct {
+	parts        []sourceTemplatePart
+	defaultValue string
+}
+
+type sourceTemplatePart struct {
+	literal  string
+	variable string
+}
+
+// NewSourceTemplate parses the source template.
+func NewSourceTemplate(config SourceTemplateConfig) (*SourceTemplate, error) {
+	if config.Template == "" {
+		return nil, errors.New("source template is required")
+	}
+
+	var parts []sourceTemplatePart
+
+	rest := config.Template
+	for rest != "" {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			parts = append(parts, sourceTemplatePart{literal: rest})
+
+			break
+		}
+
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("invalid source template %q: unexpected }", config.Template)
+		}
+
+		if start > 0 {
+			parts = append(parts, sourceTemplatePart{literal: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid source template %q: unterminated variable", config.Template)
+		}
+
+		variable := rest[start+1 : start+end]
+
+		switch {
+		case variable == sourceTemplateRemoteIP, variable == sourceTemplateIdentity:
+		case strings.HasPrefix(variable, sourceTemplateHeaderPrefix) && len(variable) > len(sourceTemplateHeaderPrefix):
+		default:
+			return nil, fmt.Errorf("invalid source template %q: unknown variable {%s}", config.Template, variable)
+		}
+
+		parts = append(parts, sourceTemplatePart{variable: variable})
+		rest = rest[start+end+1:]
+	}
+
+	return &SourceTemplate{
+		parts:        parts,
+		defaultValue: config.Default,
+	}, nil
+}
+
+// resolve returns the source of events of the request.
+func (t *SourceTemplate) resolve(r *http.Request, identity IdentityFunc) (string, error) {
+	var b strings.Builder
+
+	for _, part := range t.parts {
+		if part.variable == "" {
+			b.WriteString(part.literal)
+
+			continue
+		}
+
+		var value string
+
+		switch {
+		case part.variable == sourceTemplateRemoteIP:
+			value = remoteIP(r)
+
+		case part.variable == sourceTemplateIdentity:
+			if identity != nil {
+				value = identity(r)
+			}
+
+		default:
+			value = strings.TrimSpace(r.Header.Get(strings.TrimPrefix(part.variable, sourceTemplateHeaderPrefix)))
+		}
+
+		if value == "" {
+			if t.defaultValue != "" {
+				return t.defaultValue, nil
+			}
+
+			return "", fmt.Errorf("unable to resolve source template variable {%s}", part.variable)
+		}
+
+		b.WriteString(value)
+	}
+
+	return b.String(), nil
+}
+
+// remoteIP returns the IP address of the client (without port).
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+type derivedSourceContextKey struct{}
+
+type derivedSource struct {
+	source string
+	err    error
+}
+
+// withPrincipal passes the authenticated identity of the request to the {Collector} (see ingest.PrincipalFromContext).
+func (h Handler) withPrincipal(r *http.Request) *http.Request {
+	if h.Identity == nil {
+		return r
+	}
+
+	principal := h.Identity(r)
+	if principal == "" {
+		return r
+	}
+
+	return r.WithContext(ingest.ContextWithPrincipal(r.Context(), principal))
+}
+
+// withDerivedSource resolves the source template for the request and stores the outcome in the request context.
+func (h Handler) withDerivedSource(r *http.Request) *http.Request {
+	if h.SourceTemplate == nil {
+		return r
+	}
+
+	source, err := h.SourceTemplate.resolve(r, h.Identity)
+
+	return r.WithContext(context.WithValue(r.Context(), derivedSourceContextKey{}, derivedSource{source: source, err: err}))
+}
+
+// derivedSourceFromContext returns the source derived from the request of the context.
+func derivedSourceFromContext(ctx context.Context) (string, error) {
+	derived, ok := ctx.Value(derivedSourceContextKey{}).(derivedSource)
+	if !ok {
+		return "", errors.New("source template not resolved")
+	}
+
+	return derived.source, derived.err
+}
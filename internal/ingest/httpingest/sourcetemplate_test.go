@@ -0,0 +1,80 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestNewSourceTemplate(t *testing.T) {
+	for _, template := range []string{"", "myapp/{header:}", "myapp/{host}", "myapp/{remoteip", "myapp/}"} {
+		_, err := NewSourceTemplate(SourceTemplateConfig{Template: template})
+		assert.Error(t, err, template)
+	}
+}
+
+func TestSourceTemplate_Resolve(t *testing.T) {
+	template, err := NewSourceTemplate(SourceTemplateConfig{Template: "myapp/{header:X-Service}/{remoteip}/{identity}"})
+	require.NoError(t, err)
+
+	identity := func(r *http.Request) string {
+		return "acme"
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.1:51234"
+	req.Header.Set("X-Service", "billing")
+
+	source, err := template.resolve(req, identity)
+	require.NoError(t, err)
+	assert.Equal(t, "myapp/billing/10.0.0.1/acme", source)
+
+	_, err = template.resolve(req, nil)
+	assert.EqualError(t, err, "unable to resolve source template variable {identity}")
+
+	template, err = NewSourceTemplate(SourceTemplateConfig{Template: "myapp/{header:X-Service}", Default: "myapp/unknown"})
+	require.NoError(t, err)
+
+	source, err = template.resolve(httptest.NewRequest(http.MethodPost, "/", nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "myapp/unknown", source)
+}
+
+func TestHandler_SourceTemplate(t *testing.T) {
+	template, err := NewSourceTemplate(SourceTemplateConfig{Template: "myapp/{header:X-Service}"})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:      collector,
+		SourceTemplate: template,
+	}
+
+	send := func(ev string, service string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(ev))
+		req.Header.Set("Content-Type", ContentTypeSingle)
+
+		if service != "" {
+			req.Header.Set("X-Service", service)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(`{"specversion":"1.0","id":"1","type":"api-calls"}`, "billing"))
+	assert.Equal(t, http.StatusOK, send(`{"specversion":"1.0","id":"2","source":"producer","type":"api-calls"}`, ""))
+	assert.Equal(t, http.StatusBadRequest, send(`{"specversion":"1.0","id":"3","type":"api-calls"}`, ""))
+
+	require.Len(t, collector.Events(), 2)
+	assert.Equal(t, "myapp/billing", collector.Events()[0].Source())
+	assert.Equal(t, "producer", collector.Events()[1].Source())
+}
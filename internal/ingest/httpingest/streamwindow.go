@@ -0,0 +1,262 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const defaultStreamWindowMaxEvents = 100
+
+// StreamWindowConfig configures coalescing the events of streams (ContentTypeNDJSON) into windows forwarded together,
+// so slow producers streaming events one by one cause fewer round-trips to the {Collector}.
+//
+// A window is forwarded once its first event waited for Window, once it has MaxEvents events, or when the stream ends.
+// When the {Collector} is an ingest.BatchReceiver, the events of a window are forwarded with a single ReceiveBatch call,
+// otherwise they are forwarded one by one when the window is forwarded.
+// Windows only hold events of a single namespace.
+//
+// The results of forwarded events are written once their window is forwarded,
+// after the results of later events rejected before being forwarded (see EventResult.Index).
+type StreamWindowConfig struct {
+	// Window is the maximum time events wait before being forwarded.
+	Window time.Duration
+
+	// MaxEvents is the maximum number of events of a window. Defaults to 100.
+	MaxEvents int
+}
+
+// Validate validates the configuration.
+func (c StreamWindowConfig) Validate() error {
+	if c.Window <= 0 {
+		return errors.New("stream window must be positive")
+	}
+
+	if c.MaxEvents < 0 {
+		return errors.New("stream window max events must not be negative")
+	}
+
+	return nil
+}
+
+// errWindowed is returned by processEvent for events added to the window of their stream, their result is reported once it is forwarded.
+var errWindowed = errors.New("event added to the stream window")
+
+// streamWindow is the window of a stream request.
+type streamWindow struct {
+	handler   Handler
+	window    time.Duration
+	maxEvents int
+	onResult  func(result EventResult)
+
+	// flushMu serializes forwarding windows
+	flushMu sync.Mutex
+
+	mu        sync.Mutex
+	pending   []windowedEvent
+	namespace string
+	timer     *time.Timer
+}
+
+// windowedEvent is an event waiting in a window.
+type windowedEvent struct {
+	ctx    context.Context
+	logger *slog.Logger
+	index  int
+	ack    func() ingest.AckMode
+	event  event.Event
+}
+
+type streamWindowContextKey struct{}
+
+// streamWindowEvent is the context value of the events of a windowed stream.
+type streamWindowEvent struct {
+	window *streamWindow
+	index  int
+	ack    func() ingest.AckMode
+}
+
+// newStreamWindow returns the window of a stream, or nil if windows are not enabled.
+// onResult is called with the result of every forwarded event, possibly concurrently with the stream.
+func (h Handler) newStreamWindow(onResult func(result EventResult)) *streamWindow {
+	if h.StreamWindow == nil {
+		return nil
+	}
+
+	maxEvents := h.StreamWindow.MaxEvents
+	if maxEvents == 0 {
+		maxEvents = defaultStreamWindowMaxEvents
+	}
+
+	return &streamWindow{
+		handler:   h,
+		window:    h.StreamWindow.Window,
+		maxEvents: maxEvents,
+		onResult:  onResult,
+	}
+}
+
+// withEvent attaches the window to the context of an event of the stream.
+func (w *streamWindow) withEvent(ctx context.Context, index int, ack func() ingest.AckMode) context.Context {
+	if w == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, streamWindowContextKey{}, streamWindowEvent{window: w, index: index, ack: ack})
+}
+
+func streamWindowFromContext(ctx context.Context) (streamWindowEvent, bool) {
+	ev, ok := ctx.Value(streamWindowContextKey{}).(streamWindowEvent)
+
+	return ev, ok
+}
+
+// add adds the event to the window, forwarding the window first if the event is of another namespace,
+// and forwarding it once full. Events are added with the worker of the event (see processPooledEvent), which forwards the window.
+func (w *streamWindow) add(ctx context.Context, logger *slog.Logger, ev event.Event) {
+	slot, _ := streamWindowFromContext(ctx)
+	namespace, _ := ingest.NamespaceFromContext(ctx)
+
+	w.mu.Lock()
+
+	if len(w.pending) > 0 && namespace != w.namespace {
+		w.mu.Unlock()
+		w.flush(false)
+		w.mu.Lock()
+	}
+
+	if len(w.pending) == 0 {
+		w.namespace = namespace
+		w.timer = time.AfterFunc(w.window, func() {
+			w.flush(true)
+		})
+	}
+
+	w.pending = append(w.pending, windowedEvent{
+		ctx:    ctx,
+		logger: logger,
+		index:  slot.index,
+		ack:    slot.ack,
+		event:  ev,
+	})
+
+	full := len(w.pending) >= w.maxEvents
+
+	w.mu.Unlock()
+
+	if full {
+		w.flush(false)
+	}
+}
+
+// flush forwards the events of the window and reports their results.
+// acquire forwards the window with a worker of the pool of the handler (if any), unless the caller already holds one.
+func (w *streamWindow) flush(acquire bool) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = nil
+
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	var errs []error
+	if acquire {
+		errs = w.forward(pending)
+	} else {
+		errs = w.handler.forwardWindow(pending)
+	}
+
+	for i, windowed := range pending {
+		w.onResult(newEventResult(windowed.index, windowed.event, w.handler.successStatus(windowed.ack()), errs[i]))
+	}
+}
+
+// forward forwards the events of the window with a worker of the pool of the handler (if any),
+// for windows forwarded after the workers of their events were released (by the timer of the window or when the stream ends).
+func (w *streamWindow) forward(pending []windowedEvent) []error {
+	release, err := w.handler.Workers.acquire(pending[0].ctx)
+	if err != nil {
+		errs := make([]error, len(pending))
+
+		for i, windowed := range pending {
+			windowed.logger.DebugCtx(windowed.ctx, "event rejected", "error", err)
+			w.handler.recordRejection(windowed.ctx, RejectionScopeEvent, err)
+			forgetDeduplicated(windowed.ctx, windowed.logger)
+
+			errs[i] = err
+		}
+
+		return errs
+	}
+	defer release()
+
+	return w.handler.forwardWindow(pending)
+}
+
+// close forwards the pending events of the window, once the stream ends.
+func (w *streamWindow) close() {
+	if w == nil {
+		return
+	}
+
+	w.flush(true)
+}
+
+// forwardWindow forwards the events of a window and returns the error of every event.
+func (h Handler) forwardWindow(pending []windowedEvent) []error {
+	errs := make([]error, len(pending))
+
+	receiver, ok := h.Collector.(ingest.BatchReceiver)
+	if !ok {
+		for i, windowed := range pending {
+			errs[i] = h.forward(windowed.ctx, windowed.logger, windowed.event)
+		}
+
+		return errs
+	}
+
+	events := make([]event.Event, 0, len(pending))
+	drained := make([]func(error), 0, len(pending))
+
+	for _, windowed := range pending {
+		events = append(events, windowed.event)
+		drained = append(drained, h.Drain.track(windowed.event))
+	}
+
+	err := receiver.ReceiveBatch(pending[0].ctx, events)
+	h.LastError.observe(err)
+
+	for i, windowed := range pending {
+		drained[i](err)
+
+		if err != nil {
+			windowed.logger.ErrorCtx(windowed.ctx, "unable to forward event to collector", "error", err)
+			h.metrics().RecordRejection(windowed.ctx, RejectionScopeEvent, RejectionCollector)
+			forgetDeduplicated(windowed.ctx, windowed.logger)
+
+			errs[i] = h.collectorError(err)
+
+			continue
+		}
+
+		windowed.logger.InfoCtx(windowed.ctx, "event forwarded to downstream collector")
+	}
+
+	return errs
+}
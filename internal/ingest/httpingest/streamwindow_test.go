@@ -0,0 +1,276 @@
+package httpingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// batchReceiverCollector records the batches it receives.
+type batchReceiverCollector struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (c *batchReceiverCollector) Receive(ctx context.Context, ev event.Event) error {
+	return c.ReceiveBatch(ctx, []event.Event{ev})
+}
+
+func (c *batchReceiverCollector) ReceiveBatch(_ context.Context, events []event.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.batches = append(c.batches, eventIDs(events))
+
+	return c.err
+}
+
+func (c *batchReceiverCollector) Batches() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([][]string(nil), c.batches...)
+}
+
+// decodeStreamResults decodes the results of a stream response without trailers, sorted by index.
+func decodeStreamResults(t *testing.T, body io.Reader, n int) ([]EventResult, BatchSummary) {
+	t.Helper()
+
+	decoder := json.NewDecoder(body)
+
+	results := make([]EventResult, n)
+	for i := range results {
+		require.NoError(t, decoder.Decode(&results[i]))
+	}
+
+	var summary BatchSummary
+	require.NoError(t, decoder.Decode(&summary))
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Index < results[j].Index
+	})
+
+	return results, summary
+}
+
+func TestHandler_StreamWindow(t *testing.T) {
+	collector := &batchReceiverCollector{}
+	handler := Handler{
+		Collector:        collector,
+		MaxSubjectLength: 5,
+		StreamWindow: &StreamWindowConfig{
+			Window:    time.Hour,
+			MaxEvents: 2,
+		},
+	}
+
+	events := newTestEvents(t, 6)
+	events[2].SetSubject("too long")
+	events[4].SetExtension(NamespaceExtension, "b")
+	events[5].SetExtension(NamespaceExtension, "b")
+
+	req := httptest.NewRequest(http.MethodPost, "/", newStreamBody(t, events))
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Windows are forwarded once full, when the namespace changes, and when the stream ends
+	assert.Equal(t, [][]string{{"0", "1"}, {"3"}, {"4", "5"}}, collector.Batches())
+
+	results, summary := decodeStreamResults(t, w.Body, 6)
+
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+
+		if i == 2 {
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		} else {
+			assert.Equal(t, http.StatusOK, result.StatusCode, i)
+		}
+	}
+
+	assert.Equal(t, BatchSummary{Total: 6, Succeeded: 5, Failed: 1}, summary)
+}
+
+func TestHandler_StreamWindow_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		collector Collector
+	}{
+		{
+			name:      "BatchReceiver",
+			collector: &batchReceiverCollector{err: errors.New("downstream failure")},
+		},
+		{
+			name: "Collector",
+			collector: collectorFunc(func(ev event.Event) error {
+				return errors.New("downstream failure")
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			handler := Handler{
+				Collector:    test.collector,
+				StreamWindow: &StreamWindowConfig{Window: time.Hour},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", newStreamBody(t, newTestEvents(t, 3)))
+			req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			results, summary := decodeStreamResults(t, w.Body, 3)
+
+			for _, result := range results {
+				assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+			}
+
+			assert.Equal(t, BatchSummary{Total: 3, Failed: 3}, summary)
+		})
+	}
+}
+
+func TestHandler_StreamWindow_Timeout(t *testing.T) {
+	collector := &batchReceiverCollector{}
+	handler := Handler{
+		Collector:    collector,
+		StreamWindow: &StreamWindowConfig{Window: 10 * time.Millisecond},
+	}
+
+	body, stream := io.Pipe()
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+	done := make(chan struct{})
+	w := httptest.NewRecorder()
+
+	go func() {
+		defer close(done)
+
+		handler.ServeHTTP(w, req)
+	}()
+
+	events := newTestEvents(t, 2)
+	encoder := json.NewEncoder(stream)
+
+	require.NoError(t, encoder.Encode(events[0]))
+
+	// The window is forwarded while the producer is idle
+	require.Eventually(t, func() bool {
+		return len(collector.Batches()) == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, encoder.Encode(events[1]))
+	require.NoError(t, stream.Close())
+
+	<-done
+
+	assert.Equal(t, [][]string{{"0"}, {"1"}}, collector.Batches())
+
+	_, summary := decodeStreamResults(t, w.Body, 2)
+	assert.Equal(t, BatchSummary{Total: 2, Succeeded: 2}, summary)
+}
+
+// poolCheckingCollector records the number of busy workers of the pool when it receives batches.
+type poolCheckingCollector struct {
+	batchReceiverCollector
+
+	pool *WorkerPool
+	busy []int
+}
+
+func (c *poolCheckingCollector) ReceiveBatch(ctx context.Context, events []event.Event) error {
+	c.mu.Lock()
+	c.busy = append(c.busy, len(c.pool.sem))
+	c.mu.Unlock()
+
+	return c.batchReceiverCollector.ReceiveBatch(ctx, events)
+}
+
+func TestHandler_StreamWindow_Workers(t *testing.T) {
+	pool, err := NewWorkerPool(WorkerPoolConfig{Size: 1})
+	require.NoError(t, err)
+
+	t.Run("Forward", func(t *testing.T) {
+		collector := &poolCheckingCollector{pool: pool}
+		handler := Handler{
+			Collector: collector,
+			Workers:   pool,
+			StreamWindow: &StreamWindowConfig{
+				Window:    time.Hour,
+				MaxEvents: 2,
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", newStreamBody(t, newTestEvents(t, 3)))
+		req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		_, summary := decodeStreamResults(t, w.Body, 3)
+		assert.Equal(t, BatchSummary{Total: 3, Succeeded: 3}, summary)
+
+		// Full windows are forwarded with the worker of their last event, the last window with a worker of its own
+		assert.Equal(t, [][]string{{"0", "1"}, {"2"}}, collector.Batches())
+		assert.Equal(t, []int{1, 1}, collector.busy)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		collector := &batchReceiverCollector{}
+		handler := Handler{
+			Collector:    collector,
+			Workers:      pool,
+			StreamWindow: &StreamWindowConfig{Window: time.Hour},
+		}
+
+		var results []EventResult
+
+		window := handler.newStreamWindow(func(result EventResult) {
+			results = append(results, result)
+		})
+
+		ctx, ack := ingest.WithAck(context.Background())
+		window.add(window.withEvent(ctx, 0, ack), handler.getLogger(), newTestEvents(t, 1)[0])
+
+		// The worker of the event has been released by the time the window is forwarded
+		release, err := pool.acquire(context.Background())
+		require.NoError(t, err)
+		defer release()
+
+		window.close()
+
+		require.Len(t, results, 1)
+		assert.Equal(t, http.StatusServiceUnavailable, results[0].StatusCode)
+		assert.Empty(t, collector.Batches())
+	})
+}
+
+func TestStreamWindowConfig_Validate(t *testing.T) {
+	assert.NoError(t, StreamWindowConfig{Window: time.Millisecond}.Validate())
+	assert.Error(t, StreamWindowConfig{}.Validate())
+	assert.Error(t, StreamWindowConfig{Window: time.Millisecond, MaxEvents: -1}.Validate())
+}
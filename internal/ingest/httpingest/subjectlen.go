@@ -0,0 +1,20 @@
+package httpingest
+
+import (
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkSubjectLength rejects events whose subject is longer than MaxSubjectLength (in bytes).
+func (h Handler) checkSubjectLength(ev event.Event) error {
+	if h.MaxSubjectLength <= 0 {
+		return nil
+	}
+
+	if length := len(ev.Subject()); length > h.MaxSubjectLength {
+		return NewEventErrorf(http.StatusBadRequest, "event subject is %d bytes long, the maximum is %d", length, h.MaxSubjectLength)
+	}
+
+	return nil
+}
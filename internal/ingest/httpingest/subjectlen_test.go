@@ -0,0 +1,41 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestMaxSubjectLength(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:        collector,
+		MaxSubjectLength: 10,
+	}
+
+	newEvent := func(subject string) event.Event {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetType("api-calls")
+		ev.SetSubject(subject)
+
+		return ev
+	}
+
+	require.NoError(t, handler.processEvent(context.Background(), newEvent(strings.Repeat("a", 10))))
+
+	err := handler.processEvent(context.Background(), newEvent(strings.Repeat("a", 11)))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+	assert.EqualError(t, err, "event subject is 11 bytes long, the maximum is 10")
+
+	assert.Len(t, collector.Events(), 1)
+}
@@ -0,0 +1,125 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const (
+	// DefaultSubjectRateExtension is the default extension the rate of the subject of events is set in.
+	DefaultSubjectRateExtension = "subjectrate"
+
+	defaultSubjectRateWindow = time.Minute
+	defaultSubjectRateSize   = 10000
+)
+
+// SubjectRateConfig configures a SubjectRateTracker.
+type SubjectRateConfig struct {
+	// Extension is the extension the rate (in events per second) is set in. Defaults to DefaultSubjectRateExtension.
+	Extension string
+
+	// Window is the time constant of the moving average: older events weigh exponentially less. Defaults to 1m.
+	Window time.Duration
+
+	// Size is the maximum number of subjects whose rate is tracked. Defaults to 10000.
+	// The least recently seen subjects are forgotten first when the limit is reached.
+	Size int
+
+	// Subjects shares the tracked subjects (and their limit) with other per-subject features (optional).
+	// Size is ignored when set.
+	Subjects *SubjectTracker
+}
+
+// SubjectRateTracker maintains an exponentially weighted moving average of the ingest rate of subjects
+// and attaches it to events, giving consumers (eg. anomaly detection) context without a separate query.
+//
+// Rates are based on the time events are ingested, not the time of events.
+type SubjectRateTracker struct {
+	extension string
+	window    float64
+	subjects  *SubjectTracker
+}
+
+// NewSubjectRateTracker returns a new SubjectRateTracker.
+func NewSubjectRateTracker(config SubjectRateConfig) (*SubjectRateTracker, error) {
+	if config.Extension != "" && !event.IsExtensionNameValid(config.Extension) {
+		return nil, errors.New("invalid subject rate extension name")
+	}
+
+	if config.Window < 0 {
+		return nil, errors.New("subject rate window must not be negative")
+	}
+
+	if config.Size < 0 {
+		return nil, errors.New("subject rate size must not be negative")
+	}
+
+	extension := config.Extension
+	if extension == "" {
+		extension = DefaultSubjectRateExtension
+	}
+
+	window := config.Window
+	if window == 0 {
+		window = defaultSubjectRateWindow
+	}
+
+	subjects := config.Subjects
+	if subjects == nil {
+		size := config.Size
+		if size == 0 {
+			size = defaultSubjectRateSize
+		}
+
+		var err error
+
+		subjects, err = NewSubjectTracker(SubjectTrackerConfig{Size: size})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &SubjectRateTracker{
+		extension: extension,
+		window:    window.Seconds(),
+		subjects:  subjects,
+	}, nil
+}
+
+// observe records an event of the subject ingested at the given time and returns the rate of the subject (in events per second).
+//
+// The rate is an exponentially decaying event count divided by the window,
+// which converges to the actual rate for steady traffic and needs no special case for simultaneous events.
+func (t *SubjectRateTracker) observe(endpoint string, subject string, now time.Time) float64 {
+	var rate float64
+
+	t.subjects.update(subjectKey{endpoint: endpoint, subject: subject}, func(state *subjectState) {
+		if state.rateLastSeen.IsZero() {
+			state.rateLastSeen = now
+		}
+
+		if elapsed := now.Sub(state.rateLastSeen).Seconds(); elapsed > 0 {
+			state.rate *= math.Exp(-elapsed / t.window)
+			state.rateLastSeen = now
+		}
+
+		state.rate += 1 / t.window
+
+		rate = state.rate
+	})
+
+	return rate
+}
+
+// attachSubjectRate records the event and sets the rate of its subject in the subject rate extension.
+func (h Handler) attachSubjectRate(ctx context.Context, ev *event.Event) {
+	rate := h.SubjectRates.observe(endpointFromContext(ctx), ev.Subject(), h.now())
+
+	// Floats are not valid extension values
+	h.setServerExtension(ev, h.SubjectRates.extension, strconv.FormatFloat(rate, 'f', 3, 64))
+}
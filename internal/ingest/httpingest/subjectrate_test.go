@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestSubjectRateTracker(t *testing.T) {
+	tracker, err := NewSubjectRateTracker(SubjectRateConfig{Window: 10 * time.Second, Size: 2})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+
+	assert.InDelta(t, 0.1, tracker.observe("", "customer-1", now), 1e-9)
+	assert.InDelta(t, 0.2, tracker.observe("", "customer-1", now), 1e-9)
+	assert.InDelta(t, 0.1736, tracker.observe("", "customer-1", now.Add(10*time.Second)), 1e-4)
+
+	// Subjects of different endpoints are tracked separately
+	assert.InDelta(t, 0.1, tracker.observe("internal", "customer-1", now), 1e-9)
+
+	// Steady traffic converges to the actual rate
+	for i := 1; i <= 1000; i++ {
+		tracker.observe("", "customer-2", now.Add(time.Duration(i)*500*time.Millisecond))
+	}
+
+	assert.InDelta(t, 2, tracker.observe("", "customer-2", now.Add(500500*time.Millisecond)), 0.1)
+
+	// customer-1 has been evicted
+	assert.InDelta(t, 0.1, tracker.observe("", "customer-1", now.Add(time.Hour)), 1e-9)
+}
+
+func TestHandler_SubjectRates(t *testing.T) {
+	tracker, err := NewSubjectRateTracker(SubjectRateConfig{Window: 10 * time.Second})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	now := time.Date(2023, 6, 15, 14, 33, 0, 0, time.UTC)
+
+	handler := Handler{
+		Collector:    collector,
+		SubjectRates: tracker,
+		Clock:        func() time.Time { return now },
+	}
+
+	for i := 0; i < 2; i++ {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetSubject("customer-1")
+
+		require.NoError(t, handler.processEvent(context.Background(), ev))
+	}
+
+	require.Len(t, collector.Events(), 2)
+	assert.Equal(t, "0.100", collector.Events()[0].Extensions()[DefaultSubjectRateExtension])
+	assert.Equal(t, "0.200", collector.Events()[1].Extensions()[DefaultSubjectRateExtension])
+
+	_, err = NewSubjectRateTracker(SubjectRateConfig{Window: -time.Second})
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package httpingest
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultSubjectTrackerSize = 10000
+
+// SubjectTrackerConfig configures a SubjectTracker.
+type SubjectTrackerConfig struct {
+	// Size is the maximum number of distinct subjects tracked. Defaults to 10000.
+	Size int
+
+	// Registerer registers metrics of the number of tracked subjects and of evictions (optional).
+	Registerer prometheus.Registerer
+}
+
+// SubjectTracker holds the state of per-subject features (SequenceTracker, SubjectRateTracker),
+// bounding their memory with high cardinality subjects.
+//
+// When the limit is reached, the least recently seen subjects are forgotten by every feature sharing the tracker:
+// their events are still accepted, tracking restarts when they are seen again.
+type SubjectTracker struct {
+	size      int
+	evictions prometheus.Counter
+
+	mu      sync.Mutex
+	entries map[subjectKey]*list.Element
+	order   *list.List
+}
+
+// subjectKey identifies a subject in per-subject state, scoped to the endpoint of the handler (see endpoint.go).
+type subjectKey struct {
+	endpoint string
+	subject  string
+}
+
+// subjectState is the state of a subject, with a section per feature.
+type subjectState struct {
+	key subjectKey
+
+	// SequenceTracker
+	hasSequence bool
+	sequence    int64
+
+	// SubjectRateTracker
+	rate         float64
+	rateLastSeen time.Time
+}
+
+// NewSubjectTracker returns a new SubjectTracker.
+func NewSubjectTracker(config SubjectTrackerConfig) (*SubjectTracker, error) {
+	if config.Size < 0 {
+		return nil, errors.New("subject tracker size must not be negative")
+	}
+
+	size := config.Size
+	if size == 0 {
+		size = defaultSubjectTrackerSize
+	}
+
+	t := &SubjectTracker{
+		size:    size,
+		entries: make(map[subjectKey]*list.Element),
+		order:   list.New(),
+	}
+
+	if config.Registerer != nil {
+		t.evictions = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "tracked_subject_evictions_total",
+			Help:      "Number of subjects forgotten by per-subject features because the limit of tracked subjects was reached.",
+		})
+
+		tracked := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "tracked_subjects",
+			Help:      "Number of distinct subjects tracked by per-subject features.",
+		}, func() float64 {
+			return float64(t.Len())
+		})
+
+		for _, collector := range []prometheus.Collector{t.evictions, tracked} {
+			if err := config.Registerer.Register(collector); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// Len returns the number of tracked subjects.
+func (t *SubjectTracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.order.Len()
+}
+
+// update calls fn with the state of the subject (tracking it if needed) and marks it as the most recently seen.
+// fn is called with the tracker locked.
+func (t *SubjectTracker) update(key subjectKey, fn func(state *subjectState)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		if t.order.Len() >= t.size {
+			oldest := t.order.Back()
+
+			t.order.Remove(oldest)
+			delete(t.entries, oldest.Value.(*subjectState).key)
+
+			if t.evictions != nil {
+				t.evictions.Inc()
+			}
+		}
+
+		e = t.order.PushFront(&subjectState{key: key})
+		t.entries[key] = e
+	} else {
+		t.order.MoveToFront(e)
+	}
+
+	fn(e.Value.(*subjectState))
+}
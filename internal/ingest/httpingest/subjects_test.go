@@ -0,0 +1,75 @@
+package httpingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectTracker_Shared(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	subjects, err := NewSubjectTracker(SubjectTrackerConfig{
+		Size:       2,
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	sequences, err := NewSequenceTracker(SequenceTrackerConfig{Subjects: subjects})
+	require.NoError(t, err)
+
+	rates, err := NewSubjectRateTracker(SubjectRateConfig{Subjects: subjects})
+	require.NoError(t, err)
+
+	newEvent := func(subject string, sequence int32) event.Event {
+		ev := event.New()
+		ev.SetSubject(subject)
+		ev.SetExtension(DefaultSequenceExtension, sequence)
+
+		return ev
+	}
+
+	now := time.Now()
+
+	// Subjects tracked by either feature count towards the shared limit
+	anomaly, _ := sequences.track("", newEvent("customer-1", 1))
+	assert.Empty(t, anomaly)
+
+	rates.observe("", "customer-2", now)
+
+	assert.Equal(t, 2, subjects.Len())
+
+	// Seen by the rate tracker, customer-1 is the most recently seen subject
+	rates.observe("", "customer-1", now)
+	rates.observe("", "customer-3", now)
+
+	assert.Equal(t, 2, subjects.Len())
+	assert.Equal(t, float64(1), testutil.ToFloat64(subjects.evictions))
+
+	// The sequence of customer-1 is still tracked
+	anomaly, previous := sequences.track("", newEvent("customer-1", 3))
+	assert.Equal(t, SequenceGap, anomaly)
+	assert.Equal(t, int64(1), previous)
+
+	// customer-2 was forgotten: its rate restarts
+	rates.observe("", "customer-2", now)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(subjects.evictions))
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP openmeter_ingest_tracked_subjects Number of distinct subjects tracked by per-subject features.
+# TYPE openmeter_ingest_tracked_subjects gauge
+openmeter_ingest_tracked_subjects 2
+`), "openmeter_ingest_tracked_subjects"))
+}
+
+func TestSubjectTracker_Validate(t *testing.T) {
+	_, err := NewSubjectTracker(SubjectTrackerConfig{Size: -1})
+	assert.Error(t, err)
+}
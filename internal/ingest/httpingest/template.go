@@ -0,0 +1,142 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+
+	"github.com/openmeterio/openmeter/api"
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// EventTemplate is the CloudEvents envelope of events ingested as bare data payloads.
+type EventTemplate struct {
+	Type   string
+	Source string
+
+	// Subject of the events.
+	// When empty, the subject is read from the "subject" query parameter of each request.
+	Subject string
+}
+
+// Validate validates the template.
+func (t EventTemplate) Validate() error {
+	if t.Type == "" {
+		return errors.New("event type is required")
+	}
+
+	if t.Source == "" {
+		return errors.New("event source is required")
+	}
+
+	return nil
+}
+
+// TemplateHandler receives bare JSON data payloads and ingests them as events
+// created from a fixed template, with generated ID and time.
+//
+// Events are processed exactly like regular events received by the {Handler}.
+type TemplateHandler struct {
+	Handler  Handler
+	Template EventTemplate
+}
+
+func (h TemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, ok := h.Handler.admitRequest(w, r)
+	if !ok {
+		return
+	}
+
+	logger := h.Handler.getLogger()
+
+	body, err := h.Handler.decodeBody(w, r)
+	if err != nil {
+		logger.DebugCtx(r.Context(), "event rejected", "error", err)
+		h.Handler.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	// Payloads are read at once, they are limited even without MaxBodySize
+	data, err := io.ReadAll(http.MaxBytesReader(w, body, h.Handler.maxDecodedBodySize()))
+	if tooLarge := checkBodySize(err); tooLarge != nil {
+		logger.DebugCtx(r.Context(), "event rejected", "error", tooLarge)
+		h.Handler.recordRejection(r.Context(), RejectionScopeRequest, tooLarge)
+
+		renderError(w, r, tooLarge)
+
+		return
+	}
+
+	if aborted := checkClientAbort(r.Context(), err); aborted != nil {
+		logger.DebugCtx(r.Context(), "event aborted", "error", aborted)
+		h.Handler.recordRejection(r.Context(), RejectionScopeRequest, aborted)
+
+		renderError(w, r, aborted)
+
+		return
+	}
+
+	if err != nil {
+		logger.ErrorCtx(r.Context(), "unable to read event data", "error", err)
+		h.Handler.metrics().RecordRejection(r.Context(), RejectionScopeRequest, RejectionInternal)
+
+		_ = render.Render(w, r, api.ErrInternalServerError(err))
+
+		return
+	}
+
+	ev, err := h.newEvent(r, data)
+	if err != nil {
+		h.Handler.recordRejection(r.Context(), RejectionScopeRequest, err)
+
+		renderError(w, r, err)
+
+		return
+	}
+
+	ctx, ack := ingest.WithAck(r.Context())
+
+	err = h.Handler.processEvent(ctx, ev)
+	if err != nil {
+		renderError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(h.Handler.successStatus(ack()))
+}
+
+func (h TemplateHandler) newEvent(r *http.Request, data []byte) (event.Event, error) {
+	if !json.Valid(data) {
+		return event.Event{}, NewEventErrorf(http.StatusBadRequest, "event data must be valid JSON")
+	}
+
+	subject := h.Template.Subject
+	if subject == "" {
+		subject = r.URL.Query().Get("subject")
+		if subject == "" {
+			return event.Event{}, NewEventErrorf(http.StatusBadRequest, "subject query parameter is required")
+		}
+	}
+
+	ev := event.New()
+	ev.SetID(uuid.NewString())
+	ev.SetType(h.Template.Type)
+	ev.SetSource(h.Template.Source)
+	ev.SetSubject(subject)
+	ev.SetTime(h.Handler.now().UTC())
+
+	if err := ev.SetData(event.ApplicationJSON, data); err != nil {
+		return event.Event{}, NewEventError(http.StatusBadRequest, err)
+	}
+
+	return ev, nil
+}
@@ -0,0 +1,104 @@
+package httpingest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestTemplateHandler(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	collector := &testcollector.Collector{}
+	handler := TemplateHandler{
+		Handler: Handler{
+			Collector: collector,
+			Clock:     func() time.Time { return now },
+		},
+		Template: EventTemplate{
+			Type:   "temperature",
+			Source: "sensors",
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?subject=sensor-1", strings.NewReader(`{"celsius": 21.5}`))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		require.Len(t, collector.Events(), 1)
+
+		ev := collector.Events()[0]
+
+		assert.NotEmpty(t, ev.ID())
+		assert.Equal(t, now, ev.Time())
+		assert.Equal(t, "temperature", ev.Type())
+		assert.Equal(t, "sensors", ev.Source())
+		assert.Equal(t, "sensor-1", ev.Subject())
+		assert.JSONEq(t, `{"celsius": 21.5}`, string(ev.Data()))
+	})
+
+	t.Run("MissingSubject", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"celsius": 21.5}`))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("InvalidData", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?subject=sensor-1", strings.NewReader(`{"celsius": `))
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestTemplateHandler_Body(t *testing.T) {
+	collector := &testcollector.Collector{}
+	handler := TemplateHandler{
+		Handler: Handler{
+			Collector:   collector,
+			MaxBodySize: 64,
+		},
+		Template: EventTemplate{
+			Type:   "temperature",
+			Source: "sensors",
+		},
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?subject=sensor-1", bytes.NewReader(gzipBytes(t, []byte(`{"celsius": 21}`))))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, collector.Events(), 1)
+		assert.JSONEq(t, `{"celsius": 21}`, string(collector.Events()[0].Data()))
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/?subject=sensor-1", strings.NewReader(`{"celsius": 21.5, "humidity": 40, "location": "server room, rack 12, top shelf"}`))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
@@ -0,0 +1,30 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// checkTrailingData returns a 400 error if the request body continues after the event decoded by the decoder,
+// eg. when a client concatenates several events in a single event request.
+//
+// Whitespace is allowed after the event.
+func checkTrailingData(decoder *json.Decoder) error {
+	if decoder.More() {
+		return NewEventErrorf(http.StatusBadRequest, "unexpected data after the event: send several events as a batch")
+	}
+
+	// More reports closing delimiters as the end of values: only the end of the body is not trailing data
+	_, err := decoder.Token()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	if err := checkBodySize(err); err != nil {
+		return err
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "unexpected data after the event")
+}
@@ -0,0 +1,64 @@
+package httpingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_StrictSingle(t *testing.T) {
+	const ev = `{"specversion":"1.0","id":"1","source":"test","type":"api-calls"}`
+
+	tests := []struct {
+		name string
+		body string
+
+		strict  int
+		lenient int
+	}{
+		{name: "Event", body: ev, strict: http.StatusOK, lenient: http.StatusOK},
+		{name: "TrailingWhitespace", body: ev + "\n\t ", strict: http.StatusOK, lenient: http.StatusOK},
+		{name: "ConcatenatedEvents", body: ev + ev, strict: http.StatusBadRequest, lenient: http.StatusOK},
+		{name: "TrailingGarbage", body: ev + " garbage", strict: http.StatusBadRequest, lenient: http.StatusOK},
+		{name: "TrailingDelimiter", body: ev + "}", strict: http.StatusBadRequest, lenient: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		for _, strict := range []bool{true, false} {
+			name := test.name + "/Lenient"
+			want := test.lenient
+			if strict {
+				name = test.name + "/Strict"
+				want = test.strict
+			}
+
+			t.Run(name, func(t *testing.T) {
+				collector := &testcollector.Collector{}
+
+				handler := Handler{
+					Collector:    collector,
+					StrictSingle: strict,
+				}
+
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+				req.Header.Set("Content-Type", ContentTypeSingle)
+
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+
+				assert.Equal(t, want, w.Code, w.Body.String())
+
+				if want == http.StatusOK {
+					testcollector.AssertReceived(t, collector, "1")
+				} else {
+					testcollector.AssertReceived(t, collector)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// IdempotencyKeyHeader identifies a batch, so collectors forwarding batches atomically (see ingest.Transactor) forward retries once.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// errBatchAborted is the error of events of a batch aborted because of the failure of other events.
+var errBatchAborted = NewEventErrorf(http.StatusFailedDependency, "batch aborted: another event of the batch failed")
+
+// beginBatch starts forwarding a batch atomically when the {Collector} is an ingest.Transactor.
+// The returned end function is nil otherwise.
+func (h Handler) beginBatch(ctx context.Context, r *http.Request) (context.Context, func(commit bool) error, error) {
+	transactor, ok := h.Collector.(ingest.Transactor)
+	if !ok {
+		return ctx, nil, nil
+	}
+
+	ctx, end, err := transactor.BeginBatch(ctx, r.Header.Get(IdempotencyKeyHeader))
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	// Events of aborted batches are forgotten by endBatch
+	if h.Dedup != nil {
+		ctx = withDedupBatch(ctx)
+	}
+
+	return ctx, end, nil
+}
+
+// endBatch commits a batch forwarded atomically if every event succeeded, aborts it otherwise,
+// and returns the failures of the batch: when the batch is not committed, every event fails.
+// The deduplicated events of batches that are not committed are forgotten, so the batch can be retried.
+func (h Handler) endBatch(ctx context.Context, end func(commit bool) error, events []event.Event, failures []batchResult) []batchResult {
+	commit := len(failures) == 0
+
+	err := end(commit)
+	if err != nil {
+		h.getLogger().ErrorCtx(ctx, "unable to end event batch", "error", err)
+
+		err = h.collectorError(err)
+	}
+
+	if commit && err == nil {
+		return nil
+	}
+
+	forgetBatchDeduplicated(ctx, h.getLogger())
+
+	all := make([]batchResult, 0, len(events))
+
+	for i := range events {
+		if len(failures) > 0 && failures[0].index == i {
+			all = append(all, failures[0])
+			failures = failures[1:]
+
+			continue
+		}
+
+		failure := batchResult{index: i, err: errBatchAborted}
+		if err != nil {
+			failure.err = err
+		}
+
+		all = append(all, failure)
+	}
+
+	return all
+}
+
+// isBatchCommitted reports whether the batch was already committed (it is a retry).
+func isBatchCommitted(err error) bool {
+	return errors.Is(err, ingest.ErrBatchCommitted)
+}
@@ -0,0 +1,187 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+// fakeTransactor stages the events of batches and forwards them to the collector when batches are committed.
+type fakeTransactor struct {
+	testcollector.Collector
+
+	mu        sync.Mutex
+	committed map[string]bool
+	staged    map[int][]event.Event
+	next      int
+}
+
+type fakeBatchContextKey struct{}
+
+func (c *fakeTransactor) BeginBatch(ctx context.Context, key string) (context.Context, func(bool) error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.committed[key] {
+		return nil, nil, ingest.ErrBatchCommitted
+	}
+
+	c.next++
+	id := c.next
+
+	end := func(commit bool) error {
+		c.mu.Lock()
+		staged := c.staged[id]
+		delete(c.staged, id)
+
+		if commit && key != "" {
+			c.committed[key] = true
+		}
+		c.mu.Unlock()
+
+		if commit {
+			for _, ev := range staged {
+				_ = c.Collector.Receive(ctx, ev)
+			}
+		}
+
+		return nil
+	}
+
+	return context.WithValue(ctx, fakeBatchContextKey{}, id), end, nil
+}
+
+func (c *fakeTransactor) Receive(ctx context.Context, ev event.Event) error {
+	id, ok := ctx.Value(fakeBatchContextKey{}).(int)
+	if !ok {
+		return c.Collector.Receive(ctx, ev)
+	}
+
+	if c.ErrFunc != nil {
+		if err := c.ErrFunc(ev); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.staged[id] = append(c.staged[id], ev)
+
+	return nil
+}
+
+func TestHandler_TransactionalBatch(t *testing.T) {
+	collector := &fakeTransactor{
+		committed: make(map[string]bool),
+		staged:    make(map[int][]event.Event),
+	}
+
+	handler := Handler{
+		Collector: collector,
+	}
+
+	send := func(key string, events []event.Event) *httptest.ResponseRecorder {
+		body, err := json.Marshal(events)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// One rejected event aborts the batch
+	collector.ErrFunc = func(ev event.Event) error {
+		if ev.ID() == "1" {
+			return NewEventErrorf(http.StatusBadRequest, "invalid event")
+		}
+
+		return nil
+	}
+
+	w := send("batch-1", newTestEvents(t, 3))
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+
+	statuses := make([]int, 0, len(results))
+	for _, result := range results {
+		statuses = append(statuses, result.StatusCode)
+	}
+
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency}, statuses)
+	testcollector.AssertReceived(t, &collector.Collector)
+
+	// The retried batch is committed once
+	collector.ErrFunc = nil
+
+	for i := 0; i < 2; i++ {
+		w = send("batch-1", newTestEvents(t, 3))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// Events of a batch are received concurrently
+	assert.ElementsMatch(t, []string{"0", "1", "2"}, collector.IDs())
+}
+
+func TestHandler_TransactionalBatch_Dedup(t *testing.T) {
+	collector := &fakeTransactor{
+		committed: make(map[string]bool),
+		staged:    make(map[int][]event.Event),
+	}
+
+	handler := Handler{
+		Collector: collector,
+		Dedup:     newTestDedupFilter(t),
+	}
+
+	send := func(events []event.Event) *httptest.ResponseRecorder {
+		body, err := json.Marshal(events)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", ContentTypeBatch)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	collector.ErrFunc = func(ev event.Event) error {
+		if ev.ID() == "1" {
+			return NewEventErrorf(http.StatusBadRequest, "invalid event")
+		}
+
+		return nil
+	}
+
+	w := send(newTestEvents(t, 3))
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	testcollector.AssertReceived(t, &collector.Collector)
+
+	// Events of the aborted batch are not dropped as duplicates when it is retried
+	collector.ErrFunc = nil
+
+	w = send(newTestEvents(t, 3))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.ElementsMatch(t, []string{"0", "1", "2"}, collector.IDs())
+}
@@ -0,0 +1,32 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// TypePrefixFunc resolves the event type prefix reserved for the tenant of a request (eg. from an authentication context).
+//
+// Events of the request must have a type starting with the prefix (eg. "acme." for "acme.api-calls").
+// An empty prefix disables the check for the request.
+type TypePrefixFunc func(ctx context.Context) (string, error)
+
+func (fn TypePrefixFunc) validate(ctx context.Context, ev event.Event) error {
+	if fn == nil {
+		return nil
+	}
+
+	prefix, err := fn(ctx)
+	if err != nil {
+		return NewEventErrorf(http.StatusForbidden, "resolve event type prefix: %w", err)
+	}
+
+	if prefix != "" && !strings.HasPrefix(ev.Type(), prefix) {
+		return NewEventErrorf(http.StatusForbidden, "event type %q is not allowed: type must start with %q", ev.Type(), prefix)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+type tenantContextKey struct{}
+
+func TestTypePrefix(t *testing.T) {
+	tenants := map[string]string{
+		"acme": "acme.",
+	}
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector: collector,
+		TypePrefix: func(ctx context.Context) (string, error) {
+			tenant, _ := ctx.Value(tenantContextKey{}).(string)
+
+			prefix, ok := tenants[tenant]
+			if !ok {
+				return "", errors.New("unknown tenant")
+			}
+
+			return prefix, nil
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), tenantContextKey{}, "acme")
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("test")
+	ev.SetType("acme.api-calls")
+
+	require.NoError(t, handler.processEvent(ctx, ev))
+
+	ev.SetType("other.api-calls")
+
+	err := handler.processEvent(ctx, ev)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+
+	err = handler.processEvent(context.Background(), ev)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, statusCode(err))
+
+	assert.Len(t, collector.Events(), 1)
+}
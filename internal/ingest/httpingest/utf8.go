@@ -0,0 +1,50 @@
+package httpingest
+
+import (
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// checkUTF8 rejects events with string attributes (or extensions) that are not valid UTF-8 with 422.
+//
+// The JSON decoder keeps invalid bytes as they are: such events are accepted, but break their re-serialization and downstream storage.
+func checkUTF8(ev event.Event) error {
+	attributes := []struct {
+		name  string
+		value string
+	}{
+		{name: "id", value: ev.ID()},
+		{name: "source", value: ev.Source()},
+		{name: "type", value: ev.Type()},
+		{name: "subject", value: ev.Subject()},
+		{name: "datacontenttype", value: ev.DataContentType()},
+		{name: "dataschema", value: ev.DataSchema()},
+	}
+
+	for _, attribute := range attributes {
+		if !utf8.ValidString(attribute.value) {
+			return NewEventErrorf(http.StatusUnprocessableEntity, "event attribute %s is not valid UTF-8", attribute.name)
+		}
+	}
+
+	extensions := ev.Extensions()
+
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+
+	// The first invalid extension (by name) is reported
+	sort.Strings(names)
+
+	for _, name := range names {
+		if value, ok := extensions[name].(string); ok && !utf8.ValidString(value) {
+			return NewEventErrorf(http.StatusUnprocessableEntity, "event extension %s is not valid UTF-8", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package httpingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_ValidateUTF8(t *testing.T) {
+	const base = `"specversion":"1.0","source":"test"`
+
+	events := []string{
+		`{` + base + `,"id":"valid","type":"api-calls","subject":"zoë","region":"eu"}`,
+		`{` + base + `,"id":"subject","type":"api-calls","subject":"a` + "\xff" + `b"}`,
+		`{` + base + `,"id":"type","type":"api-` + "\xc3\x28" + `"}`,
+		`{` + base + `,"id":"extension","type":"api-calls","region":"eu","zone":"` + "\xfe" + `"}`,
+	}
+
+	for _, enabled := range []bool{true, false} {
+		name := "Disabled"
+		if enabled {
+			name = "Enabled"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:    collector,
+				ValidateUTF8: enabled,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+strings.Join(events, ",")+"]"))
+			req.Header.Set("Content-Type", ContentTypeBatch)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if !enabled {
+				assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+				assert.Equal(t, 4, collector.Len())
+
+				return
+			}
+
+			require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+			var results []EventResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+
+			assert.Equal(t, []EventResult{
+				{Index: 0, ID: "valid", StatusCode: http.StatusOK},
+				{Index: 1, ID: "subject", StatusCode: http.StatusUnprocessableEntity, Error: "event attribute subject is not valid UTF-8"},
+				{Index: 2, ID: "type", StatusCode: http.StatusUnprocessableEntity, Error: "event attribute type is not valid UTF-8"},
+				{Index: 3, ID: "extension", StatusCode: http.StatusUnprocessableEntity, Error: "event extension zone is not valid UTF-8"},
+			}, results)
+
+			testcollector.AssertReceived(t, collector, "valid")
+		})
+	}
+}
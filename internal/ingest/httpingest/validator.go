@@ -0,0 +1,109 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// Validator validates events before they are forwarded to the {Collector}, eg. with team specific rules.
+//
+// An error rejects the event. Errors created with NewEventErrorf are reported with their status,
+// other errors with 400 Bad Request.
+type Validator interface {
+	Validate(ctx context.Context, ev event.Event) error
+}
+
+// ValidatorFunc is a function implementing Validator.
+type ValidatorFunc func(ctx context.Context, ev event.Event) error
+
+func (fn ValidatorFunc) Validate(ctx context.Context, ev event.Event) error {
+	return fn(ctx, ev)
+}
+
+// DefaultValidators returns the built-in validators enabled by the options of the handler, in the order they run.
+// The handler runs them before its Authorizer and Validators.
+func (h Handler) DefaultValidators() []Validator {
+	validators := []Validator{
+		builtInValidator(h.IDFormat.validate),
+		builtInValidator(h.checkExtensionCount),
+		builtInValidator(h.checkSubjectLength),
+		builtInValidator(h.checkAllowedExtensions),
+		builtInValidator(h.checkSource),
+	}
+
+	if h.ValidateJSONData {
+		validators = append(validators, builtInValidator(checkJSONData))
+	}
+
+	if h.ValidateDataEncoding {
+		validators = append(validators, builtInValidator(checkDataEncoding))
+	}
+
+	if h.ValidateUTF8 {
+		validators = append(validators, builtInValidator(checkUTF8))
+	}
+
+	if h.RejectAmbiguousData {
+		validators = append(validators, builtInValidator(checkAmbiguousData))
+	}
+
+	validators = append(validators, ValidatorFunc(h.TypePrefix.validate))
+
+	if h.ValueRange != nil {
+		validators = append(validators, builtInValidator(h.ValueRange.Validate))
+	}
+
+	if h.CUE != nil {
+		validators = append(validators, builtInValidator(h.CUE.Validate))
+	}
+
+	if h.SchemaVersions != nil {
+		validators = append(validators, builtInValidator(h.SchemaVersions.Validate))
+	}
+
+	return validators
+}
+
+// validators returns the validators run on every event, in order: the DefaultValidators,
+// followed by the Authorizer and Validators.
+func (h Handler) validators() []Validator {
+	validators := h.DefaultValidators()
+
+	if h.Authorizer != nil {
+		validators = append(validators, ValidatorFunc(h.checkAuthorization))
+	}
+
+	for _, validator := range h.Validators {
+		validator := validator
+
+		validators = append(validators, ValidatorFunc(func(ctx context.Context, ev event.Event) error {
+			return validationError(validator.Validate(ctx, ev))
+		}))
+	}
+
+	return validators
+}
+
+// builtInValidator adapts a built-in validation function to a Validator.
+func builtInValidator(fn func(ev event.Event) error) Validator {
+	return ValidatorFunc(func(_ context.Context, ev event.Event) error {
+		return fn(ev)
+	})
+}
+
+// validationError maps errors of Validators to event errors: untyped errors are reported with 400.
+func validationError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var eventErr *EventError
+	if errors.As(err, &eventErr) {
+		return err
+	}
+
+	return NewEventErrorf(http.StatusBadRequest, "invalid event: %w", err)
+}
@@ -0,0 +1,134 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func TestHandler_Validators(t *testing.T) {
+	var calls []string
+
+	validator := func(name string, err error) Validator {
+		return ValidatorFunc(func(_ context.Context, ev event.Event) error {
+			calls = append(calls, name)
+
+			return err
+		})
+	}
+
+	tests := []struct {
+		name       string
+		validators []Validator
+		event      func(ev *event.Event)
+
+		wantStatus int
+		wantCalls  []string
+	}{
+		{
+			name:       "Valid",
+			validators: []Validator{validator("first", nil), validator("second", nil)},
+			wantStatus: http.StatusOK,
+			wantCalls:  []string{"first", "second"},
+		},
+		{
+			name:       "UntypedError",
+			validators: []Validator{validator("first", errors.New("invalid")), validator("second", nil)},
+			wantStatus: http.StatusBadRequest,
+			wantCalls:  []string{"first"},
+		},
+		{
+			name: "TypedError",
+			validators: []Validator{
+				validator("first", nil),
+				validator("second", NewEventErrorf(http.StatusForbidden, "forbidden")),
+			},
+			wantStatus: http.StatusForbidden,
+			wantCalls:  []string{"first", "second"},
+		},
+		{
+			name:       "BuiltInChecksFirst",
+			validators: []Validator{validator("first", nil)},
+			event: func(ev *event.Event) {
+				ev.SetSubject(string(make([]byte, 10)))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			calls = nil
+
+			collector := &testcollector.Collector{}
+			handler := Handler{
+				Collector:        collector,
+				MaxSubjectLength: 5,
+				Validators:       test.validators,
+			}
+
+			ev := newTestEvents(t, 1)[0]
+			if test.event != nil {
+				test.event(&ev)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newSingleEventRequest(t, ev))
+
+			require.Equal(t, test.wantStatus, w.Code)
+			assert.Equal(t, test.wantCalls, calls)
+
+			if test.wantStatus == http.StatusOK {
+				testcollector.AssertReceived(t, collector, "0")
+			} else {
+				assert.Equal(t, 0, collector.Len())
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	assert.NoError(t, validationError(nil))
+
+	err := validationError(errors.New("invalid"))
+	assert.Equal(t, http.StatusBadRequest, statusCode(err))
+
+	err = validationError(NewEventErrorf(http.StatusUnprocessableEntity, "unprocessable"))
+	assert.Equal(t, http.StatusUnprocessableEntity, statusCode(err))
+}
+
+func TestHandler_DefaultValidators(t *testing.T) {
+	handler := Handler{
+		MaxSubjectLength: 5,
+		ValidateUTF8:     true,
+	}
+
+	validators := handler.DefaultValidators()
+	assert.Len(t, validators, len(Handler{}.DefaultValidators())+1)
+
+	validate := func(ev event.Event) error {
+		for _, validator := range validators {
+			if err := validator.Validate(context.Background(), ev); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	ev := newTestEvents(t, 1)[0]
+	require.NoError(t, validate(ev))
+
+	ev.SetSubject("too long")
+	assert.Equal(t, http.StatusBadRequest, statusCode(validate(ev)))
+}
@@ -0,0 +1,76 @@
+package httpingest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// ValueRangeConfig configures a ValueRangeValidator.
+type ValueRangeConfig struct {
+	// Path is the JSONPath of the numeric value in the event data (eg. $.duration_ms).
+	Path string
+
+	// Min is the smallest accepted value (inclusive). No lower bound when nil.
+	Min *float64
+
+	// Max is the largest accepted value (inclusive). No upper bound when nil.
+	Max *float64
+
+	// AllowMissing accepts events without the value.
+	AllowMissing bool
+}
+
+// ValueRangeValidator rejects events whose numeric data value is NaN, infinite or out of the configured range.
+type ValueRangeValidator struct {
+	config ValueRangeConfig
+	path   jsonPath
+}
+
+// NewValueRangeValidator returns a new ValueRangeValidator.
+func NewValueRangeValidator(config ValueRangeConfig) (*ValueRangeValidator, error) {
+	path, err := parseJSONPath(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Min != nil && config.Max != nil && *config.Min > *config.Max {
+		return nil, fmt.Errorf("min (%v) must not be greater than max (%v)", *config.Min, *config.Max)
+	}
+
+	return &ValueRangeValidator{
+		config: config,
+		path:   path,
+	}, nil
+}
+
+// Validate validates the value of the event.
+func (v *ValueRangeValidator) Validate(ev event.Event) error {
+	value, err := v.path.lookupNumber(ev.Data())
+	if errors.Is(err, errJSONPathNotFound) {
+		if v.config.AllowMissing {
+			return nil
+		}
+
+		return NewEventErrorf(http.StatusBadRequest, "missing value at %s", v.config.Path)
+	} else if err != nil {
+		return NewEventErrorf(http.StatusBadRequest, "invalid value at %s: %w", v.config.Path, err)
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return NewEventErrorf(http.StatusBadRequest, "invalid value at %s: %v", v.config.Path, value)
+	}
+
+	if v.config.Min != nil && value < *v.config.Min {
+		return NewEventErrorf(http.StatusBadRequest, "value at %s must be at least %v, got %v", v.config.Path, *v.config.Min, value)
+	}
+
+	if v.config.Max != nil && value > *v.config.Max {
+		return NewEventErrorf(http.StatusBadRequest, "value at %s must be at most %v, got %v", v.config.Path, *v.config.Max, value)
+	}
+
+	return nil
+}
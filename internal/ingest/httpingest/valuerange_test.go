@@ -0,0 +1,122 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestValueRangeValidator(t *testing.T) {
+	validator, err := NewValueRangeValidator(ValueRangeConfig{
+		Path: "$.usage.value",
+		Min:  float64Ptr(0),
+		Max:  float64Ptr(1000),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{name: "number", data: `{"usage":{"value":12}}`, valid: true},
+		{name: "string", data: `{"usage":{"value":"12.5"}}`, valid: true},
+		{name: "min", data: `{"usage":{"value":0}}`, valid: true},
+		{name: "max", data: `{"usage":{"value":1000}}`, valid: true},
+		{name: "negative", data: `{"usage":{"value":-1}}`},
+		{name: "above max", data: `{"usage":{"value":1000.1}}`},
+		{name: "NaN", data: `{"usage":{"value":"NaN"}}`},
+		{name: "infinite", data: `{"usage":{"value":"+Inf"}}`},
+		{name: "not a number", data: `{"usage":{"value":"twelve"}}`},
+		{name: "missing", data: `{"usage":{}}`},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			ev := event.New()
+			require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(test.data)))
+
+			err := validator.Validate(ev)
+			if test.valid {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, http.StatusBadRequest, statusCode(err))
+		})
+	}
+}
+
+func TestValueRangeValidator_AllowMissing(t *testing.T) {
+	validator, err := NewValueRangeValidator(ValueRangeConfig{
+		Path:         "$.value",
+		Min:          float64Ptr(0),
+		AllowMissing: true,
+	})
+	require.NoError(t, err)
+
+	ev := event.New()
+	require.NoError(t, ev.SetData(event.ApplicationJSON, map[string]string{"other": "1"}))
+
+	assert.NoError(t, validator.Validate(ev))
+}
+
+func TestNewValueRangeValidator(t *testing.T) {
+	_, err := NewValueRangeValidator(ValueRangeConfig{Path: "value"})
+	assert.Error(t, err)
+
+	_, err = NewValueRangeValidator(ValueRangeConfig{Path: "$.value", Min: float64Ptr(1), Max: float64Ptr(0)})
+	assert.Error(t, err)
+}
+
+func TestHandler_ValueRangeBatch(t *testing.T) {
+	validator, err := NewValueRangeValidator(ValueRangeConfig{
+		Path: "$.value",
+		Min:  float64Ptr(0),
+	})
+	require.NoError(t, err)
+
+	collector := &testcollector.Collector{}
+	handler := Handler{
+		Collector:  collector,
+		ValueRange: validator,
+	}
+
+	events := newTestEvents(t, 2)
+	require.NoError(t, events[0].SetData(event.ApplicationJSON, map[string]int{"value": 1}))
+	require.NoError(t, events[1].SetData(event.ApplicationJSON, map[string]int{"value": -1}))
+
+	body, err := json.Marshal(events)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&results))
+
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, http.StatusBadRequest, results[1].StatusCode)
+	assert.Len(t, collector.Events(), 1)
+}
@@ -0,0 +1,101 @@
+package httpingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// WorkerPoolConfig configures a WorkerPool.
+type WorkerPoolConfig struct {
+	// Size is the maximum number of events of batches (and streams) forwarded to the {Collector} at the same time, across requests.
+	Size int
+
+	// Wait makes events wait for a worker instead of being rejected immediately.
+	// Waiting stops when the request context is done or after MaxWait.
+	Wait bool
+
+	// MaxWait is the maximum duration an event waits for a worker when Wait is enabled.
+	// Zero means waiting until the request context is done.
+	MaxWait time.Duration
+}
+
+// WorkerPool bounds the number of events of batches forwarded to the {Collector} concurrently across every request,
+// a process wide concurrency ceiling for downstream calls: MaxConcurrency only bounds the events of a single batch.
+//
+// Events that do not get a worker are rejected with 503 (reported in the results of their batch).
+// Share a pool between handlers to bound their downstream calls together.
+type WorkerPool struct {
+	sem     chan struct{}
+	wait    bool
+	maxWait time.Duration
+}
+
+// NewWorkerPool returns a new WorkerPool.
+func NewWorkerPool(config WorkerPoolConfig) (*WorkerPool, error) {
+	if config.Size <= 0 {
+		return nil, errors.New("worker pool size must be positive")
+	}
+
+	if config.MaxWait < 0 {
+		return nil, fmt.Errorf("invalid max wait: %s", config.MaxWait)
+	}
+
+	return &WorkerPool{
+		sem:     make(chan struct{}, config.Size),
+		wait:    config.Wait,
+		maxWait: config.MaxWait,
+	}, nil
+}
+
+// acquire acquires a worker. The returned function releases it.
+// A nil *WorkerPool never limits.
+func (p *WorkerPool) acquire(ctx context.Context) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-p.sem }
+
+	select {
+	case p.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if !p.wait {
+		return nil, NewEventErrorf(http.StatusServiceUnavailable, "no worker available to process the event")
+	}
+
+	if p.maxWait > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.maxWait)
+		defer cancel()
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, NewEventErrorf(http.StatusServiceUnavailable, "no worker available to process the event: %s", ctx.Err())
+	}
+}
+
+// processPooledEvent forwards the event with a worker of the pool of the handler (if any).
+func (h Handler) processPooledEvent(ctx context.Context, ev event.Event) error {
+	release, err := h.Workers.acquire(ctx)
+	if err != nil {
+		h.getLogger().DebugCtx(ctx, "event rejected", "error", err)
+		h.recordRejection(ctx, RejectionScopeEvent, err)
+
+		return err
+	}
+	defer release()
+
+	return h.processEvent(ctx, ev)
+}
@@ -0,0 +1,136 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_WorkerPool(t *testing.T) {
+	const poolSize = 3
+
+	workers, err := NewWorkerPool(WorkerPoolConfig{Size: poolSize, Wait: true})
+	require.NoError(t, err)
+
+	var inFlight, maxInFlight, received atomic.Int32
+
+	handler := Handler{
+		Collector: collectorFunc(func(_ event.Event) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			for {
+				peak := maxInFlight.Load()
+				if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+
+			received.Add(1)
+
+			return nil
+		}),
+		Workers: workers,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 10))
+	require.NoError(t, err)
+
+	const requests = 5
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", ContentTypeBatch)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(requests*10), received.Load())
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(poolSize), "the pool bounds events across requests")
+}
+
+func TestHandler_WorkerPoolShed(t *testing.T) {
+	workers, err := NewWorkerPool(WorkerPoolConfig{Size: 1})
+	require.NoError(t, err)
+
+	// Another request holds the only worker
+	release, err := workers.acquire(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	handler := Handler{
+		Collector: collectorFunc(func(_ event.Event) error { return nil }),
+		Workers:   workers,
+		Metrics:   metrics,
+	}
+
+	body, err := json.Marshal(newTestEvents(t, 2))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeBatch)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []EventResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	for _, result := range results {
+		assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	}
+
+	line, err := json.Marshal(newTestEvents(t, 1)[0])
+	require.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(append(line, '\n')))
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Contains(t, w.Body.String(), `"statusCode":503`)
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.rejections.WithLabelValues("", RejectionScopeEvent, string(RejectionUnavailable))))
+}
+
+func TestNewWorkerPool(t *testing.T) {
+	_, err := NewWorkerPool(WorkerPoolConfig{})
+	assert.Error(t, err)
+
+	_, err = NewWorkerPool(WorkerPoolConfig{Size: 1, MaxWait: -time.Second})
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+// Package ingest contains the building blocks of event ingestion shared by every transport and downstream broker.
+package ingest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+const (
+	metricsNamespace = "openmeter"
+	metricsSubsystem = "ingest"
+)
+
+// Collector is a receiver of events that handles sending those events to some downstream broker.
+//
+// Implementations must be safe for concurrent use: a collector may be shared by several ingest handlers.
+// The context is the context of the ingest request: implementations should stop waiting (eg. for capacity) when it is done.
+// It carries the values of the request the event is received in (see NamespaceFromContext, PrincipalFromContext and RequestIDFromContext).
+type Collector interface {
+	Receive(ctx context.Context, ev event.Event) error
+}
+
+// Flusher is implemented by collectors buffering events before sending them downstream.
+type Flusher interface {
+	// Flush sends buffered events downstream and returns the number of events sent.
+	Flush(ctx context.Context) (int, error)
+}
+
+// ErrInvalidEvent is returned (wrapped) by collectors rejecting an event because of its content.
+// Unlike other errors, retrying such events never succeeds.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// ErrThrottled is returned (wrapped) by collectors when the downstream broker throttles producers.
+// Retrying later may succeed.
+var ErrThrottled = errors.New("collector is throttled")
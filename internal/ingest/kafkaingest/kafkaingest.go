@@ -1,17 +1,29 @@
 package kafkaingest
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
 )
 
 // Collector is a receiver of events that handles sending those events to a downstream Kafka broker.
+//
+// Events are acknowledged once they are in the local producer queue (ingest.AckEnqueued): delivery failures are not reported to the sender,
+// unless ConfirmDelivery is set.
 type Collector struct {
 	Producer *kafka.Producer
 	Topic    string
 	Schema   Schema
+
+	// ConfirmDelivery waits for the delivery report of every message, so Receive returns once the broker stored the event (ingest.AckDelivered)
+	// and reports delivery failures, eg. for store and forward queues deleting events once delivered.
+	ConfirmDelivery bool
 }
 
 // Schema serializes events.
@@ -20,31 +32,110 @@ type Schema interface {
 	SerializeValue(topic string, ev event.Event) ([]byte, error)
 }
 
-func (s Collector) Receive(ev event.Event) error {
-	key, err := s.Schema.SerializeKey(s.Topic, ev)
+func (s Collector) Receive(ctx context.Context, ev event.Event) error {
+	msg, err := newMessage(s.Topic, s.Schema, ev)
 	if err != nil {
-		return fmt.Errorf("serialize event key: %w", err)
+		return err
 	}
 
-	value, err := s.Schema.SerializeValue(s.Topic, ev)
+	if s.ConfirmDelivery {
+		return deliver(ctx, s.Producer, msg)
+	}
+
+	if err := produce(s.Producer, msg, nil); err != nil {
+		return err
+	}
+
+	// Messages are delivered by the producer in the background
+	ingest.MarkEnqueued(ctx)
+
+	return nil
+}
+
+// newMessage returns the message of the event.
+func newMessage(topic string, schema Schema, ev event.Event) (*kafka.Message, error) {
+	key, err := schema.SerializeKey(topic, ev)
+	if err != nil {
+		return nil, fmt.Errorf("serialize event key: %w", err)
+	}
+
+	value, err := schema.SerializeValue(topic, ev)
 	if err != nil {
-		return fmt.Errorf("serialize event value: %w", err)
+		return nil, fmt.Errorf("serialize event value: %w", err)
 	}
 
-	msg := &kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &s.Topic, Partition: kafka.PartitionAny},
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Timestamp:      ev.Time(),
 		Headers: []kafka.Header{
 			{Key: "specversion", Value: []byte(ev.SpecVersion())},
 		},
 		Key:   key,
 		Value: value,
+	}, nil
+}
+
+// produce enqueues the message in the producer queue.
+// The delivery report is sent to the delivery channel, or to the events channel of the producer when nil.
+func produce(producer *kafka.Producer, msg *kafka.Message, delivery chan kafka.Event) error {
+	err := producer.Produce(msg, delivery)
+
+	// The local producer queue is full: the broker cannot keep up
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrQueueFull {
+		return fmt.Errorf("producing kafka message: %w: %s", ingest.ErrBufferFull, err)
 	}
 
-	err = s.Producer.Produce(msg, nil)
 	if err != nil {
 		return fmt.Errorf("producing kafka message: %w", err)
 	}
 
 	return nil
 }
+
+// deliver produces the message and waits for its delivery report.
+func deliver(ctx context.Context, producer *kafka.Producer, msg *kafka.Message) error {
+	// Buffered, so the producer does not block on reports of messages no longer waited for
+	delivery := make(chan kafka.Event, 1)
+
+	if err := produce(producer, msg, delivery); err != nil {
+		return err
+	}
+
+	select {
+	case e := <-delivery:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("unexpected kafka delivery report: %s", e)
+		}
+
+		if m.TopicPartition.Error != nil {
+			return fmt.Errorf("delivering kafka message: %w", m.TopicPartition.Error)
+		}
+
+		return nil
+
+	case <-ctx.Done():
+		return fmt.Errorf("delivering kafka message: %w", ctx.Err())
+	}
+}
+
+// defaultFlushTimeout is the maximum time Flush waits for outstanding messages without a context deadline.
+const defaultFlushTimeout = 30 * time.Second
+
+// Flush waits for outstanding messages to be delivered (until the context deadline) and returns the number of delivered messages.
+func (s Collector) Flush(ctx context.Context) (int, error) {
+	timeout := defaultFlushTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	outstanding := s.Producer.Len()
+
+	remaining := s.Producer.Flush(int(timeout.Milliseconds()))
+	if remaining > 0 {
+		return outstanding - remaining, fmt.Errorf("%d messages not delivered before timeout", remaining)
+	}
+
+	return outstanding, nil
+}
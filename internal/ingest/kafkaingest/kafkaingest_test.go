@@ -0,0 +1,60 @@
+package kafkaingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+func TestCollector_ConfirmDelivery(t *testing.T) {
+	cluster, err := kafka.NewMockCluster(1)
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	const topic = "events"
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  cluster.BootstrapServers(),
+		"message.timeout.ms": 1000,
+	})
+	require.NoError(t, err)
+	defer producer.Close()
+
+	collector := Collector{
+		Producer:        producer,
+		Topic:           topic,
+		Schema:          rawSchema{},
+		ConfirmDelivery: true,
+	}
+
+	ev := event.New()
+	ev.SetID("1")
+	ev.SetSource("test")
+
+	ctx, ack := ingest.WithAck(context.Background())
+
+	require.NoError(t, collector.Receive(ctx, ev))
+	assert.Equal(t, ingest.AckDelivered, ack())
+
+	// Delivery failures are reported to the sender
+	unreachable, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  "127.0.0.1:1",
+		"message.timeout.ms": 100,
+	})
+	require.NoError(t, err)
+	defer unreachable.Close()
+
+	collector.Producer = unreachable
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assert.Error(t, collector.Receive(ctx, ev))
+}
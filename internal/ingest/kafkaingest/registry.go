@@ -0,0 +1,316 @@
+package kafkaingest
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const (
+	defaultRegistryCacheSize    = 1000
+	defaultRegistryCacheTTL     = 10 * time.Minute
+	defaultRegistryRejectionTTL = time.Minute
+)
+
+// SchemaLoader loads the JSON schema document referenced by the dataschema attribute of events.
+type SchemaLoader func(ctx context.Context, dataSchema string) (string, error)
+
+// RegistryCollectorConfig configures a RegistryCollector.
+type RegistryCollectorConfig struct {
+	// Collector receives events with valid data (eg. a Kafka Collector).
+	Collector ingest.Collector
+
+	// Registry is the schema registry (eg. the schema registry of Redpanda or Confluent).
+	Registry schemaregistry.Client
+
+	// LoadSchema loads data schemas of events.
+	LoadSchema SchemaLoader
+
+	// SubjectName returns the registry subject of the data schemas of an event type.
+	// Defaults to "<type>-data".
+	SubjectName func(eventType string) string
+
+	// AutoRegister registers new data schemas in the registry (subject to its compatibility rules).
+	// Otherwise data schemas must already be registered.
+	AutoRegister bool
+
+	// DataSchemaPattern is a regular expression data schemas must match (optional), eg. ^https://schemas\.example\.com/.
+	// Events with other data schemas are rejected without loading their data schema.
+	DataSchemaPattern *regexp.Regexp
+
+	// CacheSize is the maximum number of cached outcomes, the least recently used outcomes are evicted first. Defaults to 1000.
+	CacheSize int
+
+	// CacheTTL is how long accepted data schemas are cached. Defaults to 10m.
+	CacheTTL time.Duration
+
+	// RejectionTTL is how long rejected data schemas are cached, so fixed schemas or registry rules take effect. Defaults to 1m.
+	RejectionTTL time.Duration
+}
+
+// RegistryCollector enforces schema evolution rules at ingest.
+//
+// On first sight of an event type and data schema, the data schema is registered (or looked up) in the schema registry,
+// and the outcome is cached. Events with incompatible or unknown data schemas are rejected,
+// as are events whose data does not conform to their data schema.
+// Rejections are cached for a shorter time than accepted data schemas, so fixed schemas or registry rules take effect.
+// Events without a data schema are forwarded as is.
+type RegistryCollector struct {
+	collector         ingest.Collector
+	registry          schemaregistry.Client
+	loadSchema        SchemaLoader
+	subjectName       func(eventType string) string
+	autoRegister      bool
+	dataSchemaPattern *regexp.Regexp
+	size              int
+	ttl               time.Duration
+	rejectionTTL      time.Duration
+	now               func() time.Time
+
+	mu      sync.Mutex
+	entries map[registryKey]*list.Element
+	order   *list.List
+}
+
+type registryKey struct {
+	eventType  string
+	dataSchema string
+}
+
+type registryEntry struct {
+	key     registryKey
+	schema  *jsonschema.Schema
+	expires time.Time
+
+	// err is the reason events with the data schema are rejected
+	err error
+}
+
+// NewRegistryCollector returns a new RegistryCollector.
+func NewRegistryCollector(config RegistryCollectorConfig) (*RegistryCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Registry == nil {
+		return nil, errors.New("schema registry is required")
+	}
+
+	if config.LoadSchema == nil {
+		return nil, errors.New("schema loader is required")
+	}
+
+	if config.CacheSize < 0 {
+		return nil, errors.New("cache size must not be negative")
+	}
+
+	if config.CacheTTL < 0 {
+		return nil, errors.New("cache TTL must not be negative")
+	}
+
+	if config.RejectionTTL < 0 {
+		return nil, errors.New("rejection TTL must not be negative")
+	}
+
+	subjectName := config.SubjectName
+	if subjectName == nil {
+		subjectName = func(eventType string) string {
+			return eventType + "-data"
+		}
+	}
+
+	size := config.CacheSize
+	if size == 0 {
+		size = defaultRegistryCacheSize
+	}
+
+	ttl := config.CacheTTL
+	if ttl == 0 {
+		ttl = defaultRegistryCacheTTL
+	}
+
+	rejectionTTL := config.RejectionTTL
+	if rejectionTTL == 0 {
+		rejectionTTL = defaultRegistryRejectionTTL
+	}
+
+	return &RegistryCollector{
+		collector:         config.Collector,
+		registry:          config.Registry,
+		loadSchema:        config.LoadSchema,
+		subjectName:       subjectName,
+		autoRegister:      config.AutoRegister,
+		dataSchemaPattern: config.DataSchemaPattern,
+		size:              size,
+		ttl:               ttl,
+		rejectionTTL:      rejectionTTL,
+		now:               time.Now,
+		entries:           make(map[registryKey]*list.Element),
+		order:             list.New(),
+	}, nil
+}
+
+func (c *RegistryCollector) Receive(ctx context.Context, ev event.Event) error {
+	if ev.DataSchema() == "" {
+		return c.collector.Receive(ctx, ev)
+	}
+
+	if c.dataSchemaPattern != nil && !c.dataSchemaPattern.MatchString(ev.DataSchema()) {
+		return fmt.Errorf("%w: data schema %s is not allowed", ingest.ErrInvalidEvent, ev.DataSchema())
+	}
+
+	entry, err := c.schema(ctx, registryKey{eventType: ev.Type(), dataSchema: ev.DataSchema()})
+	if err != nil {
+		return err
+	}
+
+	if entry.err != nil {
+		return entry.err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(ev.Data(), &data); err != nil {
+		return fmt.Errorf("%w: data is not valid JSON: %s", ingest.ErrInvalidEvent, err)
+	}
+
+	if err := entry.schema.Validate(data); err != nil {
+		return fmt.Errorf("%w: data does not conform to %s: %s", ingest.ErrInvalidEvent, ev.DataSchema(), err)
+	}
+
+	return c.collector.Receive(ctx, ev)
+}
+
+// schema returns the cached outcome of registering the data schema.
+// Errors are only returned when the outcome is unknown (eg. the registry is unavailable), those are not cached.
+func (c *RegistryCollector) schema(ctx context.Context, key registryKey) (registryEntry, error) {
+	if entry, ok := c.cached(key); ok {
+		return entry, nil
+	}
+
+	entry, err := c.register(ctx, key)
+	if err != nil {
+		return registryEntry{}, err
+	}
+
+	entry.key = key
+	c.store(entry)
+
+	return entry, nil
+}
+
+// cached returns the outcome of the data schema unless it is missing or expired.
+func (c *RegistryCollector) cached(key registryKey) (registryEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return registryEntry{}, false
+	}
+
+	entry := e.Value.(*registryEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+
+		return registryEntry{}, false
+	}
+
+	c.order.MoveToFront(e)
+
+	return *entry, true
+}
+
+// store caches the outcome, evicting the least recently used outcome if the cache is full.
+func (c *RegistryCollector) store(entry registryEntry) {
+	ttl := c.ttl
+	if entry.err != nil {
+		ttl = c.rejectionTTL
+	}
+
+	entry.expires = c.now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[entry.key]; ok {
+		e.Value = &entry
+		c.order.MoveToFront(e)
+
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*registryEntry).key)
+	}
+
+	c.entries[entry.key] = c.order.PushFront(&entry)
+}
+
+func (c *RegistryCollector) register(ctx context.Context, key registryKey) (registryEntry, error) {
+	doc, err := c.loadSchema(ctx, key.dataSchema)
+	if err != nil {
+		return registryEntry{}, fmt.Errorf("load data schema %s: %w", key.dataSchema, err)
+	}
+
+	schema, err := jsonschema.CompileString(key.dataSchema, doc)
+	if err != nil {
+		return registryEntry{err: fmt.Errorf("%w: invalid data schema %s: %s", ingest.ErrInvalidEvent, key.dataSchema, err)}, nil
+	}
+
+	subject := c.subjectName(key.eventType)
+	info := schemaregistry.SchemaInfo{
+		Schema:     doc,
+		SchemaType: "JSON",
+	}
+
+	if c.autoRegister {
+		_, err = c.registry.Register(subject, info, true)
+	} else {
+		_, err = c.registry.GetID(subject, info, true)
+	}
+
+	if err != nil {
+		var restErr *schemaregistry.RestError
+		if errors.As(err, &restErr) && isSchemaRejection(restErr) {
+			return registryEntry{err: fmt.Errorf("%w: data schema %s rejected by registry subject %s: %s", ingest.ErrInvalidEvent, key.dataSchema, subject, restErr.Message)}, nil
+		}
+
+		return registryEntry{}, fmt.Errorf("register data schema %s: %w", key.dataSchema, err)
+	}
+
+	return registryEntry{schema: schema}, nil
+}
+
+// Schema registry error codes rejecting a schema.
+// See https://docs.confluent.io/platform/current/schema-registry/develop/api.html#errors
+const (
+	registryErrSubjectNotFound     = 40401
+	registryErrSchemaNotFound      = 40403
+	registryErrIncompatibleSchema  = 409
+	registryErrInvalidSchema       = 42201
+	registryErrUnprocessableSchema = 422
+)
+
+func isSchemaRejection(err *schemaregistry.RestError) bool {
+	switch err.Code {
+	case registryErrSubjectNotFound, registryErrSchemaNotFound, registryErrIncompatibleSchema, registryErrInvalidSchema, registryErrUnprocessableSchema:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,186 @@
+package kafkaingest
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+type collectorFunc func(ctx context.Context, ev event.Event) error
+
+func (f collectorFunc) Receive(ctx context.Context, ev event.Event) error {
+	return f(ctx, ev)
+}
+
+// incompatibleRegistry rejects registering schemas of the incompatible subject.
+type incompatibleRegistry struct {
+	schemaregistry.Client
+
+	registrations int
+}
+
+func (r *incompatibleRegistry) Register(subject string, schema schemaregistry.SchemaInfo, normalize bool) (int, error) {
+	r.registrations++
+
+	if subject == "incompatible-data" {
+		return 0, &schemaregistry.RestError{Code: 409, Message: "schema being registered is incompatible"}
+	}
+
+	return r.Client.Register(subject, schema, normalize)
+}
+
+func TestRegistryCollector(t *testing.T) {
+	client, err := schemaregistry.NewClient(schemaregistry.NewConfig("mock://"))
+	require.NoError(t, err)
+
+	registry := &incompatibleRegistry{Client: client}
+
+	var forwarded []string
+
+	collector, err := NewRegistryCollector(RegistryCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Registry: registry,
+		LoadSchema: func(_ context.Context, dataSchema string) (string, error) {
+			if dataSchema == "https://example.com/missing.json" {
+				return "", errors.New("not found")
+			}
+
+			return `{"type": "object", "required": ["duration_ms"]}`, nil
+		},
+		AutoRegister: true,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	newEvent := func(id string, eventType string, dataSchema string, data string) event.Event {
+		ev := event.New()
+		ev.SetID(id)
+		ev.SetSource("test")
+		ev.SetType(eventType)
+		ev.SetDataSchema(dataSchema)
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+		return ev
+	}
+
+	const dataSchema = "https://example.com/api-calls.json"
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1", "api-calls", dataSchema, `{"duration_ms": 12}`)))
+	require.NoError(t, collector.Receive(ctx, newEvent("2", "api-calls", dataSchema, `{"duration_ms": 15}`)))
+	require.NoError(t, collector.Receive(ctx, newEvent("3", "api-calls", "", `{}`)))
+
+	err = collector.Receive(ctx, newEvent("4", "api-calls", dataSchema, `{}`))
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	err = collector.Receive(ctx, newEvent("5", "incompatible", dataSchema, `{"duration_ms": 12}`))
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	err = collector.Receive(ctx, newEvent("6", "incompatible", dataSchema, `{"duration_ms": 12}`))
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	// Schemas that could not be loaded are retried
+	err = collector.Receive(ctx, newEvent("7", "api-calls", "https://example.com/missing.json", `{}`))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ingest.ErrInvalidEvent)
+
+	assert.Equal(t, []string{"1", "2", "3"}, forwarded)
+
+	// Outcomes are cached per event type and data schema
+	assert.Equal(t, 2, registry.registrations)
+}
+
+func TestRegistryCollector_Cache(t *testing.T) {
+	client, err := schemaregistry.NewClient(schemaregistry.NewConfig("mock://"))
+	require.NoError(t, err)
+
+	registry := &incompatibleRegistry{Client: client}
+
+	var loads []string
+
+	collector, err := NewRegistryCollector(RegistryCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, _ event.Event) error {
+			return nil
+		}),
+		Registry: registry,
+		LoadSchema: func(_ context.Context, dataSchema string) (string, error) {
+			loads = append(loads, dataSchema)
+
+			return `{"type": "object"}`, nil
+		},
+		AutoRegister:      true,
+		DataSchemaPattern: regexp.MustCompile(`^https://example\.com/`),
+		CacheSize:         2,
+		CacheTTL:          time.Hour,
+		RejectionTTL:      time.Minute,
+	})
+	require.NoError(t, err)
+
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time {
+		return now
+	}
+
+	ctx := context.Background()
+
+	receive := func(eventType string, dataSchema string) error {
+		ev := event.New()
+		ev.SetID("1")
+		ev.SetSource("test")
+		ev.SetType(eventType)
+		ev.SetDataSchema(dataSchema)
+		require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(`{}`)))
+
+		return collector.Receive(ctx, ev)
+	}
+
+	// Data schemas outside of the allow-list are not loaded
+	err = receive("api-calls", "https://attacker.example.org/schema.json")
+	assert.ErrorIs(t, err, ingest.ErrInvalidEvent)
+	assert.Empty(t, loads)
+
+	require.NoError(t, receive("api-calls", "https://example.com/a.json"))
+	require.NoError(t, receive("api-calls", "https://example.com/b.json"))
+	require.NoError(t, receive("api-calls", "https://example.com/a.json"))
+
+	// b.json is evicted (least recently used)
+	require.NoError(t, receive("api-calls", "https://example.com/c.json"))
+	require.NoError(t, receive("api-calls", "https://example.com/a.json"))
+	require.NoError(t, receive("api-calls", "https://example.com/b.json"))
+
+	assert.Equal(t, []string{
+		"https://example.com/a.json",
+		"https://example.com/b.json",
+		"https://example.com/c.json",
+		"https://example.com/b.json",
+	}, loads)
+
+	// Rejections are cached
+	registrations := registry.registrations
+
+	assert.ErrorIs(t, receive("incompatible", "https://example.com/a.json"), ingest.ErrInvalidEvent)
+	assert.ErrorIs(t, receive("incompatible", "https://example.com/a.json"), ingest.ErrInvalidEvent)
+	assert.Equal(t, registrations+1, registry.registrations)
+
+	// Rejections expire before accepted data schemas
+	now = now.Add(2 * time.Minute)
+
+	assert.ErrorIs(t, receive("incompatible", "https://example.com/a.json"), ingest.ErrInvalidEvent)
+	assert.Equal(t, registrations+2, registry.registrations)
+
+	require.NoError(t, receive("api-calls", "https://example.com/b.json"))
+	assert.Equal(t, registrations+2, registry.registrations)
+}
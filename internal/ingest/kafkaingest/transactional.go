@@ -0,0 +1,247 @@
+package kafkaingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const (
+	defaultTransactionTimeout = 10 * time.Second
+
+	defaultCommittedBatchTTL  = 24 * time.Hour
+	defaultCommittedBatchSize = 100000
+)
+
+// TransactionalCollectorConfig configures a TransactionalCollector.
+type TransactionalCollectorConfig struct {
+	// Producer is a transactional producer: its transactional.id is set (which enables idempotence).
+	// Transactions are initialized by NewTransactionalCollector.
+	Producer *kafka.Producer
+
+	Topic  string
+	Schema Schema
+
+	// Committed remembers the idempotency keys of committed batches.
+	// Defaults to an ingest.MemoryDeduplicator remembering 100000 keys for 24h.
+	// Keys are only remembered per process with the default: use a shared deduplicator with several instances.
+	Committed ingest.Deduplicator
+
+	// Timeout bounds initializing, committing and aborting transactions. Defaults to 10s.
+	Timeout time.Duration
+
+	Logger *slog.Logger
+}
+
+// TransactionalCollector produces events to Kafka exactly once, with transactions of an idempotent producer.
+//
+// The events of a batch (see ingest.Transactor) are produced in a single transaction: consumers reading committed messages
+// (isolation.level=read_committed) see either all or none of them. The idempotency keys of committed batches are remembered,
+// so a retried batch is not produced twice. Other events are produced in a transaction each.
+//
+// Exactly once comes at a significant throughput cost: a producer runs a single transaction at a time,
+// so batches (and single events) are produced one after the other, and each commit waits for every message
+// of the transaction to be acknowledged by the brokers and for a round trip to the transaction coordinator.
+// Prefer batches over single events, and the regular Collector unless double counting on retries is unacceptable.
+type TransactionalCollector struct {
+	producer  *kafka.Producer
+	topic     string
+	schema    Schema
+	committed ingest.Deduplicator
+	timeout   time.Duration
+	logger    *slog.Logger
+
+	// mu is held for the duration of each transaction
+	mu sync.Mutex
+}
+
+var _ ingest.Transactor = (*TransactionalCollector)(nil)
+
+// NewTransactionalCollector initializes the transactions of the producer and returns a new TransactionalCollector.
+func NewTransactionalCollector(config TransactionalCollectorConfig) (*TransactionalCollector, error) {
+	if config.Producer == nil {
+		return nil, errors.New("producer is required")
+	}
+
+	if config.Schema == nil {
+		return nil, errors.New("schema is required")
+	}
+
+	if config.Timeout < 0 {
+		return nil, fmt.Errorf("invalid transaction timeout: %s", config.Timeout)
+	}
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTransactionTimeout
+	}
+
+	committed := config.Committed
+	if committed == nil {
+		var err error
+
+		committed, err = ingest.NewMemoryDeduplicator(ingest.MemoryDeduplicatorConfig{
+			TTL:  defaultCommittedBatchTTL,
+			Size: defaultCommittedBatchSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := config.Producer.InitTransactions(ctx); err != nil {
+		return nil, fmt.Errorf("init kafka transactions: %w", err)
+	}
+
+	return &TransactionalCollector{
+		producer:  config.Producer,
+		topic:     config.Topic,
+		schema:    config.Schema,
+		committed: committed,
+		timeout:   timeout,
+		logger:    logger,
+	}, nil
+}
+
+type transactionContextKey struct{}
+
+func (c *TransactionalCollector) BeginBatch(ctx context.Context, key string) (context.Context, func(commit bool) error, error) {
+	c.mu.Lock()
+
+	// Batches are serialized, so a retry waits for the outcome of a batch in progress with the same key
+	if key != "" {
+		seen, err := c.committed.Seen(ctx, key, 0)
+		if err != nil {
+			c.mu.Unlock()
+
+			return nil, nil, fmt.Errorf("check batch idempotency key: %w", err)
+		}
+
+		if seen {
+			c.mu.Unlock()
+
+			return nil, nil, ingest.ErrBatchCommitted
+		}
+	}
+
+	if err := c.producer.BeginTransaction(); err != nil {
+		c.forget(ctx, key)
+		c.mu.Unlock()
+
+		return nil, nil, fmt.Errorf("begin kafka transaction: %w", err)
+	}
+
+	var once sync.Once
+
+	end := func(commit bool) error {
+		var err error
+
+		once.Do(func() {
+			defer c.mu.Unlock()
+
+			if commit {
+				err = c.commit()
+			} else {
+				err = c.abort()
+			}
+
+			if err != nil || !commit {
+				c.forget(ctx, key)
+			}
+		})
+
+		return err
+	}
+
+	return context.WithValue(ctx, transactionContextKey{}, c), end, nil
+}
+
+func (c *TransactionalCollector) Receive(ctx context.Context, ev event.Event) error {
+	msg, err := newMessage(c.topic, c.schema, ev)
+	if err != nil {
+		return err
+	}
+
+	// Part of a batch
+	if ctx.Value(transactionContextKey{}) == c {
+		return produce(c.producer, msg, nil)
+	}
+
+	_, end, err := c.BeginBatch(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	if err := produce(c.producer, msg, nil); err != nil {
+		_ = end(false)
+
+		return err
+	}
+
+	return end(true)
+}
+
+// commit commits the transaction, retrying retriable errors until the timeout.
+// Transactions are committed (or aborted) even if the client is gone, so the outcome of batches is never left unknown.
+func (c *TransactionalCollector) commit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	for {
+		err := c.producer.CommitTransaction(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var kafkaErr kafka.Error
+		if errors.As(err, &kafkaErr) && kafkaErr.IsRetriable() && ctx.Err() == nil {
+			continue
+		}
+
+		if errors.As(err, &kafkaErr) && kafkaErr.TxnRequiresAbort() {
+			if aerr := c.abort(); aerr != nil {
+				c.logger.Error("unable to abort kafka transaction", slog.Any("error", aerr))
+			}
+		}
+
+		return fmt.Errorf("commit kafka transaction: %w", err)
+	}
+}
+
+func (c *TransactionalCollector) abort() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if err := c.producer.AbortTransaction(ctx); err != nil {
+		return fmt.Errorf("abort kafka transaction: %w", err)
+	}
+
+	return nil
+}
+
+// forget forgets the idempotency key of a batch that was not committed, so it can be retried.
+func (c *TransactionalCollector) forget(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := c.committed.Forget(ctx, key); err != nil {
+		c.logger.ErrorCtx(ctx, "unable to forget batch idempotency key", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,107 @@
+package kafkaingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+type rawSchema struct{}
+
+func (rawSchema) SerializeKey(_ string, ev event.Event) ([]byte, error) {
+	return []byte(ev.Subject()), nil
+}
+
+func (rawSchema) SerializeValue(_ string, ev event.Event) ([]byte, error) {
+	return []byte(ev.ID()), nil
+}
+
+func TestTransactionalCollector(t *testing.T) {
+	cluster, err := kafka.NewMockCluster(1)
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	const topic = "events"
+
+	producer, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers": cluster.BootstrapServers(),
+		"transactional.id":  "test",
+	})
+	require.NoError(t, err)
+	defer producer.Close()
+
+	collector, err := NewTransactionalCollector(TransactionalCollectorConfig{
+		Producer: producer,
+		Topic:    topic,
+		Schema:   rawSchema{},
+		Timeout:  30 * time.Second,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	newEvent := func(id string) event.Event {
+		ev := event.New()
+		ev.SetID(id)
+		ev.SetSource("test")
+
+		return ev
+	}
+
+	// A single event is produced in its own transaction
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+
+	// Aborted batches are never consumed and can be retried
+	batchCtx, end, err := collector.BeginBatch(ctx, "batch-1")
+	require.NoError(t, err)
+	require.NoError(t, collector.Receive(batchCtx, newEvent("aborted")))
+	require.NoError(t, end(false))
+
+	for i := 0; i < 2; i++ {
+		batchCtx, end, err = collector.BeginBatch(ctx, "batch-1")
+		if i > 0 {
+			// Retries of committed batches are not produced again
+			require.ErrorIs(t, err, ingest.ErrBatchCommitted)
+
+			continue
+		}
+
+		require.NoError(t, err)
+		require.NoError(t, collector.Receive(batchCtx, newEvent("2")))
+		require.NoError(t, collector.Receive(batchCtx, newEvent("3")))
+		require.NoError(t, end(true))
+	}
+
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": cluster.BootstrapServers(),
+		"group.id":          "test",
+		"auto.offset.reset": "earliest",
+		"isolation.level":   "read_committed",
+	})
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	require.NoError(t, consumer.Subscribe(topic, nil))
+
+	var consumed []string
+
+	deadline := time.Now().Add(30 * time.Second)
+	for len(consumed) < 3 && time.Now().Before(deadline) {
+		msg, err := consumer.ReadMessage(time.Second)
+		if err != nil {
+			continue
+		}
+
+		consumed = append(consumed, string(msg.Value))
+	}
+
+	// Messages are only ordered within partitions
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, consumed)
+}
@@ -0,0 +1,247 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+// MirrorDropPolicy is which events a MirrorCollector drops when its buffer is full.
+type MirrorDropPolicy string
+
+const (
+	// MirrorDropNewest drops the events received while the buffer is full.
+	MirrorDropNewest MirrorDropPolicy = "newest"
+
+	// MirrorDropOldest drops the oldest buffered event to make room for new events, keeping the mirror as current as possible.
+	MirrorDropOldest MirrorDropPolicy = "oldest"
+)
+
+const defaultMirrorName = "mirror"
+
+// MirrorCollectorConfig configures a MirrorCollector.
+type MirrorCollectorConfig struct {
+	// Primary receives events synchronously: its result is the result of Receive.
+	Primary Collector
+
+	// Mirror receives the events accepted by the primary asynchronously, eg. a collector of a secondary region.
+	Mirror Collector
+
+	// Size is the number of events the mirror buffer can hold. Defaults to 1000.
+	Size int
+
+	// DropPolicy is which events are dropped when the mirror buffer is full. Defaults to MirrorDropNewest.
+	DropPolicy MirrorDropPolicy
+
+	Logger *slog.Logger
+
+	// Name identifies the collector in queue metrics. Defaults to mirror.
+	Name string
+
+	// Metrics records the depth of the mirror buffer and the events dropped (optional).
+	Metrics *QueueMetrics
+
+	// Registerer registers the metrics of mirror lag and failures (optional).
+	Registerer prometheus.Registerer
+}
+
+// MirrorCollector forwards events to a primary collector and mirrors the events it accepts to a secondary collector
+// (eg. in another region for disaster recovery) on a best effort basis.
+//
+// Mirroring never affects the result of Receive, nor its latency beyond enqueueing events in an in-memory buffer:
+// events are dropped when the buffer is full, mirror failures are only logged and counted.
+// Unlike FailoverCollector, every accepted event is sent to both collectors.
+type MirrorCollector struct {
+	primary    Collector
+	mirror     Collector
+	buffer     chan mirroredEvent
+	dropPolicy MirrorDropPolicy
+	logger     *slog.Logger
+	name       string
+	metrics    *QueueMetrics
+	lag        prometheus.Histogram
+	failures   prometheus.Counter
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+type mirroredEvent struct {
+	event      event.Event
+	acceptedAt time.Time
+}
+
+// NewMirrorCollector returns a new MirrorCollector and starts mirroring events in the background.
+func NewMirrorCollector(config MirrorCollectorConfig) (*MirrorCollector, error) {
+	if config.Primary == nil {
+		return nil, errors.New("primary collector is required")
+	}
+
+	if config.Mirror == nil {
+		return nil, errors.New("mirror collector is required")
+	}
+
+	if config.Size < 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", config.Size)
+	}
+
+	size := config.Size
+	if size == 0 {
+		size = defaultBufferSize
+	}
+
+	dropPolicy := config.DropPolicy
+	if dropPolicy == "" {
+		dropPolicy = MirrorDropNewest
+	}
+
+	if dropPolicy != MirrorDropNewest && dropPolicy != MirrorDropOldest {
+		return nil, fmt.Errorf("invalid mirror drop policy: %s", dropPolicy)
+	}
+
+	name := config.Name
+	if name == "" {
+		name = defaultMirrorName
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &MirrorCollector{
+		primary:    config.Primary,
+		mirror:     config.Mirror,
+		buffer:     make(chan mirroredEvent, size),
+		dropPolicy: dropPolicy,
+		logger:     logger,
+		name:       name,
+		metrics:    config.Metrics,
+		done:       make(chan struct{}),
+	}
+
+	if config.Registerer != nil {
+		c.lag = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Subsystem:   metricsSubsystem,
+			Name:        "mirror_lag_seconds",
+			Help:        "Time between the primary accepting an event and its delivery to the mirror.",
+			ConstLabels: prometheus.Labels{"collector": name},
+			Buckets:     prometheus.ExponentialBuckets(0.001, 4, 10), // 1ms - 4m
+		})
+
+		c.failures = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Subsystem:   metricsSubsystem,
+			Name:        "mirror_failed_events_total",
+			Help:        "Number of events the mirror collector failed to receive.",
+			ConstLabels: prometheus.Labels{"collector": name},
+		})
+
+		for _, collector := range []prometheus.Collector{c.lag, c.failures} {
+			if err := config.Registerer.Register(collector); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.metrics.Register(c.name, func() int { return len(c.buffer) }); err != nil {
+		return nil, err
+	}
+
+	go c.forward()
+
+	return c, nil
+}
+
+// Receive forwards the event to the primary collector, and enqueues it for the mirror once the primary accepted it.
+func (c *MirrorCollector) Receive(ctx context.Context, ev event.Event) error {
+	if err := c.primary.Receive(ctx, ev); err != nil {
+		return err
+	}
+
+	c.enqueue(mirroredEvent{event: ev, acceptedAt: time.Now()})
+
+	return nil
+}
+
+func (c *MirrorCollector) enqueue(ev mirroredEvent) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		c.metrics.RecordDrop(c.name)
+
+		return
+	}
+
+	select {
+	case c.buffer <- ev:
+		return
+	default:
+	}
+
+	c.metrics.RecordDrop(c.name)
+
+	if c.dropPolicy != MirrorDropOldest {
+		return
+	}
+
+	// Concurrent receivers may take the room made here: the event is dropped then
+	select {
+	case <-c.buffer:
+	default:
+	}
+
+	select {
+	case c.buffer <- ev:
+	default:
+		c.metrics.RecordDrop(c.name)
+	}
+}
+
+func (c *MirrorCollector) forward() {
+	defer close(c.done)
+
+	for ev := range c.buffer {
+		err := c.mirror.Receive(context.Background(), ev.event)
+		if err != nil {
+			if c.failures != nil {
+				c.failures.Inc()
+			}
+
+			c.logger.Error("unable to mirror event", slog.String("event_id", ev.event.ID()), slog.Any("error", err))
+
+			continue
+		}
+
+		if c.lag != nil {
+			c.lag.Observe(time.Since(ev.acceptedAt).Seconds())
+		}
+	}
+}
+
+// Close stops mirroring and waits until buffered events are mirrored or the context is done.
+// Events received afterwards are still forwarded to the primary collector.
+func (c *MirrorCollector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.buffer)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,170 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingCollector struct {
+	err error
+}
+
+func (c failingCollector) Receive(ctx context.Context, ev event.Event) error {
+	return c.err
+}
+
+func eventIDs(c *blockingCollector) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]string, 0, len(c.events))
+	for _, ev := range c.events {
+		ids = append(ids, ev.ID())
+	}
+
+	return ids
+}
+
+func TestMirrorCollector(t *testing.T) {
+	primary := &blockingCollector{release: make(chan struct{})}
+	close(primary.release)
+
+	mirror := &blockingCollector{release: make(chan struct{})}
+	close(mirror.release)
+
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewMirrorCollector(MirrorCollectorConfig{
+		Primary:    primary,
+		Mirror:     mirror,
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	ctx, ack := WithAck(context.Background())
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+
+	// Mirroring does not change the acknowledgement of the primary
+	assert.Equal(t, AckDelivered, ack())
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	assert.Equal(t, []string{"1", "2"}, eventIDs(primary))
+	assert.Equal(t, []string{"1", "2"}, eventIDs(mirror))
+
+	count, err := testutil.GatherAndCount(registry, "openmeter_ingest_mirror_lag_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestMirrorCollector_PrimaryFailure(t *testing.T) {
+	mirror := &blockingCollector{release: make(chan struct{})}
+	close(mirror.release)
+
+	collector, err := NewMirrorCollector(MirrorCollectorConfig{
+		Primary: failingCollector{err: errors.New("primary failure")},
+		Mirror:  mirror,
+	})
+	require.NoError(t, err)
+
+	err = collector.Receive(context.Background(), newEvent("1"))
+	assert.EqualError(t, err, "primary failure")
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	// Only events accepted by the primary are mirrored
+	assert.Empty(t, eventIDs(mirror))
+}
+
+func TestMirrorCollector_MirrorFailure(t *testing.T) {
+	primary := &blockingCollector{release: make(chan struct{})}
+	close(primary.release)
+
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewMirrorCollector(MirrorCollectorConfig{
+		Primary:    primary,
+		Mirror:     failingCollector{err: errors.New("mirror failure")},
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+	require.NoError(t, collector.Close(context.Background()))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.failures))
+}
+
+func TestMirrorCollector_DropPolicy(t *testing.T) {
+	tests := []struct {
+		policy MirrorDropPolicy
+		want   []string
+	}{
+		{policy: MirrorDropNewest, want: []string{"forwarding", "1"}},
+		{policy: MirrorDropOldest, want: []string{"forwarding", "2"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(string(test.policy), func(t *testing.T) {
+			primary := &blockingCollector{release: make(chan struct{})}
+			close(primary.release)
+
+			mirror := &blockingCollector{release: make(chan struct{})}
+
+			registry := prometheus.NewRegistry()
+
+			metrics, err := NewQueueMetrics(registry)
+			require.NoError(t, err)
+
+			collector, err := NewMirrorCollector(MirrorCollectorConfig{
+				Primary:    primary,
+				Mirror:     mirror,
+				Size:       1,
+				DropPolicy: test.policy,
+				Metrics:    metrics,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, collector.Receive(context.Background(), newEvent("forwarding")))
+
+			require.Eventually(t, func() bool {
+				return len(collector.buffer) == 0
+			}, time.Second, time.Millisecond)
+
+			require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+
+			// The buffer is full, the primary still receives events
+			require.NoError(t, collector.Receive(context.Background(), newEvent("2")))
+
+			close(mirror.release)
+
+			require.NoError(t, collector.Close(context.Background()))
+
+			assert.Equal(t, []string{"forwarding", "1", "2"}, eventIDs(primary))
+			assert.Equal(t, test.want, eventIDs(mirror))
+
+			assert.Equal(t, float64(1), testutil.ToFloat64(metrics.drops.WithLabelValues(defaultMirrorName)))
+		})
+	}
+}
+
+func TestNewMirrorCollector(t *testing.T) {
+	_, err := NewMirrorCollector(MirrorCollectorConfig{
+		Primary:    failingCollector{},
+		Mirror:     failingCollector{},
+		DropPolicy: "random",
+	})
+	assert.EqualError(t, err, "invalid mirror drop policy: random")
+}
@@ -0,0 +1,191 @@
+// Package otelingest forwards events to an OpenTelemetry collector as OTLP log records.
+package otelingest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// DefaultEndpoint is the default OTLP/HTTP endpoint of an OpenTelemetry collector.
+	DefaultEndpoint = "http://127.0.0.1:4318"
+
+	logsPath  = "/v1/logs"
+	scopeName = "github.com/openmeterio/openmeter/internal/ingest/otelingest"
+
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// Collector is a receiver of events that handles sending those events to an OpenTelemetry collector.
+//
+// Each event is exported as an OTLP log record (using the OTLP/HTTP protobuf encoding),
+// with CloudEvents attributes mapped to log record attributes
+// according to the CloudEvents semantic conventions.
+// Event data is the body of the log record: a string when it is text (eg. JSON), bytes otherwise.
+type Collector struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver. Defaults to DefaultEndpoint.
+	Endpoint string
+
+	// Headers are added to every export request (eg. authentication).
+	Headers map[string]string
+
+	// ResourceAttributes describe the entity producing the log records (eg. service.name).
+	ResourceAttributes map[string]string
+
+	// Client is used for exporting log records. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (c Collector) Receive(ctx context.Context, ev event.Event) error {
+	body, err := proto.Marshal(&collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: stringAttributes(c.ResourceAttributes),
+				},
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: scopeName},
+						LogRecords: []*logspb.LogRecord{toLogRecord(ev)},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal log record: %w", err)
+	}
+
+	err = c.export(ctx, body)
+	if err != nil {
+		return fmt.Errorf("exporting log record: %w", err)
+	}
+
+	return nil
+}
+
+func (c Collector) export(ctx context.Context, body []byte) error {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+logsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentTypeProtobuf)
+
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func toLogRecord(ev event.Event) *logspb.LogRecord {
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("event.name", ev.Type()),
+		stringAttribute("cloudevents.event_id", ev.ID()),
+		stringAttribute("cloudevents.event_source", ev.Source()),
+		stringAttribute("cloudevents.event_spec_version", ev.SpecVersion()),
+		stringAttribute("cloudevents.event_type", ev.Type()),
+	}
+
+	if ev.Subject() != "" {
+		attributes = append(attributes, stringAttribute("cloudevents.event_subject", ev.Subject()))
+	}
+
+	extensions := make([]string, 0, len(ev.Extensions()))
+	for name := range ev.Extensions() {
+		extensions = append(extensions, name)
+	}
+	sort.Strings(extensions)
+
+	for _, name := range extensions {
+		attributes = append(attributes, stringAttribute("cloudevents.event_extension."+name, fmt.Sprint(ev.Extensions()[name])))
+	}
+
+	t := ev.Time()
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return &logspb.LogRecord{
+		TimeUnixNano:         uint64(t.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		Body:                 dataValue(ev.Data()),
+		Attributes:           attributes,
+	}
+}
+
+// dataValue returns the body of the log record of event data (nil without data).
+// Data that is not valid UTF-8 (eg. binary data) is not a valid protobuf string: it is sent as bytes.
+func dataValue(data []byte) *commonpb.AnyValue {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if utf8.Valid(data) {
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(data)}}
+	}
+
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: data}}
+}
+
+func stringAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func stringAttributes(attrs map[string]string) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, stringAttribute(key, attrs[key]))
+	}
+
+	return result
+}
@@ -0,0 +1,137 @@
+package otelingest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiveLogs returns a server decoding the export requests it receives into received.
+func receiveLogs(t *testing.T, received *collogspb.ExportLogsServiceRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(body, received))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCollector(t *testing.T) {
+	var received collogspb.ExportLogsServiceRequest
+
+	server := receiveLogs(t, &received)
+
+	collector := Collector{
+		Endpoint:           server.URL,
+		Headers:            map[string]string{"Authorization": "secret"},
+		ResourceAttributes: map[string]string{"service.name": "openmeter"},
+		Client:             server.Client(),
+	}
+
+	now := time.Date(2023, 06, 15, 14, 33, 00, 00, time.UTC)
+
+	ev := event.New()
+	ev.SetID("id")
+	ev.SetType("api-calls")
+	ev.SetTime(now)
+	ev.SetSubject("sub")
+	ev.SetSource("test")
+	ev.SetExtension("region", "eu")
+	require.NoError(t, ev.SetData(event.ApplicationJSON, map[string]string{"duration_ms": "12"}))
+
+	err := collector.Receive(context.Background(), ev)
+	require.NoError(t, err)
+
+	require.Len(t, received.ResourceLogs, 1)
+	assertAttributes(t, []*commonpb.KeyValue{stringAttribute("service.name", "openmeter")}, received.ResourceLogs[0].Resource.Attributes)
+
+	require.Len(t, received.ResourceLogs[0].ScopeLogs, 1)
+	require.Len(t, received.ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+
+	record := received.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+
+	assert.Equal(t, uint64(1686839580000000000), record.TimeUnixNano)
+	assert.Equal(t, `{"duration_ms":"12"}`, record.Body.GetStringValue())
+	assertAttributes(t, []*commonpb.KeyValue{
+		stringAttribute("event.name", "api-calls"),
+		stringAttribute("cloudevents.event_id", "id"),
+		stringAttribute("cloudevents.event_source", "test"),
+		stringAttribute("cloudevents.event_spec_version", "1.0"),
+		stringAttribute("cloudevents.event_type", "api-calls"),
+		stringAttribute("cloudevents.event_subject", "sub"),
+		stringAttribute("cloudevents.event_extension.region", "eu"),
+	}, record.Attributes)
+}
+
+func TestCollector_BinaryData(t *testing.T) {
+	var received collogspb.ExportLogsServiceRequest
+
+	server := receiveLogs(t, &received)
+
+	collector := Collector{
+		Endpoint: server.URL,
+		Client:   server.Client(),
+	}
+
+	data := []byte{0xff, 0x00, 0xfe}
+
+	ev := event.New()
+	ev.SetID("id")
+	ev.SetType("api-calls")
+	ev.SetSource("test")
+	require.NoError(t, ev.SetData("application/octet-stream", data))
+
+	require.NoError(t, collector.Receive(context.Background(), ev))
+
+	record := received.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+
+	assert.Equal(t, data, record.Body.GetBytesValue())
+}
+
+func assertAttributes(t *testing.T, expected []*commonpb.KeyValue, actual []*commonpb.KeyValue) {
+	t.Helper()
+
+	require.Len(t, actual, len(expected))
+
+	for i := range expected {
+		assert.True(t, proto.Equal(expected[i], actual[i]), "expected %s, got %s", expected[i], actual[i])
+	}
+}
+
+func TestCollector_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	collector := Collector{
+		Endpoint: server.URL,
+		Client:   server.Client(),
+	}
+
+	ev := event.New()
+	ev.SetID("id")
+	ev.SetSource("test")
+
+	err := collector.Receive(context.Background(), ev)
+	assert.ErrorContains(t, err, "unexpected status code: 503")
+}
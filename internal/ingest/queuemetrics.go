@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// QueueMetrics records the state of the queues of buffering collectors (eg. BufferedCollector, BatchingCollector),
+// labeled by collector name.
+//
+// Queue depths are read when metrics are collected, so they are always current.
+// A nil *QueueMetrics is valid and records nothing.
+type QueueMetrics struct {
+	depth  *prometheus.Desc
+	drops  *prometheus.CounterVec
+	blocks *prometheus.CounterVec
+
+	mu     sync.Mutex
+	queues map[string]func() int
+}
+
+// NewQueueMetrics creates queue metrics and registers them in the registerer.
+func NewQueueMetrics(registerer prometheus.Registerer) (*QueueMetrics, error) {
+	m := &QueueMetrics{
+		depth: prometheus.NewDesc(
+			"openmeter_ingest_queue_depth",
+			"Number of events waiting in the queue of a buffering collector.",
+			[]string{"collector"},
+			nil,
+		),
+		drops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "queue_dropped_events_total",
+			Help:      "Number of events rejected by a buffering collector because its queue was full.",
+		}, []string{"collector"}),
+		blocks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "queue_blocked_events_total",
+			Help:      "Number of events that waited for capacity in the queue of a buffering collector.",
+		}, []string{"collector"}),
+		queues: make(map[string]func() int),
+	}
+
+	if err := registerer.Register(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *QueueMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.depth
+
+	m.drops.Describe(ch)
+	m.blocks.Describe(ch)
+}
+
+func (m *QueueMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	for name, depth := range m.queues {
+		ch <- prometheus.MustNewConstMetric(m.depth, prometheus.GaugeValue, float64(depth()), name)
+	}
+	m.mu.Unlock()
+
+	m.drops.Collect(ch)
+	m.blocks.Collect(ch)
+}
+
+// Register registers the queue of a collector, whose depth is read by the function.
+func (m *QueueMetrics) Register(name string, depth func() int) error {
+	if m == nil {
+		return nil
+	}
+
+	if name == "" {
+		return errors.New("collector name is required to record queue metrics")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.queues[name]; ok {
+		return fmt.Errorf("duplicate collector name: %s", name)
+	}
+
+	m.queues[name] = depth
+
+	return nil
+}
+
+// RecordDrop counts an event rejected by a collector because its queue was full.
+func (m *QueueMetrics) RecordDrop(name string) {
+	if m == nil {
+		return
+	}
+
+	m.drops.WithLabelValues(name).Inc()
+}
+
+// RecordBlock counts an event that waited for capacity in the queue of a collector.
+func (m *QueueMetrics) RecordBlock(name string) {
+	if m == nil {
+		return
+	}
+
+	m.blocks.WithLabelValues(name).Inc()
+}
@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := NewQueueMetrics(registry)
+	require.NoError(t, err)
+
+	downstream := &blockingCollector{release: make(chan struct{})}
+
+	buffered, err := NewBufferedCollector(BufferedCollectorConfig{
+		Collector: downstream,
+		Size:      1,
+		Block:     true,
+		MaxBlock:  time.Millisecond,
+		Name:      "buffered",
+		Metrics:   metrics,
+	})
+	require.NoError(t, err)
+
+	batching, err := NewBatchingCollector(BatchingCollectorConfig{
+		Receiver:      batchReceiverFunc(func(context.Context, []event.Event) error { return nil }),
+		FlushInterval: time.Hour,
+		Name:          "batching",
+		Metrics:       metrics,
+	})
+	require.NoError(t, err)
+
+	_, err = NewBatchingCollector(BatchingCollectorConfig{
+		Receiver: batchReceiverFunc(func(context.Context, []event.Event) error { return nil }),
+		Name:     "batching",
+		Metrics:  metrics,
+	})
+	require.Error(t, err, "collector names must be unique")
+
+	ctx := context.Background()
+
+	fill(t, buffered)
+	require.ErrorIs(t, buffered.Receive(ctx, newEvent("dropped")), ErrBufferFull)
+
+	require.NoError(t, batching.Receive(ctx, newEvent("1")))
+	require.NoError(t, batching.Receive(ctx, newEvent("2")))
+
+	expected := `
+# HELP openmeter_ingest_queue_depth Number of events waiting in the queue of a buffering collector.
+# TYPE openmeter_ingest_queue_depth gauge
+openmeter_ingest_queue_depth{collector="batching"} 2
+openmeter_ingest_queue_depth{collector="buffered"} 1
+# HELP openmeter_ingest_queue_dropped_events_total Number of events rejected by a buffering collector because its queue was full.
+# TYPE openmeter_ingest_queue_dropped_events_total counter
+openmeter_ingest_queue_dropped_events_total{collector="buffered"} 1
+# HELP openmeter_ingest_queue_blocked_events_total Number of events that waited for capacity in the queue of a buffering collector.
+# TYPE openmeter_ingest_queue_blocked_events_total counter
+openmeter_ingest_queue_blocked_events_total{collector="buffered"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+
+	close(downstream.release)
+	require.NoError(t, buffered.Close(ctx))
+	require.NoError(t, batching.Close(ctx))
+}
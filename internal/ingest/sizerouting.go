@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+)
+
+// SizeRoutingCollectorConfig configures a SizeRoutingCollector.
+type SizeRoutingCollectorConfig struct {
+	// Threshold is the data size (in bytes) above which events are routed to the Large collector.
+	Threshold int
+
+	// Large receives events with data larger than the threshold (eg. a batching, cheaper path).
+	Large Collector
+
+	// Default receives the other events.
+	Default Collector
+}
+
+// SizeRoutingCollector routes events to a downstream {Collector} based on the size of their data.
+type SizeRoutingCollector struct {
+	threshold int
+	large     Collector
+	fallback  Collector
+}
+
+// NewSizeRoutingCollector returns a new SizeRoutingCollector.
+func NewSizeRoutingCollector(config SizeRoutingCollectorConfig) (*SizeRoutingCollector, error) {
+	if config.Threshold <= 0 {
+		return nil, errors.New("size threshold must be positive")
+	}
+
+	if config.Large == nil {
+		return nil, errors.New("large event collector is required")
+	}
+
+	if config.Default == nil {
+		return nil, errors.New("default collector is required")
+	}
+
+	return &SizeRoutingCollector{
+		threshold: config.Threshold,
+		large:     config.Large,
+		fallback:  config.Default,
+	}, nil
+}
+
+func (c *SizeRoutingCollector) Receive(ctx context.Context, ev event.Event) error {
+	if len(ev.Data()) > c.threshold {
+		return c.large.Receive(ctx, ev)
+	}
+
+	return c.fallback.Receive(ctx, ev)
+}
@@ -0,0 +1,39 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeRoutingCollector(t *testing.T) {
+	var large, small []string
+
+	collector, err := NewSizeRoutingCollector(SizeRoutingCollectorConfig{
+		Threshold: 16,
+		Large: collectorFunc(func(_ context.Context, ev event.Event) error {
+			large = append(large, ev.ID())
+
+			return nil
+		}),
+		Default: collectorFunc(func(_ context.Context, ev event.Event) error {
+			small = append(small, ev.ID())
+
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newGaugeEvent(t, "1", "customer-1", `{"value": 1}`)))
+	require.NoError(t, collector.Receive(ctx, newGaugeEvent(t, "2", "customer-1", `{"value":123456}`)))
+	require.NoError(t, collector.Receive(ctx, newGaugeEvent(t, "3", "customer-1", `{"value":1234567}`)))
+	require.NoError(t, collector.Receive(ctx, newEvent("4")))
+
+	assert.Equal(t, []string{"3"}, large)
+	assert.Equal(t, []string{"1", "2", "4"}, small)
+}
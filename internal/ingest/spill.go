@@ -0,0 +1,274 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+// spillFileExt is the extension of spilled event files.
+const spillFileExt = ".json"
+
+// ErrSpillFull is returned when spilling an event would exceed the maximum spill size.
+var ErrSpillFull = errors.New("spill directory full")
+
+// SpillFileName returns the name of the file an event is spilled to (without directory and extension).
+type SpillFileName func(ev event.Event) string
+
+// SpillCollectorConfig configures a SpillCollector.
+type SpillCollectorConfig struct {
+	// Collector is the primary collector (eg. a collector retrying transient failures).
+	Collector Collector
+
+	// Directory is the directory events are spilled to. It is created if it does not exist.
+	Directory string
+
+	// FileName returns the name of the file an event is spilled to.
+	// Defaults to the spill time (in nanoseconds) followed by the event ID, so files sort in spill order.
+	FileName SpillFileName
+
+	// MaxSize is the maximum total size (in bytes) of the spill directory (optional).
+	// Events are not spilled when the limit would be exceeded.
+	MaxSize int64
+
+	Logger *slog.Logger
+
+	// Registerer registers the metric counting spilled events (optional).
+	Registerer prometheus.Registerer
+}
+
+// SpillCollector writes events that could not be forwarded to the primary {Collector} to a local spill directory,
+// one JSON file per event, so an operator can replay them later.
+//
+// It is a lightweight alternative to a dead letter topic for single-node deployments.
+// Spilled events are acknowledged; failing to spill an event returns both the forwarding and the spill errors.
+// Events rejected because of their content (ErrInvalidEvent) are never spilled, as replaying them cannot succeed.
+type SpillCollector struct {
+	collector Collector
+	directory string
+	fileName  SpillFileName
+	maxSize   int64
+	logger    *slog.Logger
+	spilled   prometheus.Counter
+	now       func() time.Time
+	syncDir   func(directory string) error
+
+	mu   sync.Mutex
+	size int64
+}
+
+// NewSpillCollector returns a new SpillCollector.
+func NewSpillCollector(config SpillCollectorConfig) (*SpillCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Directory == "" {
+		return nil, errors.New("spill directory is required")
+	}
+
+	if config.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid spill size: %d", config.MaxSize)
+	}
+
+	if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+		return nil, fmt.Errorf("create spill directory: %w", err)
+	}
+
+	size, err := spillSize(config.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("read spill directory: %w", err)
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &SpillCollector{
+		collector: config.Collector,
+		directory: config.Directory,
+		fileName:  config.FileName,
+		maxSize:   config.MaxSize,
+		logger:    logger,
+		spilled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "spilled_events_total",
+			Help:      "Number of events written to the spill directory because they could not be forwarded.",
+		}),
+		now:     time.Now,
+		syncDir: syncDir,
+		size:    size,
+	}
+
+	if config.Registerer != nil {
+		if err := config.Registerer.Register(c.spilled); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *SpillCollector) Receive(ctx context.Context, ev event.Event) error {
+	err := c.collector.Receive(ctx, ev)
+	if err == nil || errors.Is(err, ErrInvalidEvent) {
+		return err
+	}
+
+	path, spillErr := c.spill(ev)
+	if spillErr != nil {
+		c.logger.ErrorCtx(ctx, "unable to spill event", slog.String("event_id", ev.ID()), slog.Any("error", spillErr))
+
+		return errors.Join(err, fmt.Errorf("spill event: %w", spillErr))
+	}
+
+	c.spilled.Inc()
+	c.logger.WarnCtx(ctx, "spilled event", slog.String("event_id", ev.ID()), slog.String("path", path), slog.Any("error", err))
+
+	return nil
+}
+
+func (c *SpillCollector) spill(ev event.Event) (string, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxSize > 0 && c.size+int64(len(data)) > c.maxSize {
+		// Files may have been replayed (removed) in the meantime
+		size, err := spillSize(c.directory)
+		if err != nil {
+			return "", err
+		}
+
+		c.size = size
+
+		if c.size+int64(len(data)) > c.maxSize {
+			return "", ErrSpillFull
+		}
+	}
+
+	name := c.defaultFileName(ev)
+	if c.fileName != nil {
+		name = c.fileName(ev)
+	}
+
+	path := filepath.Join(c.directory, name+spillFileExt)
+
+	// Write to a temporary file first, so replay tools never read partially written events
+	tmp, err := os.CreateTemp(c.directory, ".spill-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	// Spilled events are acknowledged: they must survive a crash once Receive returns
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return "", err
+	}
+
+	// The rename is only durable once the directory is synced.
+	// Otherwise the event is not spilled: the file is removed, so the event is not replayed on top of being retried by the client
+	if err := c.syncDir(c.directory); err != nil {
+		if removeErr := os.Remove(path); removeErr != nil {
+			return "", errors.Join(err, fmt.Errorf("remove spill file: %w", removeErr))
+		}
+
+		return "", err
+	}
+
+	c.size += int64(len(data))
+
+	return path, nil
+}
+
+// syncDir syncs the directory to disk, so entries created or renamed in it survive a crash.
+func syncDir(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+
+	if err := dir.Sync(); err != nil {
+		_ = dir.Close()
+
+		return err
+	}
+
+	return dir.Close()
+}
+
+// unsafeFileNameChars matches characters not allowed in default spill file names.
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+func (c *SpillCollector) defaultFileName(ev event.Event) string {
+	return strconv.FormatInt(c.now().UnixNano(), 10) + "-" + unsafeFileNameChars.ReplaceAllString(ev.ID(), "_")
+}
+
+// spillSize returns the total size of the event files in the spill directory.
+func spillSize(directory string) (int64, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), spillFileExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Replayed in the meantime
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		size += info.Size()
+	}
+
+	return size, nil
+}
@@ -0,0 +1,117 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillCollector(t *testing.T) {
+	directory := t.TempDir()
+
+	collector, err := NewSpillCollector(SpillCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			switch ev.ID() {
+			case "unavailable":
+				return errors.New("broker unavailable")
+			case "invalid":
+				return fmt.Errorf("%w: bad data", ErrInvalidEvent)
+			}
+
+			return nil
+		}),
+		Directory: directory,
+		FileName: func(ev event.Event) string {
+			return ev.ID()
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("ok")))
+	require.NoError(t, collector.Receive(ctx, newEvent("unavailable")))
+	require.ErrorIs(t, collector.Receive(ctx, newEvent("invalid")), ErrInvalidEvent)
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "unavailable.json", entries[0].Name())
+
+	data, err := os.ReadFile(filepath.Join(directory, "unavailable.json"))
+	require.NoError(t, err)
+
+	var spilled event.Event
+	require.NoError(t, json.Unmarshal(data, &spilled))
+	assert.Equal(t, "unavailable", spilled.ID())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.spilled))
+}
+
+func TestSpillCollector_MaxSize(t *testing.T) {
+	directory := t.TempDir()
+
+	data, err := json.Marshal(newEvent("unavailable"))
+	require.NoError(t, err)
+
+	collector, err := NewSpillCollector(SpillCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			return errors.New("broker unavailable")
+		}),
+		Directory: directory,
+		MaxSize:   int64(len(data)) * 2,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, collector.Receive(ctx, newEvent("unavailable")))
+	require.NoError(t, collector.Receive(ctx, newEvent("unavailable")))
+
+	err = collector.Receive(ctx, newEvent("unavailable"))
+	require.ErrorIs(t, err, ErrSpillFull)
+	assert.ErrorContains(t, err, "broker unavailable")
+
+	// Replayed events free up space
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, os.Remove(filepath.Join(directory, entries[0].Name())))
+
+	require.NoError(t, collector.Receive(ctx, newEvent("unavailable")))
+}
+
+func TestSpillCollector_SyncFailure(t *testing.T) {
+	directory := t.TempDir()
+
+	collector, err := NewSpillCollector(SpillCollectorConfig{
+		Collector: collectorFunc(func(context.Context, event.Event) error {
+			return errors.New("broker unavailable")
+		}),
+		Directory: directory,
+	})
+	require.NoError(t, err)
+
+	collector.syncDir = func(string) error {
+		return errors.New("sync failed")
+	}
+
+	require.Error(t, collector.Receive(context.Background(), newEvent("1")))
+
+	// Events that are not spilled do not leave a file behind to be replayed
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.Equal(t, int64(0), collector.size)
+	assert.Equal(t, float64(0), testutil.ToFloat64(collector.spilled))
+}
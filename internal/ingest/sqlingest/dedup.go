@@ -0,0 +1,209 @@
+package sqlingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+const defaultCleanupInterval = time.Minute
+
+// DeduplicatorConfig configures a Deduplicator.
+type DeduplicatorConfig struct {
+	// DB is the database keys are recorded in. The driver is up to the caller.
+	DB *sql.DB
+
+	// Table is the table keys are recorded in, eg. dedup or openmeter.dedup.
+	// It must have a dedup_key text primary key and an expires_at integer column:
+	//
+	//	CREATE TABLE dedup (dedup_key TEXT PRIMARY KEY, expires_at BIGINT NOT NULL)
+	Table string
+
+	// Placeholder is the bind parameter syntax of the driver. Defaults to PlaceholderQuestion.
+	Placeholder Placeholder
+
+	// TTL is how long keys are remembered by default.
+	//
+	// Keys recorded with a longer TTL (see ingest.DedupFilterConfig.TypeTTLs) stay in the table for longer:
+	// the table holds about the rate of each type times its TTL rows.
+	TTL time.Duration
+
+	// CleanupInterval is how often expired keys are deleted from the table. Defaults to 1m.
+	CleanupInterval time.Duration
+
+	Logger *slog.Logger
+}
+
+// Deduplicator is an ingest.Deduplicator recording keys in a table, shared by every process using the same database.
+//
+// Keys are recorded with a single upsert statement (INSERT ... ON CONFLICT, supported by PostgreSQL and SQLite),
+// so concurrent duplicates received by different processes are detected.
+// Keys are stored hashed, with the time they expire at (in Unix milliseconds): the table holds every key for its TTL,
+// unlike the ingest.MemoryDeduplicator its size is not bounded. Expired keys are deleted in the background.
+type Deduplicator struct {
+	db       *sql.DB
+	table    string
+	ttl      time.Duration
+	interval time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	seen    string
+	forget  string
+	cleanup string
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var _ ingest.Deduplicator = (*Deduplicator)(nil)
+
+// NewDeduplicator returns a new Deduplicator and starts deleting expired keys in the background.
+func NewDeduplicator(config DeduplicatorConfig) (*Deduplicator, error) {
+	if config.DB == nil {
+		return nil, errors.New("database is required")
+	}
+
+	if !identifierPattern.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table name: %q", config.Table)
+	}
+
+	placeholder := config.Placeholder
+	switch placeholder {
+	case "":
+		placeholder = PlaceholderQuestion
+	case PlaceholderQuestion, PlaceholderDollar:
+	default:
+		return nil, fmt.Errorf("invalid placeholder: %q", placeholder)
+	}
+
+	if config.TTL <= 0 {
+		return nil, errors.New("deduplication TTL must be positive")
+	}
+
+	if config.CleanupInterval < 0 {
+		return nil, fmt.Errorf("invalid cleanup interval: %s", config.CleanupInterval)
+	}
+
+	interval := config.CleanupInterval
+	if interval == 0 {
+		interval = defaultCleanupInterval
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	bind := func(n int) string {
+		if placeholder == PlaceholderDollar {
+			return fmt.Sprintf("$%d", n)
+		}
+
+		return string(placeholder)
+	}
+
+	d := &Deduplicator{
+		db:       config.DB,
+		table:    config.Table,
+		ttl:      config.TTL,
+		interval: interval,
+		logger:   logger,
+		now:      time.Now,
+		// An expired key is recorded again by updating its expiry: no row is affected when the key is not expired
+		seen: fmt.Sprintf(
+			"INSERT INTO %s (dedup_key, expires_at) VALUES (%s, %s) ON CONFLICT (dedup_key) DO UPDATE SET expires_at = excluded.expires_at WHERE expires_at <= %s",
+			config.Table, bind(1), bind(2), bind(3),
+		),
+		forget:  fmt.Sprintf("DELETE FROM %s WHERE dedup_key = %s", config.Table, bind(1)),
+		cleanup: fmt.Sprintf("DELETE FROM %s WHERE expires_at <= %s", config.Table, bind(1)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go d.run()
+
+	return d, nil
+}
+
+// hashKey hashes keys, so they have a fixed size and never contain bytes text columns reject (eg. NUL).
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *Deduplicator) Seen(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = d.ttl
+	}
+
+	now := d.now()
+
+	result, err := d.db.ExecContext(ctx, d.seen, hashKey(key), now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("record key: %w", err)
+	}
+
+	recorded, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("record key: %w", err)
+	}
+
+	return recorded == 0, nil
+}
+
+func (d *Deduplicator) Forget(ctx context.Context, key string) error {
+	if _, err := d.db.ExecContext(ctx, d.forget, hashKey(key)); err != nil {
+		return fmt.Errorf("forget key: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Deduplicator) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := d.db.ExecContext(context.Background(), d.cleanup, d.now().UnixMilli()); err != nil {
+				d.logger.Error("unable to delete expired deduplication keys", slog.String("table", d.table), slog.Any("error", err))
+			}
+
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Close stops deleting expired keys. The database is not closed.
+func (d *Deduplicator) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stop)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
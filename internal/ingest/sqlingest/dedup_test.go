@@ -0,0 +1,240 @@
+package sqlingest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// fakeDedupDatabase executes the statements of the Deduplicator against a map of keys to their expiry.
+type fakeDedupDatabase struct {
+	mu      sync.Mutex
+	keys    map[string]int64
+	queries []string
+}
+
+func (d *fakeDedupDatabase) Connect(context.Context) (driver.Conn, error) {
+	return fakeDedupConn{d}, nil
+}
+
+func (d *fakeDedupDatabase) Driver() driver.Driver {
+	return nil
+}
+
+type fakeDedupConn struct {
+	db *fakeDedupDatabase
+}
+
+func (c fakeDedupConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.queries = append(c.db.queries, query)
+
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		key, expiresAt, now := args[0].Value.(string), args[1].Value.(int64), args[2].Value.(int64)
+
+		if current, ok := c.db.keys[key]; ok && current > now {
+			return driver.RowsAffected(0), nil
+		}
+
+		c.db.keys[key] = expiresAt
+
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(query, "dedup_key ="):
+		delete(c.db.keys, args[0].Value.(string))
+
+	default:
+		for key, expiresAt := range c.db.keys {
+			if expiresAt <= args[0].Value.(int64) {
+				delete(c.db.keys, key)
+			}
+		}
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeDedupConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c fakeDedupConn) Close() error {
+	return nil
+}
+
+func (c fakeDedupConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestDeduplicator(t *testing.T) {
+	db := &fakeDedupDatabase{keys: make(map[string]int64)}
+
+	deduplicator, err := NewDeduplicator(DeduplicatorConfig{
+		DB:          sql.OpenDB(db),
+		Table:       "openmeter.dedup",
+		Placeholder: PlaceholderDollar,
+		TTL:         time.Minute,
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, deduplicator.Close(context.Background()))
+	})
+
+	now := time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC)
+	deduplicator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	seen, err := deduplicator.Seen(ctx, "test\x001", 0)
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	seen, err = deduplicator.Seen(ctx, "test\x001", 0)
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	assert.Equal(t,
+		"INSERT INTO openmeter.dedup (dedup_key, expires_at) VALUES ($1, $2) ON CONFLICT (dedup_key) DO UPDATE SET expires_at = excluded.expires_at WHERE expires_at <= $3",
+		db.queries[0],
+	)
+
+	// Keys are stored hashed
+	for key := range db.keys {
+		assert.Len(t, key, 64)
+		assert.Equal(t, now.Add(time.Minute).UnixMilli(), db.keys[key])
+	}
+
+	t.Run("TTL", func(t *testing.T) {
+		seen, err := deduplicator.Seen(ctx, "test\x002", time.Hour)
+		require.NoError(t, err)
+		assert.False(t, seen)
+
+		now = now.Add(time.Minute)
+		defer func() { now = now.Add(-time.Minute) }()
+
+		// The default TTL expired, the type TTL did not
+		seen, err = deduplicator.Seen(ctx, "test\x001", 0)
+		require.NoError(t, err)
+		assert.False(t, seen)
+
+		seen, err = deduplicator.Seen(ctx, "test\x002", time.Hour)
+		require.NoError(t, err)
+		assert.True(t, seen)
+	})
+
+	t.Run("Forget", func(t *testing.T) {
+		require.NoError(t, deduplicator.Forget(ctx, "test\x003"))
+
+		seen, err := deduplicator.Seen(ctx, "test\x003", 0)
+		require.NoError(t, err)
+		assert.False(t, seen)
+
+		require.NoError(t, deduplicator.Forget(ctx, "test\x003"))
+
+		seen, err = deduplicator.Seen(ctx, "test\x003", 0)
+		require.NoError(t, err)
+		assert.False(t, seen)
+	})
+}
+
+func TestDeduplicator_Cleanup(t *testing.T) {
+	db := &fakeDedupDatabase{keys: map[string]int64{"expired": 0}}
+
+	deduplicator, err := NewDeduplicator(DeduplicatorConfig{
+		DB:              sql.OpenDB(db),
+		Table:           "dedup",
+		TTL:             time.Minute,
+		CleanupInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+
+		return len(db.keys) == 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, deduplicator.Close(context.Background()))
+
+	assert.Equal(t, "DELETE FROM dedup WHERE expires_at <= ?", db.queries[0])
+}
+
+func TestNewDeduplicator_Invalid(t *testing.T) {
+	db := sql.OpenDB(&fakeDedupDatabase{})
+
+	tests := []DeduplicatorConfig{
+		{Table: "dedup", TTL: time.Minute},
+		{DB: db, Table: "dedup; DROP TABLE events", TTL: time.Minute},
+		{DB: db, Table: "dedup", Placeholder: ":", TTL: time.Minute},
+		{DB: db, Table: "dedup"},
+		{DB: db, Table: "dedup", TTL: time.Minute, CleanupInterval: -time.Second},
+	}
+
+	for _, config := range tests {
+		_, err := NewDeduplicator(config)
+		assert.Error(t, err)
+	}
+}
+
+func TestDeduplicator_TypeTTLs(t *testing.T) {
+	db := &fakeDedupDatabase{keys: make(map[string]int64)}
+
+	deduplicator, err := NewDeduplicator(DeduplicatorConfig{
+		DB:    sql.OpenDB(db),
+		Table: "dedup",
+		TTL:   time.Minute,
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, deduplicator.Close(context.Background()))
+	})
+
+	now := time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC)
+	deduplicator.now = func() time.Time { return now }
+
+	filter, err := ingest.NewDedupFilter(ingest.DedupFilterConfig{
+		Deduplicator: deduplicator,
+		TypeTTLs:     map[string]time.Duration{"invoices": time.Hour},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	apiCall := newEvent("1")
+
+	invoice := newEvent("1")
+	invoice.SetType("invoices")
+
+	for _, ev := range []event.Event{apiCall, invoice} {
+		seen, _, err := filter.Seen(ctx, "", ev)
+		require.NoError(t, err)
+		assert.False(t, seen, "keys include the type")
+	}
+
+	now = now.Add(time.Minute)
+
+	seen, _, err := filter.Seen(ctx, "", apiCall)
+	require.NoError(t, err)
+	assert.False(t, seen, "default TTL expired")
+
+	seen, _, err = filter.Seen(ctx, "", invoice)
+	require.NoError(t, err)
+	assert.True(t, seen, "type TTL not expired")
+}
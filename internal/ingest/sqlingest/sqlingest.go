@@ -0,0 +1,481 @@
+// Package sqlingest forwards events to SQL databases, for small deployments without Kafka.
+package sqlingest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"golang.org/x/exp/slog"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+// Fields of events that can be mapped to columns.
+const (
+	FieldID      = "id"
+	FieldSource  = "source"
+	FieldType    = "type"
+	FieldSubject = "subject"
+	FieldTime    = "time"
+	FieldData    = "data"
+
+	// FieldEvent is the whole event in CloudEvents JSON format.
+	FieldEvent = "event"
+
+	// FieldExtensionPrefix maps an extension attribute, eg. extension:namespace.
+	FieldExtensionPrefix = "extension:"
+)
+
+const (
+	defaultBatchSize = 100
+	defaultLinger    = 10 * time.Millisecond
+	defaultRetries   = 3
+	defaultBackoff   = 100 * time.Millisecond
+)
+
+// DefaultColumns maps the fields of events to columns of the same name.
+var DefaultColumns = []Column{
+	{Name: "id", Field: FieldID},
+	{Name: "source", Field: FieldSource},
+	{Name: "type", Field: FieldType},
+	{Name: "subject", Field: FieldSubject},
+	{Name: "time", Field: FieldTime},
+	{Name: "data", Field: FieldData},
+}
+
+// identifierPattern matches table (optionally qualified by a schema) and column names:
+// they are not quoted in insert statements.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Placeholder is the bind parameter syntax of a database driver.
+type Placeholder string
+
+const (
+	// PlaceholderQuestion binds parameters with ? (eg. MySQL, SQLite).
+	PlaceholderQuestion Placeholder = "?"
+
+	// PlaceholderDollar binds parameters with $1, $2... (eg. PostgreSQL).
+	PlaceholderDollar Placeholder = "$"
+)
+
+// Column maps a field of events to a column of the table.
+type Column struct {
+	Name string
+
+	// Field is one of the Field constants.
+	Field string
+}
+
+// CollectorConfig configures a Collector.
+type CollectorConfig struct {
+	// DB is the database events are inserted into. The driver is up to the caller.
+	DB *sql.DB
+
+	// Table is the table events are inserted into, eg. events or openmeter.events.
+	Table string
+
+	// Columns maps fields of events to columns of the table. Defaults to DefaultColumns.
+	Columns []Column
+
+	// Placeholder is the bind parameter syntax of the driver. Defaults to PlaceholderQuestion.
+	Placeholder Placeholder
+
+	// BatchSize is the maximum number of events inserted in a single statement. Defaults to 100.
+	BatchSize int
+
+	// Linger is how long events wait for others to be inserted along with them. Defaults to 10ms.
+	Linger time.Duration
+
+	// Retries is the number of times an insert failing with a transient error is retried. Defaults to 3.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubled after every attempt. Defaults to 100ms.
+	Backoff time.Duration
+
+	// IsTransient reports whether an insert error is worth retrying. Defaults to IsTransient.
+	IsTransient func(err error) bool
+
+	Logger *slog.Logger
+}
+
+// Collector inserts events into a table with database/sql.
+//
+// Concurrently received events are inserted together, in a multi-row statement of up to BatchSize events,
+// and Receive returns once the statement has been executed: insert failures are reported to the sender.
+// Inserts failing with a transient error (see IsTransient) are retried with backoff.
+//
+// Collector is a simple sink for low volumes: every event waits for a database round trip,
+// and nothing prevents inserting retried events twice (unless the table has a unique key on the event id).
+type Collector struct {
+	db          *sql.DB
+	statement   string
+	columns     []Column
+	placeholder Placeholder
+	batchSize   int
+	linger      time.Duration
+	retries     int
+	backoff     time.Duration
+	isTransient func(err error) bool
+	logger      *slog.Logger
+
+	requests chan *request
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type request struct {
+	ctx    context.Context
+	values []interface{}
+	result chan error
+}
+
+var _ ingest.Collector = (*Collector)(nil)
+
+// NewCollector returns a new Collector and starts inserting events in the background.
+func NewCollector(config CollectorConfig) (*Collector, error) {
+	if config.DB == nil {
+		return nil, errors.New("database is required")
+	}
+
+	if !identifierPattern.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table name: %q", config.Table)
+	}
+
+	columns := config.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	names := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column.Name) || strings.Contains(column.Name, ".") {
+			return nil, fmt.Errorf("invalid column name: %q", column.Name)
+		}
+
+		if err := validateField(column.Field); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.Name, err)
+		}
+
+		names = append(names, column.Name)
+	}
+
+	placeholder := config.Placeholder
+	switch placeholder {
+	case "":
+		placeholder = PlaceholderQuestion
+	case PlaceholderQuestion, PlaceholderDollar:
+	default:
+		return nil, fmt.Errorf("invalid placeholder: %q", placeholder)
+	}
+
+	if config.BatchSize < 0 {
+		return nil, fmt.Errorf("invalid batch size: %d", config.BatchSize)
+	}
+
+	if config.Linger < 0 {
+		return nil, fmt.Errorf("invalid linger: %s", config.Linger)
+	}
+
+	if config.Retries < 0 {
+		return nil, fmt.Errorf("invalid number of retries: %d", config.Retries)
+	}
+
+	if config.Backoff < 0 {
+		return nil, fmt.Errorf("invalid backoff: %s", config.Backoff)
+	}
+
+	batchSize := config.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	linger := config.Linger
+	if linger == 0 {
+		linger = defaultLinger
+	}
+
+	retries := config.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	}
+
+	backoff := config.Backoff
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+
+	isTransient := config.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransient
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c := &Collector{
+		db:          config.DB,
+		statement:   fmt.Sprintf("INSERT INTO %s (%s) VALUES ", config.Table, strings.Join(names, ", ")),
+		columns:     columns,
+		placeholder: placeholder,
+		batchSize:   batchSize,
+		linger:      linger,
+		retries:     retries,
+		backoff:     backoff,
+		isTransient: isTransient,
+		logger:      logger,
+		requests:    make(chan *request),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	go c.run()
+
+	return c, nil
+}
+
+func validateField(field string) error {
+	switch field {
+	case FieldID, FieldSource, FieldType, FieldSubject, FieldTime, FieldData, FieldEvent:
+		return nil
+	}
+
+	if name, ok := strings.CutPrefix(field, FieldExtensionPrefix); ok && event.IsExtensionNameValid(name) {
+		return nil
+	}
+
+	return fmt.Errorf("invalid field: %q", field)
+}
+
+// IsTransient reports whether an error is a lost connection or a timeout.
+func IsTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Receive inserts the event (along with concurrently received events) and returns the outcome of the insert.
+func (c *Collector) Receive(ctx context.Context, ev event.Event) error {
+	values, err := c.values(ev)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ingest.ErrInvalidEvent, err)
+	}
+
+	// The result is sent even if the sender is gone
+	req := &request{ctx: ctx, values: values, result: make(chan error, 1)}
+
+	select {
+	case c.requests <- req:
+	case <-c.stop:
+		return ingest.ErrCollectorClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// values returns the values of the columns of the event.
+func (c *Collector) values(ev event.Event) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(c.columns))
+
+	for _, column := range c.columns {
+		var value interface{}
+
+		switch column.Field {
+		case FieldID:
+			value = ev.ID()
+		case FieldSource:
+			value = ev.Source()
+		case FieldType:
+			value = ev.Type()
+		case FieldSubject:
+			value = nullable(ev.Subject())
+		case FieldTime:
+			value = ev.Time().UTC()
+		case FieldData:
+			value = nullable(string(ev.Data()))
+		case FieldEvent:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return nil, fmt.Errorf("encode event: %w", err)
+			}
+
+			value = string(data)
+		default:
+			name := strings.TrimPrefix(column.Field, FieldExtensionPrefix)
+
+			if ext, ok := ev.Extensions()[name]; ok {
+				s, err := types.Format(ext)
+				if err != nil {
+					return nil, fmt.Errorf("extension %s: %w", name, err)
+				}
+
+				value = s
+			}
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// nullable inserts empty strings as NULL.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func (c *Collector) run() {
+	defer close(c.done)
+
+	for {
+		var batch []*request
+
+		select {
+		case req := <-c.requests:
+			batch = append(batch, req)
+		case <-c.stop:
+			return
+		}
+
+		timer := time.NewTimer(c.linger)
+
+	collect:
+		for len(batch) < c.batchSize {
+			select {
+			case req := <-c.requests:
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			case <-c.stop:
+				break collect
+			}
+		}
+
+		timer.Stop()
+
+		c.insertBatch(batch)
+	}
+}
+
+// insertBatch inserts the events of requests whose sender is still waiting and sends them the outcome.
+func (c *Collector) insertBatch(batch []*request) {
+	waiting := batch[:0]
+
+	for _, req := range batch {
+		if req.ctx.Err() == nil {
+			waiting = append(waiting, req)
+		}
+	}
+
+	if len(waiting) == 0 {
+		return
+	}
+
+	err := c.insert(waiting)
+	if err != nil {
+		c.logger.Error("unable to insert events", slog.Int("events", len(waiting)), slog.Any("error", err))
+	}
+
+	for _, req := range waiting {
+		req.result <- err
+	}
+}
+
+// insert executes a multi-row insert statement, retrying transient errors.
+func (c *Collector) insert(batch []*request) error {
+	var query strings.Builder
+
+	query.WriteString(c.statement)
+
+	args := make([]interface{}, 0, len(batch)*len(c.columns))
+
+	for i, req := range batch {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteString("(")
+
+		for j, value := range req.values {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+
+			args = append(args, value)
+
+			query.WriteString(string(c.placeholder))
+			if c.placeholder == PlaceholderDollar {
+				query.WriteString(strconv.Itoa(len(args)))
+			}
+		}
+
+		query.WriteString(")")
+	}
+
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		_, err := c.db.ExecContext(context.Background(), query.String(), args...)
+		if err == nil {
+			return nil
+		}
+
+		if attempt == c.retries || !c.isTransient(err) {
+			return fmt.Errorf("insert events: %w", err)
+		}
+
+		c.logger.Warn("retrying insert of events", slog.Int("attempt", attempt+1), slog.Any("error", err))
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close stops accepting events and waits until the events being inserted are inserted or the context is done.
+func (c *Collector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
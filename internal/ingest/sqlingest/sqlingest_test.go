@@ -0,0 +1,254 @@
+package sqlingest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDatabase records executed statements, failing with errs (in order) first.
+type fakeDatabase struct {
+	mu    sync.Mutex
+	execs []fakeExec
+	errs  []error
+}
+
+func (d *fakeDatabase) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{d}, nil
+}
+
+func (d *fakeDatabase) Driver() driver.Driver {
+	return nil
+}
+
+func (d *fakeDatabase) Execs() []fakeExec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]fakeExec(nil), d.execs...)
+}
+
+type fakeConn struct {
+	db *fakeDatabase
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	exec := fakeExec{query: query}
+	for _, arg := range args {
+		exec.args = append(exec.args, arg.Value)
+	}
+
+	c.db.execs = append(c.db.execs, exec)
+
+	if len(c.db.errs) > 0 {
+		err := c.db.errs[0]
+		c.db.errs = c.db.errs[1:]
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return driver.RowsAffected(int64(len(args))), nil
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestCollector(t *testing.T, db *fakeDatabase, config CollectorConfig) *Collector {
+	t.Helper()
+
+	config.DB = sql.OpenDB(db)
+	if config.Table == "" {
+		config.Table = "events"
+	}
+
+	collector, err := NewCollector(config)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, collector.Close(context.Background()))
+	})
+
+	return collector
+}
+
+func newEvent(id string) event.Event {
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetSource("test")
+	ev.SetType("api-calls")
+	ev.SetSubject("sub")
+	ev.SetTime(time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC))
+
+	return ev
+}
+
+func TestCollector_Batch(t *testing.T) {
+	db := &fakeDatabase{}
+	collector := newTestCollector(t, db, CollectorConfig{
+		BatchSize: 3,
+		Linger:    time.Minute,
+	})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 3; i++ {
+		ev := newEvent(strconv.Itoa(i))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, collector.Receive(context.Background(), ev))
+		}()
+	}
+
+	wg.Wait()
+
+	execs := db.Execs()
+	require.Len(t, execs, 1)
+
+	assert.Equal(t, "INSERT INTO events (id, source, type, subject, time, data) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)", execs[0].query)
+
+	var ids []interface{}
+	for i := 0; i < len(execs[0].args); i += len(DefaultColumns) {
+		ids = append(ids, execs[0].args[i])
+	}
+
+	assert.ElementsMatch(t, []interface{}{"0", "1", "2"}, ids)
+}
+
+func TestCollector_Columns(t *testing.T) {
+	db := &fakeDatabase{}
+	collector := newTestCollector(t, db, CollectorConfig{
+		Table: "openmeter.events",
+		Columns: []Column{
+			{Name: "event_id", Field: FieldID},
+			{Name: "namespace", Field: FieldExtensionPrefix + "namespace"},
+			{Name: "region", Field: FieldExtensionPrefix + "region"},
+			{Name: "data", Field: FieldData},
+			{Name: "ts", Field: FieldTime},
+		},
+		Placeholder: PlaceholderDollar,
+		Linger:      time.Millisecond,
+	})
+
+	ev := newEvent("1")
+	ev.SetExtension("namespace", "acme")
+	require.NoError(t, ev.SetData(event.ApplicationJSON, map[string]int{"tokens": 4}))
+
+	require.NoError(t, collector.Receive(context.Background(), ev))
+
+	execs := db.Execs()
+	require.Len(t, execs, 1)
+
+	assert.Equal(t, "INSERT INTO openmeter.events (event_id, namespace, region, data, ts) VALUES ($1, $2, $3, $4, $5)", execs[0].query)
+	assert.Equal(t, []driver.Value{"1", "acme", nil, `{"tokens":4}`, time.Date(2023, 6, 15, 14, 0, 0, 0, time.UTC)}, execs[0].args)
+}
+
+func TestCollector_Retry(t *testing.T) {
+	errTransient := errors.New("connection reset")
+	isTransient := func(err error) bool {
+		return errors.Is(err, errTransient)
+	}
+
+	t.Run("Transient", func(t *testing.T) {
+		db := &fakeDatabase{errs: []error{errTransient, errTransient}}
+		collector := newTestCollector(t, db, CollectorConfig{
+			Linger:      time.Millisecond,
+			Backoff:     time.Millisecond,
+			IsTransient: isTransient,
+		})
+
+		require.NoError(t, collector.Receive(context.Background(), newEvent("1")))
+		assert.Len(t, db.Execs(), 3)
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		db := &fakeDatabase{errs: []error{errTransient, errTransient, errTransient}}
+		collector := newTestCollector(t, db, CollectorConfig{
+			Linger:      time.Millisecond,
+			Retries:     2,
+			Backoff:     time.Millisecond,
+			IsTransient: isTransient,
+		})
+
+		err := collector.Receive(context.Background(), newEvent("1"))
+		assert.ErrorIs(t, err, errTransient)
+		assert.Len(t, db.Execs(), 3)
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		errConstraint := errors.New("constraint violation")
+
+		db := &fakeDatabase{errs: []error{errConstraint}}
+		collector := newTestCollector(t, db, CollectorConfig{
+			Linger:      time.Millisecond,
+			IsTransient: isTransient,
+		})
+
+		err := collector.Receive(context.Background(), newEvent("1"))
+		assert.ErrorIs(t, err, errConstraint)
+		assert.Len(t, db.Execs(), 1)
+	})
+}
+
+func TestCollector_Closed(t *testing.T) {
+	collector, err := NewCollector(CollectorConfig{DB: sql.OpenDB(&fakeDatabase{}), Table: "events"})
+	require.NoError(t, err)
+
+	require.NoError(t, collector.Close(context.Background()))
+
+	err = collector.Receive(context.Background(), newEvent("1"))
+	assert.ErrorIs(t, err, ingest.ErrCollectorClosed)
+}
+
+func TestNewCollector_Invalid(t *testing.T) {
+	db := sql.OpenDB(&fakeDatabase{})
+
+	tests := []struct {
+		name   string
+		config CollectorConfig
+	}{
+		{name: "Table", config: CollectorConfig{DB: db, Table: "events; DROP TABLE events"}},
+		{name: "Column", config: CollectorConfig{DB: db, Table: "events", Columns: []Column{{Name: "a.id", Field: FieldID}}}},
+		{name: "Field", config: CollectorConfig{DB: db, Table: "events", Columns: []Column{{Name: "id", Field: "identifier"}}}},
+		{name: "Placeholder", config: CollectorConfig{DB: db, Table: "events", Placeholder: ":"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewCollector(test.config)
+			assert.Error(t, err)
+		})
+	}
+}
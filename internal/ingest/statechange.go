@@ -0,0 +1,182 @@
+package ingest
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultStateChangeSize = 10000
+
+// StateChangeCollectorConfig configures a StateChangeCollector.
+type StateChangeCollectorConfig struct {
+	// Collector receives events that change the state of their subject.
+	Collector Collector
+
+	// Size is the maximum number of subjects whose last state is remembered. Defaults to 10000.
+	// The least recently used subjects are forgotten first when the limit is reached.
+	Size int
+
+	// Field is the top level data field compared with the previous event.
+	// When empty, the whole event data is compared.
+	Field string
+
+	// Registerer registers the metric counting events dropped as unchanged (optional).
+	Registerer prometheus.Registerer
+}
+
+// StateChangeCollector forwards gauge-style events to a downstream {Collector} only when they change the state of their subject.
+//
+// The state of a subject is the data (or a data field) of the last event forwarded with the same type and subject.
+// Events whose state equals the previous one are dropped.
+// Events without a comparable state (data that is not JSON or lacks the field) are always forwarded,
+// as are events flagged with FlagAudit.
+type StateChangeCollector struct {
+	collector Collector
+	size      int
+	field     string
+	dropped   prometheus.Counter
+
+	mu      sync.Mutex
+	entries map[stateKey]*list.Element
+	order   *list.List
+}
+
+type stateKey struct {
+	eventType string
+	subject   string
+}
+
+type stateEntry struct {
+	key   stateKey
+	state interface{}
+}
+
+// NewStateChangeCollector returns a new StateChangeCollector.
+func NewStateChangeCollector(config StateChangeCollectorConfig) (*StateChangeCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.Size < 0 {
+		return nil, fmt.Errorf("invalid state size: %d", config.Size)
+	}
+
+	size := config.Size
+	if size == 0 {
+		size = defaultStateChangeSize
+	}
+
+	c := &StateChangeCollector{
+		collector: config.Collector,
+		size:      size,
+		field:     config.Field,
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "unchanged_events_dropped_total",
+			Help:      "Number of events dropped because they did not change the state of their subject.",
+		}),
+		entries: make(map[stateKey]*list.Element),
+		order:   list.New(),
+	}
+
+	if config.Registerer != nil {
+		if err := config.Registerer.Register(c.dropped); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *StateChangeCollector) Receive(ctx context.Context, ev event.Event) error {
+	state, ok := c.state(ev)
+	if !ok {
+		return c.collector.Receive(ctx, ev)
+	}
+
+	key := stateKey{eventType: ev.Type(), subject: ev.Subject()}
+
+	if c.unchanged(key, state) && !FlagsFromContext(ctx).Has(FlagAudit) {
+		c.dropped.Inc()
+
+		return nil
+	}
+
+	if err := c.collector.Receive(ctx, ev); err != nil {
+		return err
+	}
+
+	// The state is only recorded once the event is forwarded, so retried events are not dropped
+	c.store(key, state)
+
+	return nil
+}
+
+// state returns the value of the event compared with the previous event of the subject.
+func (c *StateChangeCollector) state(ev event.Event) (interface{}, bool) {
+	if len(ev.Data()) == 0 {
+		return nil, false
+	}
+
+	if c.field == "" {
+		var state interface{}
+		if err := json.Unmarshal(ev.Data(), &state); err != nil {
+			return nil, false
+		}
+
+		return state, true
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(ev.Data(), &data); err != nil {
+		return nil, false
+	}
+
+	state, ok := data[c.field]
+
+	return state, ok
+}
+
+func (c *StateChangeCollector) unchanged(key stateKey, state interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+
+	c.order.MoveToBack(e)
+
+	return reflect.DeepEqual(e.Value.(*stateEntry).state, state)
+}
+
+func (c *StateChangeCollector) store(key stateKey, state interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*stateEntry).state = state
+		c.order.MoveToBack(e)
+
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		e := c.order.Front()
+
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*stateEntry).key)
+	}
+
+	c.entries[key] = c.order.PushBack(&stateEntry{key: key, state: state})
+}
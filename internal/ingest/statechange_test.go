@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectorFunc func(ctx context.Context, ev event.Event) error
+
+func (f collectorFunc) Receive(ctx context.Context, ev event.Event) error {
+	return f(ctx, ev)
+}
+
+func newGaugeEvent(t *testing.T, id string, subject string, data string) event.Event {
+	t.Helper()
+
+	ev := newEvent(id)
+	ev.SetType("gauge")
+	ev.SetSubject(subject)
+
+	require.NoError(t, ev.SetData(event.ApplicationJSON, []byte(data)))
+
+	return ev
+}
+
+func TestStateChangeCollector(t *testing.T) {
+	var forwarded []string
+
+	collector, err := NewStateChangeCollector(StateChangeCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Registerer: prometheus.NewRegistry(),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for _, ev := range []event.Event{
+		newGaugeEvent(t, "1", "a", `{"value": 1, "unit": "GB"}`),
+		newGaugeEvent(t, "2", "a", `{"unit": "GB", "value": 1}`),
+		newGaugeEvent(t, "3", "b", `{"value": 1, "unit": "GB"}`),
+		newGaugeEvent(t, "4", "a", `{"value": 2, "unit": "GB"}`),
+		newGaugeEvent(t, "5", "a", `{"value": 1, "unit": "GB"}`),
+		newGaugeEvent(t, "6", "a", `{"value": 1, "unit": "GB"}`),
+	} {
+		require.NoError(t, collector.Receive(ctx, ev))
+	}
+
+	assert.Equal(t, []string{"1", "3", "4", "5"}, forwarded)
+	assert.Equal(t, float64(2), testutil.ToFloat64(collector.dropped))
+
+	// Audit events are forwarded even if unchanged
+	require.NoError(t, collector.Receive(ContextWithFlags(ctx, ParseFlags(FlagAudit)), newGaugeEvent(t, "7", "a", `{"value": 1, "unit": "GB"}`)))
+
+	assert.Equal(t, []string{"1", "3", "4", "5", "7"}, forwarded)
+}
+
+func TestStateChangeCollector_Field(t *testing.T) {
+	var forwarded []string
+
+	collector, err := NewStateChangeCollector(StateChangeCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Field: "value",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for _, ev := range []event.Event{
+		newGaugeEvent(t, "1", "a", `{"value": 1, "observedAt": "10:00"}`),
+		newGaugeEvent(t, "2", "a", `{"value": 1, "observedAt": "10:01"}`),
+		newGaugeEvent(t, "3", "a", `{"observedAt": "10:02"}`),
+		newGaugeEvent(t, "4", "a", `{"value": 3, "observedAt": "10:03"}`),
+	} {
+		require.NoError(t, collector.Receive(ctx, ev))
+	}
+
+	assert.Equal(t, []string{"1", "3", "4"}, forwarded)
+}
+
+func TestStateChangeCollector_Eviction(t *testing.T) {
+	var forwarded []string
+
+	collector, err := NewStateChangeCollector(StateChangeCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+		Size: 1,
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	for _, ev := range []event.Event{
+		newGaugeEvent(t, "1", "a", `{"value": 1}`),
+		newGaugeEvent(t, "2", "b", `{"value": 1}`),
+		// The state of subject a has been forgotten
+		newGaugeEvent(t, "3", "a", `{"value": 1}`),
+	} {
+		require.NoError(t, collector.Receive(ctx, ev))
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, forwarded)
+}
+
+func TestStateChangeCollector_ForwardError(t *testing.T) {
+	fail := true
+
+	var forwarded []string
+
+	collector, err := NewStateChangeCollector(StateChangeCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			if fail {
+				return errors.New("unavailable")
+			}
+
+			forwarded = append(forwarded, ev.ID())
+
+			return nil
+		}),
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.Error(t, collector.Receive(ctx, newGaugeEvent(t, "1", "a", `{"value": 1}`)))
+
+	fail = false
+
+	// The retried event must not be dropped as unchanged
+	require.NoError(t, collector.Receive(ctx, newGaugeEvent(t, "1", "a", `{"value": 1}`)))
+
+	assert.Equal(t, []string{"1"}, forwarded)
+}
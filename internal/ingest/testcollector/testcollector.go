@@ -0,0 +1,138 @@
+// Package testcollector provides an in-memory ingest.Collector for tests of packages embedding the ingest handler.
+//
+// It is meant to be imported from tests only: production code should never depend on it.
+package testcollector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openmeterio/openmeter/internal/ingest"
+)
+
+var _ ingest.Collector = (*Collector)(nil)
+
+// Collector records the events it receives.
+//
+// The zero value accepts every event immediately. It is safe for concurrent use.
+type Collector struct {
+	// Err is returned for every event (optional).
+	Err error
+
+	// ErrFunc returns the error of an event (optional). It takes precedence over Err.
+	ErrFunc func(ev event.Event) error
+
+	// Delay simulates the latency of a downstream broker: events are received after the delay,
+	// unless the context of the request is done first.
+	Delay time.Duration
+
+	// Ack is how received events are acknowledged (see ingest.WithAck). Defaults to ingest.AckDelivered.
+	Ack ingest.AckMode
+
+	mu     sync.Mutex
+	events []event.Event
+}
+
+// Receive records the event unless an error is injected for it.
+func (c *Collector) Receive(ctx context.Context, ev event.Event) error {
+	if c.Delay > 0 {
+		timer := time.NewTimer(c.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := c.err(ev); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = append(c.events, ev)
+
+	if c.Ack == ingest.AckEnqueued {
+		ingest.MarkEnqueued(ctx)
+	}
+
+	return nil
+}
+
+func (c *Collector) err(ev event.Event) error {
+	if c.ErrFunc != nil {
+		return c.ErrFunc(ev)
+	}
+
+	return c.Err
+}
+
+// Events returns a copy of the events received so far, in the order they were received.
+func (c *Collector) Events() []event.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := make([]event.Event, 0, len(c.events))
+	for _, ev := range c.events {
+		events = append(events, ev.Clone())
+	}
+
+	return events
+}
+
+// IDs returns the IDs of the events received so far.
+func (c *Collector) IDs() []string {
+	events := c.Events()
+
+	ids := make([]string, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID())
+	}
+
+	return ids
+}
+
+// Len returns the number of events received so far.
+func (c *Collector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.events)
+}
+
+// Reset forgets the events received so far.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events = nil
+}
+
+// AssertReceived asserts that the collector received events with the IDs, in order (and no other events).
+func AssertReceived(t assert.TestingT, c *Collector, ids ...string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	if ids == nil {
+		ids = []string{}
+	}
+
+	return assert.Equal(t, ids, c.IDs(), "received events")
+}
+
+// AssertEventually asserts that the collector receives n events within the timeout (eg. through a buffered collector).
+func AssertEventually(t assert.TestingT, c *Collector, n int, timeout time.Duration) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	return assert.Eventually(t, func() bool { return c.Len() >= n }, timeout, timeout/100, "expected %d events", n)
+}
@@ -0,0 +1,78 @@
+package testcollector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(id string) event.Event {
+	ev := event.New()
+	ev.SetID(id)
+	ev.SetSource("test")
+	ev.SetType("api-calls")
+
+	return ev
+}
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+
+	collector := &Collector{}
+	AssertReceived(t, collector)
+
+	require.NoError(t, collector.Receive(ctx, newEvent("1")))
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+	AssertReceived(t, collector, "1", "2")
+
+	// Events are copied
+	collector.Events()[0].SetID("modified")
+	AssertReceived(t, collector, "1", "2")
+
+	collector.Reset()
+	assert.Equal(t, 0, collector.Len())
+}
+
+func TestCollector_Errors(t *testing.T) {
+	ctx := context.Background()
+	errUnavailable := errors.New("unavailable")
+
+	collector := &Collector{Err: errUnavailable}
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("1")), errUnavailable)
+	AssertReceived(t, collector)
+
+	collector.ErrFunc = func(ev event.Event) error {
+		if ev.ID() == "invalid" {
+			return errUnavailable
+		}
+
+		return nil
+	}
+
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("invalid")), errUnavailable)
+	require.NoError(t, collector.Receive(ctx, newEvent("2")))
+	AssertReceived(t, collector, "2")
+}
+
+func TestCollector_Delay(t *testing.T) {
+	collector := &Collector{Delay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, collector.Receive(ctx, newEvent("1")), context.DeadlineExceeded)
+	AssertReceived(t, collector)
+
+	collector.Delay = 10 * time.Millisecond
+
+	go func() {
+		_ = collector.Receive(context.Background(), newEvent("2"))
+	}()
+
+	AssertEventually(t, collector, 1, time.Second)
+}
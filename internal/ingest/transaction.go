@@ -0,0 +1,21 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+)
+
+// Transactor is implemented by collectors forwarding the events of a batch atomically (eg. in a Kafka transaction).
+//
+// Events received with the context returned by BeginBatch are part of the batch:
+// they are forwarded when the batch is committed, and discarded when it is aborted.
+type Transactor interface {
+	// BeginBatch starts a batch identified by the idempotency key (optional).
+	// It returns ErrBatchCommitted if a batch with the same key has already been committed, so retried batches are forwarded once.
+	//
+	// End must be called exactly once: it commits the batch (commit is true) or aborts it, and returns the outcome of the commit.
+	BeginBatch(ctx context.Context, key string) (batchCtx context.Context, end func(commit bool) error, err error)
+}
+
+// ErrBatchCommitted is returned by transactors when a batch with the same idempotency key has already been committed.
+var ErrBatchCommitted = errors.New("batch already committed")
@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultTypeMetricsMaxTypes = 100
+
+// typeMetricsOther is the type label of events whose type exceeds the cap of distinct types.
+const typeMetricsOther = "other"
+
+// TypeMetricsCollectorConfig configures a TypeMetricsCollector.
+type TypeMetricsCollectorConfig struct {
+	// Collector receives the events.
+	Collector Collector
+
+	// MaxTypes is the maximum number of distinct event types recorded with their own label. Defaults to 100.
+	// Events of other types are recorded as "other".
+	MaxTypes int
+
+	// Registerer registers the metrics (optional).
+	Registerer prometheus.Registerer
+}
+
+// TypeMetricsCollector records the latency and errors of a downstream {Collector} by event type, eg. to track SLOs per event type.
+//
+// The first MaxTypes distinct types are recorded with their own label, bounding the cardinality of the metrics.
+type TypeMetricsCollector struct {
+	collector Collector
+	maxTypes  int
+	latency   *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+
+	mu    sync.Mutex
+	types map[string]struct{}
+}
+
+// NewTypeMetricsCollector returns a new TypeMetricsCollector.
+func NewTypeMetricsCollector(config TypeMetricsCollectorConfig) (*TypeMetricsCollector, error) {
+	if config.Collector == nil {
+		return nil, errors.New("collector is required")
+	}
+
+	if config.MaxTypes < 0 {
+		return nil, errors.New("max types must not be negative")
+	}
+
+	maxTypes := config.MaxTypes
+	if maxTypes == 0 {
+		maxTypes = defaultTypeMetricsMaxTypes
+	}
+
+	c := &TypeMetricsCollector{
+		collector: config.Collector,
+		maxTypes:  maxTypes,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "collector_receive_duration_seconds",
+			Help:      "Time taken by the downstream collector to receive events, by event type.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms - 4s
+		}, []string{"type"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "collector_receive_errors_total",
+			Help:      "Number of events the downstream collector failed to receive, by event type.",
+		}, []string{"type"}),
+		types: make(map[string]struct{}),
+	}
+
+	if config.Registerer != nil {
+		for _, collector := range []prometheus.Collector{c.latency, c.errors} {
+			if err := config.Registerer.Register(collector); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *TypeMetricsCollector) Receive(ctx context.Context, ev event.Event) error {
+	start := time.Now()
+
+	err := c.collector.Receive(ctx, ev)
+
+	label := c.typeLabel(ev.Type())
+
+	c.latency.WithLabelValues(label).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		c.errors.WithLabelValues(label).Inc()
+	}
+
+	return err
+}
+
+// typeLabel returns the label of the event type, "other" once the cap of distinct types is reached.
+func (c *TypeMetricsCollector) typeLabel(eventType string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.types[eventType]; ok {
+		return eventType
+	}
+
+	if len(c.types) >= c.maxTypes {
+		return typeMetricsOther
+	}
+
+	c.types[eventType] = struct{}{}
+
+	return eventType
+}
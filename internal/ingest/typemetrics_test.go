@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeMetricsCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	collector, err := NewTypeMetricsCollector(TypeMetricsCollectorConfig{
+		Collector: collectorFunc(func(_ context.Context, ev event.Event) error {
+			if strings.HasPrefix(ev.ID(), "fail") {
+				return errors.New("unavailable")
+			}
+
+			return nil
+		}),
+		MaxTypes:   2,
+		Registerer: registry,
+	})
+	require.NoError(t, err)
+
+	for _, e := range []struct{ id, eventType string }{
+		{"1", "api-calls"},
+		{"fail-2", "api-calls"},
+		{"3", "storage"},
+		{"fail-4", "storage"},
+		{"fail-5", "storage"},
+		{"6", "compute"},
+		{"fail-7", "network"},
+	} {
+		ev := newEvent(e.id)
+		ev.SetType(e.eventType)
+
+		err := collector.Receive(context.Background(), ev)
+		assert.Equal(t, strings.HasPrefix(e.id, "fail"), err != nil, e.id)
+	}
+
+	// Types over the cap are recorded as other
+	assert.Equal(t, 3, testutil.CollectAndCount(collector.latency))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.errors.WithLabelValues("api-calls")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(collector.errors.WithLabelValues("storage")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.errors.WithLabelValues("other")))
+
+	count, err := testutil.GatherAndCount(registry, "openmeter_ingest_collector_receive_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestNewTypeMetricsCollector(t *testing.T) {
+	_, err := NewTypeMetricsCollector(TypeMetricsCollectorConfig{})
+	assert.Error(t, err)
+
+	_, err = NewTypeMetricsCollector(TypeMetricsCollectorConfig{Collector: failingCollector{}, MaxTypes: -1})
+	assert.Error(t, err)
+}
@@ -19,6 +19,8 @@ import (
 func init() {
 	// See https://github.com/getkin/kin-openapi/issues/640
 	openapi3filter.RegisterBodyDecoder("application/cloudevents+json", jsonBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/cloudevents-batch+json", jsonBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", ndjsonBodyDecoder)
 }
 
 func jsonBodyDecoder(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (interface{}, error) {
@@ -29,10 +31,36 @@ func jsonBodyDecoder(body io.Reader, header http.Header, schema *openapi3.Schema
 	return value, nil
 }
 
+func ndjsonBodyDecoder(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (interface{}, error) {
+	values := []interface{}{}
+
+	decoder := json.NewDecoder(body)
+	for {
+		var value interface{}
+
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
 type Config struct {
 	StreamingConnector streaming.Connector
 	IngestHandler      http.Handler
 	Meters             []*models.Meter
+
+	// IngestBatchHandler receives batches at /api/v1alpha1/events/batch (optional)
+	IngestBatchHandler http.Handler
+
+	// IngestTemplateHandlers receive bare data payloads at /api/v1alpha1/events/{route} (see httpingest.TemplateHandler)
+	IngestTemplateHandlers map[string]http.Handler
 }
 
 type Router struct {
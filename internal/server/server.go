@@ -2,18 +2,25 @@ package server
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
 
 	oapimiddleware "github.com/deepmap/oapi-codegen/pkg/chi-middleware"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"golang.org/x/exp/slog"
 
 	"github.com/openmeterio/openmeter/api"
+	"github.com/openmeterio/openmeter/internal/ingest/httpingest"
 	"github.com/openmeterio/openmeter/internal/server/router"
 )
 
+// ingestBatchPath is the path of the separate batch ingest route.
+const ingestBatchPath = "/api/v1alpha1/events/batch"
+
 type Server struct {
 	chi.Router
 }
@@ -41,6 +48,10 @@ func NewServer(config *Config) (*Server, error) {
 	// that server names match. We don't know how this thing will be run.
 	swagger.Servers = nil
 
+	// oapi-codegen supports a single JSON request body per operation,
+	// so batch content types of the ingest endpoint are added to the spec here.
+	addIngestBatchContentTypes(swagger)
+
 	impl, err := router.NewRouter(config.RouterConfig)
 	if err != nil {
 		slog.Error("failed to create API", "error", err)
@@ -67,14 +78,76 @@ func NewServer(config *Config) (*Server, error) {
 	_ = api.HandlerWithOptions(impl, api.ChiServerOptions{
 		BaseRouter: r,
 		Middlewares: []api.MiddlewareFunc{
-			oapimiddleware.OapiRequestValidator(swagger),
+			requestValidator(swagger),
 		},
 		ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
 			_ = render.Render(w, r, api.ErrInternalServerError(err))
 		},
 	})
 
+	// Capability probes are not part of the OpenAPI spec
+	if config.RouterConfig.IngestHandler != nil {
+		r.Method(http.MethodHead, "/api/v1alpha1/events", config.RouterConfig.IngestHandler)
+	}
+
+	// Batches are ingested at a separate route when configured, it is not part of the OpenAPI spec
+	if config.RouterConfig.IngestBatchHandler != nil {
+		r.Method(http.MethodHead, ingestBatchPath, config.RouterConfig.IngestBatchHandler)
+		r.Method(http.MethodPost, ingestBatchPath, config.RouterConfig.IngestBatchHandler)
+	}
+
+	// Template routes ingest bare data payloads, they are not part of the OpenAPI spec
+	for route, handler := range config.RouterConfig.IngestTemplateHandlers {
+		r.Method(http.MethodPost, "/api/v1alpha1/events/"+route, handler)
+	}
+
 	return &Server{
 		Router: r,
 	}, nil
 }
+
+// requestValidator validates requests against the OpenAPI schema.
+// Bodies the validator cannot decode (compressed, Avro encoded or multipart events) are only validated by the ingest handler.
+func requestValidator(swagger *openapi3.T) api.MiddlewareFunc {
+	validate := oapimiddleware.OapiRequestValidator(swagger)
+	validateWithoutBody := oapimiddleware.OapiRequestValidatorWithOptions(swagger, &oapimiddleware.Options{
+		Options: openapi3filter.Options{ExcludeRequestBody: true},
+	})
+
+	return func(next http.Handler) http.Handler {
+		withBody := validate(next)
+		withoutBody := validateWithoutBody(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+			encoding := r.Header.Get("Content-Encoding")
+			if (encoding != "" && encoding != "identity") || contentType == httpingest.ContentTypeAvro || contentType == httpingest.ContentTypeMultipart {
+				withoutBody.ServeHTTP(w, r)
+
+				return
+			}
+
+			withBody.ServeHTTP(w, r)
+		})
+	}
+}
+
+func addIngestBatchContentTypes(swagger *openapi3.T) {
+	path := swagger.Paths.Find("/api/v1alpha1/events")
+	if path == nil || path.Post == nil || path.Post.RequestBody == nil || path.Post.RequestBody.Value == nil {
+		return
+	}
+
+	content := path.Post.RequestBody.Value.Content
+
+	single := content.Get("application/cloudevents+json")
+	if single == nil || single.Schema == nil {
+		return
+	}
+
+	batch := openapi3.NewMediaType().WithSchema(openapi3.NewArraySchema().WithItems(single.Schema.Value))
+
+	content[httpingest.ContentTypeBatch] = batch
+	content[httpingest.ContentTypeNDJSON] = batch
+}
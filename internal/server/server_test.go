@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/openmeterio/openmeter/internal/ingest/httpingest"
+	"github.com/openmeterio/openmeter/internal/ingest/testcollector"
+	"github.com/openmeterio/openmeter/internal/server/router"
+)
+
+func TestServer_IngestMultipart(t *testing.T) {
+	collector := &testcollector.Collector{}
+
+	server, err := NewServer(&Config{
+		RouterConfig: router.Config{
+			IngestHandler: httpingest.Handler{
+				Collector:     collector,
+				MultipartPart: "events",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var body bytes.Buffer
+
+	writer := multipart.NewWriter(&body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="events"`)
+	header.Set("Content-Type", httpingest.ContentTypeSingle)
+
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+
+	_, err = part.Write([]byte(`{"specversion":"1.0","id":"1","source":"test","type":"api-calls","subject":"sub"}`))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1alpha1/events", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	testcollector.AssertReceived(t, collector, "1")
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
 	"runtime"
 	"syscall"
 	"time"
@@ -17,8 +19,10 @@ import (
 	"github.com/confluentinc/confluent-kafka-go/v2/schemaregistry"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	_ "github.com/lib/pq"
 	"github.com/lmittmann/tint"
 	"github.com/oklog/run"
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -32,13 +36,18 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 
+	"github.com/openmeterio/openmeter/internal/ingest"
+	"github.com/openmeterio/openmeter/internal/ingest/badgeringest"
 	"github.com/openmeterio/openmeter/internal/ingest/httpingest"
 	"github.com/openmeterio/openmeter/internal/ingest/kafkaingest"
+	"github.com/openmeterio/openmeter/internal/ingest/otelingest"
+	"github.com/openmeterio/openmeter/internal/ingest/sqlingest"
 	"github.com/openmeterio/openmeter/internal/server"
 	"github.com/openmeterio/openmeter/internal/server/router"
 	"github.com/openmeterio/openmeter/internal/streaming/kafka_connector"
 	"github.com/openmeterio/openmeter/pkg/gosundheit"
 	"github.com/openmeterio/openmeter/pkg/gosundheit/ksqldbcheck"
+	"github.com/openmeterio/openmeter/pkg/gosundheit/warmupcheck"
 )
 
 func main() {
@@ -139,6 +148,18 @@ func main() {
 	// Configure health checker
 	healthChecker := health.New(health.WithCheckListeners(gosundheit.NewLogger(logger.With(slog.String("component", "healthcheck")))))
 	{
+		// Report not ready until the warm-up period elapses (and every other check passes)
+		if config.Telemetry.WarmUpPeriod > 0 {
+			err := healthChecker.RegisterCheck(
+				warmupcheck.NewCheck("warmup", config.Telemetry.WarmUpPeriod),
+				health.ExecutionPeriod(time.Second),
+			)
+			if err != nil {
+				logger.Error("registering warm-up health check", "error", err)
+				os.Exit(1)
+			}
+		}
+
 		handler := healthhttp.HandleHealthJSON(healthChecker)
 		telemetryRouter.Handle("/healthz", handler)
 
@@ -230,14 +251,592 @@ func main() {
 
 	slog.Info("kafka connector successfully initialized")
 
+	var valueRange *httpingest.ValueRangeValidator
+	if config.Ingest.ValueRange != nil {
+		valueRange, err = httpingest.NewValueRangeValidator(*config.Ingest.ValueRange)
+		if err != nil {
+			logger.Error("init value range validator", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var schemaVersions *httpingest.SchemaVersionValidator
+	if config.Ingest.SchemaVersions != nil {
+		schemaVersions, err = config.Ingest.SchemaVersions.validator()
+		if err != nil {
+			logger.Error("init schema version validator", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var receipts *httpingest.ReceiptSigner
+	if config.Ingest.Receipts != nil {
+		receipts, err = config.Ingest.Receipts.signer()
+		if err != nil {
+			logger.Error("init receipt signer", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var cueValidator *httpingest.CUEValidator
+	if config.Ingest.CUE != nil {
+		cueValidator, err = config.Ingest.CUE.validator()
+		if err != nil {
+			logger.Error("init cue validator", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sampler httpingest.Sampler
+	if config.Ingest.Sampling != nil {
+		sampler, err = httpingest.NewValueSampler(*config.Ingest.Sampling)
+		if err != nil {
+			logger.Error("init value sampler", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var decodeLimiter *httpingest.DecodeLimiter
+	if config.Ingest.DecodeLimit != nil {
+		decodeLimiter, err = httpingest.NewDecodeLimiter(*config.Ingest.DecodeLimit)
+		if err != nil {
+			logger.Error("init decode limiter", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var workers *httpingest.WorkerPool
+	if config.Ingest.Workers != nil {
+		workers, err = httpingest.NewWorkerPool(*config.Ingest.Workers)
+		if err != nil {
+			logger.Error("init worker pool", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var rateLimiter *httpingest.RateLimiter
+	if config.Ingest.RateLimit != nil {
+		rateLimiter, err = httpingest.NewRateLimiter(*config.Ingest.RateLimit)
+		if err != nil {
+			logger.Error("init rate limiter", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var dropFilter *httpingest.DropFilter
+	if config.Ingest.DropFilter != nil {
+		dropFilterConfig := *config.Ingest.DropFilter
+		dropFilterConfig.Registerer = prometheusclient.DefaultRegisterer
+
+		dropFilter, err = httpingest.NewDropFilter(dropFilterConfig)
+		if err != nil {
+			logger.Error("init drop filter", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sourceLimit *httpingest.SourceLimiter
+	if config.Ingest.SourceLimit != nil {
+		sourceLimitConfig := *config.Ingest.SourceLimit
+		sourceLimitConfig.Registerer = prometheusclient.DefaultRegisterer
+
+		sourceLimit, err = httpingest.NewSourceLimiter(sourceLimitConfig)
+		if err != nil {
+			logger.Error("init source limit", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var meterExtractor *httpingest.MeterExtractor
+	if config.Ingest.MeterExtractor != nil {
+		meterExtractor, err = httpingest.NewMeterExtractor(*config.Ingest.MeterExtractor)
+		if err != nil {
+			logger.Error("init meter extractor", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sourceTemplate *httpingest.SourceTemplate
+	if config.Ingest.SourceTemplate != nil {
+		sourceTemplate, err = httpingest.NewSourceTemplate(*config.Ingest.SourceTemplate)
+		if err != nil {
+			logger.Error("init source template", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var identity httpingest.IdentityFunc
+	if config.Ingest.IdentityHeader != "" {
+		identity = httpingest.HeaderIdentity(config.Ingest.IdentityHeader)
+	}
+
+	var authorizer httpingest.Authorizer
+	if config.Ingest.OPA != nil {
+		authorizer, err = httpingest.NewOPAAuthorizer(*config.Ingest.OPA)
+		if err != nil {
+			logger.Error("init opa authorizer", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Per-subject features share a single limit of tracked subjects when configured
+	var subjects *httpingest.SubjectTracker
+	if config.Ingest.MaxTrackedSubjects > 0 {
+		subjects, err = httpingest.NewSubjectTracker(httpingest.SubjectTrackerConfig{
+			Size:       config.Ingest.MaxTrackedSubjects,
+			Registerer: prometheusclient.DefaultRegisterer,
+		})
+		if err != nil {
+			logger.Error("init subject tracker", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sequences *httpingest.SequenceTracker
+	if config.Ingest.Sequences != nil {
+		sequencesConfig := *config.Ingest.Sequences
+		sequencesConfig.Subjects = subjects
+
+		sequences, err = httpingest.NewSequenceTracker(sequencesConfig)
+		if err != nil {
+			logger.Error("init sequence tracker", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var subjectRates *httpingest.SubjectRateTracker
+	if config.Ingest.SubjectRates != nil {
+		subjectRatesConfig := *config.Ingest.SubjectRates
+		subjectRatesConfig.Subjects = subjects
+
+		subjectRates, err = httpingest.NewSubjectRateTracker(subjectRatesConfig)
+		if err != nil {
+			logger.Error("init subject rate tracker", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var dedup *ingest.DedupFilter
+	if config.Ingest.Dedup != nil {
+		var deduplicator ingest.Deduplicator
+
+		switch config.Ingest.Dedup.Backend {
+		case dedupBackendPostgres:
+			db, err := sql.Open("postgres", config.Ingest.Dedup.Postgres.DSN)
+			if err != nil {
+				logger.Error("init ingest dedup database", "error", err)
+				os.Exit(1)
+			}
+			defer db.Close()
+
+			sqlDeduplicator, err := sqlingest.NewDeduplicator(sqlingest.DeduplicatorConfig{
+				DB:              db,
+				Table:           config.Ingest.Dedup.Postgres.Table,
+				Placeholder:     sqlingest.PlaceholderDollar,
+				TTL:             config.Ingest.Dedup.TTL,
+				CleanupInterval: config.Ingest.Dedup.Postgres.CleanupInterval,
+				Logger:          logger,
+			})
+			if err != nil {
+				logger.Error("init ingest deduplicator", "error", err)
+				os.Exit(1)
+			}
+			defer sqlDeduplicator.Close(context.Background())
+
+			deduplicator = sqlDeduplicator
+
+		default:
+			deduplicator, err = ingest.NewMemoryDeduplicator(ingest.MemoryDeduplicatorConfig{
+				TTL:  config.Ingest.Dedup.TTL,
+				Size: config.Ingest.Dedup.Size,
+			})
+			if err != nil {
+				logger.Error("init ingest deduplicator", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		dedup, err = ingest.NewDedupFilter(ingest.DedupFilterConfig{
+			Deduplicator: deduplicator,
+			TimeBucket:   config.Ingest.Dedup.TimeBucket,
+			TypeTTLs:     config.Ingest.Dedup.TypeTTLs,
+		})
+		if err != nil {
+			logger.Error("init ingest dedup", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sourceSequences *httpingest.SourceSequencer
+	if config.Ingest.SourceSequences != nil {
+		sourceSequences, err = config.Ingest.SourceSequences.sequencer()
+		if err != nil {
+			logger.Error("init source sequencer", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Maintenance mode can be toggled at runtime even when the server starts without it
+	maintenance := &httpingest.Maintenance{}
+	if config.Ingest.Maintenance != nil {
+		maintenance.Start(config.Ingest.Maintenance.window())
+	}
+
+	lastError := &httpingest.LastErrorTracker{
+		ClearOnSuccess: config.Ingest.ClearLastError,
+	}
+
+	var avroDecoder *httpingest.AvroDecoder
+	if config.Ingest.Avro != nil {
+		avroDecoder, err = httpingest.NewAvroDecoder(httpingest.AvroDecoderConfig{
+			Registry: httpingest.SchemaRegistryAvroSchemas{
+				Client:  schemaRegistry,
+				Subject: config.Ingest.Avro.Subject,
+			},
+		})
+		if err != nil {
+			logger.Error("init avro decoder", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var geo *httpingest.GeoEnricher
+	if config.Ingest.Geo != nil {
+		resolver, err := httpingest.NewMaxMindGeoResolver(config.Ingest.Geo.Database)
+		if err != nil {
+			logger.Error("init geo resolver", "error", err)
+			os.Exit(1)
+		}
+
+		geo, err = httpingest.NewGeoEnricher(httpingest.GeoEnricherConfig{
+			Resolver: resolver,
+			Fields:   config.Ingest.Geo.Fields,
+		})
+		if err != nil {
+			logger.Error("init geo enricher", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var sourcePattern *regexp.Regexp
+	if config.Ingest.SourcePattern != "" {
+		sourcePattern, err = regexp.Compile(config.Ingest.SourcePattern)
+		if err != nil {
+			logger.Error("init source pattern", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var idFormat *httpingest.IDFormat
+	if config.Ingest.IDFormat != "" {
+		idFormat, err = httpingest.ParseIDFormat(config.Ingest.IDFormat)
+		if err != nil {
+			logger.Error("init id format", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var ingestMetrics httpingest.MultiMetricsRecorder
+	for _, backend := range config.Ingest.MetricsBackends {
+		var recorder httpingest.MetricsRecorder
+
+		switch backend {
+		case metricsBackendPrometheus:
+			recorder, err = httpingest.NewMetrics(prometheusclient.DefaultRegisterer)
+		case metricsBackendOpenTelemetry:
+			recorder, err = httpingest.NewOTelMetrics(otel.Meter("github.com/openmeterio/openmeter/internal/ingest/httpingest"))
+		}
+		if err != nil {
+			logger.Error("init ingest metrics", "error", err)
+			os.Exit(1)
+		}
+
+		ingestMetrics = append(ingestMetrics, recorder)
+	}
+
+	var duplicateRequests *httpingest.DuplicateRequestDetector
+	if config.Ingest.DuplicateRequests != nil {
+		duplicateRequests, err = httpingest.NewDuplicateRequestDetector(*config.Ingest.DuplicateRequests)
+		if err != nil {
+			logger.Error("init duplicate request detector", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var batchCoalescer *httpingest.BatchCoalescer
+	if config.Ingest.CoalesceBatches {
+		batchCoalescer = &httpingest.BatchCoalescer{}
+	}
+
+	var sinkCollector ingest.Collector = collector
+	if config.Ingest.OTel != nil {
+		sinkCollector = otelingest.Collector{
+			Endpoint:           config.Ingest.OTel.Endpoint,
+			Headers:            config.Ingest.OTel.Headers,
+			ResourceAttributes: config.Ingest.OTel.Attributes(),
+			Client:             &http.Client{Timeout: config.Ingest.OTel.Timeout},
+		}
+	}
+
+	ingestCollector := sinkCollector
+	if config.Ingest.Kafka.TransactionalID != "" {
+		transactionalCollector, err := kafkaingest.NewTransactionalCollector(kafkaingest.TransactionalCollectorConfig{
+			Producer: producer,
+			Topic:    topic,
+			Schema:   schema,
+			Timeout:  config.Ingest.Kafka.TransactionTimeout,
+			Logger:   logger,
+		})
+		if err != nil {
+			logger.Error("init transactional collector", "error", err)
+			os.Exit(1)
+		}
+
+		ingestCollector = transactionalCollector
+	}
+
+	if config.Ingest.Spill != nil {
+		ingestCollector, err = ingest.NewSpillCollector(ingest.SpillCollectorConfig{
+			Collector:  sinkCollector,
+			Directory:  config.Ingest.Spill.Directory,
+			MaxSize:    config.Ingest.Spill.MaxSize,
+			Logger:     logger,
+			Registerer: prometheusclient.DefaultRegisterer,
+		})
+		if err != nil {
+			logger.Error("init spill collector", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.Ingest.Queue != nil {
+		queueMetrics, err := ingest.NewQueueMetrics(prometheusclient.DefaultRegisterer)
+		if err != nil {
+			logger.Error("init ingest queue metrics", "error", err)
+			os.Exit(1)
+		}
+
+		// Queued events are deleted once delivered, the producer queue does not confirm delivery
+		deliveringCollector := collector
+		deliveringCollector.ConfirmDelivery = true
+
+		queue, err := badgeringest.NewCollector(badgeringest.CollectorConfig{
+			Collector:  deliveringCollector,
+			Directory:  config.Ingest.Queue.Directory,
+			MaxSize:    config.Ingest.Queue.MaxSize,
+			SyncWrites: config.Ingest.Queue.SyncWrites,
+			Logger:     logger,
+			Name:       "queue",
+			Metrics:    queueMetrics,
+		})
+		if err != nil {
+			logger.Error("init ingest queue", "error", err)
+			os.Exit(1)
+		}
+		defer queue.Close(context.Background())
+
+		ingestCollector = queue
+	}
+
+	if config.Ingest.Aggregation != nil {
+		aggregator, err := httpingest.NewCounterAggregator(httpingest.CounterAggregatorConfig{
+			Collector:     ingestCollector,
+			Meters:        config.Meters,
+			Aggregate:     config.Ingest.Aggregation.Meters,
+			Window:        config.Ingest.Aggregation.Window,
+			FlushDelay:    config.Ingest.Aggregation.FlushDelay,
+			MaxRollups:    config.Ingest.Aggregation.MaxRollups,
+			KeyExtensions: config.Ingest.Aggregation.KeyExtensions,
+			LogMaskPolicy: config.Ingest.LogMask,
+			Logger:        logger,
+		})
+		if err != nil {
+			logger.Error("init ingest aggregation", "error", err)
+			os.Exit(1)
+		}
+		defer aggregator.Close(context.Background())
+
+		ingestCollector = aggregator
+	}
+
+	// Events are audited as received by the handler, before they are aggregated
+	handlerCollector := ingestCollector
+	if config.Ingest.Audit != nil {
+		auditFile, err := os.OpenFile(config.Ingest.Audit.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			logger.Error("init ingest audit log", "error", err)
+			os.Exit(1)
+		}
+		defer auditFile.Close()
+
+		handlerCollector, err = ingest.NewAuditCollector(ingest.AuditCollectorConfig{
+			Collector:    ingestCollector,
+			Sink:         ingest.NewWriterAuditSink(auditFile),
+			Required:     config.Ingest.Audit.Required,
+			AfterForward: config.Ingest.Audit.AfterForward,
+			Flag:         config.Ingest.Audit.Flag,
+			Tenant: func(ctx context.Context) string {
+				namespace, _ := ingest.NamespaceFromContext(ctx)
+
+				return namespace
+			},
+			Logger: logger,
+		})
+		if err != nil {
+			logger.Error("init ingest audit", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var drainer *httpingest.Drainer
+	if config.Ingest.Drain != nil {
+		drainer, err = httpingest.NewDrainer(httpingest.DrainerConfig{
+			Timeout:       config.Ingest.Drain.Timeout,
+			LogMaskPolicy: config.Ingest.LogMask,
+			Logger:        logger,
+			Registerer:    prometheusclient.DefaultRegisterer,
+		})
+		if err != nil {
+			logger.Error("init ingest drainer", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	ingestHandler := httpingest.Handler{
+		Endpoint:                "events",
+		Collector:               handlerCollector,
+		Logger:                  logger,
+		LogMaskPolicy:           config.Ingest.LogMask,
+		ReservedExtensions:      config.Ingest.ReservedExtensions,
+		ReservedExtensionPolicy: config.Ingest.ReservedExtensionPolicy,
+		SourceDefaults:          config.sourceDefaults(),
+		ExtensionDefaults:       config.Ingest.ExtensionDefaults,
+		ValueRange:              valueRange,
+		CUE:                     cueValidator,
+		SchemaVersions:          schemaVersions,
+		DuplicateRequests:       duplicateRequests,
+		BatchCoalescer:          batchCoalescer,
+		Receipts:                receipts,
+		Metrics:                 ingestMetrics,
+		Dedup:                   dedup,
+		RegenerateIDs:           config.Ingest.Dedup != nil && config.Ingest.Dedup.RegenerateIDs,
+		Sampler:                 sampler,
+		OrderSubjectEvents:      config.Ingest.OrderSubjectEvents,
+		RequestID:               config.Ingest.RequestID,
+		DecodeLimiter:           decodeLimiter,
+		Workers:                 workers,
+		MaxRequestDateSkew:      config.Ingest.MaxRequestDateSkew,
+		Flags:                   config.Ingest.Flags,
+		RateLimiter:             rateLimiter,
+		SourceLimit:             sourceLimit,
+		DropFilter:              dropFilter,
+		MeterExtractor:          meterExtractor,
+		SingleNamespaceBatch:    config.Ingest.SingleNamespaceBatch,
+		ClockDrift:              config.Ingest.ClockDrift,
+		TimePrecision:           config.Ingest.TimePrecision,
+		SourcePattern:           sourcePattern,
+		IDFormat:                idFormat,
+		MaxBatchSize:            config.Ingest.MaxBatchSize,
+		BatchBudget:             config.Ingest.BatchBudget,
+		StreamWindow:            config.Ingest.StreamWindow,
+		MaxExtensions:           config.Ingest.MaxExtensions,
+		StrictExtensions:        config.Ingest.StrictExtensions,
+		AllowedExtensions:       config.Ingest.AllowedExtensions,
+		MaxSubjectLength:        config.Ingest.MaxSubjectLength,
+		SubjectLag:              config.Ingest.SubjectLag,
+		ValidateJSONData:        config.Ingest.ValidateJSONData,
+		ValidateDataEncoding:    config.Ingest.ValidateDataEncoding,
+		ValidateUTF8:            config.Ingest.ValidateUTF8,
+		RejectAmbiguousData:     config.Ingest.RejectAmbiguousData,
+		TrustedSourceHeader:     config.Ingest.TrustedSourceHeader,
+		SourceTemplate:          sourceTemplate,
+		Identity:                identity,
+		Authorizer:              authorizer,
+		ContentHash:             config.Ingest.ContentHash,
+		Sequences:               sequences,
+		MaxBodySize:             config.Ingest.MaxBodySize,
+		AcceptedStatus:          config.Ingest.AcceptedStatus,
+		StrictSingle:            config.Ingest.StrictSingle,
+		StampReceivedAt:         config.Ingest.StampReceivedAt,
+		ProblemBatchErrors:      config.Ingest.ProblemBatchErrors,
+		MultipartPart:           config.Ingest.MultipartPart,
+		DryRun:                  config.Ingest.DryRun,
+		Drain:                   drainer,
+		Maintenance:             maintenance,
+		LastError:               lastError,
+		SubjectRates:            subjectRates,
+		SourceSequences:         sourceSequences,
+		Avro:                    avroDecoder,
+		Geo:                     geo,
+	}
+
+	var ingestBatchHandler http.Handler
+	if config.Ingest.SeparateBatchRoute {
+		batchHandler := ingestHandler
+		batchHandler.Endpoint = "events/batch"
+		batchHandler.ContentTypes = []string{httpingest.ContentTypeBatch, httpingest.ContentTypeNDJSON}
+
+		ingestHandler.ContentTypes = []string{httpingest.ContentTypeSingle}
+		if avroDecoder != nil {
+			ingestHandler.ContentTypes = append(ingestHandler.ContentTypes, httpingest.ContentTypeAvro)
+		}
+
+		ingestBatchHandler = batchHandler
+	}
+
+	// Admin endpoints are served on the telemetry address only, to clients with the admin token
+	if config.Ingest.AdminToken != "" {
+		authenticate, err := httpingest.BearerTokenAuthenticator(config.Ingest.AdminToken)
+		if err != nil {
+			logger.Error("init ingest admin authentication", "error", err)
+			os.Exit(1)
+		}
+
+		admin := func(handler http.Handler) http.Handler {
+			chain, err := httpingest.NewChain(handler, httpingest.ChainConfig{Authenticate: authenticate})
+			if err != nil {
+				logger.Error("init ingest admin endpoint", "error", err)
+				os.Exit(1)
+			}
+
+			return chain
+		}
+
+		telemetryRouter.Method(http.MethodPost, "/ingest/flush", admin(httpingest.FlushHandler{
+			Collector: ingestCollector,
+			Logger:    logger,
+		}))
+
+		telemetryRouter.Handle("/ingest/maintenance", admin(httpingest.MaintenanceHandler{
+			Maintenance: maintenance,
+			Logger:      logger,
+		}))
+
+		telemetryRouter.Method(http.MethodGet, "/ingest/lasterror", admin(httpingest.LastErrorHandler{
+			Tracker: lastError,
+		}))
+	} else {
+		logger.Info("ingest admin endpoints disabled: no admin token configured")
+	}
+
+	ingestTemplateHandlers := make(map[string]http.Handler, len(config.Ingest.Templates))
+	for _, t := range config.Ingest.Templates {
+		templateHandler := ingestHandler
+		templateHandler.Endpoint = "events/" + t.Route
+
+		ingestTemplateHandlers[t.Route] = httpingest.TemplateHandler{
+			Handler:  templateHandler,
+			Template: t.EventTemplate(),
+		}
+	}
+
 	s, err := server.NewServer(&server.Config{
 		RouterConfig: router.Config{
-			StreamingConnector: connector,
-			IngestHandler: httpingest.Handler{
-				Collector: collector,
-				Logger:    logger,
-			},
-			Meters: config.Meters,
+			StreamingConnector:     connector,
+			IngestHandler:          ingestHandler,
+			IngestBatchHandler:     ingestBatchHandler,
+			IngestTemplateHandlers: ingestTemplateHandlers,
+			Meters:                 config.Meters,
 		},
 		RouterHook: func(r chi.Router) {
 			r.Use(func(h http.Handler) http.Handler {
@@ -310,7 +909,12 @@ func main() {
 
 		group.Add(
 			func() error { return server.ListenAndServe() },
-			func(err error) { _ = server.Shutdown(context.Background()) }, // TODO: context deadline
+			func(err error) {
+				// Rejects new ingest requests while the events in flight are forwarded
+				_, _ = ingestHandler.Shutdown(context.Background())
+
+				_ = server.Shutdown(context.Background()) // TODO: context deadline
+			},
 		)
 	}
 
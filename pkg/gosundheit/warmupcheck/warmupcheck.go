@@ -0,0 +1,46 @@
+package warmupcheck
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gosundheit "github.com/AppsFlyer/go-sundheit"
+)
+
+type check struct {
+	name    string
+	readyAt time.Time
+	now     func() time.Time
+}
+
+// NewCheck creates a new warm-up check.
+//
+// The check fails until the warm-up period elapses after its creation,
+// which keeps readiness probes failing while downstream connections are warming up.
+func NewCheck(name string, period time.Duration) gosundheit.Check {
+	return newCheck(name, period, time.Now)
+}
+
+func newCheck(name string, period time.Duration, now func() time.Time) check {
+	return check{
+		name:    name,
+		readyAt: now().Add(period),
+		now:     now,
+	}
+}
+
+func (c check) Name() string {
+	return c.name
+}
+
+func (c check) Execute(ctx context.Context) (details any, err error) {
+	remaining := c.readyAt.Sub(c.now())
+	if remaining > 0 {
+		return map[string]string{
+			"remaining": remaining.Round(time.Second).String(),
+		}, fmt.Errorf("warming up: %s remaining", remaining.Round(time.Second))
+	}
+
+	return details, nil
+}
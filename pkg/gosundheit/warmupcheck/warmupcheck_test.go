@@ -0,0 +1,23 @@
+package warmupcheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2023, 06, 15, 14, 33, 00, 00, time.UTC)
+
+	c := newCheck("warmup", 30*time.Second, func() time.Time { return now })
+
+	_, err := c.Execute(context.Background())
+	assert.EqualError(t, err, "warming up: 30s remaining")
+
+	now = now.Add(30 * time.Second)
+
+	_, err = c.Execute(context.Background())
+	assert.NoError(t, err)
+}